@@ -0,0 +1,115 @@
+package main
+
+// AdapterCapabilities describes optional features of a chat platform.
+type AdapterCapabilities struct {
+	// EditReply reports whether sent replies can be edited.
+	EditReply bool
+}
+
+// ReplyOptions are delivery hints for a reply.
+// Adapters ignore options their platform does not support.
+type ReplyOptions struct {
+	DisableNotification bool
+	DisableLinkPreview  bool
+
+	// Send a standalone message instead of a reply
+	Standalone bool
+
+	// Hide the text behind a spoiler
+	Spoiler bool
+	// Wrap the text in an expandable blockquote
+	Blockquote bool
+
+	// Optional. Appended to the text on a separate line, not wrapped
+	Footer string
+
+	// Optional. Inline buttons attached to the reply
+	Buttons []ReplyButton
+}
+
+// withFooter returns text followed by the footer of o, if any.
+func (o ReplyOptions) withFooter(text string) string {
+	if o.Footer == "" {
+		return text
+	}
+	return text + "\n\n" + o.Footer
+}
+
+// ReplyButton is an inline button of a reply.
+type ReplyButton struct {
+	Text string
+
+	// Returned in the Callback when the button is pressed
+	Data string
+}
+
+// Callback is a press of a reply button.
+type Callback struct {
+	Platform string
+	ChatID   int64
+	// The reply the button is attached to
+	MessageID int64
+	UserID    int64
+	Data      string
+
+	// Optional. IETF language tag of the user's client
+	LanguageCode string
+
+	// Acknowledges the press, showing text to the user if not empty
+	Answer func(text string) error
+}
+
+// CallbackAdapter is implemented by adapters supporting reply buttons.
+type CallbackAdapter interface {
+	// ReceiveCallbacks returns the channel of button presses.
+	// The same channel is returned on every call.
+	ReceiveCallbacks() <-chan *Callback
+}
+
+// NotifyAdapter is implemented by adapters able to send messages to a chat
+// unprompted, e.g. reports to an admin chat.
+type NotifyAdapter interface {
+	// SendMessage sends text to the chat.
+	SendMessage(chatId int64, text string, opts ReplyOptions) (*SentReply, error)
+}
+
+// ResumeAdapter is implemented by adapters whose messages can be handled
+// after a restart, e.g. messages of batches persisted in the state.
+type ResumeAdapter interface {
+	// ParseRaw restores Message.Raw from its JSON encoding.
+	ParseRaw(data []byte) (any, error)
+}
+
+// SentReply identifies a reply sent by an adapter.
+type SentReply struct {
+	ChatID    int64
+	MessageID int64
+}
+
+// ChatAdapter abstracts a chat platform, so the translation pipeline
+// does not depend on any platform specific API.
+type ChatAdapter interface {
+	// Name returns the platform name.
+	Name() string
+
+	// ReceiveMessages returns the channel of incoming messages.
+	// The same channel is returned on every call.
+	ReceiveMessages() <-chan *Message
+
+	// IsAllowed reports whether msg comes from an authorized source.
+	IsAllowed(msg *Message) bool
+
+	// Reload applies the reloadable part of the bot config.
+	Reload(conf BotConfig)
+
+	// Reply sends text as a reply to msg.
+	Reply(msg *Message, text string, opts ReplyOptions) (*SentReply, error)
+
+	// EditReply replaces the text of a sent reply.
+	EditReply(reply *SentReply, text string, opts ReplyOptions) error
+
+	Capabilities() AdapterCapabilities
+
+	// Stop stops receiving messages.
+	Stop()
+}
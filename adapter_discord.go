@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	adapterDiscord = "discord"
+
+	// Chat type of messages sent in guild channels and threads
+	chatTypeGuild = "guild"
+)
+
+type DiscordConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Required if enabled. Discord bot token, without the "Bot " prefix.
+	Token string `yaml:"token" redact:"true"`
+
+	// Guild IDs in which the bot translates messages.
+	AllowedGuilds []string `yaml:"allowed_guilds"`
+
+	// Channel IDs in which the bot translates messages. Channels of
+	// allowed guilds are always allowed. For direct messages, user IDs.
+	AllowedChannels []string `yaml:"allowed_channels"`
+
+	// Reply in a new thread started from the original message
+	// instead of replying in the channel.
+	ReplyInThread bool `yaml:"reply_in_thread"`
+}
+
+// DiscordAdapter receives messages through the Discord gateway.
+type DiscordAdapter struct {
+	session   *discordgo.Session
+	messages  chan *Message
+	callbacks chan *Callback
+
+	mu              *sync.RWMutex
+	allowedGuilds   []string
+	allowedChannels []string
+	replyInThread   bool
+}
+
+func newDiscordAdapter(conf DiscordConfig) (da *DiscordAdapter, err error) {
+	if conf.Token == "" {
+		err = fmt.Errorf("discord bot token required")
+		return
+	}
+
+	session, err := discordgo.New("Bot " + conf.Token)
+	if err != nil {
+		return
+	}
+	session.Identify.Intents = discordgo.IntentsGuildMessages |
+		discordgo.IntentsDirectMessages |
+		discordgo.IntentsMessageContent
+
+	da = &DiscordAdapter{
+		session:   session,
+		messages:  make(chan *Message),
+		callbacks: make(chan *Callback),
+		mu:        new(sync.RWMutex),
+	}
+	da.Reload(BotConfig{Discord: conf})
+	session.AddHandler(da.onMessageCreate)
+	session.AddHandler(da.onInteractionCreate)
+
+	logrus.Info("connecting to discord gateway")
+	err = session.Open()
+	if err != nil {
+		err = fmt.Errorf("discord gateway connection failed: %w", err)
+		return
+	}
+	logrus.Infof("authorized on discord account: %s", session.State.User.Username)
+	return
+}
+
+func (da *DiscordAdapter) onInteractionCreate(s *discordgo.Session, ic *discordgo.InteractionCreate) {
+	if ic.Type != discordgo.InteractionMessageComponent || ic.Message == nil {
+		return
+	}
+
+	user := ic.User
+	if ic.Member != nil {
+		user = ic.Member.User
+	}
+	if user == nil {
+		return
+	}
+	chatId, _ := strconv.ParseInt(ic.ChannelID, 10, 64)
+	messageId, _ := strconv.ParseInt(ic.Message.ID, 10, 64)
+	userId, _ := strconv.ParseInt(user.ID, 10, 64)
+
+	da.callbacks <- &Callback{
+		Platform:  adapterDiscord,
+		ChatID:    chatId,
+		MessageID: messageId,
+		UserID:    userId,
+		Data:      ic.MessageComponentData().CustomID,
+
+		LanguageCode: string(ic.Locale),
+		Answer: func(text string) error {
+			if text == "" {
+				return s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+					Type: discordgo.InteractionResponseDeferredMessageUpdate,
+				})
+			}
+			return s.InteractionRespond(ic.Interaction, &discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: text,
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+		},
+	}
+}
+
+func (da *DiscordAdapter) ReceiveCallbacks() <-chan *Callback {
+	return da.callbacks
+}
+
+// discordComponents returns nil without buttons.
+func discordComponents(buttons []ReplyButton) []discordgo.MessageComponent {
+	if len(buttons) == 0 {
+		return nil
+	}
+	row := discordgo.ActionsRow{}
+	for _, b := range buttons {
+		row.Components = append(row.Components, discordgo.Button{
+			Label:    b.Text,
+			Style:    discordgo.SecondaryButton,
+			CustomID: b.Data,
+		})
+	}
+	return []discordgo.MessageComponent{row}
+}
+
+func (da *DiscordAdapter) onMessageCreate(s *discordgo.Session, mc *discordgo.MessageCreate) {
+	if mc.Author == nil || mc.Author.ID == s.State.User.ID || mc.Author.Bot {
+		return
+	}
+
+	chatId, _ := strconv.ParseInt(mc.ChannelID, 10, 64)
+	messageId, _ := strconv.ParseInt(mc.ID, 10, 64)
+	userId, _ := strconv.ParseInt(mc.Author.ID, 10, 64)
+
+	chatType := chatTypeGuild
+	if mc.GuildID == "" {
+		chatType = "private"
+	}
+
+	contentType := contentTypeText
+	if mc.Content == "" {
+		switch {
+		case len(mc.StickerItems) > 0:
+			contentType = contentTypeSticker
+		case len(mc.Attachments) > 0:
+			contentType = contentTypeMedia
+		default:
+			contentType = contentTypeOther
+		}
+	}
+
+	m := &Message{
+		Platform:    adapterDiscord,
+		Raw:         mc.Message,
+		Content:     mc.Content,
+		ContentType: contentType,
+		ChatID:      chatId,
+		ChatType:    chatType,
+		UserID:      userId,
+		MessageID:   messageId,
+	}
+	// Discord doesn't tell the origin of forwarded messages
+	if mc.MessageReference != nil && mc.MessageReference.Type == discordgo.MessageReferenceTypeForward {
+		m.ForwardOrigin = forwardOriginHidden
+	}
+	if mc.ReferencedMessage != nil {
+		m.ReplyTo = mc.ReferencedMessage.Content
+	}
+	m.SenderName = mc.Author.DisplayName()
+	if mc.Member != nil && mc.Member.Nick != "" {
+		m.SenderName = mc.Member.Nick
+	}
+	m.SenderUsername = mc.Author.Username
+	if ch, err := s.State.Channel(mc.ChannelID); err == nil {
+		m.ChatTitle = ch.Name
+	}
+	if len(mc.Attachments) > 0 {
+		a := mc.Attachments[0]
+		doc := &Document{
+			Name: a.Filename,
+			Size: int64(a.Size),
+			Ref:  a.URL,
+		}
+		if mc.Flags&discordgo.MessageFlagsIsVoiceMessage != 0 {
+			m.Voice = doc
+		} else if strings.HasPrefix(a.ContentType, "image/") {
+			m.Photo = doc
+		} else {
+			m.Document = doc
+		}
+	}
+	da.messages <- m
+}
+
+func (da *DiscordAdapter) Name() string {
+	return adapterDiscord
+}
+
+func (da *DiscordAdapter) ReceiveMessages() <-chan *Message {
+	return da.messages
+}
+
+func (da *DiscordAdapter) IsAllowed(msg *Message) bool {
+	m := msg.Raw.(*discordgo.Message)
+
+	da.mu.RLock()
+	defer da.mu.RUnlock()
+	if m.GuildID == "" {
+		return slices.Contains(da.allowedChannels, m.Author.ID)
+	}
+	return slices.Contains(da.allowedGuilds, m.GuildID) ||
+		slices.Contains(da.allowedChannels, m.ChannelID)
+}
+
+func (da *DiscordAdapter) Reload(conf BotConfig) {
+	da.mu.Lock()
+	da.allowedGuilds = slices.Clone(conf.Discord.AllowedGuilds)
+	da.allowedChannels = slices.Clone(conf.Discord.AllowedChannels)
+	da.replyInThread = conf.Discord.ReplyInThread
+	da.mu.Unlock()
+}
+
+func (da *DiscordAdapter) Reply(msg *Message, text string, opts ReplyOptions) (sent *SentReply, err error) {
+	return da.reply(msg, &discordgo.MessageSend{
+		Content:         opts.withFooter(text),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}, opts)
+}
+
+func (da *DiscordAdapter) SendMessage(chatId int64, text string, opts ReplyOptions) (sent *SentReply, err error) {
+	send := &discordgo.MessageSend{
+		Content:         opts.withFooter(text),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}
+	if opts.DisableNotification {
+		send.Flags |= discordgo.MessageFlagsSuppressNotifications
+	}
+	if opts.DisableLinkPreview {
+		send.Flags |= discordgo.MessageFlagsSuppressEmbeds
+	}
+
+	r, err := da.session.ChannelMessageSendComplex(strconv.FormatInt(chatId, 10), send)
+	if err != nil {
+		return
+	}
+	messageId, _ := strconv.ParseInt(r.ID, 10, 64)
+	return &SentReply{ChatID: chatId, MessageID: messageId}, nil
+}
+
+func (da *DiscordAdapter) IsChatAdmin(msg *Message) (bool, error) {
+	m := msg.Raw.(*discordgo.Message)
+	if m.GuildID == "" {
+		return true, nil
+	}
+	perms, err := da.session.UserChannelPermissions(m.Author.ID, m.ChannelID)
+	if err != nil {
+		return false, err
+	}
+	return perms&(discordgo.PermissionAdministrator|discordgo.PermissionManageGuild) != 0, nil
+}
+
+func (da *DiscordAdapter) SendTyping(msg *Message) error {
+	return da.session.ChannelTyping(msg.Raw.(*discordgo.Message).ChannelID)
+}
+
+func (da *DiscordAdapter) DownloadDocument(doc *Document, limit int64) ([]byte, error) {
+	return downloadLimited(context.Background(), doc.Ref, limit)
+}
+
+func (da *DiscordAdapter) ReplyDocument(msg *Message, name string, data []byte, opts ReplyOptions) (sent *SentReply, err error) {
+	return da.reply(msg, &discordgo.MessageSend{
+		Files: []*discordgo.File{{
+			Name:        name,
+			ContentType: "text/plain; charset=utf-8",
+			Reader:      bytes.NewReader(data),
+		}},
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	}, opts)
+}
+
+// reply sends a message as a reply to msg, or into a thread started from msg.
+func (da *DiscordAdapter) reply(msg *Message, send *discordgo.MessageSend, opts ReplyOptions) (sent *SentReply, err error) {
+	m := msg.Raw.(*discordgo.Message)
+
+	da.mu.RLock()
+	replyInThread := da.replyInThread && m.GuildID != ""
+	da.mu.RUnlock()
+
+	if opts.DisableNotification {
+		send.Flags |= discordgo.MessageFlagsSuppressNotifications
+	}
+	if opts.DisableLinkPreview {
+		send.Flags |= discordgo.MessageFlagsSuppressEmbeds
+	}
+	send.Components = discordComponents(opts.Buttons)
+
+	channelId := m.ChannelID
+	if replyInThread && !opts.Standalone {
+		var thread *discordgo.Channel
+		thread, err = da.session.MessageThreadStartComplex(m.ChannelID, m.ID, &discordgo.ThreadStart{
+			Name:                "Translation",
+			AutoArchiveDuration: 60,
+		})
+		if err != nil {
+			return
+		}
+		channelId = thread.ID
+	} else if !opts.Standalone {
+		send.Reference = m.Reference()
+	}
+
+	r, err := da.session.ChannelMessageSendComplex(channelId, send)
+	if err != nil {
+		return
+	}
+	chatId, _ := strconv.ParseInt(r.ChannelID, 10, 64)
+	messageId, _ := strconv.ParseInt(r.ID, 10, 64)
+	return &SentReply{ChatID: chatId, MessageID: messageId}, nil
+}
+
+func (da *DiscordAdapter) EditReply(sent *SentReply, text string, opts ReplyOptions) (err error) {
+	edit := discordgo.NewMessageEdit(
+		strconv.FormatInt(sent.ChatID, 10),
+		strconv.FormatInt(sent.MessageID, 10),
+	).SetContent(opts.withFooter(text))
+	components := discordComponents(opts.Buttons)
+	if components == nil {
+		components = []discordgo.MessageComponent{}
+	}
+	edit.Components = &components
+	_, err = da.session.ChannelMessageEditComplex(edit)
+	return
+}
+
+func (da *DiscordAdapter) ParseRaw(data []byte) (any, error) {
+	m := new(discordgo.Message)
+	err := json.Unmarshal(data, m)
+	return m, err
+}
+
+func (da *DiscordAdapter) Capabilities() AdapterCapabilities {
+	return AdapterCapabilities{
+		EditReply: true,
+	}
+}
+
+func (da *DiscordAdapter) Stop() {
+	da.session.Close()
+}
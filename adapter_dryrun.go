@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// DryRunAdapter wraps a ChatAdapter, receiving messages as usual
+// but logging replies instead of sending them.
+type DryRunAdapter struct {
+	ChatAdapter
+}
+
+func newDryRunAdapter(adapter ChatAdapter) *DryRunAdapter {
+	return &DryRunAdapter{ChatAdapter: adapter}
+}
+
+func (a *DryRunAdapter) Reply(msg *Message, text string, opts ReplyOptions) (*SentReply, error) {
+	msg.logger.WithField("dry_run", true).Infof("reply not sent: %q", redact(opts.withFooter(text)))
+	return &SentReply{ChatID: msg.ChatID}, nil
+}
+
+func (a *DryRunAdapter) AppendToPost(msg *Message, text string, opts ReplyOptions) (*SentReply, error) {
+	if _, ok := a.ChatAdapter.(PostEditAdapter); !ok {
+		return nil, fmt.Errorf("%s adapter does not support editing posts", a.Name())
+	}
+	msg.logger.WithField("dry_run", true).Infof("post not edited: %q", redact(opts.withFooter(text)))
+	return &SentReply{ChatID: msg.ChatID, MessageID: msg.MessageID}, nil
+}
+
+func (a *DryRunAdapter) SendMessage(chatId int64, text string, opts ReplyOptions) (*SentReply, error) {
+	if _, ok := a.ChatAdapter.(NotifyAdapter); !ok {
+		return nil, fmt.Errorf("%s adapter does not support sending messages", a.Name())
+	}
+	logrus.WithFields(logrus.Fields{
+		"dry_run": true,
+		"chat_id": chatId,
+	}).Infof("message not sent: %q", redact(opts.withFooter(text)))
+	return &SentReply{ChatID: chatId}, nil
+}
+
+func (a *DryRunAdapter) DownloadDocument(doc *Document, limit int64) ([]byte, error) {
+	da, ok := a.ChatAdapter.(DocumentAdapter)
+	if !ok {
+		return nil, fmt.Errorf("%s adapter does not support documents", a.Name())
+	}
+	return da.DownloadDocument(doc, limit)
+}
+
+func (a *DryRunAdapter) IsChatAdmin(msg *Message) (bool, error) {
+	aa, ok := a.ChatAdapter.(AdminAdapter)
+	if !ok {
+		return false, nil
+	}
+	return aa.IsChatAdmin(msg)
+}
+
+func (a *DryRunAdapter) ParseRaw(data []byte) (any, error) {
+	ra, ok := a.ChatAdapter.(ResumeAdapter)
+	if !ok {
+		return nil, fmt.Errorf("%s adapter does not support resuming messages", a.Name())
+	}
+	return ra.ParseRaw(data)
+}
+
+// ReceiveCallbacks forwards the button presses of the wrapped adapter, so
+// they are handled, or returns a closed channel if it has no buttons.
+func (a *DryRunAdapter) ReceiveCallbacks() <-chan *Callback {
+	ca, ok := a.ChatAdapter.(CallbackAdapter)
+	if !ok {
+		callbacks := make(chan *Callback)
+		close(callbacks)
+		return callbacks
+	}
+	return ca.ReceiveCallbacks()
+}
+
+func (a *DryRunAdapter) SendTyping(msg *Message) error {
+	ta, ok := a.ChatAdapter.(TypingAdapter)
+	if !ok {
+		return fmt.Errorf("%s adapter does not support typing indicators", a.Name())
+	}
+	return ta.SendTyping(msg)
+}
+
+func (a *DryRunAdapter) ReplyDocument(msg *Message, name string, data []byte, _ ReplyOptions) (*SentReply, error) {
+	msg.logger.WithField("dry_run", true).Infof("document reply not sent: %s (%d bytes)", name, len(data))
+	return &SentReply{ChatID: msg.ChatID}, nil
+}
+
+func (a *DryRunAdapter) EditReply(_ *SentReply, text string, opts ReplyOptions) error {
+	logrus.WithField("dry_run", true).Infof("edit not sent: %q", redact(opts.withFooter(text)))
+	return nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	adapterKafka = "kafka"
+)
+
+type KafkaConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Required if enabled
+	Brokers []string `yaml:"brokers"`
+
+	// Required if enabled
+	InputTopic string `yaml:"input_topic"`
+
+	// Required if enabled
+	OutputTopic string `yaml:"output_topic"`
+
+	// Required if enabled. Consumer group ID
+	GroupID string `yaml:"group_id"`
+}
+
+// KafkaAdapter consumes texts from a Kafka topic and
+// publishes translations to another one. Offsets are committed once
+// the messages up to them are handled, so messages in flight are consumed
+// again after a restart.
+type KafkaAdapter struct {
+	reader   *kafka.Reader
+	writer   *kafka.Writer
+	messages chan *Message
+	cancel   context.CancelFunc
+	offsets  *kafkaOffsets
+}
+
+// kafkaOffsets tracks the fetched messages of each partition, so only
+// offsets up to which every message is handled are committed. Messages
+// are handled out of order by the workers.
+type kafkaOffsets struct {
+	mu sync.Mutex
+	// Uncommitted messages by partition, in fetch order
+	partitions map[int][]*kafkaOffset
+}
+
+type kafkaOffset struct {
+	msg  kafka.Message
+	done bool
+}
+
+// fetched tracks m until it is done.
+func (ko *kafkaOffsets) fetched(m kafka.Message) *kafkaOffset {
+	ko.mu.Lock()
+	defer ko.mu.Unlock()
+	o := &kafkaOffset{msg: m}
+	ko.partitions[m.Partition] = append(ko.partitions[m.Partition], o)
+	return o
+}
+
+// done marks o handled and commits the last of the leading handled
+// messages of its partition, if any. Commits are serialized, so the
+// committed offset never goes back.
+func (ko *kafkaOffsets) done(o *kafkaOffset, commit func(kafka.Message) error) {
+	ko.mu.Lock()
+	defer ko.mu.Unlock()
+	o.done = true
+	pending := ko.partitions[o.msg.Partition]
+	n := 0
+	for n < len(pending) && pending[n].done {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	last := pending[n-1].msg
+	if err := commit(last); err != nil {
+		logrus.Errorf("kafka commit of offset %d failed: %v", last.Offset, err)
+		return
+	}
+	ko.partitions[o.msg.Partition] = pending[n:]
+}
+
+func newKafkaAdapter(conf KafkaConfig) (ka *KafkaAdapter, err error) {
+	if len(conf.Brokers) == 0 || conf.InputTopic == "" || conf.OutputTopic == "" || conf.GroupID == "" {
+		err = fmt.Errorf("kafka: brokers, input_topic, output_topic and group_id are required")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ka = &KafkaAdapter{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers: conf.Brokers,
+			GroupID: conf.GroupID,
+			Topic:   conf.InputTopic,
+		}),
+		writer: &kafka.Writer{
+			Addr:     kafka.TCP(conf.Brokers...),
+			Topic:    conf.OutputTopic,
+			Balancer: &kafka.Hash{},
+		},
+		messages: make(chan *Message),
+		cancel:   cancel,
+		offsets:  &kafkaOffsets{partitions: make(map[int][]*kafkaOffset)},
+	}
+	logrus.Infof("consuming kafka topic: %s", conf.InputTopic)
+	go ka.receive(ctx)
+	return
+}
+
+func (ka *KafkaAdapter) receive(ctx context.Context) {
+	defer close(ka.messages)
+	for {
+		m, err := ka.reader.FetchMessage(ctx)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logrus.Errorf("kafka read failed: %v", err)
+			}
+			return
+		}
+
+		in := parseQueueInput(m.Value)
+		offset := ka.offsets.fetched(m)
+		ka.messages <- &Message{
+			Platform:  adapterKafka,
+			Raw:       in,
+			Content:   in.Text,
+			ChatID:    int64(m.Partition),
+			ChatType:  chatTypeQueue,
+			MessageID: m.Offset,
+			OnDone: func() {
+				ka.offsets.done(offset, func(m kafka.Message) error {
+					return ka.reader.CommitMessages(context.Background(), m)
+				})
+			},
+		}
+	}
+}
+
+func (ka *KafkaAdapter) Name() string {
+	return adapterKafka
+}
+
+func (ka *KafkaAdapter) ReceiveMessages() <-chan *Message {
+	return ka.messages
+}
+
+// IsAllowed always returns true, the queue is a trusted source.
+func (ka *KafkaAdapter) IsAllowed(*Message) bool {
+	return true
+}
+
+func (ka *KafkaAdapter) Reload(BotConfig) {}
+
+func (ka *KafkaAdapter) Reply(msg *Message, text string, _ ReplyOptions) (sent *SentReply, err error) {
+	b, err := newQueueOutput(msg, text)
+	if err != nil {
+		return
+	}
+	err = ka.writer.WriteMessages(context.Background(), kafka.Message{
+		Key:   []byte(msg.TraceId),
+		Value: b,
+	})
+	if err != nil {
+		return
+	}
+	return &SentReply{ChatID: msg.ChatID, MessageID: msg.MessageID}, nil
+}
+
+func (ka *KafkaAdapter) EditReply(*SentReply, string, ReplyOptions) error {
+	return errQueueEditUnsupported
+}
+
+func (ka *KafkaAdapter) ParseRaw(data []byte) (any, error) {
+	return parseQueueRaw(data)
+}
+
+func (ka *KafkaAdapter) Capabilities() AdapterCapabilities {
+	return AdapterCapabilities{}
+}
+
+func (ka *KafkaAdapter) Stop() {
+	ka.cancel()
+	ka.reader.Close()
+	ka.writer.Close()
+}
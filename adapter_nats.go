@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	adapterNATS = "nats"
+)
+
+type NATSConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Required if enabled, e.g. nats://127.0.0.1:4222
+	URL string `yaml:"url"`
+
+	// Required if enabled
+	InputSubject string `yaml:"input_subject"`
+
+	// Required if enabled
+	OutputSubject string `yaml:"output_subject"`
+
+	// Optional. Replicas in the same queue group share the input
+	QueueGroup string `yaml:"queue_group"`
+}
+
+// NATSAdapter consumes texts from a NATS subject and
+// publishes translations to another one.
+type NATSAdapter struct {
+	conn          *nats.Conn
+	sub           *nats.Subscription
+	outputSubject string
+	messages      chan *Message
+
+	// Message IDs, a random prefix per process in the upper 32 bits, so
+	// trace IDs don't repeat after a restart
+	seq atomic.Int64
+}
+
+func newNATSAdapter(conf NATSConfig) (na *NATSAdapter, err error) {
+	if conf.URL == "" || conf.InputSubject == "" || conf.OutputSubject == "" {
+		err = fmt.Errorf("nats: url, input_subject and output_subject are required")
+		return
+	}
+
+	conn, err := nats.Connect(conf.URL)
+	if err != nil {
+		err = fmt.Errorf("nats connection failed: %w", err)
+		return
+	}
+
+	na = &NATSAdapter{
+		conn:          conn,
+		outputSubject: conf.OutputSubject,
+		messages:      make(chan *Message),
+	}
+	na.seq.Store(int64(rand.Uint32()>>1) << 32)
+	na.sub, err = conn.QueueSubscribe(conf.InputSubject, conf.QueueGroup, na.onMessage)
+	if err != nil {
+		conn.Close()
+		err = fmt.Errorf("nats subscribe failed: %w", err)
+		return
+	}
+	logrus.Infof("consuming nats subject: %s", conf.InputSubject)
+	return
+}
+
+func (na *NATSAdapter) onMessage(m *nats.Msg) {
+	in := parseQueueInput(m.Data)
+	na.messages <- &Message{
+		Platform:  adapterNATS,
+		Raw:       in,
+		Content:   in.Text,
+		ChatType:  chatTypeQueue,
+		MessageID: na.seq.Add(1),
+	}
+}
+
+func (na *NATSAdapter) Name() string {
+	return adapterNATS
+}
+
+func (na *NATSAdapter) ReceiveMessages() <-chan *Message {
+	return na.messages
+}
+
+// IsAllowed always returns true, the queue is a trusted source.
+func (na *NATSAdapter) IsAllowed(*Message) bool {
+	return true
+}
+
+func (na *NATSAdapter) Reload(BotConfig) {}
+
+func (na *NATSAdapter) Reply(msg *Message, text string, _ ReplyOptions) (sent *SentReply, err error) {
+	b, err := newQueueOutput(msg, text)
+	if err != nil {
+		return
+	}
+	err = na.conn.Publish(na.outputSubject, b)
+	if err != nil {
+		return
+	}
+	return &SentReply{MessageID: msg.MessageID}, nil
+}
+
+func (na *NATSAdapter) EditReply(*SentReply, string, ReplyOptions) error {
+	return errQueueEditUnsupported
+}
+
+func (na *NATSAdapter) ParseRaw(data []byte) (any, error) {
+	return parseQueueRaw(data)
+}
+
+func (na *NATSAdapter) Capabilities() AdapterCapabilities {
+	return AdapterCapabilities{}
+}
+
+func (na *NATSAdapter) Stop() {
+	na.sub.Unsubscribe()
+	na.conn.Drain()
+}
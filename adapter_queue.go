@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// Chat type of messages consumed from a message queue
+	chatTypeQueue = "queue"
+)
+
+type QueueConfig struct {
+	NATS  NATSConfig  `yaml:"nats"`
+	Kafka KafkaConfig `yaml:"kafka"`
+}
+
+// queueInput is the JSON payload consumed from a message queue.
+// A payload which is not JSON is used as text as is.
+type queueInput struct {
+	// Optional. Passed through to the output
+	Id   string `json:"id,omitempty"`
+	Text string `json:"text"`
+}
+
+// queueOutput is the JSON payload published for each translation.
+type queueOutput struct {
+	Id          string `json:"id,omitempty"`
+	TraceId     string `json:"trace_id"`
+	Original    string `json:"original"`
+	Translation string `json:"translation"`
+}
+
+func parseQueueInput(data []byte) (in queueInput) {
+	if json.Unmarshal(data, &in) != nil {
+		in = queueInput{Text: string(data)}
+	}
+	return
+}
+
+// parseQueueRaw restores the queueInput of a message.
+func parseQueueRaw(data []byte) (any, error) {
+	var in queueInput
+	err := json.Unmarshal(data, &in)
+	return in, err
+}
+
+func newQueueOutput(msg *Message, text string) ([]byte, error) {
+	in := msg.Raw.(queueInput)
+	return json.Marshal(queueOutput{
+		Id:          in.Id,
+		TraceId:     msg.TraceId,
+		Original:    msg.Content,
+		Translation: text,
+	})
+}
+
+var errQueueEditUnsupported = fmt.Errorf("editing published messages is not supported")
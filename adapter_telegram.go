@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	adapterTelegram = "telegram"
+)
+
+// TelegramAdapter receives messages through the Telegram Bot API long polling.
+type TelegramAdapter struct {
+	bot          *tgbotapi.BotAPI
+	messages     chan *Message
+	callbacks    chan *Callback
+	allowedChats *SafeSlice[int64]
+
+	// Set once serving the webhook, polling otherwise
+	webhook atomic.Pointer[telegramWebhook]
+}
+
+// newTelegramAdapter starts polling updates, or serving the webhook if
+// enabled.
+func newTelegramAdapter(conf BotConfig) (ta *TelegramAdapter, err error) {
+	logrus.Info("authorizing telegram bot")
+
+	var botApi *tgbotapi.BotAPI
+	botApi, err = tgbotapi.NewBotAPI(conf.Token)
+	if err != nil {
+		return
+	}
+	logrus.Infof("authorized on account: %s", botApi.Self.UserName)
+	// Debug logs whole API requests, including message texts
+	botApi.Debug = conf.Debug && !privacyMode
+
+	ta = &TelegramAdapter{
+		bot:          botApi,
+		messages:     make(chan *Message),
+		callbacks:    make(chan *Callback),
+		allowedChats: newSafeSlice(conf.AllowedChats),
+	}
+	if conf.TelegramWebhook.Enabled {
+		var wh *telegramWebhook
+		wh, err = startTelegramWebhook(botApi, conf.TelegramWebhook)
+		if err != nil {
+			return nil, err
+		}
+		ta.webhook.Store(wh)
+		go ta.receive(wh.updates)
+		return
+	}
+	go func() {
+		// Updates can't be polled while a webhook is set
+		if _, err := botApi.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+			logrus.Warnf("delete telegram webhook failed: %v", err)
+		}
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+		ta.receive(botApi.GetUpdatesChan(u))
+	}()
+	return
+}
+
+func (ta *TelegramAdapter) receive(updates tgbotapi.UpdatesChannel) {
+	defer close(ta.messages)
+	defer close(ta.callbacks)
+	for update := range updates {
+		if update.Message != nil {
+			ta.messages <- ta.newMessage(update.Message)
+		} else if update.ChannelPost != nil {
+			ta.messages <- ta.newMessage(update.ChannelPost)
+		} else if q := update.CallbackQuery; q != nil && q.Message != nil {
+			ta.callbacks <- &Callback{
+				Platform:  adapterTelegram,
+				ChatID:    q.Message.Chat.ID,
+				MessageID: int64(q.Message.MessageID),
+				UserID:    q.From.ID,
+				Data:      q.Data,
+
+				LanguageCode: q.From.LanguageCode,
+				Answer: func(text string) (err error) {
+					_, err = ta.bot.Request(tgbotapi.NewCallback(q.ID, text))
+					return
+				},
+			}
+		}
+	}
+}
+
+func (ta *TelegramAdapter) ReceiveCallbacks() <-chan *Callback {
+	return ta.callbacks
+}
+
+// telegramKeyboard returns nil without buttons.
+func telegramKeyboard(buttons []ReplyButton) *tgbotapi.InlineKeyboardMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+	row := make([]tgbotapi.InlineKeyboardButton, 0, len(buttons))
+	for _, b := range buttons {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(b.Text, b.Data))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return &keyboard
+}
+
+func (ta *TelegramAdapter) newMessage(message *tgbotapi.Message) *Message {
+	var text string
+	if len(message.Text) > 0 {
+		text = markCodeEntities(message.Text, message.Entities)
+	} else if len(message.Caption) > 0 {
+		text = markCodeEntities(message.Caption, message.CaptionEntities)
+	}
+
+	m := &Message{
+		Platform:    adapterTelegram,
+		Raw:         message,
+		Content:     text,
+		ContentType: telegramContentType(message),
+		ChatID:      message.Chat.ID,
+		ChatType:    message.Chat.Type,
+		MessageID:   int64(message.MessageID),
+	}
+	if message.From != nil {
+		m.UserID = message.From.ID
+		m.LanguageCode = message.From.LanguageCode
+		m.SenderName = strings.TrimSpace(message.From.FirstName + " " + message.From.LastName)
+		m.SenderUsername = message.From.UserName
+	} else if message.SenderChat != nil {
+		// Channel posts and anonymous admins
+		m.SenderName = message.SenderChat.Title
+	}
+	m.ChatTitle = message.Chat.Title
+	m.ForwardOrigin = telegramForwardOrigin(message)
+	if r := message.ReplyToMessage; r != nil {
+		m.ReplyTo = r.Text
+		if m.ReplyTo == "" {
+			m.ReplyTo = r.Caption
+		}
+	}
+	if message.Document != nil {
+		m.Document = &Document{
+			Name: message.Document.FileName,
+			Size: int64(message.Document.FileSize),
+			Ref:  message.Document.FileID,
+		}
+	}
+	if n := len(message.Photo); n > 0 {
+		// Sizes are ordered from the smallest
+		p := message.Photo[n-1]
+		m.Photo = &Document{
+			Name: "photo.jpg",
+			Size: int64(p.FileSize),
+			Ref:  p.FileID,
+		}
+	}
+	if message.Voice != nil {
+		m.Voice = &Document{
+			// Telegram voice notes are Opus in an Ogg container
+			Name: "voice.ogg",
+			Size: int64(message.Voice.FileSize),
+			Ref:  message.Voice.FileID,
+		}
+	}
+	return m
+}
+
+func (ta *TelegramAdapter) Name() string {
+	return adapterTelegram
+}
+
+func (ta *TelegramAdapter) ReceiveMessages() <-chan *Message {
+	return ta.messages
+}
+
+func (ta *TelegramAdapter) IsAllowed(msg *Message) bool {
+	if msg.ChatType == "private" {
+		return ta.allowedChats.Contains(msg.UserID)
+	}
+	return ta.allowedChats.Contains(msg.ChatID)
+}
+
+func (ta *TelegramAdapter) Reload(conf BotConfig) {
+	ta.allowedChats.New(conf.AllowedChats)
+}
+
+func (ta *TelegramAdapter) Reply(msg *Message, text string, opts ReplyOptions) (sent *SentReply, err error) {
+	reply := tgbotapi.NewMessage(msg.ChatID, opts.withFooter(text))
+	reply.DisableNotification = opts.DisableNotification
+	reply.DisableWebPagePreview = opts.DisableLinkPreview
+	reply.Entities = telegramWrapEntities("", text, opts)
+	if !opts.Standalone {
+		reply.ReplyToMessageID = int(msg.MessageID)
+	}
+	if keyboard := telegramKeyboard(opts.Buttons); keyboard != nil {
+		reply.ReplyMarkup = keyboard
+	}
+
+	m, err := ta.bot.Send(reply)
+	if err != nil {
+		return
+	}
+	return &SentReply{ChatID: m.Chat.ID, MessageID: int64(m.MessageID)}, nil
+}
+
+func (ta *TelegramAdapter) SendMessage(chatId int64, text string, opts ReplyOptions) (sent *SentReply, err error) {
+	send := tgbotapi.NewMessage(chatId, opts.withFooter(text))
+	send.DisableNotification = opts.DisableNotification
+	send.DisableWebPagePreview = opts.DisableLinkPreview
+
+	m, err := ta.bot.Send(send)
+	if err != nil {
+		return
+	}
+	return &SentReply{ChatID: m.Chat.ID, MessageID: int64(m.MessageID)}, nil
+}
+
+func (ta *TelegramAdapter) EditReply(sent *SentReply, text string, opts ReplyOptions) (err error) {
+	edit := tgbotapi.NewEditMessageText(sent.ChatID, int(sent.MessageID), opts.withFooter(text))
+	edit.DisableWebPagePreview = opts.DisableLinkPreview
+	edit.Entities = telegramWrapEntities("", text, opts)
+	edit.ReplyMarkup = telegramKeyboard(opts.Buttons)
+	_, err = ta.bot.Send(edit)
+	return
+}
+
+// AppendToPost appends text to the text or caption of msg, separated by a
+// blank line, keeping the formatting of the original.
+func (ta *TelegramAdapter) AppendToPost(msg *Message, text string, opts ReplyOptions) (sent *SentReply, err error) {
+	m := msg.Raw.(*tgbotapi.Message)
+	keyboard := telegramKeyboard(opts.Buttons)
+
+	var edit tgbotapi.Chattable
+	switch {
+	case m.Text != "":
+		prefix := m.Text + "\n\n"
+		e := tgbotapi.NewEditMessageText(m.Chat.ID, m.MessageID, prefix+opts.withFooter(text))
+		e.Entities = append(slices.Clone(m.Entities), telegramWrapEntities(prefix, text, opts)...)
+		e.DisableWebPagePreview = opts.DisableLinkPreview
+		e.ReplyMarkup = keyboard
+		edit = e
+	case m.Caption != "":
+		prefix := m.Caption + "\n\n"
+		e := tgbotapi.NewEditMessageCaption(m.Chat.ID, m.MessageID, prefix+opts.withFooter(text))
+		e.CaptionEntities = append(slices.Clone(m.CaptionEntities), telegramWrapEntities(prefix, text, opts)...)
+		e.ReplyMarkup = keyboard
+		edit = e
+	default:
+		err = fmt.Errorf("message has no text to append to")
+		return
+	}
+
+	_, err = ta.bot.Send(edit)
+	if err != nil {
+		return
+	}
+	return &SentReply{ChatID: m.Chat.ID, MessageID: int64(m.MessageID)}, nil
+}
+
+func (ta *TelegramAdapter) IsChatAdmin(msg *Message) (bool, error) {
+	m := msg.Raw.(*tgbotapi.Message)
+	switch {
+	// Only admins post in channels or as the group itself
+	case msg.ChatType == "private" || msg.ChatType == "channel":
+		return true, nil
+	case m.SenderChat != nil && m.SenderChat.ID == msg.ChatID:
+		return true, nil
+	case msg.UserID == 0:
+		return false, nil
+	}
+	member, err := ta.bot.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: msg.ChatID, UserID: msg.UserID},
+	})
+	if err != nil {
+		return false, err
+	}
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
+
+func (ta *TelegramAdapter) SendTyping(msg *Message) (err error) {
+	_, err = ta.bot.Request(tgbotapi.NewChatAction(msg.ChatID, tgbotapi.ChatTyping))
+	return
+}
+
+func (ta *TelegramAdapter) ParseRaw(data []byte) (any, error) {
+	m := new(tgbotapi.Message)
+	err := json.Unmarshal(data, m)
+	return m, err
+}
+
+func (ta *TelegramAdapter) Capabilities() AdapterCapabilities {
+	return AdapterCapabilities{
+		EditReply: true,
+	}
+}
+
+func (ta *TelegramAdapter) Stop() {
+	if wh := ta.webhook.Load(); wh != nil {
+		wh.Stop()
+		return
+	}
+	ta.bot.StopReceivingUpdates()
+}
+
+func (ta *TelegramAdapter) DownloadDocument(doc *Document, limit int64) (data []byte, err error) {
+	url, err := ta.bot.GetFileDirectURL(doc.Ref)
+	if err != nil {
+		return
+	}
+	return downloadLimited(context.Background(), url, limit)
+}
+
+func (ta *TelegramAdapter) ReplyDocument(msg *Message, name string, data []byte, opts ReplyOptions) (sent *SentReply, err error) {
+	reply := tgbotapi.NewDocument(msg.ChatID, tgbotapi.FileBytes{Name: name, Bytes: data})
+	reply.DisableNotification = opts.DisableNotification
+	if !opts.Standalone {
+		reply.ReplyToMessageID = int(msg.MessageID)
+	}
+
+	m, err := ta.bot.Send(reply)
+	if err != nil {
+		return
+	}
+	return &SentReply{ChatID: m.Chat.ID, MessageID: int64(m.MessageID)}, nil
+}
+
+// telegramWrapEntities returns the spoiler and blockquote entities of text
+// following prefix, as requested by opts.
+func telegramWrapEntities(prefix, text string, opts ReplyOptions) (entities []tgbotapi.MessageEntity) {
+	// Entity offsets are in UTF-16 code units
+	offset := len(utf16.Encode([]rune(prefix)))
+	length := len(utf16.Encode([]rune(text)))
+	if length == 0 {
+		return
+	}
+	if opts.Blockquote {
+		entities = append(entities, tgbotapi.MessageEntity{Type: "expandable_blockquote", Offset: offset, Length: length})
+	}
+	if opts.Spoiler {
+		entities = append(entities, tgbotapi.MessageEntity{Type: "spoiler", Offset: offset, Length: length})
+	}
+	return
+}
+
+// markCodeEntities wraps code and pre entities in Markdown backticks,
+// since Telegram delivers them as plain text, so they can be protected
+// from translation.
+func markCodeEntities(text string, entities []tgbotapi.MessageEntity) string {
+	// Entity offsets are in UTF-16 code units
+	units := utf16.Encode([]rune(text))
+	var b strings.Builder
+	last := 0
+	for _, e := range entities {
+		if e.Type != "code" && e.Type != "pre" {
+			continue
+		}
+		end := e.Offset + e.Length
+		if e.Offset < last || end > len(units) {
+			continue
+		}
+
+		b.WriteString(string(utf16.Decode(units[last:e.Offset])))
+		code := string(utf16.Decode(units[e.Offset:end]))
+		if e.Type == "pre" {
+			b.WriteString("```" + e.Language + "\n" + code + "\n```")
+		} else {
+			b.WriteString("`" + code + "`")
+		}
+		last = end
+	}
+	if last == 0 {
+		return text
+	}
+	b.WriteString(string(utf16.Decode(units[last:])))
+	return b.String()
+}
+
+func telegramContentType(message *tgbotapi.Message) string {
+	switch {
+	case message.Text != "" || message.Caption != "":
+		return contentTypeText
+	case message.Sticker != nil:
+		return contentTypeSticker
+	case message.Dice != nil:
+		return contentTypeDice
+	case message.Location != nil || message.Venue != nil:
+		return contentTypeLocation
+	case message.Photo != nil || message.Video != nil || message.Animation != nil ||
+		message.Audio != nil || message.Voice != nil || message.VideoNote != nil ||
+		message.Document != nil:
+		return contentTypeMedia
+	}
+	return contentTypeOther
+}
+
+// telegramForwardOrigin returns "" if message is not forwarded.
+// Automatic forwards of a linked channel into its discussion group
+// count as forwarded from a channel.
+func telegramForwardOrigin(message *tgbotapi.Message) string {
+	switch {
+	case message.IsAutomaticForward:
+		return forwardOriginChannel
+	case message.ForwardFromChat != nil && message.ForwardFromChat.IsChannel():
+		return forwardOriginChannel
+	case message.ForwardFromChat != nil:
+		return forwardOriginGroup
+	case message.ForwardFrom != nil && message.ForwardFrom.IsBot:
+		return forwardOriginBot
+	case message.ForwardFrom != nil:
+		return forwardOriginUser
+	case message.ForwardSenderName != "" || message.ForwardDate != 0:
+		return forwardOriginHidden
+	}
+	return ""
+}
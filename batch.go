@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+	"github.com/sirupsen/logrus"
+)
+
+// Name of the stage handing messages off to the batch, after the route stage
+const stageBatch = "batch"
+
+type BatchConfig struct {
+	// Translate text messages of Chats through the batch API of
+	// Translator, replying once the batch completes, typically within
+	// hours at half the price. For chats not read in real time
+	Enabled bool `yaml:"enabled"`
+
+	// Required. Name of an OpenAI translator
+	Translator string `yaml:"translator"`
+
+	// Required. Chat IDs whose messages are batched
+	Chats []int64 `yaml:"chats"`
+
+	// Positive. Seconds messages are accumulated before a batch is
+	// submitted
+	Window int64 `yaml:"window"`
+
+	// Positive. A batch is submitted at once with this many messages
+	MaxMessages int `yaml:"max_messages"`
+
+	// Positive. Seconds between checks of submitted batches
+	PollInterval int64 `yaml:"poll_interval"`
+}
+
+func (bc *BatchConfig) Check() (err error) {
+	if !bc.Enabled {
+		return
+	}
+	if bc.Translator == "" {
+		err = fmt.Errorf("batch translator is required")
+		return
+	}
+	if len(bc.Chats) == 0 {
+		err = fmt.Errorf("batch chats are required")
+		return
+	}
+	if bc.Window <= 0 || bc.MaxMessages <= 0 || bc.PollInterval <= 0 {
+		err = fmt.Errorf("batch window, max messages and poll interval must be positive")
+	}
+	return
+}
+
+type batchItem struct {
+	// nil if the message couldn't be resumed
+	msg *Message
+	req translator.TranslateRequest
+}
+
+// batchState holds the submitted batches by batch ID until delivered,
+// persisted by the StateStore, so they are resumed after a restart.
+type batchState map[string]*submittedBatch
+
+type submittedBatch struct {
+	Translator string          `json:"translator"`
+	Items      []batchedRecord `json:"items"`
+}
+
+// batchedRecord is what delivering the translation of a batched message
+// takes.
+type batchedRecord struct {
+	Platform       string          `json:"platform"`
+	ChatID         int64           `json:"chat_id"`
+	ChatType       string          `json:"chat_type"`
+	UserID         int64           `json:"user_id"`
+	MessageID      int64           `json:"message_id"`
+	Content        string          `json:"content"`
+	LanguageCode   string          `json:"language_code,omitempty"`
+	SenderName     string          `json:"sender_name,omitempty"`
+	SenderUsername string          `json:"sender_username,omitempty"`
+	ChatTitle      string          `json:"chat_title,omitempty"`
+	Raw            json.RawMessage `json:"raw"`
+
+	Lang                  *detector.DetectResponse    `json:"lang,omitempty"`
+	Detector              string                      `json:"detector,omitempty"`
+	Placeholder           *SentReply                  `json:"placeholder,omitempty"`
+	PlaceholderFailedText string                      `json:"placeholder_failed_text,omitempty"`
+	Request               translator.TranslateRequest `json:"request"`
+}
+
+func newBatchedRecord(item batchItem) (r batchedRecord, err error) {
+	msg := item.msg
+	r = batchedRecord{
+		Platform:              msg.Platform,
+		ChatID:                msg.ChatID,
+		ChatType:              msg.ChatType,
+		UserID:                msg.UserID,
+		MessageID:             msg.MessageID,
+		Content:               msg.Content,
+		LanguageCode:          msg.LanguageCode,
+		SenderName:            msg.SenderName,
+		SenderUsername:        msg.SenderUsername,
+		ChatTitle:             msg.ChatTitle,
+		Lang:                  msg.lang,
+		Detector:              msg.detectorName,
+		Placeholder:           msg.placeholder,
+		PlaceholderFailedText: msg.placeholderFailedText,
+		Request:               item.req,
+	}
+	r.Raw, err = json.Marshal(msg.Raw)
+	return
+}
+
+// message restores the message of r received by adapter.
+func (r batchedRecord) message(adapter ChatAdapter, m *metrics.Metrics) (msg *Message, err error) {
+	ra, ok := adapter.(ResumeAdapter)
+	if !ok {
+		err = fmt.Errorf("%s adapter does not support resuming messages", adapter.Name())
+		return
+	}
+	raw, err := ra.ParseRaw(r.Raw)
+	if err != nil {
+		return
+	}
+	msg = &Message{
+		Platform:              r.Platform,
+		Raw:                   raw,
+		Content:               r.Content,
+		ChatID:                r.ChatID,
+		ChatType:              r.ChatType,
+		UserID:                r.UserID,
+		MessageID:             r.MessageID,
+		LanguageCode:          r.LanguageCode,
+		SenderName:            r.SenderName,
+		SenderUsername:        r.SenderUsername,
+		ChatTitle:             r.ChatTitle,
+		lang:                  r.Lang,
+		detectorName:          r.Detector,
+		placeholder:           r.Placeholder,
+		placeholderFailedText: r.PlaceholderFailedText,
+	}
+	msg.prepare(adapter, m)
+	// In processing since submitted by the previous run
+	msg.onPending()
+	msg.onProcessing()
+	return
+}
+
+// batcher accumulates messages of batched chats until the batch window
+// elapses or the batch is full.
+type batcher struct {
+	mu      sync.Mutex
+	pending []batchItem
+	timer   *time.Timer
+}
+
+// take returns the pending messages, starting a new batch.
+func (bt *batcher) take() (items []batchItem) {
+	if bt.timer != nil {
+		bt.timer.Stop()
+		bt.timer = nil
+	}
+	items, bt.pending = bt.pending, nil
+	return
+}
+
+// batched reports whether msg is translated in a batch.
+func (b *Bot) batched(msg *Message) (conf BatchConfig, ok bool) {
+	b.configMu.RLock()
+	conf = b.batch
+	b.configMu.RUnlock()
+	return conf, conf.Enabled && slices.Contains(conf.Chats, msg.ChatID)
+}
+
+// batchStage hands text messages of batched chats off to the batch.
+func (b *Bot) batchStage(s *messageState) bool {
+	msg := s.msg
+	if conf, ok := b.batched(msg); ok && !msg.vision && !msg.segmented && !isSubtitles(msg.Content) {
+		b.batchMessage(msg, s.req, conf)
+		return false
+	}
+	return true
+}
+
+// batchMessage adds msg to the pending batch, leaving it in processing
+// until the batch completes.
+func (b *Bot) batchMessage(msg *Message, req translator.TranslateRequest, conf BatchConfig) {
+	msg.endTyping()
+	msg.logger.Debug("added to batch")
+
+	bt := b.batcher
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+	bt.pending = append(bt.pending, batchItem{msg: msg, req: req})
+	if len(bt.pending) >= conf.MaxMessages {
+		go b.submitBatch(bt.take())
+		return
+	}
+	if bt.timer == nil {
+		bt.timer = time.AfterFunc(time.Duration(conf.Window)*time.Second, func() {
+			bt.mu.Lock()
+			items := bt.take()
+			bt.mu.Unlock()
+			b.submitBatch(items)
+		})
+	}
+}
+
+// submitBatch translates items in one batch and delivers the translations.
+func (b *Bot) submitBatch(items []batchItem) {
+	if len(items) == 0 {
+		return
+	}
+	b.configMu.RLock()
+	name := b.batch.Translator
+	b.configMu.RUnlock()
+	b.translateBatch(items, name, "")
+}
+
+// resumeBatches waits for the batches submitted by the previous leader,
+// once the adapters are started. Messages of adapters no longer enabled
+// are dropped.
+func (b *Bot) resumeBatches() {
+	var batches batchState
+	b.state.view(func(state *botState) {
+		batches = maps.Clone(state.Batches)
+	})
+	for id, sb := range batches {
+		items := make([]batchItem, len(sb.Items))
+		for i, r := range sb.Items {
+			items[i].req = r.Request
+			var err error
+			adapter := b.adapter(r.Platform)
+			if adapter == nil {
+				err = fmt.Errorf("%s adapter not enabled", r.Platform)
+			} else {
+				items[i].msg, err = r.message(adapter, b.metrics)
+			}
+			if err != nil {
+				logrus.WithField("batch_id", id).Warnf("dropped message of chat %d: %v", r.ChatID, err)
+			}
+		}
+		go b.translateBatch(items, sb.Translator, id)
+	}
+}
+
+// translateBatch translates items in one batch of the named translator,
+// or waits for batch id if not empty, and delivers the translations. The
+// batch is persisted until delivered. Messages of a failed batch or
+// request fail without retries, retrying would take hours again.
+func (b *Bot) translateBatch(items []batchItem, name, id string) {
+	b.configMu.RLock()
+	conf := b.batch
+	b.configMu.RUnlock()
+	ts, release := b.acquireTranslateService()
+	defer release()
+
+	reqs := make([]translator.TranslateRequest, len(items))
+	for i, item := range items {
+		reqs[i] = item.req
+	}
+	opts := translator.BatchOptions{
+		Poll: time.Duration(conf.PollInterval) * time.Second,
+		ID:   id,
+	}
+	if id == "" {
+		opts.OnSubmit = func(submitted string) {
+			id = submitted
+			b.persistBatch(id, name, items)
+		}
+	}
+	ctx := context.Background()
+	start := time.Now()
+	resps, errs, err := ts.TranslateBatch(ctx, name, reqs, opts)
+	if id != "" {
+		b.state.update(func(state *botState) {
+			delete(state.Batches, id)
+		})
+	}
+
+	for i, item := range items {
+		msg := item.msg
+		if msg == nil {
+			continue
+		}
+		msg.addPhase(latencyPhaseTranslate, start)
+		msg.logger = msg.logger.WithField("translator_name", name)
+		itemErr := err
+		if itemErr == nil {
+			itemErr = errs[i]
+		}
+		if itemErr != nil {
+			b.replyError(msg)
+			msg.onMessageHandleFailed()
+			msg.logger.Errorf("an error occurred while translating in batch: %v", itemErr)
+			continue
+		}
+		b.deliverTranslation(ctx, msg, ts, item.req, resps[i], name)
+	}
+}
+
+// persistBatch keeps a submitted batch in the state until delivered.
+func (b *Bot) persistBatch(id, name string, items []batchItem) {
+	sb := &submittedBatch{Translator: name}
+	for _, item := range items {
+		// Kept anyway, requests are identified by their index
+		r, err := newBatchedRecord(item)
+		if err != nil {
+			item.msg.logger.Warnf("message of batch '%s' not persisted: %v", id, err)
+		}
+		sb.Items = append(sb.Items, r)
+	}
+	b.state.update(func(state *botState) {
+		state.Batches[id] = sb
+	})
+}
@@ -1,17 +1,18 @@
 package main
 
 import (
-	"encoding/base64"
-	"errors"
+	"context"
+	"fmt"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
 	"github.com/4O4-Not-F0und/Gura-Bot/translate"
-	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,6 +22,16 @@ const (
 	messageHandleStateFailed       = "failed"
 	messageHandleStateProcessed    = "processed"
 	messageHandleStateProcessing   = "processing"
+	messageHandleStateSkipped      = "skipped"
+	messageHandleStateModerated    = "moderated"
+
+	// Stages retried by scheduleRetry
+	retryStageDetect    = "detect"
+	retryStageTranslate = "translate"
+
+	// Outcomes of retried stages
+	retryOutcomeRecovered = "recovered"
+	retryOutcomeExhausted = "exhausted"
 )
 
 var (
@@ -30,6 +41,8 @@ var (
 		messageHandleStateProcessing,
 		messageHandleStateProcessed,
 		messageHandleStateFailed,
+		messageHandleStateSkipped,
+		messageHandleStateModerated,
 	}
 
 	allChatTypes = []string{
@@ -37,26 +50,168 @@ var (
 		"group",
 		"supergroup",
 		"channel",
+		chatTypeGuild,
+		chatTypeQueue,
 	}
 )
 
 type BotConfig struct {
-	Debug           bool               `yaml:"debug"`
-	Token           string             `yaml:"token"`
+	Debug bool `yaml:"debug"`
+	// Telegram bot token, leave empty to disable Telegram
+	Token           string             `yaml:"token" redact:"true"`
 	MessageSettings BotMessageSettings `yaml:"message_settings"`
-	AllowedChats    []int64            `yaml:"allowed_chats"`
-	WorkerPoolSize  int                `yaml:"worker_pool_size"`
+	// Telegram chat IDs or user IDs
+	AllowedChats   []int64        `yaml:"allowed_chats"`
+	WorkerPoolSize int            `yaml:"worker_pool_size"`
+	QueueSize      int            `yaml:"queue_size"`
+	Priority       PriorityConfig `yaml:"priority"`
+	// "block", "drop_oldest" or "drop_newest"
+	OverflowPolicy string `yaml:"overflow_policy"`
+	// Optional. Replied to messages dropped by "drop_newest"
+	BusyReply    string             `yaml:"busy_reply"`
+	Backpressure BackpressureConfig `yaml:"backpressure"`
+	Debounce     DebounceConfig     `yaml:"debounce"`
+	MemoryGuard  MemoryGuardConfig  `yaml:"memory_guard"`
+	Documents    DocumentConfig     `yaml:"documents"`
+	Voice        VoiceConfig        `yaml:"voice"`
+	Vision       VisionConfig       `yaml:"vision"`
+	Forwards     ForwardConfig      `yaml:"forwards"`
+	Placeholder  PlaceholderConfig  `yaml:"placeholder"`
+	ReplyMode    ReplyModeConfig    `yaml:"reply_mode"`
+	Quota        QuotaConfig        `yaml:"quota"`
+	I18n         I18nConfig         `yaml:"i18n"`
+	Typing       TypingConfig       `yaml:"typing"`
+	ErrorReply   ErrorReplyConfig   `yaml:"error_reply"`
+	Feedback     FeedbackConfig     `yaml:"feedback"`
+	State        StateConfig        `yaml:"state"`
+	Discord      DiscordConfig      `yaml:"discord"`
+	WebhookOut   WebhookOutConfig   `yaml:"webhook_out"`
+	Queue        QueueConfig        `yaml:"queue"`
+
+	UserRateLimit  UserRateLimitConfig  `yaml:"user_rate_limit"`
+	Moderation     ModerationConfig     `yaml:"moderation"`
+	ReplyContext   ReplyContextConfig   `yaml:"reply_context"`
+	PromptMetadata PromptMetadataConfig `yaml:"prompt_metadata"`
+	Summary        SummaryConfig        `yaml:"summary"`
+	Notifications  NotificationsConfig  `yaml:"notifications"`
+	Batch          BatchConfig          `yaml:"batch"`
+	Summarize      SummarizeConfig      `yaml:"summarize"`
+	Digest         DigestConfig         `yaml:"digest"`
+
+	// Requires restart
+	LeaderElection  LeaderElectionConfig  `yaml:"leader_election"`
+	TelegramWebhook TelegramWebhookConfig `yaml:"telegram_webhook"`
 }
 
 type BotMessageSettings struct {
 	DisableNotification bool `yaml:"disable_notification"`
 	DisableLinkPreview  bool `yaml:"disable_link_preview"`
+
+	// Hide translations behind a spoiler, Telegram only
+	Spoiler bool `yaml:"spoiler"`
+
+	// Wrap translations in an expandable blockquote, Telegram only
+	Blockquote bool `yaml:"blockquote"`
+
+	// Append the detected language, its confidence and the translator
+	// to translations, e.g. "🌐 JA · 0.92 · gpt-4o-mini"
+	Footer bool `yaml:"footer"`
 }
 
 func newBotConfig() BotConfig {
 	return BotConfig{
 		MessageSettings: BotMessageSettings{},
 		AllowedChats:    make([]int64, 0),
+		QueueSize:       100,
+		OverflowPolicy:  overflowPolicyBlock,
+		Backpressure: BackpressureConfig{
+			Enabled:       true,
+			CheckInterval: 5,
+		},
+		Debounce: DebounceConfig{
+			WindowMs:    1500,
+			MaxMessages: 10,
+		},
+		MemoryGuard: MemoryGuardConfig{
+			HighWatermark: 0.85,
+			LowWatermark:  0.7,
+			MinWorkers:    1,
+			CheckInterval: 1,
+		},
+		Documents: DocumentConfig{
+			Chats:      make([]int64, 0),
+			Extensions: []string{".txt", ".srt", ".vtt", ".md"},
+			MaxSizeKB:  256,
+			ChunkSize:  4000,
+		},
+		Voice: VoiceConfig{
+			Chats:     make([]int64, 0),
+			MaxSizeKB: 2048,
+		},
+		Vision: VisionConfig{
+			Chats:     make([]int64, 0),
+			MaxSizeKB: 5120,
+		},
+		ReplyContext: ReplyContextConfig{
+			MaxLength: 500,
+		},
+		Summary: SummaryConfig{
+			TopChats: 5,
+		},
+		Summarize: SummarizeConfig{
+			ChatTypes:       make([]string, 0),
+			HistorySize:     200,
+			DefaultMessages: 50,
+			Timeout:         60,
+		},
+		Digest: DigestConfig{
+			Chats:     make([]int64, 0),
+			Interval:  60,
+			MaxPosts:  20,
+			MaxLength: 4000,
+		},
+		Batch: BatchConfig{
+			Chats:        make([]int64, 0),
+			Window:       600,
+			MaxMessages:  1000,
+			PollInterval: 60,
+		},
+		Forwards: ForwardConfig{
+			Chats: make(map[int64]ForwardRule),
+		},
+		Typing: TypingConfig{
+			ChatTypes: []string{"private"},
+			Interval:  5,
+		},
+		Feedback: FeedbackConfig{
+			ChatTypes:  make([]string, 0),
+			MaxAgeDays: 7,
+		},
+		State: StateConfig{
+			SaveInterval: 30,
+		},
+		ErrorReply: ErrorReplyConfig{
+			Templates: map[string]string{
+				"private": "",
+			},
+			SuppressSec: 300,
+		},
+
+		Priority: PriorityConfig{
+			ChatTypes: map[string]string{"private": priorityHigh},
+			Chats:     map[int64]string{},
+		},
+		Discord: DiscordConfig{
+			AllowedGuilds:   make([]string, 0),
+			AllowedChannels: make([]string, 0),
+		},
+		LeaderElection: LeaderElectionConfig{
+			Backend:       leaderElectionFile,
+			RetryInterval: 5,
+		},
+		TelegramWebhook: TelegramWebhookConfig{
+			AllowedSources: telegramSourceRanges,
+		},
 	}
 }
 
@@ -94,137 +249,645 @@ func (ss *SafeSlice[T]) Clone() (s []T) {
 }
 
 type Bot struct {
-	bot              *tgbotapi.BotAPI
-	updatesChan      tgbotapi.UpdatesChannel
+	// Started once this replica is the leader
+	adapters []ChatAdapter
+
+	// Latest config, adapters are created with it
+	config BotConfig
+
+	messages         chan *Message
 	translateService *translate.TranslateService
 	messageSettings  BotMessageSettings
-	allowedChats     *SafeSlice[int64]
+	webhookOut       *WebhookOut
 	workerPoolSize   int
+	queueSize        int
+	priority         PriorityConfig
+	overflowPolicy   string
+	busyReply        string
+	backpressure     BackpressureConfig
+	debounce         DebounceConfig
+	debouncer        *debouncer
+	memoryGuard      *memoryGuard
+	documents        DocumentConfig
+	voice            VoiceConfig
+	vision           VisionConfig
+	forwards         ForwardConfig
+	placeholder      PlaceholderConfig
+	typing           TypingConfig
+	errorReply       ErrorReplyConfig
+	errorReplies     *errorReplies
+	feedback         FeedbackConfig
+	i18n             I18nConfig
+	targetLangs      *targetLangs
+	replyModes       ReplyModeConfig
+	quota            QuotaConfig
+	userRateLimit    UserRateLimitConfig
+	userLimiter      *userLimiter
+	moderator        *moderator
+	replyContextConf ReplyContextConfig
+	promptMeta       PromptMetadataConfig
+	summary          SummaryConfig
+	notifications    NotificationsConfig
+	batch            BatchConfig
+	batcher          *batcher
+	summarizer       *summarizer
+	history          *chatHistory
+	retranslations   *retranslations
+	digest           DigestConfig
+	digests          *digests
+	stages           []messageStage
+	state            *StateStore
 	configMu         *sync.RWMutex
-	stopServeNotify  chan int
+	metrics          *metrics.Metrics
+
+	// Owned by the update loop after ServeBot is called
+	pool       *workerPool
+	poolChange chan *workerPool
+
+	// Messages to be queued again for a retry
+	retries chan *Message
+
+	// Set while the update loop is running
+	serving *atomic.Bool
+
+	// Optional. Leader election
+	elector *LeaderElector
 }
 
-func newBot(config BotConfig, translateService *translate.TranslateService) (bot *Bot, err error) {
-	if config.Token == "" {
-		logrus.Fatal("telegram bot token required")
+func newBot(config BotConfig, translateService *translate.TranslateService, m *metrics.Metrics) (bot *Bot, err error) {
+	if config.Token == "" && !config.Discord.Enabled &&
+		!config.Queue.NATS.Enabled && !config.Queue.Kafka.Enabled {
+		logrus.Fatal("telegram bot token, discord or a message queue required")
 	}
 
-	if config.WorkerPoolSize <= 0 {
-		logrus.Fatalf("invalid 'worker_pool_size': %d", config.WorkerPoolSize)
+	err = config.LeaderElection.Check()
+	if err != nil {
+		return
 	}
-	logrus.Info("authorizing telegram bot")
-
-	var botApi *tgbotapi.BotAPI
-	botApi, err = tgbotapi.NewBotAPI(config.Token)
+	if config.LeaderElection.Enabled && config.Token == "" {
+		err = fmt.Errorf("leader election requires a telegram bot token")
+		return
+	}
+	if config.Token != "" {
+		err = config.TelegramWebhook.Check()
+		if err != nil {
+			return
+		}
+	}
+	elector, err := newLeaderElector(config.LeaderElection, m.Leader)
 	if err != nil {
 		return
 	}
-	logrus.Infof("authorized on account: %s", botApi.Self.UserName)
-	botApi.Debug = config.Debug
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
-	updates := botApi.GetUpdatesChan(u)
+	err = config.State.Check()
+	if err != nil {
+		return
+	}
+	state, err := newStateStore(config.State)
+	if err != nil {
+		return
+	}
 
 	bot = &Bot{
-		bot:              botApi,
-		updatesChan:      updates,
+		messages:         make(chan *Message),
 		translateService: translateService,
 		messageSettings:  config.MessageSettings,
-		allowedChats:     newSafeSlice(config.AllowedChats),
 		workerPoolSize:   config.WorkerPoolSize,
+		queueSize:        config.QueueSize,
 		configMu:         &sync.RWMutex{},
-		stopServeNotify:  make(chan int, 1),
+		metrics:          m,
+		poolChange:       make(chan *workerPool),
+		retries:          make(chan *Message),
+		debouncer:        newDebouncer(),
+		memoryGuard:      newMemoryGuard(m),
+		userLimiter:      newUserLimiter(),
+		batcher:          new(batcher),
+		history:          newChatHistory(),
+		retranslations:   newRetranslations(),
+		digests:          newDigests(),
+		stages:           defaultStages(),
+		errorReplies:     newErrorReplies(),
+		targetLangs:      newTargetLangs(),
+		state:            state,
+		serving:          new(atomic.Bool),
+		elector:          elector,
+	}
+
+	err = bot.InsertStage(stageRoute, messageStage{stageBatch, (*Bot).batchStage})
+	if err != nil {
+		return
 	}
 
 	_, err = bot.loadConfig(config, translateService)
 	if err != nil {
 		return
 	}
+	bot.pool = newWorkerPool(config.WorkerPoolSize, config.QueueSize, bot.handleMessage)
 
 	bot.initMessageMetrics()
+	m.ComponentCooldown.SetSource(bot.componentCooldowns)
+	if elector == nil {
+		err = bot.lead()
+		return
+	}
+	go func() {
+		elector.WaitForLeadership()
+		if err := bot.lead(); err != nil {
+			logrus.Fatal(err)
+		}
+	}()
+	return
+}
+
+// lead starts the adapters and background jobs once this replica is the
+// leader. A new leader reloads the state saved by the previous one.
+func (b *Bot) lead() (err error) {
+	if b.elector != nil {
+		err = b.state.load()
+		if err != nil {
+			return
+		}
+	}
+	b.state.start()
+	b.initFeedbackMetrics()
+
+	b.configMu.Lock()
+	adapters, err := newAdapters(b.config)
+	if err == nil {
+		b.adapters = adapters
+	}
+	b.configMu.Unlock()
+	if err != nil {
+		return
+	}
+
+	go b.runSummaries()
+	go b.runDigests()
+	b.resumeBatches()
+	for _, a := range adapters {
+		go b.receive(a)
+		if ca, ok := a.(CallbackAdapter); ok {
+			go b.handleCallbacks(ca)
+		}
+	}
+	return
+}
+
+// newAdapters creates and starts the adapters enabled by config.
+func newAdapters(config BotConfig) (adapters []ChatAdapter, err error) {
+	if config.Token != "" {
+		var ta *TelegramAdapter
+		ta, err = newTelegramAdapter(config)
+		if err != nil {
+			return
+		}
+		adapters = append(adapters, ta)
+	}
+	if config.Discord.Enabled {
+		var da *DiscordAdapter
+		da, err = newDiscordAdapter(config.Discord)
+		if err != nil {
+			return
+		}
+		adapters = append(adapters, da)
+	}
+	if config.Queue.NATS.Enabled {
+		var na *NATSAdapter
+		na, err = newNATSAdapter(config.Queue.NATS)
+		if err != nil {
+			return
+		}
+		adapters = append(adapters, na)
+	}
+	if config.Queue.Kafka.Enabled {
+		var ka *KafkaAdapter
+		ka, err = newKafkaAdapter(config.Queue.Kafka)
+		if err != nil {
+			return
+		}
+		adapters = append(adapters, ka)
+	}
+
+	if dryRun {
+		logrus.Warn("dry run: replies and webhook output are logged only")
+		for i, a := range adapters {
+			adapters[i] = newDryRunAdapter(a)
+		}
+	}
 	return
 }
 
-func (b *Bot) loadConfig(botConfig BotConfig, translateService *translate.TranslateService) (reServeRequired bool, err error) {
+// Close stops receiving messages and saves the bot state.
+func (b *Bot) Close() {
+	b.configMu.RLock()
+	for _, a := range b.adapters {
+		a.Stop()
+	}
+	b.configMu.RUnlock()
+	if err := b.state.Close(); err != nil {
+		logrus.Errorf("save state failed: %v", err)
+	}
+}
+
+// receive forwards messages of an adapter to the update loop.
+func (b *Bot) receive(adapter ChatAdapter) {
+	for msg := range adapter.ReceiveMessages() {
+		msg.prepare(adapter, b.metrics)
+		b.messages <- msg
+	}
+}
+
+// Check validates the reloadable part of the bot config.
+func (bc *BotConfig) Check() (err error) {
+	err = bc.WebhookOut.Check()
+	if err != nil {
+		return
+	}
+
+	if bc.WorkerPoolSize <= 0 {
+		err = fmt.Errorf("invalid 'worker_pool_size': %d", bc.WorkerPoolSize)
+		return
+	}
+
+	if bc.QueueSize < 0 {
+		err = fmt.Errorf("invalid 'queue_size': %d", bc.QueueSize)
+		return
+	}
+
+	err = bc.Priority.Check()
+	if err != nil {
+		return
+	}
+
+	err = checkOverflowPolicy(bc.OverflowPolicy)
+	if err != nil {
+		return
+	}
+	// An unbuffered queue has no oldest message to drop
+	if bc.OverflowPolicy == overflowPolicyDropOldest && bc.QueueSize == 0 {
+		err = fmt.Errorf("overflow policy '%s' requires a positive 'queue_size'", overflowPolicyDropOldest)
+		return
+	}
+
+	err = bc.Backpressure.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Debounce.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Documents.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Voice.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Vision.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Forwards.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Typing.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.ErrorReply.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Feedback.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.I18n.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.ReplyMode.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Quota.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.UserRateLimit.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Moderation.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.ReplyContext.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.PromptMetadata.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Summary.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Notifications.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Batch.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Summarize.Check()
+	if err != nil {
+		return
+	}
+
+	err = bc.Digest.Check()
+	return
+}
+
+func (b *Bot) loadConfig(botConfig BotConfig, translateService *translate.TranslateService) (poolResizeRequired bool, err error) {
+	err = botConfig.Check()
+	if err != nil {
+		return
+	}
+
+	err = botConfig.MemoryGuard.Check()
+	if err != nil {
+		return
+	}
+
 	logrus.Trace("acquiring bot.configMu")
 	b.configMu.Lock()
 	defer b.configMu.Unlock()
 	logrus.Trace("acquired bot.configMu")
 
-	b.allowedChats.New(botConfig.AllowedChats)
+	for _, a := range b.adapters {
+		a.Reload(botConfig)
+	}
+	b.config = botConfig
 	b.messageSettings = botConfig.MessageSettings
+	b.webhookOut = newWebhookOut(botConfig.WebhookOut)
+	b.priority = botConfig.Priority
+	b.overflowPolicy = botConfig.OverflowPolicy
+	b.busyReply = botConfig.BusyReply
+	b.backpressure = botConfig.Backpressure
+	b.debounce = botConfig.Debounce
+	b.memoryGuard.Reload(botConfig.MemoryGuard)
+	b.documents = botConfig.Documents
+	b.voice = botConfig.Voice
+	b.vision = botConfig.Vision
+	b.forwards = botConfig.Forwards
+	b.placeholder = botConfig.Placeholder
+	b.typing = botConfig.Typing
+	b.errorReply = botConfig.ErrorReply
+	b.feedback = botConfig.Feedback
+	b.i18n = botConfig.I18n
+	b.replyModes = botConfig.ReplyMode
+	b.quota = botConfig.Quota
+	b.userRateLimit = botConfig.UserRateLimit
+	b.moderator = newModerator(botConfig.Moderation)
+	b.replyContextConf = botConfig.ReplyContext
+	b.promptMeta = botConfig.PromptMetadata
+	b.summary = botConfig.Summary
+	b.notifications = botConfig.Notifications
+	b.batch = botConfig.Batch
+	b.summarizer = newSummarizer(botConfig.Summarize)
+	b.digest = botConfig.Digest
 	b.translateService = translateService
-	reServeRequired = b.workerPoolSize != botConfig.WorkerPoolSize
+	poolResizeRequired = b.workerPoolSize != botConfig.WorkerPoolSize ||
+		b.queueSize != botConfig.QueueSize
 	b.workerPoolSize = botConfig.WorkerPoolSize
+	b.queueSize = botConfig.QueueSize
 
 	logrus.Trace("released bot.configMu")
 	return
 }
 
 func (b *Bot) Reload(botConfig BotConfig, translateService *translate.TranslateService) (err error) {
-	var reServeRequired bool
-	reServeRequired, err = b.loadConfig(botConfig, translateService)
+	var poolResizeRequired bool
+	poolResizeRequired, err = b.loadConfig(botConfig, translateService)
 	if err != nil {
 		return
 	}
 
-	if reServeRequired {
-		logrus.Info("re-serve bot required, attempting to restart bot loop")
-		b.stopServeNotify <- 1
-		go b.ServeBot()
+	if poolResizeRequired {
+		logrus.Info("worker pool resize required, replacing worker pool")
+		b.poolChange <- newWorkerPool(botConfig.WorkerPoolSize, botConfig.QueueSize, b.handleMessage)
 	}
 
 	return
 }
 
-// ServeBot starts the bot's main loop for receiving and processing updates.
+// ServeBot starts the bot's main loop for receiving updates
+// and queueing them to the worker pool.
 func (b *Bot) ServeBot() {
-	q := make(chan int, b.workerPoolSize)
-
-	logrus.Infof("begin update loop, queue size: %d", b.workerPoolSize)
+	logrus.Info("begin update loop")
+	b.serving.Store(true)
 	defer func() {
+		b.serving.Store(false)
 		logrus.Info("stopped update loop")
 	}()
-	for update := range b.updatesChan {
+	for {
+		b.waitForTranslators()
+
 		select {
-		case <-b.stopServeNotify:
+		case pool := <-b.poolChange:
+			b.replacePool(pool)
+		case msg := <-b.retries:
+			b.submit(msg)
+		case msg := <-b.debouncer.out:
+			msg.onPending()
+			b.startTyping(msg)
+			b.submit(msg)
+		case msg := <-b.messages:
+			b.configMu.RLock()
+			debounce := b.debounce
+			skip := b.forwards.filter(msg)
+			msg.translateDoc = b.documents.accepts(msg)
+			msg.transcribe = msg.Content == "" && b.voice.accepts(msg) &&
+				b.translateService.TranscriptionAvailable()
+			msg.vision = b.vision.accepts(msg) && b.translateService.VisionAvailable()
+			b.configMu.RUnlock()
+
+			if skip != "" {
+				msg.onSkipped(skip)
+				continue
+			}
+			// Texts of queues are never commands
+			if msg.ChatType != chatTypeQueue {
+				_, _, msg.command = parseCommand(msg.Content)
+			}
+			media := msg.translateDoc || msg.transcribe || msg.vision
+			if !msg.command && !media {
+				if ct := msg.contentType(); ct != contentTypeText {
+					msg.onSkipped(ct)
+					continue
+				}
+			}
+			// Only messages to be translated count against the limits
+			if !msg.command && !b.checkUserRate(msg) {
+				msg.onSkipped(contentTypeRateLimited)
+				continue
+			}
+			if !msg.command && !b.checkQuota(msg) {
+				msg.onSkipped(contentTypeQuota)
+				continue
+			}
+			if msg.command || media {
+				msg.onPending()
+				b.startTyping(msg)
+				b.submit(msg)
+				continue
+			}
+
+			if debounce.Enabled && msg.UserID != 0 {
+				b.debouncer.Add(msg, debounce)
+				continue
+			}
+
+			msg.onPending()
+			b.startTyping(msg)
+			b.submit(msg)
+		}
+	}
+}
+
+// submit queues msg to the worker pool. If the queue is full,
+// the configured overflow policy applies.
+func (b *Bot) submit(msg *Message) {
+	b.configMu.RLock()
+	high := b.priority.isHigh(msg)
+	policy := b.overflowPolicy
+	b.configMu.RUnlock()
+
+	select {
+	case b.pool.queue(high) <- msg:
+		b.metrics.Saturation.WithLabelValues(saturationQueueFull).Set(0)
+		return
+	default:
+		b.metrics.Saturation.WithLabelValues(saturationQueueFull).Set(1)
+	}
+
+	switch policy {
+	case overflowPolicyDropNewest:
+		msg.onDropped(policy)
+		go b.replyBusy(msg)
+	case overflowPolicyDropOldest:
+		for {
+			select {
+			case b.pool.queue(high) <- msg:
+				return
+			default:
+			}
+			select {
+			case old := <-b.pool.queue(high):
+				old.onDropped(policy)
+			default:
+			}
+		}
+	default:
+		for {
+			select {
+			case b.pool.queue(high) <- msg:
+				return
+			case pool := <-b.poolChange:
+				b.replacePool(pool)
+			}
+		}
+	}
+}
+
+// waitForTranslators pauses the update loop while all translators are
+// disabled, so messages are left to the chat platform instead of piling up.
+func (b *Bot) waitForTranslators() {
+	paused := false
+	for {
+		b.configMu.RLock()
+		bc := b.backpressure
+		ts := b.translateService
+		b.configMu.RUnlock()
+
+		if !bc.Enabled || ts.TranslatorAvailable() {
+			if paused {
+				logrus.Info("translator available, resumed receiving messages")
+			}
+			b.metrics.Saturation.WithLabelValues(saturationTranslatorsUnavailable).Set(0)
 			return
-		default:
 		}
 
-		var msg *Message
-		if update.Message != nil {
-			msg = newMessage(update.Message)
-		} else if update.ChannelPost != nil {
-			msg = newMessage(update.ChannelPost)
-		} else {
-			continue
+		if !paused {
+			logrus.Warn("all translators are disabled, paused receiving messages")
+			b.metrics.Saturation.WithLabelValues(saturationTranslatorsUnavailable).Set(1)
+			paused = true
 		}
 
-		if msg.Content == "" {
-			msg.logger.Debug("message text undetected")
-			continue
+		select {
+		case pool := <-b.poolChange:
+			b.replacePool(pool)
+		case <-time.After(time.Duration(bc.CheckInterval) * time.Second):
 		}
+	}
+}
 
-		msg.onPending()
-		logrus.Trace("acquiring queue")
-		q <- 1
-		msg.onProcessing()
-		logrus.Trace("acquired queue")
+// replyBusy tells the sender of a dropped message the bot is busy, if configured.
+func (b *Bot) replyBusy(msg *Message) {
+	b.configMu.RLock()
+	text := b.busyReply
+	replyOpts := ReplyOptions{
+		DisableNotification: b.messageSettings.DisableNotification,
+		DisableLinkPreview:  b.messageSettings.DisableLinkPreview,
+	}
+	b.configMu.RUnlock()
 
-		go func(m *Message) {
-			b.handleMessage(m)
-			<-q
-			logrus.Trace("released queue")
-		}(msg)
+	if text == "" || !b.isAllowed(msg) {
+		return
 	}
+	_, err := msg.adapter.Reply(msg, text, replyOpts)
+	if err != nil {
+		msg.logger.Errorf("an error occurred while replying busy message: %v", err)
+	}
+}
+
+// replacePool switches the update loop to a new worker pool.
+// Workers of the old pool exit after finishing its queued messages.
+func (b *Bot) replacePool(pool *workerPool) {
+	b.pool.Close()
+	b.pool = pool
 }
 
-// handleMessage processes a single incoming Telegram message.
-// It checks for authorization, extracts text, detects language,
-// translates, and sends a reply.
+// handleMessage runs msg through the stages of the pipeline, in order,
+// until a stage completes or hands it off.
 func (b *Bot) handleMessage(msg *Message) {
+	b.memoryGuard.acquire()
+	defer b.memoryGuard.release()
 	defer func() {
 		if r := recover(); r != nil {
 			msg.logger.Errorf("panic recovered in handleMessage: %v", r)
@@ -232,83 +895,178 @@ func (b *Bot) handleMessage(msg *Message) {
 		}
 	}()
 
-	if !b.isAllowed(msg) {
-		msg.onUnauthorized()
-		return
+	ts, release := b.acquireTranslateService()
+	defer release()
+	s := &messageState{
+		ctx: context.Background(),
+		msg: msg,
+		ts:  ts,
 	}
+	for _, stage := range b.stages {
+		if !stage.run(b, s) {
+			return
+		}
+	}
+}
 
-	langResp, detectorName, err := b.translateService.DetectLang(detector.DetectRequest{
-		Text:    msg.Content,
-		TraceId: msg.TraceId,
+// deliverTranslation replies resp, the translation of req by translator
+// translatorName, to msg, or
+// posts it to the output webhook, and completes msg.
+func (b *Bot) deliverTranslation(ctx context.Context, msg *Message, ts *translate.TranslateService, req translator.TranslateRequest, resp *translator.TranslateResponse, translatorName string) {
+	msg.logger = msg.logger.WithFields(logrus.Fields{
+		"usage_completion_tokens": resp.TokenUsage.Completion,
+		"usage_prompt_tokens":     resp.TokenUsage.Prompt,
+		"usage_cached_tokens":     resp.TokenUsage.CachedPrompt,
 	})
-	if detectorName != "" {
-		msg.logger = msg.logger.WithField("detector_name", detectorName)
+
+	b.configMu.RLock()
+	replyOpts := ReplyOptions{
+		DisableNotification: b.messageSettings.DisableNotification,
+		DisableLinkPreview:  b.messageSettings.DisableLinkPreview,
+		Spoiler:             b.messageSettings.Spoiler,
+		Blockquote:          b.messageSettings.Blockquote,
 	}
-	if langResp != nil {
-		msg.logger = msg.logger.WithFields(logrus.Fields{
-			"lang":            langResp.Language,
-			"lang_confidence": langResp.Confidence,
-		})
+	footer := b.messageSettings.Footer
+	webhookOut := b.webhookOut
+	b.configMu.RUnlock()
+	feedback := b.feedbackEnabled(msg)
+	if feedback {
+		replyOpts.Buttons = feedbackButtons()
 	}
-	if err != nil {
-		msg.logger.Warn(err)
-		msg.onMessageHandleFailed()
-		return
+	retranslate := b.retranslateEnabled(msg)
+	if retranslate {
+		replyOpts.Buttons = append(replyOpts.Buttons, b.retranslateButton(msg))
 	}
 
-	resp, translatorName, err := b.translateService.Translate(translator.TranslateRequest{
-		Text:    msg.Content,
-		TraceId: msg.TraceId,
-	})
-	if translatorName != "" {
-		msg.logger = msg.logger.WithField("translator_name", translatorName)
+	lang := msg.lang
+	if lang == nil {
+		lang = &detector.DetectResponse{}
+	}
+	if footer {
+		replyOpts.Footer = detectionFooter(lang, translatorName)
 	}
-	if err != nil {
-		msg.onMessageHandleFailed()
 
-		var te = new(common.HTTPError)
-		if errors.As(err, &te) {
-			msg.logger.Debugf("http request: %s", base64.StdEncoding.EncodeToString(te.DumpRequest(true)))
-			msg.logger.Debugf("http response: %s", base64.StdEncoding.EncodeToString(te.DumpResponse(true)))
+	if webhookOut != nil && dryRun {
+		msg.logger.WithField("dry_run", true).Info("webhook output not posted")
+	} else if webhookOut != nil {
+		err := webhookOut.Post(ctx, WebhookOutPayload{
+			Platform:           msg.Platform,
+			ChatID:             msg.ChatID,
+			ChatType:           msg.ChatType,
+			UserID:             msg.UserID,
+			MessageID:          msg.MessageID,
+			TraceId:            msg.TraceId,
+			Original:           msg.Content,
+			Translation:        resp.Text,
+			SourceLanguage:     lang.Language,
+			LanguageConfidence: lang.Confidence,
+			LanguageCandidates: lang.Candidates,
+			DetectorName:       msg.detectorName,
+			TranslatorName:     translatorName,
+			CompletionTokens:   resp.TokenUsage.Completion,
+			PromptTokens:       resp.TokenUsage.Prompt,
+		})
+		if err != nil {
+			msg.onMessageHandleFailed()
+			msg.logger.Errorf("an error occurred while posting webhook: %v", err)
+			return
 		}
-		msg.logger.Errorf("an error occurred while translating: %v", err)
-		return
 	}
 
-	msg.logger = msg.logger.WithFields(logrus.Fields{
-		"usage_completion_tokens": resp.TokenUsage.Completion,
-		"usage_prompt_tokens":     resp.TokenUsage.Prompt,
-	})
+	if b.digested(msg) {
+		b.addToDigest(msg, resp.Text)
+	} else if webhookOut == nil || !webhookOut.ReplaceReply() {
+		start := time.Now()
+		sent, err := b.deliver(msg, resp.Text, replyOpts)
+		msg.addPhase(latencyPhaseSend, start)
+		if err != nil {
+			msg.onMessageHandleFailed()
+			msg.logger.Errorf("an error occurred while replying message: %v", err)
+			return
+		}
+		if feedback {
+			b.trackFeedback(msg, sent, translatorName, resp.PromptVariant)
+		}
+		if retranslate {
+			b.trackRetranslation(msg, sent, req, replyOpts, footer, translatorName)
+		}
+	}
+	msg.onTranslated(lang.Language, resp.TargetLang)
+	b.targetLangs.set(msg.ChatID, resp.TargetLang)
+	if resp.PromptVariant != "" {
+		b.metrics.PromptVariantTranslations.WithLabelValues(translatorName, resp.PromptVariant).Inc()
+	}
+	b.recordUsage(msg, translatorName, resp.TokenUsage.Prompt, resp.TokenUsage.Completion, ts.Cost(translatorName, resp))
+	msg.logger.Info("completed")
+	msg.onSuccess()
+}
 
-	reply := tgbotapi.NewMessage(msg.Chat.ID, resp.Text)
+// detectionFooter describes how a message was translated,
+// e.g. "🌐 JA · 0.92 · gpt-4o-mini".
+func detectionFooter(lang *detector.DetectResponse, translatorName string) string {
+	var parts []string
+	if lang.Language != "" {
+		parts = append(parts, strings.ToUpper(lang.Language), fmt.Sprintf("%.2f", lang.Confidence))
+	}
+	parts = append(parts, translatorName)
+	return "🌐 " + strings.Join(parts, " · ")
+}
+
+func (b *Bot) getTranslateService() *translate.TranslateService {
 	b.configMu.RLock()
-	reply.DisableNotification = b.messageSettings.DisableNotification
-	reply.DisableWebPagePreview = b.messageSettings.DisableLinkPreview
-	b.configMu.RUnlock()
-	reply.ReplyToMessageID = msg.MessageID
+	defer b.configMu.RUnlock()
+	return b.translateService
+}
 
-	_, err = b.bot.Send(reply)
-	if err != nil {
-		msg.onMessageHandleFailed()
-		msg.logger.Errorf("an error occurred while replying message: %v", err)
+// acquireTranslateService returns the current service, kept open after a
+// reload until release is called. Acquiring holds configMu, so the
+// replaced service is never acquired once Reload returns.
+func (b *Bot) acquireTranslateService() (ts *translate.TranslateService, release func()) {
+	b.configMu.RLock()
+	defer b.configMu.RUnlock()
+	return b.translateService, b.translateService.Acquire()
+}
+
+// scheduleRetry queues msg again after the retry cooldown if err is retryable,
+// so the worker is free for other messages meanwhile.
+// It returns false if no more retries are allowed.
+func (b *Bot) scheduleRetry(msg *Message, ts *translate.TranslateService, err error, stage string, retries *int) bool {
+	if !ts.Retryable(err, *retries) {
+		if *retries > 0 {
+			msg.logger.Errorf("no more retries: maximum retries exceeded after %d attempts", *retries)
+		}
+		msg.onRetriesDone(stage, *retries, retryOutcomeExhausted)
+		return false
 	}
-	msg.logger.Info("completed")
-	msg.onSuccess()
+
+	*retries += 1
+	cooldown := ts.RetryCooldown()
+	msg.logger.Warnf("%v. Retry attempt %d/%d in %s", err, *retries, ts.MaximumRetry, cooldown)
+	msg.onRetryScheduled(stage)
+	// The retry acquires the current service once queued
+	release := ts.Acquire()
+	time.AfterFunc(cooldown, func() {
+		defer release()
+		b.retries <- msg
+	})
+	return true
 }
 
 func (b *Bot) initMessageMetrics() {
 	for _, ct := range allChatTypes {
 		for _, state := range allMessageStates {
-			metrics.MetricMessages.WithLabelValues(state, ct).Set(0)
+			b.metrics.Messages.WithLabelValues(state, ct).Set(0)
 		}
 	}
+	b.metrics.Saturation.WithLabelValues(saturationQueueFull).Set(0)
+	b.metrics.Saturation.WithLabelValues(saturationTranslatorsUnavailable).Set(0)
+	for _, stage := range []string{retryStageDetect, retryStageTranslate} {
+		b.metrics.RetriesScheduled.WithLabelValues(stage).Add(0)
+	}
 
 	logrus.Info("all bot metrics initialized")
 }
 
 func (b *Bot) isAllowed(message *Message) bool {
-	if message.Chat.Type == "private" {
-		return b.allowedChats.Contains(message.From.ID)
-	}
-	return b.allowedChats.Contains(message.Chat.ID)
+	return message.adapter.IsAllowed(message)
 }
@@ -3,83 +3,251 @@ package main
 import (
 	"crypto/md5"
 	"fmt"
-	"strconv"
+	"time"
+	"unicode"
 
 	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	contentTypeText     = "text"
+	contentTypeEmoji    = "emoji"
+	contentTypeSticker  = "sticker"
+	contentTypeDice     = "dice"
+	contentTypeLocation = "location"
+	contentTypeMedia    = "media"
+	contentTypeOther    = "other"
+
+	// Not translated because of forward rules
+	contentTypeForward    = "forward"
+	contentTypeNotForward = "not_forward"
+	// Not translated because the daily quota of the chat is used up
+	contentTypeQuota = "quota"
+	// Not translated because the sender exceeded the user rate limit
+	contentTypeRateLimited = "rate_limited"
+
+	// Label of languages not detected or configured
+	langUnknown = "unknown"
+)
+
+// Message is a platform independent incoming chat message.
+// Adapters fill the exported fields, the bot sets up the rest.
 type Message struct {
-	*tgbotapi.Message
-	logger   *logrus.Entry
-	Content  string
-	ChatId   string
-	ChatType string
-	TraceId  string
-}
-
-func newMessage(message *tgbotapi.Message) *Message {
-	logger := logrus.WithFields(logrus.Fields{
-		"chat_type": message.Chat.Type,
-		"chat_id":   message.Chat.ID,
-	})
+	logger  *logrus.Entry
+	metrics *metrics.Metrics
+	adapter ChatAdapter
 
-	if message.From != nil {
-		logger = logger.WithField("user_id", message.From.ID)
-	}
+	// Name of the adapter which received the message
+	Platform string
+	// Platform specific message, e.g. *tgbotapi.Message
+	Raw any
 
-	var text string
-	if len(message.Text) > 0 {
-		text = message.Text
-	} else if len(message.Caption) > 0 {
-		text = message.Caption
-	}
+	Content string
+	// Set by adapters for messages without text, e.g. contentTypeSticker
+	ContentType string
+	// Optional. Origin of a forwarded message, e.g. forwardOriginChannel
+	ForwardOrigin string
+	// Optional. Attached file
+	Document *Document
+	// Optional. Voice note
+	Voice *Document
+	// Optional. Photo, the largest size available
+	Photo *Document
+	// Optional. Text of the message replied to
+	ReplyTo string
+
+	ChatID    int64
+	ChatType  string
+	UserID    int64
+	MessageID int64
+	TraceId   string
+
+	// Optional. IETF language tag of the sender's client, e.g. "en-US"
+	LanguageCode string
+
+	// Optional. Display name and username of the sender, title of the chat
+	SenderName     string
+	SenderUsername string
+	ChatTitle      string
+
+	// Optional. Called once the message is handled, whatever the outcome,
+	// e.g. to commit its offset
+	OnDone func()
+
+	// Handling state kept between retry attempts
+	lang             *detector.DetectResponse
+	detectorName     string
+	segmented        bool
+	translateDoc     bool
+	transcribe       bool
+	vision           bool
+	image            []byte
+	imageType        string
+	detectRetries    int
+	translateRetries int
+	historyRecorded  bool
+	moderated        bool
 
-	m := &Message{
-		Message:  message,
-		logger:   logger,
-		Content:  text,
-		ChatType: message.Chat.Type,
-		ChatId:   strconv.FormatInt(message.Chat.ID, 10),
+	// Bot command, handled instead of translated
+	command bool
+
+	// Optional. Reply edited with the translation
+	placeholder           *SentReply
+	placeholderFailedText string
+
+	// Closed once the message is processed
+	stopTyping chan struct{}
+
+	times messageTimes
+}
+
+// prepare sets up logging, metrics and trace id of a received message.
+func (m *Message) prepare(adapter ChatAdapter, metrics *metrics.Metrics) {
+	m.adapter = adapter
+	m.metrics = metrics
+	m.times.received = time.Now()
+	m.logger = logrus.WithFields(logrus.Fields{
+		"platform":  m.Platform,
+		"chat_type": m.ChatType,
+		"chat_id":   m.ChatID,
+	})
+
+	if m.UserID != 0 {
+		m.logger = m.logger.WithField("user_id", m.UserID)
 	}
+
 	m.TraceId = m.traceId()
 	m.logger = m.logger.WithField("trace_id", m.TraceId)
-	return m
 }
 
 func (m *Message) traceId() string {
 	h := md5.New()
 	var b []byte
-	h.Write(fmt.Appendf(b, "%s%d", m.ChatId, m.MessageID))
+	h.Write(fmt.Appendf(b, "%d%d", m.ChatID, m.MessageID))
 	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
+// routingKey keeps the chat on the same translator with the
+// consistent_hash selector. IDs of platforms may collide, so the platform
+// is part of it.
+func (m *Message) routingKey() string {
+	return fmt.Sprintf("%s:%d", m.Platform, m.ChatID)
+}
+
+// contentType classifies the message, anything but contentTypeText
+// is skipped without translation.
+func (m *Message) contentType() string {
+	if m.ContentType != "" && m.ContentType != contentTypeText {
+		return m.ContentType
+	}
+	if m.Content == "" {
+		return contentTypeOther
+	}
+	for _, r := range m.Content {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return contentTypeText
+		}
+	}
+	// Only emoji, symbols, punctuation and whitespace
+	return contentTypeEmoji
+}
+
 func (m *Message) onMessageHandleFailed() {
-	metrics.MetricMessages.WithLabelValues(messageHandleStateFailed, m.ChatType).Inc()
+	m.metrics.Messages.WithLabelValues(messageHandleStateFailed, m.ChatType).Inc()
+	m.observeLatency(latencyOutcomeFailed)
 	m.onProcessed()
+	m.failPlaceholder()
 }
 
 func (m *Message) onUnauthorized() {
-	metrics.MetricMessages.WithLabelValues(messageHandleStateUnauthorized, m.ChatType).Inc()
+	m.metrics.Messages.WithLabelValues(messageHandleStateUnauthorized, m.ChatType).Inc()
 	m.onProcessed()
 	m.logger.Infoln("disallowed message source")
 }
 
+func (m *Message) onDropped(policy string) {
+	m.metrics.Messages.WithLabelValues(messageHandleStatePending, m.ChatType).Dec()
+	m.metrics.MessagesDropped.WithLabelValues(policy, m.ChatType).Inc()
+	m.addPhase(latencyPhaseQueue, m.times.pendingSince)
+	m.observeLatency(latencyOutcomeDropped)
+	m.failPlaceholder()
+	m.endTyping()
+	m.logger.Warnf("worker queue full, message dropped by policy: %s", policy)
+	m.onDone()
+}
+
+// onRetryScheduled moves a message back to pending while waiting for a retry.
+func (m *Message) onRetryScheduled(stage string) {
+	m.metrics.Messages.WithLabelValues(messageHandleStateProcessing, m.ChatType).Dec()
+	m.metrics.Messages.WithLabelValues(messageHandleStatePending, m.ChatType).Inc()
+	m.metrics.RetriesScheduled.WithLabelValues(stage).Inc()
+	m.times.pendingSince = time.Now()
+}
+
+// onRetriesDone records the outcome of a stage retried at least once.
+func (m *Message) onRetriesDone(stage string, retries int, outcome string) {
+	if retries > 0 {
+		m.metrics.RetryOutcomes.WithLabelValues(stage, outcome).Observe(float64(retries))
+	}
+}
+
+// onSkipped counts a message not worth translating, before it is queued.
+func (m *Message) onSkipped(contentType string) {
+	m.metrics.Messages.WithLabelValues(messageHandleStateSkipped, m.ChatType).Inc()
+	m.metrics.MessagesSkipped.WithLabelValues(contentType, m.ChatType).Inc()
+	m.logger.Debugf("skipped message of content type: %s", contentType)
+	m.onDone()
+}
+
+// onModerated completes a message skipped by moderation.
+func (m *Message) onModerated() {
+	m.metrics.Messages.WithLabelValues(messageHandleStateModerated, m.ChatType).Inc()
+	m.observeLatency(latencyOutcomeModerated)
+	m.onProcessed()
+	m.failPlaceholder()
+}
+
+// onTranslated counts a translated message by language pair. Languages
+// not detected or configured are "unknown".
+func (m *Message) onTranslated(sourceLang, targetLang string) {
+	if sourceLang == "" {
+		sourceLang = langUnknown
+	}
+	if targetLang == "" {
+		targetLang = langUnknown
+	}
+	m.metrics.Translations.WithLabelValues(sourceLang, targetLang).Inc()
+}
+
 func (m *Message) onPending() {
-	metrics.MetricMessages.WithLabelValues(messageHandleStatePending, m.ChatType).Inc()
+	m.metrics.Messages.WithLabelValues(messageHandleStatePending, m.ChatType).Inc()
+	m.times.pendingSince = time.Now()
 }
 
 func (m *Message) onProcessing() {
-	metrics.MetricMessages.WithLabelValues(messageHandleStatePending, m.ChatType).Dec()
-	metrics.MetricMessages.WithLabelValues(messageHandleStateProcessing, m.ChatType).Inc()
+	m.metrics.Messages.WithLabelValues(messageHandleStatePending, m.ChatType).Dec()
+	m.metrics.Messages.WithLabelValues(messageHandleStateProcessing, m.ChatType).Inc()
+	m.addPhase(latencyPhaseQueue, m.times.pendingSince)
 }
 
 func (m *Message) onSuccess() {
-	metrics.MetricMessages.WithLabelValues(messageHandleStateProcessed, m.ChatType).Inc()
+	m.metrics.Messages.WithLabelValues(messageHandleStateProcessed, m.ChatType).Inc()
+	m.observeLatency(latencyOutcomeSuccess)
 	m.onProcessed()
 }
 
 func (m *Message) onProcessed() {
-	metrics.MetricMessages.WithLabelValues(messageHandleStateProcessing, m.ChatType).Dec()
+	m.metrics.Messages.WithLabelValues(messageHandleStateProcessing, m.ChatType).Dec()
+	m.endTyping()
+	m.onDone()
+}
+
+// onDone calls OnDone at most once.
+func (m *Message) onDone() {
+	if f := m.OnDone; f != nil {
+		m.OnDone = nil
+		f()
+	}
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+)
+
+type benchResult struct {
+	latencies        []time.Duration
+	errors           int
+	promptTokens     int64
+	completionTokens int64
+}
+
+// runBenchCommand sends texts from a corpus file to translator instances and
+// reports latency percentiles, error rates and token usage per instance.
+func runBenchCommand(args []string) (err error) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	conf := fs.String("config", configFile, "path to config file")
+	corpus := fs.String("corpus", "", "file with one text per line")
+	names := fs.String("translators", "", "comma separated translator names, defaults to all")
+	requests := fs.Int("requests", 20, "number of requests per translator")
+	concurrency := fs.Int("concurrency", 4, "concurrent requests per translator")
+	fs.Parse(args)
+
+	if *requests <= 0 || *concurrency <= 0 {
+		err = fmt.Errorf("-requests and -concurrency must be positive")
+		return
+	}
+
+	texts, err := readCorpus(*corpus)
+	if err != nil {
+		return
+	}
+
+	ts, err := newCommandTranslateService(*conf)
+	if err != nil {
+		return
+	}
+	defer ts.Close()
+
+	targets := ts.TranslatorNames()
+	if *names != "" {
+		targets = strings.Split(*names, ",")
+		for _, name := range targets {
+			if !slices.Contains(ts.TranslatorNames(), name) {
+				err = fmt.Errorf("translator not found: %s", name)
+				return
+			}
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "TRANSLATOR\tREQUESTS\tERRORS\tP50\tP90\tP99\tMAX\tPROMPT TOKENS\tCOMPLETION TOKENS\t")
+	for _, name := range targets {
+		start := time.Now()
+		r := benchTranslator(name, texts, *requests, *concurrency, func(ctx context.Context, req translator.TranslateRequest) (*translator.TranslateResponse, error) {
+			return ts.TranslateWith(ctx, name, req)
+		})
+		fmt.Fprintf(os.Stderr, "%s finished in %s\n", name, time.Since(start).Round(time.Millisecond))
+
+		slices.Sort(r.latencies)
+		fmt.Fprintf(w, "%s\t%d\t%d (%.1f%%)\t%s\t%s\t%s\t%s\t%d\t%d\t\n",
+			name, *requests, r.errors, float64(r.errors)*100/float64(*requests),
+			percentile(r.latencies, 0.5), percentile(r.latencies, 0.9), percentile(r.latencies, 0.99),
+			percentile(r.latencies, 1), r.promptTokens, r.completionTokens)
+	}
+	return w.Flush()
+}
+
+func benchTranslator(name string, texts []string, requests, concurrency int,
+	translate func(context.Context, translator.TranslateRequest) (*translator.TranslateResponse, error)) (r benchResult) {
+	jobs := make(chan int)
+	mu := new(sync.Mutex)
+	wg := new(sync.WaitGroup)
+
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				start := time.Now()
+				resp, err := translate(context.Background(), translator.TranslateRequest{
+					Text:    texts[i%len(texts)],
+					TraceId: fmt.Sprintf("bench-%s-%d", name, i),
+				})
+				elapsed := time.Since(start)
+
+				mu.Lock()
+				if err != nil {
+					r.errors += 1
+				} else {
+					r.latencies = append(r.latencies, elapsed)
+					r.promptTokens += resp.TokenUsage.Prompt
+					r.completionTokens += resp.TokenUsage.Completion
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range requests {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return
+}
+
+// percentile returns the p-th percentile of sorted latencies of successful requests.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	i = max(0, min(i, len(sorted)-1))
+	return sorted[i].Round(time.Millisecond)
+}
+
+func readCorpus(path string) (texts []string, err error) {
+	if path == "" {
+		err = fmt.Errorf("-corpus is required")
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			texts = append(texts, line)
+		}
+	}
+	err = scanner.Err()
+	if err == nil && len(texts) == 0 {
+		err = fmt.Errorf("corpus '%s' is empty", path)
+	}
+	return
+}
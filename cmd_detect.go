@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+)
+
+// runDetectCommand runs every configured detector once and prints their results.
+func runDetectCommand(args []string) (err error) {
+	fs := flag.NewFlagSet("detect", flag.ExitOnError)
+	conf := fs.String("config", configFile, "path to config file")
+	text := fs.String("text", "", "text to detect")
+	fs.Parse(args)
+
+	if *text == "" {
+		err = fmt.Errorf("-text is required")
+		return
+	}
+
+	ts, err := newCommandTranslateService(*conf)
+	if err != nil {
+		return
+	}
+	defer ts.Close()
+
+	ctx := context.Background()
+	req := detector.DetectRequest{Text: *text, TraceId: "cli"}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DETECTOR\tLANGUAGE\tCONFIDENCE\tCANDIDATES\tELAPSED\tRESULT")
+	for _, name := range ts.DetectorNames() {
+		start := time.Now()
+		resp, detectErr := ts.DetectWith(ctx, name, req)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if detectErr != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t%s\t%v\n", name, elapsed, detectErr)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%.4f\t%s\t%s\tok\n", name, resp.Language, resp.Confidence, formatCandidates(resp.Candidates), elapsed)
+	}
+	return w.Flush()
+}
+
+// formatCandidates lists candidates as "JA:0.91 ZH:0.07".
+func formatCandidates(candidates []detector.LanguageCandidate) string {
+	parts := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		parts = append(parts, fmt.Sprintf("%s:%.2f", c.Language, c.Confidence))
+	}
+	return strings.Join(parts, " ")
+}
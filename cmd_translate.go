@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+	"github.com/sirupsen/logrus"
+)
+
+// runTranslateCommand detects and translates a text once and prints the result.
+func runTranslateCommand(args []string) (err error) {
+	fs := flag.NewFlagSet("translate", flag.ExitOnError)
+	conf := fs.String("config", configFile, "path to config file")
+	text := fs.String("text", "", "text to translate")
+	translatorName := fs.String("translator", "", "use this translator instead of the selector")
+	fs.Parse(args)
+
+	if *text == "" {
+		err = fmt.Errorf("-text is required")
+		return
+	}
+
+	ts, err := newCommandTranslateService(*conf)
+	if err != nil {
+		return
+	}
+	defer ts.Close()
+
+	ctx := context.Background()
+	traceId := "cli"
+
+	start := time.Now()
+	lang, detectorName, err := ts.DetectLang(ctx, detector.DetectRequest{Text: *text, TraceId: traceId})
+	if err != nil {
+		err = fmt.Errorf("detect failed: %w", err)
+		return
+	}
+	fmt.Printf("detector:    %s (%s)\n", detectorName, time.Since(start).Round(time.Millisecond))
+	fmt.Printf("language:    %s (confidence %.4f)\n", lang.Language, lang.Confidence)
+	fmt.Printf("candidates:  %s\n", formatCandidates(lang.Candidates))
+
+	req := translator.TranslateRequest{Text: *text, TraceId: traceId}
+	start = time.Now()
+	var resp *translator.TranslateResponse
+	name := *translatorName
+	if name != "" {
+		resp, err = ts.TranslateWith(ctx, name, req)
+	} else {
+		resp, name, err = ts.Translate(ctx, req)
+	}
+	if err != nil {
+		err = fmt.Errorf("translate failed: %w", err)
+		return
+	}
+	fmt.Printf("translator:  %s (%s)\n", name, time.Since(start).Round(time.Millisecond))
+	fmt.Printf("tokens:      prompt %d, completion %d\n", resp.TokenUsage.Prompt, resp.TokenUsage.Completion)
+	fmt.Printf("translation:\n%s\n", resp.Text)
+	return
+}
+
+// newCommandTranslateService builds a translate service for one-shot subcommands.
+// Logs go to stderr, leaving stdout for the result.
+func newCommandTranslateService(configFile string) (ts *translate.TranslateService, err error) {
+	logrus.SetOutput(os.Stderr)
+
+	appConfig, err := loadConfig(configFile)
+	if err != nil {
+		err = fmt.Errorf("load config failed: %w", err)
+		return
+	}
+
+	if logLevel, levelErr := logrus.ParseLevel(appConfig.LogLevel); levelErr == nil {
+		logrus.SetLevel(logLevel)
+	}
+
+	return translate.NewTranslateService(appConfig.TranslateService, translate.TranslateServiceOptions{})
+}
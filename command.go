@@ -0,0 +1,77 @@
+package main
+
+import "strings"
+
+// commandHandler handles a bot command of an authorized message.
+type commandHandler func(b *Bot, msg *Message, args string)
+
+// botCommands are the commands handled instead of translated, by name.
+var botCommands = map[string]commandHandler{
+	"usage":     (*Bot).handleUsageCommand,
+	"status":    (*Bot).handleStatusCommand,
+	"forgetme":  (*Bot).handleForgetMeCommand,
+	"summarize": (*Bot).handleSummarizeCommand,
+}
+
+// AdminAdapter is implemented by adapters able to tell chat admins apart.
+type AdminAdapter interface {
+	// IsChatAdmin reports whether the sender of msg administers its chat.
+	IsChatAdmin(msg *Message) (bool, error)
+}
+
+// parseCommand returns the name and arguments of a bot command in text,
+// e.g. "/usage@gura_bot month". Names are case-insensitive.
+func parseCommand(text string) (name, args string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return
+	}
+	name, args, _ = strings.Cut(text[1:], " ")
+	// Telegram appends the bot username in groups
+	name, _, _ = strings.Cut(name, "@")
+	name = strings.ToLower(name)
+	if _, ok = botCommands[name]; !ok {
+		return "", "", false
+	}
+	args = strings.TrimSpace(args)
+	return
+}
+
+// handleCommand runs the command of msg.
+func (b *Bot) handleCommand(msg *Message) {
+	name, args, _ := parseCommand(msg.Content)
+	msg.logger = msg.logger.WithField("command", name)
+	botCommands[name](b, msg, args)
+}
+
+// requireAdmin replies a notice and returns false if the sender of msg
+// isn't an admin of the chat.
+func (b *Bot) requireAdmin(msg *Message) bool {
+	aa, ok := msg.adapter.(AdminAdapter)
+	if !ok {
+		return false
+	}
+	admin, err := aa.IsChatAdmin(msg)
+	if err != nil {
+		msg.logger.Warnf("an error occurred while checking chat admin: %v", err)
+		return false
+	}
+	if !admin {
+		b.replyText(msg, b.text(msg, msgAdminOnly))
+	}
+	return admin
+}
+
+// replyText replies text to msg, logging failures.
+func (b *Bot) replyText(msg *Message, text string) {
+	b.configMu.RLock()
+	replyOpts := ReplyOptions{
+		DisableNotification: b.messageSettings.DisableNotification,
+		DisableLinkPreview:  b.messageSettings.DisableLinkPreview,
+	}
+	b.configMu.RUnlock()
+
+	_, err := msg.adapter.Reply(msg, text, replyOpts)
+	if err != nil {
+		msg.logger.Errorf("an error occurred while replying message: %v", err)
+	}
+}
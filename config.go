@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"reflect"
+	"strings"
 
 	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
 	"github.com/4O4-Not-F0und/Gura-Bot/translate"
@@ -14,6 +17,10 @@ type Config struct {
 	LogLevel         string                           `yaml:"log_level"`
 	TranslateService translate.TranslateServiceConfig `yaml:"translate_service"`
 	Metric           metrics.MetricConfig             `yaml:"metric"`
+
+	// Never log message content or dump HTTP bodies, only hashes and
+	// metadata. Also turns off bot.debug. Requires restart
+	Privacy bool `yaml:"privacy"`
 }
 
 func newConfig() *Config {
@@ -41,3 +48,80 @@ func loadConfig(configFile string) (cfg *Config, err error) {
 	}
 	return
 }
+
+// diffConfig returns a line per changed setting, e.g. "bot.queue_size: 100 -> 200".
+// Values of fields tagged `redact:"true"` and of maps, e.g. headers, are
+// never printed, nor credentials in URLs.
+func diffConfig(old, new *Config) (changes []string) {
+	diffValue("", reflect.ValueOf(*old), reflect.ValueOf(*new), false, &changes)
+	return
+}
+
+func diffValue(path string, old, new reflect.Value, secret bool, changes *[]string) {
+	switch old.Kind() {
+	case reflect.Struct:
+		for i := range old.NumField() {
+			field := old.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			fieldPath := path
+			if name != "" {
+				fieldPath = strings.TrimPrefix(path+"."+name, ".")
+			}
+			diffValue(fieldPath, old.Field(i), new.Field(i), secret || field.Tag.Get("redact") == "true", changes)
+		}
+		return
+	case reflect.Slice:
+		if old.Len() == new.Len() && old.Type().Elem().Kind() == reflect.Struct {
+			for i := range old.Len() {
+				diffValue(fmt.Sprintf("%s[%d]", path, i), old.Index(i), new.Index(i), secret, changes)
+			}
+			return
+		}
+	}
+
+	if reflect.DeepEqual(old.Interface(), new.Interface()) {
+		return
+	}
+	if secret {
+		*changes = append(*changes, path+": (secret changed)")
+		return
+	}
+	if old.Kind() == reflect.Map {
+		*changes = append(*changes, path+": changed")
+		return
+	}
+	oldValue, newValue := redactURLs(old), redactURLs(new)
+	if len(oldValue)+len(newValue) > 120 || strings.ContainsAny(oldValue+newValue, "\n") {
+		*changes = append(*changes, path+": changed")
+		return
+	}
+	*changes = append(*changes, fmt.Sprintf("%s: %s -> %s", path, oldValue, newValue))
+}
+
+// redactURLs prints v, replacing the user info of strings which are URLs.
+func redactURLs(v reflect.Value) string {
+	redact := func(s string) string {
+		u, err := url.Parse(s)
+		if err != nil || u.User == nil {
+			return s
+		}
+		u.User = url.User("redacted")
+		return u.String()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return redact(v.String())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.String {
+			values := make([]string, v.Len())
+			for i := range v.Len() {
+				values[i] = redact(v.Index(i).String())
+			}
+			return fmt.Sprint(values)
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DebounceConfig struct {
+	// Combine rapid consecutive messages of the same user in a chat
+	Enabled bool `yaml:"enabled"`
+
+	// Positive. Milliseconds to wait for another message before translating
+	WindowMs int `yaml:"window_ms"`
+
+	// Positive. Translate immediately once this many messages are combined
+	MaxMessages int `yaml:"max_messages"`
+}
+
+func (dc *DebounceConfig) Check() (err error) {
+	if !dc.Enabled {
+		return
+	}
+	if dc.WindowMs <= 0 {
+		err = fmt.Errorf("debounce window must be positive")
+		return
+	}
+	if dc.MaxMessages <= 0 {
+		err = fmt.Errorf("debounce max messages must be positive")
+		return
+	}
+	return
+}
+
+type debounceKey struct {
+	platform string
+	chatId   int64
+	userId   int64
+}
+
+type debounceBatch struct {
+	msgs  []*Message
+	timer *time.Timer
+}
+
+// debouncer combines messages of the same user arriving within
+// the debounce window into a single message.
+type debouncer struct {
+	mu      *sync.Mutex
+	batches map[debounceKey]*debounceBatch
+	out     chan *Message
+}
+
+func newDebouncer() *debouncer {
+	return &debouncer{
+		mu:      new(sync.Mutex),
+		batches: map[debounceKey]*debounceBatch{},
+		out:     make(chan *Message),
+	}
+}
+
+// Add buffers msg. Combined messages are delivered on d.out.
+func (d *debouncer) Add(msg *Message, conf DebounceConfig) {
+	key := debounceKey{platform: msg.Platform, chatId: msg.ChatID, userId: msg.UserID}
+	window := time.Duration(conf.WindowMs) * time.Millisecond
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	b, ok := d.batches[key]
+	if !ok {
+		b = &debounceBatch{}
+		d.batches[key] = b
+		b.timer = time.AfterFunc(window, func() { d.flush(key, b) })
+	} else {
+		b.timer.Reset(window)
+	}
+	b.msgs = append(b.msgs, msg)
+
+	if len(b.msgs) >= conf.MaxMessages {
+		b.timer.Stop()
+		go d.flush(key, b)
+	}
+}
+
+func (d *debouncer) flush(key debounceKey, b *debounceBatch) {
+	d.mu.Lock()
+	if d.batches[key] != b {
+		// Already flushed
+		d.mu.Unlock()
+		return
+	}
+	delete(d.batches, key)
+	d.mu.Unlock()
+
+	d.out <- combineMessages(b.msgs)
+}
+
+// combineMessages joins message contents with newlines.
+// The reply goes to the last message.
+func combineMessages(msgs []*Message) *Message {
+	last := msgs[len(msgs)-1]
+	if len(msgs) == 1 {
+		return last
+	}
+
+	contents := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		contents = append(contents, m.Content)
+	}
+	last.Content = strings.Join(contents, "\n")
+	last.logger = last.logger.WithField("combined_messages", len(msgs))
+	return last
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+type DigestConfig struct {
+	// Optional. Chat IDs, e.g. of channels, whose translations are
+	// published as a combined digest instead of replied one by one
+	Chats []int64 `yaml:"chats"`
+
+	// Positive. Minutes translations are collected before a digest is
+	// published
+	Interval int64 `yaml:"interval"`
+
+	// Positive. A digest is published early with this many translations
+	MaxPosts int `yaml:"max_posts"`
+
+	// Positive. Characters per message, longer digests are split
+	MaxLength int `yaml:"max_length"`
+}
+
+func (dc *DigestConfig) Check() (err error) {
+	if len(dc.Chats) == 0 {
+		return
+	}
+	if dc.Interval <= 0 || dc.MaxPosts <= 0 || dc.MaxLength <= 0 {
+		err = fmt.Errorf("digest interval, max posts and max length must be positive")
+	}
+	return
+}
+
+type digestKey struct {
+	platform string
+	chatId   int64
+}
+
+type digestBatch struct {
+	since   time.Time
+	entries []string
+
+	// Last message added, localizing and logging the digest
+	last *Message
+}
+
+// digests collects translations of digest chats, in memory only.
+type digests struct {
+	mu      sync.Mutex
+	batches map[digestKey]*digestBatch
+}
+
+func newDigests() *digests {
+	return &digests{batches: make(map[digestKey]*digestBatch)}
+}
+
+// digested reports whether translations of msg go to a digest.
+func (b *Bot) digested(msg *Message) bool {
+	b.configMu.RLock()
+	defer b.configMu.RUnlock()
+	return slices.Contains(b.digest.Chats, msg.ChatID)
+}
+
+// addToDigest adds translation of msg to the digest of its chat,
+// publishing the digest once full.
+func (b *Bot) addToDigest(msg *Message, translation string) {
+	b.configMu.RLock()
+	conf := b.digest
+	b.configMu.RUnlock()
+
+	key := digestKey{platform: msg.Platform, chatId: msg.ChatID}
+	b.digests.mu.Lock()
+	d := b.digests.batches[key]
+	if d == nil {
+		d = &digestBatch{since: time.Now()}
+		b.digests.batches[key] = d
+	}
+	d.entries = append(d.entries, translation)
+	d.last = msg
+	full := len(d.entries) >= conf.MaxPosts
+	if full {
+		delete(b.digests.batches, key)
+	}
+	b.digests.mu.Unlock()
+
+	msg.logger.Debug("added to digest")
+	if full {
+		go b.publishDigest(key, d, conf)
+	}
+}
+
+// runDigests publishes digests collected for the digest interval,
+// checking every minute.
+func (b *Bot) runDigests() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		b.configMu.RLock()
+		conf := b.digest
+		b.configMu.RUnlock()
+
+		var due map[digestKey]*digestBatch
+		b.digests.mu.Lock()
+		for k, d := range b.digests.batches {
+			if now.Sub(d.since) >= time.Duration(conf.Interval)*time.Minute {
+				if due == nil {
+					due = make(map[digestKey]*digestBatch)
+				}
+				due[k] = d
+				delete(b.digests.batches, k)
+			}
+		}
+		b.digests.mu.Unlock()
+
+		for k, d := range due {
+			b.publishDigest(k, d, conf)
+		}
+	}
+}
+
+// publishDigest sends d to its chat, split into messages of at most
+// conf.MaxLength characters.
+func (b *Bot) publishDigest(key digestKey, d *digestBatch, conf DigestConfig) {
+	na := b.notifyAdapter(key.platform)
+	if na == nil {
+		d.last.logger.Errorf("adapter can't send digests")
+		return
+	}
+	header := b.text(d.last, msgDigest, len(d.entries))
+	opts := b.notifyOptions()
+	for _, text := range splitDigest(header, d.entries, conf.MaxLength) {
+		if _, err := na.SendMessage(key.chatId, text, opts); err != nil {
+			d.last.logger.Errorf("an error occurred while sending digest: %v", err)
+			return
+		}
+	}
+	d.last.logger.Infof("published digest of %d translations", len(d.entries))
+}
+
+// splitDigest joins header and entries by blank lines into texts of at most
+// limit characters, cutting entries too long on their own.
+func splitDigest(header string, entries []string, limit int) (texts []string) {
+	current := header
+	for _, e := range entries {
+		if utf8.RuneCountInString(e) > limit {
+			e = string([]rune(e)[:limit-1]) + "…"
+		}
+		if current != "" && utf8.RuneCountInString(current)+2+utf8.RuneCountInString(e) > limit {
+			texts = append(texts, current)
+			current = ""
+		}
+		if current != "" {
+			current += "\n\n"
+		}
+		current += e
+	}
+	if current != "" {
+		texts = append(texts, current)
+	}
+	return
+}
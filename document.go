@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+	"github.com/sirupsen/logrus"
+)
+
+// Document is a file attached to a message.
+type Document struct {
+	Name string
+	Size int64
+
+	// Platform specific file reference, e.g. a Telegram file ID or a URL
+	Ref string
+}
+
+// DocumentAdapter is implemented by adapters able to exchange files.
+type DocumentAdapter interface {
+	// DownloadDocument returns the content of a file attached to a message,
+	// reading at most limit bytes.
+	DownloadDocument(doc *Document, limit int64) ([]byte, error)
+
+	// ReplyDocument sends a file as a reply to msg.
+	ReplyDocument(msg *Message, name string, data []byte, opts ReplyOptions) (*SentReply, error)
+}
+
+type DocumentConfig struct {
+	// Translate attached text files
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Chat IDs in which documents are translated, all authorized chats if empty
+	Chats []int64 `yaml:"chats"`
+
+	// File extensions to translate
+	Extensions []string `yaml:"extensions"`
+
+	// Positive. Larger files are ignored
+	MaxSizeKB int64 `yaml:"max_size_kb"`
+
+	// Positive. Maximum bytes of text sent in one translation request
+	ChunkSize int `yaml:"chunk_size"`
+}
+
+func (dc *DocumentConfig) Check() (err error) {
+	if !dc.Enabled {
+		return
+	}
+	if len(dc.Extensions) == 0 {
+		err = fmt.Errorf("no document extensions configured")
+		return
+	}
+	if dc.MaxSizeKB <= 0 {
+		err = fmt.Errorf("document max size must be positive")
+		return
+	}
+	if dc.ChunkSize <= 0 {
+		err = fmt.Errorf("document chunk size must be positive")
+		return
+	}
+	return
+}
+
+// accepts reports whether the document of msg should be translated.
+func (dc *DocumentConfig) accepts(msg *Message) bool {
+	if !dc.Enabled || msg.Document == nil {
+		return false
+	}
+	if len(dc.Chats) > 0 && !slices.Contains(dc.Chats, msg.ChatID) {
+		return false
+	}
+	if msg.Document.Size > dc.MaxSizeKB*1024 {
+		return false
+	}
+	_, ok := msg.adapter.(DocumentAdapter)
+	return ok && slices.Contains(dc.Extensions, strings.ToLower(filepath.Ext(msg.Document.Name)))
+}
+
+// handleDocument translates an attached text file chunk by chunk and
+// replies with the translated file.
+func (b *Bot) handleDocument(ctx context.Context, msg *Message, ts *translate.TranslateService) {
+	b.configMu.RLock()
+	conf := b.documents
+	replyOpts := ReplyOptions{
+		DisableNotification: b.messageSettings.DisableNotification,
+		DisableLinkPreview:  b.messageSettings.DisableLinkPreview,
+	}
+	b.configMu.RUnlock()
+	replyOpts.Standalone = b.replyMode(msg) == replyModeMessage
+
+	logger := msg.logger.WithField("document", msg.Document.Name)
+	adapter := msg.adapter.(DocumentAdapter)
+
+	data, err := adapter.DownloadDocument(msg.Document, conf.MaxSizeKB*1024)
+	if err != nil {
+		msg.onMessageHandleFailed()
+		logger.Errorf("an error occurred while downloading document: %v", err)
+		return
+	}
+	if !utf8.Valid(data) {
+		msg.onMessageHandleFailed()
+		logger.Warn("document is not valid UTF-8 text")
+		return
+	}
+	text := string(data)
+	if !b.moderate(ctx, msg, text) {
+		return
+	}
+
+	started := time.Now()
+	lang, detectorName, err := ts.DetectLang(ctx, detector.DetectRequest{
+		Text:    truncateUTF8(text, conf.ChunkSize),
+		TraceId: msg.TraceId,
+	})
+	msg.addPhase(latencyPhaseDetect, started)
+	if err != nil {
+		msg.onMessageHandleFailed()
+		logger.Warn(err)
+		return
+	}
+	logger = logger.WithFields(logrus.Fields{
+		"detector_name":   detectorName,
+		"lang":            lang.Language,
+		"lang_confidence": lang.Confidence,
+	})
+
+	started = time.Now()
+	if isSubtitles(text) {
+		var n int
+		var out string
+		var prompt, completion int64
+		var cost float64
+		var target, translatorName string
+		out, prompt, completion, err = translateSubtitles(text, conf.ChunkSize, func(text string) (resp *translator.TranslateResponse, err error) {
+			n += 1
+			var name string
+			resp, name, err = ts.Translate(ctx, translator.TranslateRequest{
+				Text:       text,
+				TraceId:    fmt.Sprintf("%s-%d", msg.TraceId, n),
+				SourceLang: lang.Language,
+				RoutingKey: msg.routingKey(),
+			})
+			if err == nil {
+				cost += ts.Cost(name, resp)
+				target = resp.TargetLang
+				translatorName = name
+			}
+			return
+		})
+		msg.addPhase(latencyPhaseTranslate, started)
+		if err != nil {
+			b.replyError(msg)
+			msg.onMessageHandleFailed()
+			logger.Errorf("an error occurred while translating subtitles: %v", err)
+			return
+		}
+		logger = logger.WithFields(logrus.Fields{
+			"document_chunks":         n,
+			"usage_completion_tokens": completion,
+			"usage_prompt_tokens":     prompt,
+		})
+		msg.onTranslated(lang.Language, target)
+		b.recordUsage(msg, translatorName, prompt, completion, cost)
+		b.replyDocument(msg, logger, adapter, out, replyOpts)
+		return
+	}
+
+	var out strings.Builder
+	var prompt, completion int64
+	var cost float64
+	var target, translatorName string
+	chunks := chunkText(text, conf.ChunkSize)
+	for i, chunk := range chunks {
+		if strings.TrimSpace(chunk) == "" {
+			out.WriteString(chunk)
+			continue
+		}
+
+		// Keep surrounding whitespace, e.g. blank lines between subtitle blocks
+		trimmed := strings.TrimSpace(chunk)
+		start := strings.Index(chunk, trimmed)
+
+		var resp *translator.TranslateResponse
+		var name string
+		resp, name, err = ts.Translate(ctx, translator.TranslateRequest{
+			Text:       trimmed,
+			TraceId:    fmt.Sprintf("%s-%d", msg.TraceId, i),
+			SourceLang: lang.Language,
+			RoutingKey: msg.routingKey(),
+		})
+		if err != nil {
+			msg.addPhase(latencyPhaseTranslate, started)
+			b.replyError(msg)
+			msg.onMessageHandleFailed()
+			logger.Errorf("an error occurred while translating document chunk %d/%d: %v", i+1, len(chunks), err)
+			return
+		}
+		out.WriteString(chunk[:start] + resp.Text + chunk[start+len(trimmed):])
+		prompt += resp.TokenUsage.Prompt
+		completion += resp.TokenUsage.Completion
+		cost += ts.Cost(name, resp)
+		target = resp.TargetLang
+		translatorName = name
+	}
+	msg.addPhase(latencyPhaseTranslate, started)
+	logger = logger.WithFields(logrus.Fields{
+		"document_chunks":         len(chunks),
+		"usage_completion_tokens": completion,
+		"usage_prompt_tokens":     prompt,
+	})
+	msg.onTranslated(lang.Language, target)
+	b.recordUsage(msg, translatorName, prompt, completion, cost)
+	b.replyDocument(msg, logger, adapter, out.String(), replyOpts)
+}
+
+// replyDocument replies the translated text as a file named after the document.
+func (b *Bot) replyDocument(msg *Message, logger *logrus.Entry, adapter DocumentAdapter, text string, replyOpts ReplyOptions) {
+	ext := filepath.Ext(msg.Document.Name)
+	name := strings.TrimSuffix(msg.Document.Name, ext) + ".translated" + ext
+	start := time.Now()
+	_, err := adapter.ReplyDocument(msg, name, []byte(text), replyOpts)
+	msg.addPhase(latencyPhaseSend, start)
+	if err != nil {
+		msg.onMessageHandleFailed()
+		logger.Errorf("an error occurred while replying document: %v", err)
+		return
+	}
+	logger.Info("completed")
+	msg.onSuccess()
+}
+
+// chunkText splits text at paragraph, then line boundaries into chunks of
+// at most size bytes where possible. Joining the chunks yields the text.
+func chunkText(text string, size int) (chunks []string) {
+	for len(text) > size {
+		cut := strings.LastIndex(text[:size], "\n\n")
+		if cut <= 0 {
+			cut = strings.LastIndex(text[:size], "\n")
+		}
+		if cut <= 0 {
+			cut = len(truncateUTF8(text, size))
+		} else {
+			cut += 1
+		}
+		if cut == 0 {
+			break
+		}
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+	return
+}
+
+// truncateUTF8 cuts text to at most size bytes without splitting a rune.
+func truncateUTF8(text string, size int) string {
+	if len(text) <= size {
+		return text
+	}
+	for size > 0 && !utf8.RuneStart(text[size]) {
+		size--
+	}
+	return text[:size]
+}
+
+// downloadLimited fetches url, failing if the body exceeds limit bytes.
+func downloadLimited(ctx context.Context, url string, limit int64) (data []byte, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("download failed: %s", resp.Status)
+		return
+	}
+	data, err = io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err == nil && int64(len(data)) > limit {
+		err = fmt.Errorf("document exceeds %d bytes", limit)
+	}
+	return
+}
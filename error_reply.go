@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+type ErrorReplyConfig struct {
+	// Reply to messages which failed to translate after all retries
+	Enabled bool `yaml:"enabled"`
+
+	// Reply templates by chat type, chat types without one get no error replies.
+	// Templates may use {{.TraceId}}, {{.Platform}} and {{.ChatType}}.
+	// Empty templates reply the localized default message
+	Templates map[string]string `yaml:"templates"`
+
+	// Non-negative. Seconds after an error reply in which no more are sent to the same chat
+	SuppressSec int `yaml:"suppress_sec"`
+
+	templates map[string]*template.Template
+}
+
+func (ec *ErrorReplyConfig) Check() (err error) {
+	if !ec.Enabled {
+		return
+	}
+	if ec.SuppressSec < 0 {
+		err = fmt.Errorf("error reply suppress_sec must not be negative")
+		return
+	}
+	ec.templates = make(map[string]*template.Template, len(ec.Templates))
+	for chatType, text := range ec.Templates {
+		ec.templates[chatType], err = template.New(chatType).Parse(text)
+		if err != nil {
+			err = fmt.Errorf("invalid error reply template of chat type '%s': %w", chatType, err)
+			return
+		}
+	}
+	return
+}
+
+type errorReplyData struct {
+	TraceId  string
+	Platform string
+	ChatType string
+}
+
+// errorReplies remembers when chats got their last error reply,
+// so outages don't flood them.
+type errorReplies struct {
+	mu   sync.Mutex
+	last map[int64]time.Time
+}
+
+func newErrorReplies() *errorReplies {
+	return &errorReplies{last: make(map[int64]time.Time)}
+}
+
+// allow reports whether chatId may get an error reply now, recording it if so.
+func (er *errorReplies) allow(chatId int64, suppress time.Duration) bool {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	now := time.Now()
+	if t, ok := er.last[chatId]; ok && now.Sub(t) < suppress {
+		return false
+	}
+	for id, t := range er.last {
+		if now.Sub(t) >= suppress {
+			delete(er.last, id)
+		}
+	}
+	er.last[chatId] = now
+	return true
+}
+
+// replyError tells the user that msg failed to translate. If a placeholder
+// was sent, it is replaced with the error instead.
+func (b *Bot) replyError(msg *Message) {
+	b.configMu.RLock()
+	conf := b.errorReply
+	replyOpts := ReplyOptions{
+		DisableNotification: b.messageSettings.DisableNotification,
+		DisableLinkPreview:  b.messageSettings.DisableLinkPreview,
+	}
+	b.configMu.RUnlock()
+
+	tmpl, ok := conf.templates[msg.ChatType]
+	if !conf.Enabled || !ok {
+		return
+	}
+
+	var text strings.Builder
+	if conf.Templates[msg.ChatType] == "" {
+		text.WriteString(b.text(msg, msgErrorReply))
+	} else if err := tmpl.Execute(&text, errorReplyData{
+		TraceId:  msg.TraceId,
+		Platform: msg.Platform,
+		ChatType: msg.ChatType,
+	}); err != nil {
+		msg.logger.Warnf("an error occurred while rendering error reply: %v", err)
+		return
+	}
+
+	if msg.placeholder != nil {
+		msg.placeholderFailedText = text.String()
+		return
+	}
+	if !b.errorReplies.allow(msg.ChatID, time.Duration(conf.SuppressSec)*time.Second) {
+		msg.logger.Debug("error reply suppressed")
+		return
+	}
+	_, err := msg.adapter.Reply(msg, text.String(), replyOpts)
+	if err != nil {
+		msg.logger.Warnf("an error occurred while replying error: %v", err)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	callbackFeedbackPrefix = "feedback:"
+
+	feedbackVoteUp   = "up"
+	feedbackVoteDown = "down"
+)
+
+type FeedbackConfig struct {
+	// Attach 👍/👎 buttons to translated replies, Telegram and Discord only
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Chat types in which buttons are attached, all if empty
+	ChatTypes []string `yaml:"chat_types"`
+
+	// Positive. Days in which votes on a reply are accepted
+	MaxAgeDays int `yaml:"max_age_days"`
+
+	// Also attach a button translating the message again with another
+	// translator, editing the reply
+	Retranslate bool `yaml:"retranslate"`
+}
+
+func (fc *FeedbackConfig) Check() (err error) {
+	if fc.Enabled && fc.MaxAgeDays <= 0 {
+		err = fmt.Errorf("feedback max age must be positive")
+	}
+	return
+}
+
+// feedbackState holds the votes, persisted by the StateStore.
+type feedbackState struct {
+	// Replies open for votes, by feedbackReplyKey
+	Replies map[string]*feedbackReply `json:"replies"`
+
+	// Vote counts by translator, source language and vote
+	Totals map[string]map[string]map[string]int64 `json:"totals"`
+
+	// Vote counts by translator, prompt variant and vote
+	Variants map[string]map[string]map[string]int64 `json:"variants"`
+
+	// Retranslations by previous translator, then new translator
+	Retranslations map[string]map[string]int64 `json:"retranslations"`
+}
+
+type feedbackReply struct {
+	Translator string    `json:"translator"`
+	SourceLang string    `json:"source_lang"`
+	Time       time.Time `json:"time"`
+
+	// Optional. Prompt variant that translated
+	Variant string `json:"variant,omitempty"`
+
+	// Votes by user ID
+	Votes map[int64]string `json:"votes"`
+}
+
+func (fs *feedbackState) init() {
+	if fs.Replies == nil {
+		fs.Replies = make(map[string]*feedbackReply)
+	}
+	if fs.Totals == nil {
+		fs.Totals = make(map[string]map[string]map[string]int64)
+	}
+	if fs.Variants == nil {
+		fs.Variants = make(map[string]map[string]map[string]int64)
+	}
+	if fs.Retranslations == nil {
+		fs.Retranslations = make(map[string]map[string]int64)
+	}
+}
+
+func (fs *feedbackState) add(translator, lang, vote string, delta int64) int64 {
+	return addVote(fs.Totals, translator, lang, vote, delta)
+}
+
+func (fs *feedbackState) addVariant(translator, variant, vote string, delta int64) int64 {
+	return addVote(fs.Variants, translator, variant, vote, delta)
+}
+
+func (fs *feedbackState) addRetranslation(from, to string) int64 {
+	if fs.Retranslations[from] == nil {
+		fs.Retranslations[from] = make(map[string]int64)
+	}
+	fs.Retranslations[from][to]++
+	return fs.Retranslations[from][to]
+}
+
+func addVote(totals map[string]map[string]map[string]int64, translator, key, vote string, delta int64) int64 {
+	if totals[translator] == nil {
+		totals[translator] = make(map[string]map[string]int64)
+	}
+	if totals[translator][key] == nil {
+		totals[translator][key] = make(map[string]int64)
+	}
+	totals[translator][key][vote] += delta
+	return totals[translator][key][vote]
+}
+
+func feedbackReplyKey(platform string, chatId, messageId int64) string {
+	return fmt.Sprintf("%s:%d:%d", platform, chatId, messageId)
+}
+
+func feedbackButtons() []ReplyButton {
+	return []ReplyButton{
+		{Text: "👍", Data: callbackFeedbackPrefix + feedbackVoteUp},
+		{Text: "👎", Data: callbackFeedbackPrefix + feedbackVoteDown},
+	}
+}
+
+// feedbackEnabled reports whether replies to msg get feedback buttons.
+func (b *Bot) feedbackEnabled(msg *Message) bool {
+	if _, ok := msg.adapter.(CallbackAdapter); !ok {
+		return false
+	}
+	b.configMu.RLock()
+	defer b.configMu.RUnlock()
+	return b.feedback.Enabled &&
+		(len(b.feedback.ChatTypes) == 0 || slices.Contains(b.feedback.ChatTypes, msg.ChatType))
+}
+
+// trackFeedback opens a sent reply for votes.
+func (b *Bot) trackFeedback(msg *Message, sent *SentReply, translatorName, variant string) {
+	b.configMu.RLock()
+	maxAge := time.Duration(b.feedback.MaxAgeDays) * 24 * time.Hour
+	b.configMu.RUnlock()
+
+	lang := ""
+	if msg.lang != nil {
+		lang = strings.ToUpper(msg.lang.Language)
+	}
+	b.state.update(func(state *botState) {
+		now := time.Now()
+		for k, r := range state.Feedback.Replies {
+			if now.Sub(r.Time) > maxAge {
+				delete(state.Feedback.Replies, k)
+			}
+		}
+		state.Feedback.Replies[feedbackReplyKey(msg.Platform, sent.ChatID, sent.MessageID)] = &feedbackReply{
+			Translator: translatorName,
+			SourceLang: lang,
+			Time:       now,
+			Variant:    variant,
+			Votes:      make(map[int64]string),
+		}
+	})
+}
+
+// handleFeedback records a vote, replacing the previous vote of the user.
+func (b *Bot) handleFeedback(cb *Callback) {
+	vote := strings.TrimPrefix(cb.Data, callbackFeedbackPrefix)
+	if vote != feedbackVoteUp && vote != feedbackVoteDown {
+		return
+	}
+
+	answer := b.localize(cb.ChatID, cb.LanguageCode, msgFeedbackThanks)
+	b.state.update(func(state *botState) {
+		r, ok := state.Feedback.Replies[feedbackReplyKey(cb.Platform, cb.ChatID, cb.MessageID)]
+		if !ok {
+			answer = b.localize(cb.ChatID, cb.LanguageCode, msgFeedbackEnded)
+			return
+		}
+		prev := r.Votes[cb.UserID]
+		if prev == vote {
+			return
+		}
+		if prev != "" {
+			b.metrics.FeedbackVotes.WithLabelValues(prev, r.Translator, r.SourceLang).
+				Set(float64(state.Feedback.add(r.Translator, r.SourceLang, prev, -1)))
+			if r.Variant != "" {
+				b.metrics.PromptVariantVotes.WithLabelValues(prev, r.Translator, r.Variant).
+					Set(float64(state.Feedback.addVariant(r.Translator, r.Variant, prev, -1)))
+			}
+		}
+		r.Votes[cb.UserID] = vote
+		b.metrics.FeedbackVotes.WithLabelValues(vote, r.Translator, r.SourceLang).
+			Set(float64(state.Feedback.add(r.Translator, r.SourceLang, vote, 1)))
+		if r.Variant != "" {
+			b.metrics.PromptVariantVotes.WithLabelValues(vote, r.Translator, r.Variant).
+				Set(float64(state.Feedback.addVariant(r.Translator, r.Variant, vote, 1)))
+		}
+	})
+
+	if err := cb.Answer(answer); err != nil {
+		logrus.Debugf("an error occurred while answering feedback: %v", err)
+	}
+}
+
+// initFeedbackMetrics exposes the persisted vote counts.
+func (b *Bot) initFeedbackMetrics() {
+	b.state.view(func(state *botState) {
+		for translator, langs := range state.Feedback.Totals {
+			for lang, votes := range langs {
+				for vote, n := range votes {
+					b.metrics.FeedbackVotes.WithLabelValues(vote, translator, lang).Set(float64(n))
+				}
+			}
+		}
+		for translator, variants := range state.Feedback.Variants {
+			for variant, votes := range variants {
+				for vote, n := range votes {
+					b.metrics.PromptVariantVotes.WithLabelValues(vote, translator, variant).Set(float64(n))
+				}
+			}
+		}
+		for from, tos := range state.Feedback.Retranslations {
+			for to, n := range tos {
+				b.metrics.Retranslations.WithLabelValues(from, to).Set(float64(n))
+			}
+		}
+	})
+}
+
+// handleCallbacks dispatches button presses of an adapter.
+func (b *Bot) handleCallbacks(ca CallbackAdapter) {
+	for cb := range ca.ReceiveCallbacks() {
+		switch {
+		case strings.HasPrefix(cb.Data, callbackFeedbackPrefix):
+			b.handleFeedback(cb)
+		case cb.Data == callbackRetranslate:
+			b.handleRetranslate(cb)
+		default:
+			if err := cb.Answer(""); err != nil {
+				logrus.Debugf("an error occurred while answering callback: %v", err)
+			}
+		}
+	}
+}
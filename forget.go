@@ -0,0 +1,53 @@
+package main
+
+import "strings"
+
+// forgetUser deletes the persisted data of a user: votes, and the usage
+// and quota notices of private chats with the user, the messages kept
+// for /summarize and the replies open for retranslation. Vote totals are
+// anonymous and kept. Returns the number of records deleted.
+func (b *Bot) forgetUser(msg *Message) (deleted int) {
+	keys := []string{usageChatKey(msg.Platform, msg.UserID)}
+	if msg.ChatType == "private" {
+		// Discord DM channel IDs differ from user IDs
+		keys = append(keys, usageChatKey(msg.Platform, msg.ChatID))
+	}
+
+	deleted = b.history.forget(msg.Platform, msg.UserID)
+	deleted += b.retranslations.forget(msg.Platform, msg.UserID)
+	b.state.update(func(state *botState) {
+		for k, r := range state.Feedback.Replies {
+			if !strings.HasPrefix(k, msg.Platform+":") {
+				continue
+			}
+			if _, ok := r.Votes[msg.UserID]; ok {
+				delete(r.Votes, msg.UserID)
+				deleted++
+			}
+		}
+		for _, k := range keys {
+			if _, ok := state.Usage.Chats[k]; ok {
+				delete(state.Usage.Chats, k)
+				deleted++
+			}
+			if _, ok := state.Quota.Notices[k]; ok {
+				delete(state.Quota.Notices, k)
+				deleted++
+			}
+		}
+	})
+	return
+}
+
+// handleForgetMeCommand deletes the data of the sender and saves the state
+// right away, so nothing is left on disk once confirmed.
+func (b *Bot) handleForgetMeCommand(msg *Message, _ string) {
+	deleted := b.forgetUser(msg)
+	if err := b.state.save(); err != nil {
+		msg.logger.Errorf("an error occurred while saving state: %v", err)
+		b.replyText(msg, b.text(msg, msgForgetMeFailed))
+		return
+	}
+	msg.logger.Infof("deleted %d records of user", deleted)
+	b.replyText(msg, b.text(msg, msgForgetMe))
+}
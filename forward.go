@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Origins of forwarded messages
+const (
+	forwardOriginUser    = "user"
+	forwardOriginBot     = "bot"
+	forwardOriginChannel = "channel"
+	forwardOriginGroup   = "group"
+	// The sender is unknown, e.g. hidden by their privacy settings
+	forwardOriginHidden = "hidden"
+)
+
+var forwardOrigins = []string{
+	forwardOriginUser,
+	forwardOriginBot,
+	forwardOriginChannel,
+	forwardOriginGroup,
+	forwardOriginHidden,
+}
+
+type ForwardRule struct {
+	// Optional. Origins of forwarded messages that are not translated
+	IgnoreOrigins []string `yaml:"ignore_origins"`
+
+	// Translate forwarded messages only
+	OnlyForwards bool `yaml:"only_forwards"`
+}
+
+func (fr *ForwardRule) Check() (err error) {
+	for _, o := range fr.IgnoreOrigins {
+		if !slices.Contains(forwardOrigins, o) {
+			err = fmt.Errorf("invalid forward origin: %s", o)
+			return
+		}
+	}
+	return
+}
+
+type ForwardConfig struct {
+	// Applies to chats without their own rule
+	Default ForwardRule `yaml:"default"`
+
+	// Optional. Rules by chat ID
+	Chats map[int64]ForwardRule `yaml:"chats"`
+}
+
+func (fc *ForwardConfig) Check() (err error) {
+	err = fc.Default.Check()
+	if err != nil {
+		return
+	}
+	for k, r := range fc.Chats {
+		if err = r.Check(); err != nil {
+			err = fmt.Errorf("chat '%d': %w", k, err)
+			return
+		}
+	}
+	return
+}
+
+// filter returns the reason msg should be skipped, or "" if it is translated.
+func (fc *ForwardConfig) filter(msg *Message) string {
+	rule, ok := fc.Chats[msg.ChatID]
+	if !ok {
+		rule = fc.Default
+	}
+	if msg.ForwardOrigin == "" {
+		if rule.OnlyForwards {
+			return contentTypeNotForward
+		}
+		return ""
+	}
+	if slices.Contains(rule.IgnoreOrigins, msg.ForwardOrigin) {
+		return contentTypeForward
+	}
+	return ""
+}
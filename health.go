@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	healthzPath = "/healthz"
+)
+
+// handleHealthz reports whether the update loop is running.
+// It is served on the metric listener.
+func (b *Bot) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	if !b.serving.Load() {
+		http.Error(w, "update loop not running", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// runHealthcheckCommand queries the local /healthz endpoint and
+// returns an error unless it responds with 200.
+func runHealthcheckCommand(args []string) (err error) {
+	fs := flag.NewFlagSet("healthcheck", flag.ExitOnError)
+	conf := fs.String("config", configFile, "path to config file, used to find the metric listen address")
+	url := fs.String("url", "", "health endpoint URL, overrides the address from the config file")
+	timeout := fs.Duration("timeout", 3*time.Second, "request timeout")
+	fs.Parse(args)
+
+	if *url == "" {
+		var appConfig *Config
+		appConfig, err = loadConfig(*conf)
+		if err != nil {
+			return
+		}
+		*url, err = healthzURL(appConfig.Metric.Listen, appConfig.Metric.TLS.Enabled())
+		if err != nil {
+			return
+		}
+	}
+
+	client := &http.Client{Timeout: *timeout}
+	if strings.HasPrefix(*url, "https://") {
+		// Only liveness is checked, against the local listener, whose
+		// certificate is rarely issued for the loopback address
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}
+	}
+	resp, err := client.Get(*url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("unhealthy: %s", resp.Status)
+	}
+	return
+}
+
+// healthzURL converts a listen address into a local health endpoint URL.
+func healthzURL(listen string, https bool) (url string, err error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		err = fmt.Errorf("invalid metric listen address '%s': %w", listen, err)
+		return
+	}
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	scheme := "http://"
+	if https {
+		scheme = "https://"
+	}
+	return scheme + net.JoinHostPort(host, port) + healthzPath, nil
+}
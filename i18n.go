@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Keys of user-facing bot messages. Values are fmt formats.
+const (
+	msgPlaceholder        = "placeholder"
+	msgPlaceholderFailed  = "placeholder_failed"
+	msgErrorReply         = "error_reply"
+	msgAdminOnly          = "admin_only"
+	msgFeedbackThanks     = "feedback_thanks"
+	msgFeedbackEnded      = "feedback_ended"
+	msgUsage              = "usage"
+	msgUsageLine          = "usage_line"
+	msgStatusQueue        = "status_queue"
+	msgStatusTranslators  = "status_translators"
+	msgStatusDetectors    = "status_detectors"
+	msgStatusUp           = "status_up"
+	msgStatusFailures     = "status_failures"
+	msgStatusCooldown     = "status_cooldown"
+	msgStatusDisabled     = "status_disabled"
+	msgQuotaSoft          = "quota_soft"
+	msgQuotaSoftUnlimited = "quota_soft_unlimited"
+	msgQuotaHard          = "quota_hard"
+	msgForgetMe           = "forget_me"
+	msgForgetMeFailed     = "forget_me_failed"
+	msgSummary            = "summary"
+	msgSummaryEntry       = "summary_entry"
+	msgSummaryTopChats    = "summary_top_chats"
+	msgSummaryFailovers   = "summary_failovers"
+	msgSummaryFailover    = "summary_failover"
+	msgSummaryNone        = "summary_none"
+	msgSummarize          = "summarize"
+	msgSummarizeEmpty     = "summarize_empty"
+	msgSummarizeFailed    = "summarize_failed"
+	msgSummarizeDisabled  = "summarize_disabled"
+	msgDigest             = "digest"
+	msgRetranslateButton  = "retranslate_button"
+	msgRetranslating      = "retranslating"
+	msgRetranslateEnded   = "retranslate_ended"
+)
+
+const defaultLocale = "en"
+
+// catalog holds the built-in messages by locale, then key.
+// English is complete, other locales fall back to it.
+var catalog = map[string]map[string]string{
+	"en": {
+		msgPlaceholder:        "Translating…",
+		msgPlaceholderFailed:  "Translation failed.",
+		msgErrorReply:         "Translation failed, please try again later.",
+		msgAdminOnly:          "Only chat admins can use this command.",
+		msgFeedbackThanks:     "Thanks for your feedback!",
+		msgFeedbackEnded:      "Voting on this translation has ended.",
+		msgRetranslateButton:  "🔄 Try another translator",
+		msgRetranslating:      "Translating with another translator…",
+		msgRetranslateEnded:   "This translation can no longer be retranslated.",
+		msgUsage:              "Usage of this chat (UTC)\nToday: %s\nThis month: %s",
+		msgUsageLine:          "%d messages, %d tokens (%d prompt, %d completion), ~$%.4f",
+		msgStatusQueue:        "Queue: %d pending, %d processing",
+		msgStatusTranslators:  "Translators:",
+		msgStatusDetectors:    "Detectors:",
+		msgStatusUp:           "%s: up",
+		msgStatusFailures:     "%s: up, %d recent failures",
+		msgStatusCooldown:     "%s: cooling down for %s",
+		msgStatusDisabled:     "%s: disabled until reload",
+		msgQuotaSoft:          "This chat has used %d of its %d daily translations.",
+		msgQuotaSoftUnlimited: "This chat has used %d translations today.",
+		msgQuotaHard:          "This chat has reached its limit of %d translations today, translating resumes at 00:00 UTC.",
+		msgForgetMe:           "All data stored about you has been deleted.",
+		msgForgetMeFailed:     "Deleting your data failed, please try again later.",
+		msgSummary:            "Usage summary %s – %s (UTC)\nTotal: %s",
+		msgSummaryEntry:       "%s: %s",
+		msgSummaryTopChats:    "Top chats:",
+		msgSummaryFailovers:   "Failover incidents:",
+		msgSummaryFailover:    "%s: %d cooldowns, %d permanently disabled",
+		msgSummaryNone:        "none",
+		msgSummarize:          "Summary of the last %d messages:\n%s",
+		msgSummarizeEmpty:     "No recent messages to summarize.",
+		msgSummarizeFailed:    "Summarizing failed, please try again later.",
+		msgSummarizeDisabled:  "Summaries are not enabled.",
+		msgDigest:             "📰 Digest of %d posts",
+	},
+	"zh": {
+		msgPlaceholder:        "翻译中…",
+		msgPlaceholderFailed:  "翻译失败。",
+		msgErrorReply:         "翻译失败，请稍后再试。",
+		msgAdminOnly:          "只有群组管理员可以使用此命令。",
+		msgFeedbackThanks:     "感谢你的反馈！",
+		msgFeedbackEnded:      "此翻译的投票已结束。",
+		msgRetranslateButton:  "🔄 换个翻译器",
+		msgRetranslating:      "正在用其他翻译器翻译…",
+		msgRetranslateEnded:   "此翻译已无法重新翻译。",
+		msgUsage:              "本聊天用量（UTC）\n今日：%s\n本月：%s",
+		msgUsageLine:          "%d 条消息，%d 个 token（提示 %d，生成 %d），约 $%.4f",
+		msgStatusQueue:        "队列：%d 条等待中，%d 条处理中",
+		msgStatusTranslators:  "翻译器：",
+		msgStatusDetectors:    "语言检测器：",
+		msgStatusUp:           "%s：正常",
+		msgStatusFailures:     "%s：正常，最近失败 %d 次",
+		msgStatusCooldown:     "%s：冷却中，剩余 %s",
+		msgStatusDisabled:     "%s：已停用，直到重新加载配置",
+		msgQuotaSoft:          "本聊天今日已使用 %d 次翻译，每日上限为 %d 次。",
+		msgQuotaSoftUnlimited: "本聊天今日已使用 %d 次翻译。",
+		msgQuotaHard:          "本聊天已达到今日 %d 次翻译的上限，将于 UTC 00:00 恢复翻译。",
+		msgForgetMe:           "已删除保存的所有关于你的数据。",
+		msgForgetMeFailed:     "删除数据失败，请稍后再试。",
+		msgSummary:            "用量汇总 %s – %s（UTC）\n合计：%s",
+		msgSummaryEntry:       "%s：%s",
+		msgSummaryTopChats:    "用量最多的聊天：",
+		msgSummaryFailovers:   "故障转移事件：",
+		msgSummaryFailover:    "%s：冷却 %d 次，永久停用 %d 次",
+		msgSummaryNone:        "无",
+		msgSummarize:          "最近 %d 条消息的摘要：\n%s",
+		msgSummarizeEmpty:     "没有可以总结的最近消息。",
+		msgSummarizeFailed:    "总结失败，请稍后再试。",
+		msgSummarizeDisabled:  "未启用摘要功能。",
+		msgDigest:             "📰 %d 条帖子的摘要",
+	},
+	"ja": {
+		msgPlaceholder:        "翻訳中…",
+		msgPlaceholderFailed:  "翻訳に失敗しました。",
+		msgErrorReply:         "翻訳に失敗しました。しばらくしてからもう一度お試しください。",
+		msgAdminOnly:          "このコマンドはチャットの管理者のみ使用できます。",
+		msgFeedbackThanks:     "フィードバックありがとうございます！",
+		msgFeedbackEnded:      "この翻訳への投票は終了しました。",
+		msgRetranslateButton:  "🔄 別の翻訳エンジンで試す",
+		msgRetranslating:      "別の翻訳エンジンで翻訳しています…",
+		msgRetranslateEnded:   "この翻訳はもう再翻訳できません。",
+		msgUsage:              "このチャットの使用量（UTC）\n今日：%s\n今月：%s",
+		msgUsageLine:          "%d 件のメッセージ、%d トークン（プロンプト %d、生成 %d）、約 $%.4f",
+		msgStatusQueue:        "キュー：待機中 %d 件、処理中 %d 件",
+		msgStatusTranslators:  "翻訳器：",
+		msgStatusDetectors:    "言語検出器：",
+		msgStatusUp:           "%s：正常",
+		msgStatusFailures:     "%s：正常、直近の失敗 %d 回",
+		msgStatusCooldown:     "%s：クールダウン中、残り %s",
+		msgStatusDisabled:     "%s：設定の再読み込みまで無効",
+		msgQuotaSoft:          "このチャットの本日の翻訳回数：%d 回（1日の上限 %d 回）。",
+		msgQuotaSoftUnlimited: "このチャットの本日の翻訳回数：%d 回。",
+		msgQuotaHard:          "このチャットは本日の翻訳上限 %d 回に達しました。UTC 00:00 に再開します。",
+		msgForgetMe:           "あなたに関する保存データをすべて削除しました。",
+		msgForgetMeFailed:     "データの削除に失敗しました。しばらくしてからもう一度お試しください。",
+		msgSummary:            "使用量の概要 %s – %s（UTC）\n合計：%s",
+		msgSummaryEntry:       "%s：%s",
+		msgSummaryTopChats:    "使用量の多いチャット：",
+		msgSummaryFailovers:   "フェイルオーバーの発生：",
+		msgSummaryFailover:    "%s：クールダウン %d 回、永久無効 %d 回",
+		msgSummaryNone:        "なし",
+		msgSummarize:          "直近 %d 件のメッセージの要約：\n%s",
+		msgSummarizeEmpty:     "要約できる最近のメッセージがありません。",
+		msgSummarizeFailed:    "要約に失敗しました。しばらくしてからもう一度お試しください。",
+		msgSummarizeDisabled:  "要約機能は有効になっていません。",
+		msgDigest:             "📰 投稿 %d 件のダイジェスト",
+	},
+}
+
+type I18nConfig struct {
+	// Optional. Locale of chats without their own. If empty, the language
+	// translations in the chat are in is used where known, then the
+	// language of the sender's client, English otherwise
+	DefaultLocale string `yaml:"default_locale"`
+
+	// Optional. Locales by chat ID
+	Chats map[int64]string `yaml:"chats"`
+
+	// Optional. Messages by locale, then message key, adding locales or
+	// replacing built-in messages
+	Messages map[string]map[string]string `yaml:"messages"`
+}
+
+func (ic *I18nConfig) Check() (err error) {
+	for locale, messages := range ic.Messages {
+		for key := range messages {
+			if _, ok := catalog[defaultLocale][key]; !ok {
+				err = fmt.Errorf("unknown message key of locale '%s': %s", locale, key)
+				return
+			}
+		}
+	}
+	return
+}
+
+// locale selects the locale of a chat. targetLang is the language of the
+// latest translation in the chat and languageCode the IETF language tag of
+// the sender's client, if known, e.g. "pt-BR".
+func (ic *I18nConfig) locale(chatId int64, targetLang, languageCode string) string {
+	if l, ok := ic.Chats[chatId]; ok {
+		return l
+	}
+	if ic.DefaultLocale != "" {
+		return ic.DefaultLocale
+	}
+	for _, tag := range []string{targetLang, languageCode} {
+		l, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if l == "" {
+			continue
+		}
+		if _, ok := ic.Messages[l]; ok {
+			return l
+		}
+		if _, ok := catalog[l]; ok {
+			return l
+		}
+	}
+	return defaultLocale
+}
+
+// targetLangs holds the language of the latest translation by chat.
+type targetLangs struct {
+	mu    sync.RWMutex
+	langs map[int64]string
+}
+
+func newTargetLangs() *targetLangs {
+	return &targetLangs{langs: make(map[int64]string)}
+}
+
+func (tl *targetLangs) set(chatId int64, lang string) {
+	if lang == "" {
+		return
+	}
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.langs[chatId] = lang
+}
+
+func (tl *targetLangs) get(chatId int64) string {
+	tl.mu.RLock()
+	defer tl.mu.RUnlock()
+	return tl.langs[chatId]
+}
+
+// message returns the message of key in locale, falling back to English.
+func (ic *I18nConfig) message(locale, key string) string {
+	for _, l := range []string{locale, defaultLocale} {
+		if m, ok := ic.Messages[l][key]; ok {
+			return m
+		}
+		if m, ok := catalog[l][key]; ok {
+			return m
+		}
+	}
+	return key
+}
+
+// text returns the localized message of key for the chat of msg,
+// formatted with args.
+func (b *Bot) text(msg *Message, key string, args ...any) string {
+	return b.localize(msg.ChatID, msg.LanguageCode, key, args...)
+}
+
+func (b *Bot) localize(chatId int64, languageCode, key string, args ...any) string {
+	b.configMu.RLock()
+	conf := b.i18n
+	b.configMu.RUnlock()
+
+	format := conf.message(conf.locale(chatId, b.targetLangs.get(chatId), languageCode), key)
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
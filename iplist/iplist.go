@@ -0,0 +1,55 @@
+// Package iplist matches remote addresses against IP allowlists.
+package iplist
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// Allowlist holds IP ranges. An empty Allowlist allows any address.
+type Allowlist []netip.Prefix
+
+// Parse parses CIDR ranges and single addresses.
+func Parse(entries []string) (l Allowlist, err error) {
+	for _, e := range entries {
+		var p netip.Prefix
+		if strings.Contains(e, "/") {
+			p, err = netip.ParsePrefix(e)
+		} else {
+			var a netip.Addr
+			a, err = netip.ParseAddr(e)
+			p = netip.PrefixFrom(a, a.BitLen())
+		}
+		if err != nil {
+			err = fmt.Errorf("invalid IP range '%s': %w", e, err)
+			return
+		}
+		l = append(l, p.Masked())
+	}
+	return
+}
+
+// Allows reports whether remoteAddr, e.g. http.Request.RemoteAddr,
+// is in any of the ranges.
+func (l Allowlist) Allows(remoteAddr string) bool {
+	if len(l) == 0 {
+		return true
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range l {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
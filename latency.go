@@ -0,0 +1,61 @@
+package main
+
+import "time"
+
+// Phases of message handling timed by the latency metrics
+const (
+	// Waiting in the worker queue, including retry cooldowns
+	latencyPhaseQueue     = "queue"
+	latencyPhaseDetect    = "detect"
+	latencyPhaseTranslate = "translate"
+	// Sending the translation to the chat platform
+	latencyPhaseSend = "send"
+)
+
+var allLatencyPhases = []string{
+	latencyPhaseQueue,
+	latencyPhaseDetect,
+	latencyPhaseTranslate,
+	latencyPhaseSend,
+}
+
+// Outcomes of messages timed by the latency metrics
+const (
+	latencyOutcomeSuccess   = "success"
+	latencyOutcomeFailed    = "failed"
+	latencyOutcomeModerated = "moderated"
+	latencyOutcomeDropped   = "dropped"
+)
+
+// messageTimes keeps when a message was received and how long it spent in
+// each phase over all attempts.
+type messageTimes struct {
+	received     time.Time
+	pendingSince time.Time
+	phases       map[string]time.Duration
+}
+
+// addPhase adds the time since start to phase.
+func (m *Message) addPhase(phase string, start time.Time) {
+	if m.times.phases == nil {
+		m.times.phases = make(map[string]time.Duration)
+	}
+	m.times.phases[phase] += time.Since(start)
+}
+
+// observeLatency records the time from receipt to completion of a message
+// and its phases, with the trace id as exemplar if enabled. Commands are
+// not timed.
+func (m *Message) observeLatency(outcome string) {
+	if m.command || m.times.received.IsZero() {
+		return
+	}
+	m.metrics.ObserveWithTrace(m.metrics.MessageLatency.WithLabelValues(m.ChatType, outcome),
+		time.Since(m.times.received).Seconds(), m.TraceId)
+	for _, phase := range allLatencyPhases {
+		if d, ok := m.times.phases[phase]; ok {
+			m.metrics.ObserveWithTrace(m.metrics.MessagePhaseLatency.WithLabelValues(phase, m.ChatType, outcome),
+				d.Seconds(), m.TraceId)
+		}
+	}
+}
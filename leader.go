@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	leaderElectionFile = "file"
+)
+
+type LeaderElectionConfig struct {
+	// Only the leader runs the adapters and background jobs, standbys wait
+	// to take over. Requires a Telegram bot token
+	Enabled bool `yaml:"enabled"`
+
+	// Backend type, only "file" is supported
+	Backend string `yaml:"backend"`
+
+	// Required by the file backend
+	LockFile string `yaml:"lock_file"`
+
+	// Positive. Seconds between attempts to acquire leadership
+	RetryInterval int `yaml:"retry_interval"`
+}
+
+func (lc *LeaderElectionConfig) Check() (err error) {
+	if !lc.Enabled {
+		return
+	}
+	switch lc.Backend {
+	case leaderElectionFile:
+		if lc.LockFile == "" {
+			err = fmt.Errorf("leader election lock file is required")
+			return
+		}
+	default:
+		err = fmt.Errorf("unknown leader election backend: %s", lc.Backend)
+		return
+	}
+	if lc.RetryInterval <= 0 {
+		err = fmt.Errorf("leader election retry interval must be positive")
+		return
+	}
+	return
+}
+
+// leaderLock is a mutual exclusion lock shared between replicas.
+type leaderLock interface {
+	// TryLock reports whether the lock was acquired.
+	TryLock() (bool, error)
+}
+
+// LeaderElector blocks standby replicas until they become the leader.
+// Leadership is held until the process exits.
+type LeaderElector struct {
+	lock          leaderLock
+	retryInterval time.Duration
+	leader        atomic.Bool
+
+	// Mirrors leader
+	leaderMetric prometheus.Gauge
+}
+
+// newLeaderElector returns nil if leader election is disabled.
+func newLeaderElector(conf LeaderElectionConfig, leaderMetric prometheus.Gauge) (le *LeaderElector, err error) {
+	if !conf.Enabled {
+		leaderMetric.Set(1)
+		return
+	}
+
+	le = &LeaderElector{
+		retryInterval: time.Duration(conf.RetryInterval) * time.Second,
+		leaderMetric:  leaderMetric,
+	}
+	switch conf.Backend {
+	case leaderElectionFile:
+		le.lock, err = newFileLock(conf.LockFile)
+	}
+	leaderMetric.Set(0)
+	return
+}
+
+// IsLeader reports whether this replica is the leader. A nil elector is
+// always the leader.
+func (le *LeaderElector) IsLeader() bool {
+	return le == nil || le.leader.Load()
+}
+
+// isLeader reports whether this replica is the leader, always true without
+// leader election.
+func (b *Bot) isLeader() bool {
+	return b.elector.IsLeader()
+}
+
+// WaitForLeadership blocks until this replica is the leader.
+func (le *LeaderElector) WaitForLeadership() {
+	logged := false
+	for {
+		ok, err := le.lock.TryLock()
+		if err != nil {
+			logrus.Errorf("leader election failed: %v", err)
+		} else if ok {
+			logrus.Info("acquired leadership")
+			le.leader.Store(true)
+			le.leaderMetric.Set(1)
+			return
+		} else if !logged {
+			logrus.Info("another replica is the leader, standing by")
+			logged = true
+		}
+		time.Sleep(le.retryInterval)
+	}
+}
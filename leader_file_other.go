@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import "fmt"
+
+type fileLock struct{}
+
+func newFileLock(string) (*fileLock, error) {
+	return nil, fmt.Errorf("file leader election is not supported on this platform")
+}
+
+func (fl *fileLock) TryLock() (bool, error) {
+	return false, nil
+}
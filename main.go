@@ -2,13 +2,17 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
 	"github.com/4O4-Not-F0und/Gura-Bot/translate"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,11 +21,15 @@ const (
 )
 
 var (
-	configFile = defaultConfigFile
+	configFile  = defaultConfigFile
+	showVersion = false
+	dryRun      = false
 )
 
 func init() {
 	flag.StringVar(&configFile, "config", defaultConfigFile, "path to config file")
+	flag.BoolVar(&showVersion, "version", false, "print version and exit")
+	flag.BoolVar(&dryRun, "dry-run", false, "detect and translate messages, but log replies instead of sending them")
 	flag.Parse()
 
 	logrus.SetOutput(os.Stdout)
@@ -36,31 +44,89 @@ func init() {
 }
 
 func main() {
+	switch flag.Arg(0) {
+	case "":
+	case "version":
+		showVersion = true
+	case "translate":
+		err := runTranslateCommand(flag.Args()[1:])
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	case "detect":
+		err := runDetectCommand(flag.Args()[1:])
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	case "bench":
+		err := runBenchCommand(flag.Args()[1:])
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	case "healthcheck":
+		err := runHealthcheckCommand(flag.Args()[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	default:
+		logrus.Fatalf("unknown subcommand: %s", flag.Arg(0))
+	}
+
+	if showVersion {
+		fmt.Println(versionString())
+		return
+	}
+	logrus.Infof("starting %s", versionString())
+
 	appConfig, err := loadConfig(configFile)
 	if err != nil {
 		logrus.Fatalf("load config failed: %v", err)
 	}
 	logrus.Infof("loaded config from '%s'", configFile)
+	privacyMode = appConfig.Privacy
 
 	err = reloadLogConfig(appConfig.LogLevel)
 	if err != nil {
 		logrus.Errorf("error parsing new log level '%s': %v", appConfig.LogLevel, err)
 	}
 
-	metrics.InitMetricServer(appConfig.Metric)
-
-	translateService, err := translate.NewTranslateService(appConfig.TranslateService)
+	logger := logrus.NewEntry(logrus.StandardLogger())
+	m := metrics.NewMetrics(prometheus.DefaultRegisterer)
+	m.Exemplars = appConfig.Metric.Exemplars
+	metrics.InitMetricServer(appConfig.Metric, prometheus.DefaultGatherer, logger, healthzPath)
+
+	// The bot is created after the service, failover incidents before
+	// are not recorded
+	var botRef atomic.Pointer[Bot]
+	serviceOpts := translate.TranslateServiceOptions{
+		Logger:  logger,
+		Metrics: m,
+		OnDisabled: func(component, name string, permanent bool) {
+			if bot := botRef.Load(); bot != nil {
+				bot.onComponentDisabled(component, name, permanent)
+			}
+		},
+	}
+	translateService, err := translate.NewTranslateService(appConfig.TranslateService, serviceOpts)
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	bot, err := newBot(appConfig.Bot, translateService)
+	bot, err := newBot(appConfig.Bot, translateService, m)
 	if err != nil {
 		logrus.Fatal(err)
 	}
+	botRef.Store(bot)
+
+	http.HandleFunc(healthzPath, bot.handleHealthz)
 
 	go bot.ServeBot()
-	handleSignals(bot)
+	handleSignals(bot, appConfig, translateService, serviceOpts)
 }
 
 func reloadLogConfig(level string) (err error) {
@@ -73,40 +139,75 @@ func reloadLogConfig(level string) (err error) {
 	return
 }
 
-func handleSignals(bot *Bot) {
+func handleSignals(bot *Bot, currentConfig *Config, currentService *translate.TranslateService, serviceOpts translate.TranslateServiceOptions) {
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGHUP)
+	signal.Notify(sigChan, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
 
 	for sig := range sigChan {
 		switch sig {
 		case syscall.SIGHUP:
 			logrus.Infof("received %s, attempting to reload config", sig.String())
 
-			appConfig, err := loadConfig(configFile)
+			appConfig, translateService, err := reload(bot, serviceOpts)
 			if err != nil {
-				logrus.Errorf("error reloading config: %v", err)
+				logrus.Errorf("config reload failed, keeping previous config: %v", err)
+				bot.notify(eventReloadFailed,
+					fmt.Sprintf("config reload failed, keeping previous config: %v", err), nil)
 				continue
 			}
+			// Messages, retries, retranslations and batches in flight keep
+			// using the previous service until done
+			go currentService.CloseWhenIdle()
+			currentService = translateService
 
-			err = reloadLogConfig(appConfig.LogLevel)
-			if err != nil {
-				logrus.Errorf("error parsing new log level '%s': %v", appConfig.LogLevel, err)
-				continue
-			}
-
-			translateService, err := translate.NewTranslateService(appConfig.TranslateService)
-			if err != nil {
-				logrus.Error(err)
-				continue
-			}
-
-			err = bot.Reload(appConfig.Bot, translateService)
-			if err != nil {
-				logrus.Error(err)
-				continue
+			for _, change := range diffConfig(currentConfig, appConfig) {
+				logrus.Infof("config changed: %s", change)
 			}
+			currentConfig = appConfig
 
 			logrus.Info("config reloaded")
+		case syscall.SIGINT, syscall.SIGTERM:
+			logrus.Infof("received %s, shutting down", sig.String())
+			bot.Close()
+			currentService.Close()
+			return
 		}
 	}
 }
+
+// reload builds and validates everything from the config file before
+// applying any of it, so a failed reload leaves the previous config untouched.
+func reload(bot *Bot, serviceOpts translate.TranslateServiceOptions) (appConfig *Config, translateService *translate.TranslateService, err error) {
+	appConfig, err = loadConfig(configFile)
+	if err != nil {
+		return
+	}
+
+	logLevel, err := logrus.ParseLevel(appConfig.LogLevel)
+	if err != nil {
+		err = fmt.Errorf("error parsing new log level '%s': %w", appConfig.LogLevel, err)
+		return
+	}
+
+	err = appConfig.Bot.Check()
+	if err != nil {
+		return
+	}
+
+	translateService, err = translate.NewTranslateService(appConfig.TranslateService, serviceOpts)
+	if err != nil {
+		return
+	}
+
+	err = bot.Reload(appConfig.Bot, translateService)
+	if err != nil {
+		translateService.Close()
+		return
+	}
+
+	if logLevel != logrus.GetLevel() {
+		logrus.Infof("log level changed to: %s", logLevel)
+		logrus.SetLevel(logLevel)
+	}
+	return
+}
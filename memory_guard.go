@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime/debug"
+	rtmetrics "runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// MemoryGuardConfig reduces the number of workers handling messages while
+// memory is near the limit, so lingua models plus many concurrent LLM
+// payloads don't OOM small containers.
+type MemoryGuardConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Limit in MiB, GOMEMLIMIT if 0
+	LimitMB int64 `yaml:"limit_mb"`
+
+	// Share of the limit above which workers are reduced
+	HighWatermark float64 `yaml:"high_watermark"`
+
+	// Share of the limit below which all workers resume
+	LowWatermark float64 `yaml:"low_watermark"`
+
+	// Positive. Workers handling messages while reduced
+	MinWorkers int `yaml:"min_workers"`
+
+	// Positive. Seconds between memory checks
+	CheckInterval int `yaml:"check_interval"`
+}
+
+func (mc *MemoryGuardConfig) Check() (err error) {
+	if !mc.Enabled {
+		return
+	}
+	if mc.LimitMB < 0 {
+		err = fmt.Errorf("memory guard limit must not be negative")
+		return
+	}
+	if mc.LimitMB == 0 && debug.SetMemoryLimit(-1) == math.MaxInt64 {
+		err = fmt.Errorf("memory guard requires 'limit_mb' or GOMEMLIMIT")
+		return
+	}
+	if mc.LowWatermark <= 0 || mc.LowWatermark >= mc.HighWatermark || mc.HighWatermark > 1 {
+		err = fmt.Errorf("memory guard watermarks must satisfy 0 < low_watermark < high_watermark <= 1")
+		return
+	}
+	if mc.MinWorkers <= 0 {
+		err = fmt.Errorf("memory guard min workers must be positive")
+		return
+	}
+	if mc.CheckInterval <= 0 {
+		err = fmt.Errorf("memory guard check interval must be positive")
+		return
+	}
+	return
+}
+
+// limit returns the memory limit in bytes.
+func (mc *MemoryGuardConfig) limit() int64 {
+	if mc.LimitMB > 0 {
+		return mc.LimitMB << 20
+	}
+	return debug.SetMemoryLimit(-1)
+}
+
+const (
+	// Memory counted by GOMEMLIMIT is the total less the released heap
+	memoryClassesTotal        = "/memory/classes/total:bytes"
+	memoryClassesHeapReleased = "/memory/classes/heap/released:bytes"
+
+	// Checks of a disabled guard, picking up reloads
+	memoryGuardIdleInterval = 5 * time.Second
+)
+
+// memoryGuard admits handlers of messages, only MinWorkers at a time
+// while memory is above the high watermark. Workers waiting for admission
+// keep their message.
+type memoryGuard struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	conf    MemoryGuardConfig
+	limited bool
+	active  int
+	metrics *metrics.Metrics
+	samples []rtmetrics.Sample
+}
+
+func newMemoryGuard(m *metrics.Metrics) *memoryGuard {
+	mg := &memoryGuard{
+		metrics: m,
+		samples: []rtmetrics.Sample{
+			{Name: memoryClassesTotal},
+			{Name: memoryClassesHeapReleased},
+		},
+	}
+	mg.cond = sync.NewCond(&mg.mu)
+	mg.metrics.Saturation.WithLabelValues(saturationMemoryPressure).Set(0)
+	go mg.monitor()
+	return mg
+}
+
+// Reload applies a checked config.
+func (mg *memoryGuard) Reload(conf MemoryGuardConfig) {
+	mg.mu.Lock()
+	mg.conf = conf
+	mg.mu.Unlock()
+	mg.check()
+}
+
+// acquire waits until a handler may run.
+func (mg *memoryGuard) acquire() {
+	mg.mu.Lock()
+	for mg.limited && mg.active >= mg.conf.MinWorkers {
+		mg.cond.Wait()
+	}
+	mg.active += 1
+	mg.mu.Unlock()
+}
+
+func (mg *memoryGuard) release() {
+	mg.mu.Lock()
+	mg.active -= 1
+	mg.cond.Broadcast()
+	mg.mu.Unlock()
+}
+
+func (mg *memoryGuard) monitor() {
+	for {
+		mg.mu.Lock()
+		interval := memoryGuardIdleInterval
+		if mg.conf.Enabled {
+			interval = time.Duration(mg.conf.CheckInterval) * time.Second
+		}
+		mg.mu.Unlock()
+
+		time.Sleep(interval)
+		mg.check()
+	}
+}
+
+// check updates the state by the memory in use.
+func (mg *memoryGuard) check() {
+	mg.mu.Lock()
+	defer mg.mu.Unlock()
+
+	limited := false
+	if mg.conf.Enabled {
+		rtmetrics.Read(mg.samples)
+		used := mg.samples[0].Value.Uint64() - mg.samples[1].Value.Uint64()
+		ratio := float64(used) / float64(mg.conf.limit())
+		switch {
+		case ratio >= mg.conf.HighWatermark:
+			limited = true
+		case ratio > mg.conf.LowWatermark:
+			// Keep the state between the watermarks
+			limited = mg.limited
+		}
+		logrus.Tracef("memory in use: %d MiB, %.2f of the limit", used>>20, ratio)
+		if limited != mg.limited {
+			if limited {
+				logrus.Warnf("memory in use at %.2f of the limit, reducing workers to %d", ratio, mg.conf.MinWorkers)
+			} else {
+				logrus.Infof("memory in use at %.2f of the limit, resuming all workers", ratio)
+			}
+		}
+	}
+	if limited == mg.limited {
+		return
+	}
+
+	mg.limited = limited
+	if limited {
+		mg.metrics.Saturation.WithLabelValues(saturationMemoryPressure).Set(1)
+	} else {
+		mg.metrics.Saturation.WithLabelValues(saturationMemoryPressure).Set(0)
+		mg.cond.Broadcast()
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ComponentCooldown is the failover state of a translator or detector
+// exported by CooldownCollector.
+type ComponentCooldown struct {
+	// "translator" or "detector"
+	Component string
+	Name      string
+
+	// Seconds until re-enabled: 0 if up, +Inf if permanently disabled
+	Seconds float64
+}
+
+// CooldownCollector exports the seconds until disabled components are
+// re-enabled. They are computed when scraped, as the cooldowns change
+// with time only.
+type CooldownCollector struct {
+	desc   *prometheus.Desc
+	source atomic.Pointer[func() []ComponentCooldown]
+}
+
+func newCooldownCollector() *CooldownCollector {
+	return &CooldownCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "component_cooldown_seconds"),
+			"Seconds until a disabled translator or detector is re-enabled. 0 if up, +Inf if permanently disabled.",
+			[]string{"component", "name"}, nil,
+		),
+	}
+}
+
+// SetSource sets the function listing the components on each scrape.
+func (cc *CooldownCollector) SetSource(f func() []ComponentCooldown) {
+	cc.source.Store(&f)
+}
+
+func (cc *CooldownCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cc.desc
+}
+
+func (cc *CooldownCollector) Collect(ch chan<- prometheus.Metric) {
+	f := cc.source.Load()
+	if f == nil {
+		return
+	}
+	for _, c := range (*f)() {
+		ch <- prometheus.MustNewConstMetric(cc.desc, prometheus.GaugeValue, c.Seconds, c.Component, c.Name)
+	}
+}
@@ -1,8 +1,15 @@
 package metrics
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"net/http"
+	"os"
+	"slices"
+	"time"
 
+	"github.com/4O4-Not-F0und/Gura-Bot/iplist"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -13,110 +20,579 @@ const (
 	namespace = "gura_bot"
 )
 
+// Buckets of message latencies in seconds
+var latencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
 type MetricConfig struct {
 	Listen string `yaml:"listen"`
+
+	// Optional. IP ranges or addresses allowed to connect, any if empty
+	AllowedSources []string `yaml:"allowed_sources"`
+
+	// Optional. Serve HTTPS, requiring client certificates if a client CA
+	// is set
+	TLS TLSConfig `yaml:"tls"`
+
+	// Attach trace IDs of messages as exemplars to the latency histograms.
+	// Exemplars are exposed in the OpenMetrics format only, which is
+	// enabled with them
+	Exemplars bool `yaml:"exemplars"`
+}
+
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+
+	// Optional. PEM file of the CAs client certificates must be signed by
+	ClientCAFile string `yaml:"client_ca_file"`
 }
 
-var (
+// Enabled reports whether the metric server serves HTTPS.
+func (tc TLSConfig) Enabled() bool {
+	return tc.CertFile != ""
+}
+
+func (tc TLSConfig) config() (conf *tls.Config, err error) {
+	if tc.CertFile == "" || tc.KeyFile == "" {
+		err = fmt.Errorf("metric tls: cert_file and key_file are required")
+		return
+	}
+	cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+	if err != nil {
+		err = fmt.Errorf("metric tls: %w", err)
+		return
+	}
+	conf = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if tc.ClientCAFile == "" {
+		return
+	}
+	pem, err := os.ReadFile(tc.ClientCAFile)
+	if err != nil {
+		err = fmt.Errorf("metric tls: %w", err)
+		return
+	}
+	conf.ClientCAs = x509.NewCertPool()
+	if !conf.ClientCAs.AppendCertsFromPEM(pem) {
+		err = fmt.Errorf("metric tls: no certificates in '%s'", tc.ClientCAFile)
+		return
+	}
+	// Verified in guard, so open paths stay reachable without one
+	conf.ClientAuth = tls.VerifyClientCertIfGiven
+	return
+}
+
+// Metrics holds every collector exported by the bot. Collectors are
+// registered on the Registerer given to NewMetrics, so an embedding
+// application can keep them apart from its own metrics.
+type Metrics struct {
 	// States: "pending" (in bot's worker queue), "processing" (actively handled),
 	//         "unauthorized" (terminal state for disallowed messages),
 	//         "failed" (terminal state for error occurred while handling messages),
-	//         "processed" (terminal state for successfully handled messages).
-	MetricMessages = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "messages_total",
-			Help:      "Current number of messages being processed by the bot.",
-		},
-		[]string{"state", "chat_type"},
-	)
-
-	// States: "pending" (waiting for rate limiter),
+	//         "processed" (terminal state for successfully handled messages),
+	//         "moderated" (terminal state for messages skipped by moderation).
+	Messages *prometheus.GaugeVec
+
+	// Messages dropped because the worker queue was full
+	MessagesDropped *prometheus.CounterVec
+
+	// Types: "emoji", "sticker", "dice", "location", "media", "other",
+	//        "forward", "not_forward", "quota", "rate_limited".
+	// Messages skipped without translation, by content type
+	MessagesSkipped *prometheus.CounterVec
+
+	// Actions: "skip", "flag". Sources: "pattern", "openai".
+	// Messages caught by moderation
+	MessagesModerated *prometheus.CounterVec
+
+	// Stages: "detect", "translate".
+	// Retries scheduled, by stage
+	RetriesScheduled *prometheus.CounterVec
+
+	// Outcomes: "recovered" (the stage succeeded after retries),
+	//           "exhausted" (the stage failed after its last retry).
+	// Retries per message of stages retried at least once, by stage and outcome
+	RetryOutcomes *prometheus.HistogramVec
+
+	// Outcomes: "success", "failed", "moderated", "dropped".
+	// Seconds from receipt to completion of messages queued for
+	// translation, by chat type and outcome
+	MessageLatency *prometheus.HistogramVec
+
+	// Phases: "queue" (waiting for a worker, including retry cooldowns),
+	//         "detect", "translate", "send" (replying to the chat).
+	// Seconds spent in each phase per message over all attempts,
+	// by phase, chat type and outcome
+	MessagePhaseLatency *prometheus.HistogramVec
+
+	// Reasons: "queue_full" (worker queue has no free slot),
+	//          "translators_unavailable" (all translators are disabled),
+	//          "memory_pressure" (memory guard reduced the workers).
+	// Value is 1 if the pipeline is saturated for the reason, 0 otherwise.
+	Saturation *prometheus.GaugeVec
+
+	// Value is 1 if this replica is the leader running the adapters,
+	// 0 if it is standing by.
+	Leader prometheus.Gauge
+
+	// States: "pending" (waiting for rate limiter or max_in_flight),
 	//         "processing" (waiting for translation API response),
 	//         "success" (translation and parsing successful),
 	//         "failed" (any step in translation failed).
-	MetricTranslatorTasks = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "translator_tasks_total",
-			Help:      "Total number of translation tasks, by state.",
-		},
-		[]string{"state", "translator_name"},
-	)
+	TranslatorTasks *prometheus.GaugeVec
 
 	// Types: "completion" (output tokens)
 	// 		  "prompt" (input tokens)
-	MetricTranslatorTokensUsed = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "translator_tokens_used",
-			Help:      "Used tokens of translation tasks.",
-		},
-		[]string{"token_type", "translator_name"},
-	)
+	// 		  "cached_prompt" (input tokens read from the prompt cache,
+	// 		                   also counted as prompt)
+	TranslatorTokensUsed *prometheus.CounterVec
 
 	// Gauge for translator up status
 	// Value is 1 if the translator is up, 0 if it is disabled.
-	MetricTranslatorUp = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "translator_up",
-			Help:      "Indicates if a translator is currently up and operational. 1 for up, 0 for disabled.",
-		},
-		[]string{"translator_name"},
-	)
+	TranslatorUp *prometheus.GaugeVec
+
+	// Smoothed health score of translators from 0 to 1,
+	// by success rate and latency
+	TranslatorHealth *prometheus.GaugeVec
 
 	// Gauge for translator selected times
-	MetricTranslatorSelectionTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "translator_selection_total",
-			Help:      "Times of translator instance was chosen.",
-		},
-		[]string{"translator_name"},
-	)
-
-	// States: "pending" (waiting for rate limiter),
+	TranslatorSelectionTotal *prometheus.CounterVec
+
+	// States: "pending" (waiting for rate limiter or max_in_flight),
 	//         "processing" (waiting for translation API response),
 	//         "success" (translation and parsing successful),
 	//         "failed" (any step in translation failed).
-	MetricDetectorTasks = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "detector_tasks_total",
-			Help:      "Total number of translation tasks, by state.",
-		},
-		[]string{"state", "detector_name"},
-	)
+	DetectorTasks *prometheus.GaugeVec
 
 	// Gauge for detector up status
 	// Value is 1 if the detector is up, 0 if it is disabled.
-	MetricDetectorUp = promauto.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "detector_up",
-			Help:      "Indicates if a detector is currently up and operational. 1 for up, 0 for disabled.",
-		},
-		[]string{"detector_name"},
-	)
+	DetectorUp *prometheus.GaugeVec
+
+	// Smoothed health score of detectors from 0 to 1,
+	// by success rate and latency
+	DetectorHealth *prometheus.GaugeVec
 
 	// Gauge for detector selected times
-	MetricDetectorSelectionTotal = promauto.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "detector_selection_total",
-			Help:      "Times of detector instance was chosen.",
-		},
-		[]string{"detector_name"},
-	)
-)
+	DetectorSelectionTotal *prometheus.CounterVec
+
+	// Results: "hit", "miss".
+	// Lookups of the detection cache by detector name and result
+	DetectorCacheRequests *prometheus.CounterVec
+
+	// Upstream calls of translators and detectors in flight,
+	// limited by translate_service.max_in_flight
+	UpstreamInFlight prometheus.Gauge
+
+	// Limiters: "rate" (rate_limit of the instance),
+	//           "in_flight" (translate_service.max_in_flight).
+	// Seconds waited on limiters before upstream calls, by limiter,
+	// component ("translator" or "detector") and instance name
+	LimiterWait *prometheus.HistogramVec
+
+	// Results: "success", "failed", "dropped" (too many in flight).
+	// Shadow translations by translator and result
+	ShadowTranslations *prometheus.CounterVec
+
+	// Seconds taken by successful shadow translations, by translator
+	ShadowLatency *prometheus.HistogramVec
+
+	// Similarity of successful shadow translations to the translations
+	// replied, from 0 to 1, by translator
+	ShadowSimilarity *prometheus.HistogramVec
+
+	// Results: "pass", "low", "failed" (back-translation failed), "dropped"
+	// (too many in flight with the flag action).
+	// Quality estimations of translations by translator and result
+	QualityEstimations *prometheus.CounterVec
+
+	// Similarity of back-translations to the original texts, from 0 to 1,
+	// by translator and prompt variant
+	QualityScore *prometheus.HistogramVec
+
+	// Reasons: "refusal", "length", "quality_score".
+	// Translations translated again by another translator as rejected, by
+	// the translator rejected and reason
+	QualityRetries *prometheus.CounterVec
+
+	// Translated messages by detected source language and target language
+	// of the translator, "unknown" if not detected or configured
+	Translations *prometheus.CounterVec
+
+	// Votes: "up", "down".
+	// Feedback votes on translated replies, by translator and source language
+	FeedbackVotes *prometheus.GaugeVec
+
+	// Translated replies by translator and prompt variant, for translators
+	// with variants
+	PromptVariantTranslations *prometheus.CounterVec
+
+	// Votes: "up", "down".
+	// Feedback votes on translated replies, by translator and prompt variant
+	PromptVariantVotes *prometheus.GaugeVec
+
+	// Replies translated again by another translator on request of users,
+	// by previous and new translator
+	Retranslations *prometheus.GaugeVec
+
+	// Seconds until each translator and detector is re-enabled,
+	// computed from the source set by the bot when scraped
+	ComponentCooldown *CooldownCollector
+
+	// Attach exemplars in ObserveWithTrace
+	Exemplars bool
+}
+
+// NewMetrics creates all collectors and registers them on reg.
+// A nil reg creates unregistered collectors.
+func NewMetrics(reg prometheus.Registerer) (m *Metrics) {
+	f := promauto.With(reg)
+	m = &Metrics{
+		Messages: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "messages_total",
+				Help:      "Current number of messages being processed by the bot.",
+			},
+			[]string{"state", "chat_type"},
+		),
+		MessagesDropped: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "messages_dropped_total",
+				Help:      "Total number of messages dropped because the worker queue was full.",
+			},
+			[]string{"overflow_policy", "chat_type"},
+		),
+		MessagesSkipped: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "messages_skipped_total",
+				Help:      "Total number of messages skipped without translation, by content type.",
+			},
+			[]string{"content_type", "chat_type"},
+		),
+		MessagesModerated: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "messages_moderated_total",
+				Help:      "Total number of messages caught by moderation, by action and source.",
+			},
+			[]string{"action", "source", "chat_type"},
+		),
+		RetriesScheduled: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "retries_scheduled_total",
+				Help:      "Total number of retries scheduled, by stage.",
+			},
+			[]string{"stage"},
+		),
+		RetryOutcomes: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "retry_outcome_attempts",
+				Help:      "Retries per message of stages retried at least once, by stage and final outcome.",
+				Buckets:   []float64{1, 2, 3, 5, 10},
+			},
+			[]string{"stage", "outcome"},
+		),
+		MessageLatency: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "message_latency_seconds",
+				Help:      "Seconds from receipt to completion of messages, by chat type and outcome.",
+				Buckets:   latencyBuckets,
+			},
+			[]string{"chat_type", "outcome"},
+		),
+		MessagePhaseLatency: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "message_phase_seconds",
+				Help:      "Seconds spent in each handling phase per message, by phase, chat type and outcome.",
+				Buckets:   latencyBuckets,
+			},
+			[]string{"phase", "chat_type", "outcome"},
+		),
+		Saturation: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "saturation",
+				Help:      "Indicates if the message pipeline is saturated, by reason. 1 for saturated, 0 otherwise.",
+			},
+			[]string{"reason"},
+		),
+		Leader: f.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "leader",
+				Help:      "Indicates if this replica is the leader polling updates. 1 for leader, 0 for standby.",
+			},
+		),
+		TranslatorTasks: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "translator_tasks_total",
+				Help:      "Total number of translation tasks, by state.",
+			},
+			[]string{"state", "translator_name"},
+		),
+		TranslatorTokensUsed: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "translator_tokens_used",
+				Help:      "Used tokens of translation tasks.",
+			},
+			[]string{"token_type", "translator_name"},
+		),
+		TranslatorUp: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "translator_up",
+				Help:      "Indicates if a translator is currently up and operational. 1 for up, 0 for disabled.",
+			},
+			[]string{"translator_name"},
+		),
+		TranslatorHealth: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "translator_health_score",
+				Help:      "Exponentially smoothed health score of a translator from 0 to 1, by success rate and latency.",
+			},
+			[]string{"translator_name"},
+		),
+		TranslatorSelectionTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "translator_selection_total",
+				Help:      "Times of translator instance was chosen.",
+			},
+			[]string{"translator_name"},
+		),
+		DetectorTasks: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "detector_tasks_total",
+				Help:      "Total number of translation tasks, by state.",
+			},
+			[]string{"state", "detector_name"},
+		),
+		DetectorUp: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "detector_up",
+				Help:      "Indicates if a detector is currently up and operational. 1 for up, 0 for disabled.",
+			},
+			[]string{"detector_name"},
+		),
+		DetectorHealth: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "detector_health_score",
+				Help:      "Exponentially smoothed health score of a detector from 0 to 1, by success rate and latency.",
+			},
+			[]string{"detector_name"},
+		),
+		DetectorSelectionTotal: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "detector_selection_total",
+				Help:      "Times of detector instance was chosen.",
+			},
+			[]string{"detector_name"},
+		),
+		DetectorCacheRequests: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "detector_cache_requests_total",
+				Help:      "Lookups of the detection cache by detector name and result.",
+			},
+			[]string{"detector_name", "result"},
+		),
+		LimiterWait: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "limiter_wait_seconds",
+				Help:      "Seconds waited on limiters before upstream calls, by limiter, component and instance.",
+				Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.5, 1, 2.5, 5, 10, 30},
+			},
+			[]string{"limiter", "component", "name"},
+		),
+		UpstreamInFlight: f.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "upstream_in_flight",
+				Help:      "Current number of upstream calls of translators and detectors, if max_in_flight is set.",
+			},
+		),
+		ShadowTranslations: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "shadow_translations_total",
+				Help:      "Shadow translations, by translator and result.",
+			},
+			[]string{"translator_name", "result"},
+		),
+		ShadowLatency: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "shadow_latency_seconds",
+				Help:      "Seconds taken by successful shadow translations, by translator.",
+				Buckets:   latencyBuckets,
+			},
+			[]string{"translator_name"},
+		),
+		ShadowSimilarity: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "shadow_similarity",
+				Help:      "Similarity of shadow translations to the translations replied, from 0 to 1, by translator.",
+				Buckets:   prometheus.LinearBuckets(0.1, 0.1, 10),
+			},
+			[]string{"translator_name"},
+		),
+		QualityEstimations: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "quality_estimations_total",
+				Help:      "Quality estimations of translations by back-translation, by translator and result.",
+			},
+			[]string{"translator_name", "result"},
+		),
+		QualityScore: f.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace: namespace,
+				Name:      "quality_score",
+				Help:      "Similarity of back-translations to the original texts, from 0 to 1, by translator and prompt variant.",
+				Buckets:   prometheus.LinearBuckets(0.1, 0.1, 10),
+			},
+			[]string{"translator_name", "variant"},
+		),
+		QualityRetries: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "quality_retries_total",
+				Help:      "Translations translated again by another translator as rejected, by the translator rejected and reason.",
+			},
+			[]string{"translator_name", "reason"},
+		),
+		Translations: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "translations_total",
+				Help:      "Translated messages, by source and target language.",
+			},
+			[]string{"source_lang", "target_lang"},
+		),
+		FeedbackVotes: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "feedback_votes",
+				Help:      "Feedback votes on translated replies, by vote, translator and source language.",
+			},
+			[]string{"vote", "translator_name", "source_lang"},
+		),
+		PromptVariantTranslations: f.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Name:      "prompt_variant_translations_total",
+				Help:      "Translated replies, by translator and prompt variant.",
+			},
+			[]string{"translator_name", "variant"},
+		),
+		PromptVariantVotes: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "prompt_variant_feedback_votes",
+				Help:      "Feedback votes on translated replies, by vote, translator and prompt variant.",
+			},
+			[]string{"vote", "translator_name", "variant"},
+		),
+		Retranslations: f.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Name:      "retranslations",
+				Help:      "Replies translated again by another translator on request of users, by previous and new translator.",
+			},
+			[]string{"from_translator", "to_translator"},
+		),
+		ComponentCooldown: newCooldownCollector(),
+	}
+	if reg != nil {
+		reg.MustRegister(m.ComponentCooldown)
+	}
+	return
+}
+
+// ObserveWithTrace observes v on o, attaching traceId as an exemplar if
+// exemplars are enabled.
+func (m *Metrics) ObserveWithTrace(o prometheus.Observer, v float64, traceId string) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && m.Exemplars && traceId != "" {
+		eo.ObserveWithExemplar(v, prometheus.Labels{"trace_id": traceId})
+		return
+	}
+	o.Observe(v)
+}
+
+// InitMetricServer serves the default ServeMux with /metrics on conf.Listen.
+// Paths in open, e.g. health probes, are exempt from the source allowlist
+// and client certificates.
+func InitMetricServer(conf MetricConfig, gatherer prometheus.Gatherer, logger *logrus.Entry, open ...string) {
+	sources, err := iplist.Parse(conf.AllowedSources)
+	if err != nil {
+		logger.Fatalf("Invalid metric allowed sources: %v", err)
+	}
+	var tlsConf *tls.Config
+	if conf.TLS.Enabled() {
+		tlsConf, err = conf.TLS.config()
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+	requireCert := tlsConf != nil && tlsConf.ClientCAs != nil
+	server := &http.Server{
+		Addr:              conf.Listen,
+		Handler:           guard(http.DefaultServeMux, sources, requireCert, open, logger),
+		TLSConfig:         tlsConf,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-func InitMetricServer(conf MetricConfig) {
 	go func() {
-		http.Handle("/metrics", promhttp.Handler())
-		logrus.Infof("Metrics server listening on %s", conf.Listen)
-		if err := http.ListenAndServe(conf.Listen, nil); err != nil {
-			logrus.Fatalf("Failed to start metrics server: %v", err)
+		http.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+			EnableOpenMetrics: conf.Exemplars,
+		}))
+		logger.Infof("Metrics server listening on %s", conf.Listen)
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil {
+			logger.Fatalf("Failed to start metrics server: %v", err)
 		}
 	}()
 }
+
+// guard rejects requests from outside sources and, if requireCert,
+// without a verified client certificate.
+func guard(next http.Handler, sources iplist.Allowlist, requireCert bool, open []string, logger *logrus.Entry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(open, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !sources.Allows(r.RemoteAddr) {
+			logger.Warnf("Metrics server rejected request from %s", r.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if requireCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			logger.Warnf("Metrics server rejected request without client certificate from %s", r.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const (
+	// Moderated messages are not translated
+	moderationActionSkip = "skip"
+	// Moderated messages are translated, but logged and counted
+	moderationActionFlag = "flag"
+
+	moderationSourcePattern = "pattern"
+	moderationSourceOpenAI  = "openai"
+
+	defaultModerationEndpoint = "https://api.openai.com/v1"
+	defaultModerationModel    = "omni-moderation-latest"
+)
+
+type ModerationConfig struct {
+	// Check texts before they are sent to detectors and translators
+	Enabled bool `yaml:"enabled"`
+
+	// "skip" (default) or "flag"
+	Action string `yaml:"action"`
+
+	// Optional. Regular expressions moderating the texts they match
+	Patterns []string `yaml:"patterns"`
+
+	// Optional. Asked for texts no pattern matches
+	OpenAI OpenAIModerationConfig `yaml:"openai"`
+
+	// Translate messages if the moderation endpoint fails,
+	// instead of failing them
+	FailOpen bool `yaml:"fail_open"`
+
+	patterns []*regexp.Regexp
+}
+
+type OpenAIModerationConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. OpenAI API by default
+	Endpoint string `yaml:"endpoint"`
+
+	// Optional
+	Token string `yaml:"token" redact:"true"`
+
+	// Optional. File holding the token instead
+	TokenFile string `yaml:"token_file"`
+
+	// Optional. omni-moderation-latest by default
+	Model string `yaml:"model"`
+
+	// Positive. Timeout in seconds
+	Timeout int64 `yaml:"timeout"`
+
+	// Optional. Categories moderating a text, e.g. "violence", any flagged
+	// by the endpoint if empty
+	Categories []string `yaml:"categories"`
+}
+
+func (mc *ModerationConfig) Check() (err error) {
+	if !mc.Enabled {
+		return
+	}
+	switch mc.Action {
+	case "":
+		mc.Action = moderationActionSkip
+	case moderationActionSkip, moderationActionFlag:
+	default:
+		err = fmt.Errorf("moderation: invalid action: %s", mc.Action)
+		return
+	}
+
+	mc.patterns = nil
+	for _, p := range mc.Patterns {
+		var re *regexp.Regexp
+		re, err = regexp.Compile(p)
+		if err != nil {
+			err = fmt.Errorf("moderation: invalid pattern '%s': %w", p, err)
+			return
+		}
+		mc.patterns = append(mc.patterns, re)
+	}
+
+	oc := &mc.OpenAI
+	if !oc.Enabled {
+		return
+	}
+	if oc.Endpoint == "" {
+		oc.Endpoint = defaultModerationEndpoint
+	}
+	if oc.Model == "" {
+		oc.Model = defaultModerationModel
+	}
+	if oc.Timeout <= 0 {
+		err = fmt.Errorf("moderation: openai timeout must be positive")
+		return
+	}
+	err = common.LoadSecretFile(&oc.Token, oc.TokenFile)
+	if err != nil {
+		err = fmt.Errorf("moderation: openai token: %w", err)
+	}
+	return
+}
+
+// moderator checks texts against the moderation rules.
+type moderator struct {
+	conf   ModerationConfig
+	client *openai.Client
+}
+
+func newModerator(conf ModerationConfig) *moderator {
+	if !conf.Enabled {
+		return nil
+	}
+	m := &moderator{conf: conf}
+	if conf.OpenAI.Enabled {
+		opts := []option.RequestOption{option.WithBaseURL(conf.OpenAI.Endpoint)}
+		if conf.OpenAI.Token != "" {
+			opts = append(opts, option.WithAPIKey(conf.OpenAI.Token))
+		}
+		client := openai.NewClient(opts...)
+		m.client = &client
+	}
+	return m
+}
+
+// check returns the source moderating text, "" if none does.
+func (m *moderator) check(ctx context.Context, text string) (source string, categories []string, err error) {
+	for _, re := range m.conf.patterns {
+		if re.MatchString(text) {
+			source = moderationSourcePattern
+			return
+		}
+	}
+	if m.client == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(m.conf.OpenAI.Timeout)*time.Second)
+	defer cancel()
+	resp, err := m.client.Moderations.New(ctx, openai.ModerationNewParams{
+		Input: openai.ModerationNewParamsInputUnion{OfString: openai.String(text)},
+		Model: openai.ModerationModel(m.conf.OpenAI.Model),
+	})
+	if err != nil {
+		err = fmt.Errorf("moderation request failed: %w", err)
+		return
+	}
+	for _, r := range resp.Results {
+		if !r.Flagged {
+			continue
+		}
+		// Categories are decoded by name, so new ones can be configured
+		// without an update of the client
+		var flagged map[string]bool
+		if err = json.Unmarshal([]byte(r.Categories.RawJSON()), &flagged); err != nil {
+			err = fmt.Errorf("parse moderation categories failed: %w", err)
+			return
+		}
+		for c, ok := range flagged {
+			if ok && (len(m.conf.OpenAI.Categories) == 0 || slices.Contains(m.conf.OpenAI.Categories, c)) {
+				categories = append(categories, c)
+			}
+		}
+	}
+	if len(categories) > 0 {
+		source = moderationSourceOpenAI
+		sort.Strings(categories)
+	}
+	return
+}
+
+// moderate reports whether text of msg may be translated. Skipped and
+// failed messages are completed here.
+func (b *Bot) moderate(ctx context.Context, msg *Message, text string) bool {
+	b.configMu.RLock()
+	m := b.moderator
+	b.configMu.RUnlock()
+	if m == nil || strings.TrimSpace(text) == "" {
+		return true
+	}
+
+	source, categories, err := m.check(ctx, text)
+	if err != nil {
+		if m.conf.FailOpen {
+			msg.logger.Warnf("%v, translating anyway", err)
+			return true
+		}
+		msg.logger.Error(err)
+		msg.onMessageHandleFailed()
+		return false
+	}
+	if source == "" {
+		return true
+	}
+
+	b.metrics.MessagesModerated.WithLabelValues(m.conf.Action, source, msg.ChatType).Inc()
+	logger := msg.logger.WithField("moderation_source", source)
+	if len(categories) > 0 {
+		logger = logger.WithField("moderation_categories", strings.Join(categories, ","))
+	}
+	if m.conf.Action == moderationActionFlag {
+		logger.Warn("message flagged by moderation")
+		return true
+	}
+	logger.Info("message skipped by moderation")
+	msg.onModerated()
+	return false
+}
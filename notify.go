@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Operational events posted to notification webhooks
+const (
+	// A translator or detector is disabled until the config is reloaded
+	eventComponentDisabled = "component_disabled"
+	// A chat reached the hard cap of its daily quota
+	eventQuotaExhausted = "quota_exhausted"
+	// Reloading the config failed, the previous config is kept
+	eventReloadFailed = "reload_failed"
+)
+
+var allEvents = []string{
+	eventComponentDisabled,
+	eventQuotaExhausted,
+	eventReloadFailed,
+}
+
+// Payload formats of notification webhooks
+const (
+	// NotificationPayload
+	notifyFormatJSON = "json"
+	// {"text": "..."}, Slack incoming webhooks
+	notifyFormatSlack = "slack"
+	// {"content": "..."}, Discord webhooks
+	notifyFormatDiscord = "discord"
+
+	// Maximum content length of Discord webhooks
+	discordContentLimit = 2000
+)
+
+type NotificationsConfig struct {
+	// Optional. Webhooks posted to on operational events
+	Webhooks []NotificationWebhookConfig `yaml:"webhooks"`
+}
+
+type NotificationWebhookConfig struct {
+	// Required
+	URL string `yaml:"url"`
+
+	// "json" (default), "slack" or "discord"
+	Format string `yaml:"format"`
+
+	// Optional. Extra HTTP headers, e.g. Authorization
+	Headers map[string]string `yaml:"headers"`
+
+	// Positive. Timeout in seconds
+	Timeout int64 `yaml:"timeout"`
+
+	// Optional. Events posted, all if empty
+	Events []string `yaml:"events"`
+}
+
+func (nc *NotificationsConfig) Check() (err error) {
+	for i := range nc.Webhooks {
+		wc := &nc.Webhooks[i]
+		if wc.URL == "" {
+			err = fmt.Errorf("notifications: webhook %d: url is required", i)
+			return
+		}
+		switch wc.Format {
+		case "":
+			wc.Format = notifyFormatJSON
+		case notifyFormatJSON, notifyFormatSlack, notifyFormatDiscord:
+		default:
+			err = fmt.Errorf("notifications: webhook %d: unrecognized format: %s", i, wc.Format)
+			return
+		}
+		if wc.Timeout <= 0 {
+			err = fmt.Errorf("notifications: webhook %d: timeout must be positive", i)
+			return
+		}
+		for _, e := range wc.Events {
+			if !slices.Contains(allEvents, e) {
+				err = fmt.Errorf("notifications: webhook %d: unknown event: %s", i, e)
+				return
+			}
+		}
+	}
+	return
+}
+
+// NotificationPayload is the JSON body posted in the "json" format.
+type NotificationPayload struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	Text  string    `json:"text"`
+
+	// Details by event, e.g. "chat_id"
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+// notify posts an event to the notification webhooks subscribed to it,
+// in the background.
+func (b *Bot) notify(event, text string, fields map[string]any) {
+	b.configMu.RLock()
+	webhooks := b.notifications.Webhooks
+	b.configMu.RUnlock()
+
+	payload := NotificationPayload{
+		Event:  event,
+		Time:   time.Now().UTC(),
+		Text:   text,
+		Fields: fields,
+	}
+	for _, wc := range webhooks {
+		if len(wc.Events) > 0 && !slices.Contains(wc.Events, event) {
+			continue
+		}
+		go postNotification(wc, payload)
+	}
+}
+
+func postNotification(wc NotificationWebhookConfig, payload NotificationPayload) {
+	var body any = payload
+	switch wc.Format {
+	case notifyFormatSlack:
+		body = map[string]string{"text": payload.Text}
+	case notifyFormatDiscord:
+		body = map[string]string{"content": truncateUTF8(payload.Text, discordContentLimit)}
+	}
+
+	timeout := time.Duration(wc.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := postJSON(ctx, &http.Client{Timeout: timeout}, wc.URL, wc.Headers, body)
+	if err != nil {
+		logrus.WithField("event", payload.Event).Errorf("an error occurred while posting notification: %v", err)
+	}
+}
+
+// onComponentDisabled records a component disabled by failover, notifying
+// if it is disabled until the config is reloaded.
+func (b *Bot) onComponentDisabled(component, name string, permanent bool) {
+	b.recordFailover(component, name, permanent)
+	if permanent {
+		b.notify(eventComponentDisabled,
+			fmt.Sprintf("%s %s is disabled until the config is reloaded", component, name),
+			map[string]any{"component": component, "name": name})
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+	"github.com/sirupsen/logrus"
+)
+
+// Names of the built-in stages, in order
+const (
+	stageAuthorize   = "authorize"
+	stageCommand     = "command"
+	stageDocument    = "document"
+	stagePlaceholder = "placeholder"
+	stageTranscribe  = "transcribe"
+	stageModerate    = "moderate"
+	stagePhoto       = "photo"
+	stageDetect      = "detect"
+	stageRoute       = "route"
+	stageTranslate   = "translate"
+	stageDeliver     = "deliver"
+)
+
+// messageState is passed through the stages handling a message.
+type messageState struct {
+	ctx context.Context
+	msg *Message
+	ts  *translate.TranslateService
+
+	// Text of the message replied to, given to translators as context
+	replyContext string
+
+	// Set by the route stage
+	req translator.TranslateRequest
+
+	// Set by the translate stage
+	resp           *translator.TranslateResponse
+	translatorName string
+}
+
+// messageStage is a step of handling a message. run returns false once the
+// message is completed or handed off, e.g. failed, scheduled for a retry or
+// added to a batch, ending the pipeline.
+type messageStage struct {
+	name string
+	run  func(b *Bot, s *messageState) bool
+}
+
+func defaultStages() []messageStage {
+	return []messageStage{
+		{stageAuthorize, (*Bot).authorizeStage},
+		{stageCommand, (*Bot).commandStage},
+		{stageDocument, (*Bot).documentStage},
+		{stagePlaceholder, (*Bot).placeholderStage},
+		{stageTranscribe, (*Bot).transcribeStage},
+		{stageModerate, (*Bot).moderateStage},
+		{stagePhoto, (*Bot).photoStage},
+		{stageDetect, (*Bot).detectStage},
+		{stageRoute, (*Bot).routeStage},
+		{stageTranslate, (*Bot).translateStage},
+		{stageDeliver, (*Bot).deliverStage},
+	}
+}
+
+// InsertStage adds stage to the pipeline after the stage named after, e.g.
+// a glossary lookup after detection. Stages must be inserted before the bot
+// starts.
+func (b *Bot) InsertStage(after string, stage messageStage) (err error) {
+	if slices.ContainsFunc(b.stages, func(s messageStage) bool { return s.name == stage.name }) {
+		err = fmt.Errorf("stage already registered: %s", stage.name)
+		return
+	}
+	i := slices.IndexFunc(b.stages, func(s messageStage) bool { return s.name == after })
+	if i < 0 {
+		err = fmt.Errorf("stage not found: %s", after)
+		return
+	}
+	b.stages = slices.Insert(b.stages, i+1, stage)
+	return
+}
+
+func (b *Bot) authorizeStage(s *messageState) bool {
+	if !b.isAllowed(s.msg) {
+		s.msg.onUnauthorized()
+		return false
+	}
+	return true
+}
+
+func (b *Bot) commandStage(s *messageState) bool {
+	if !s.msg.command {
+		return true
+	}
+	b.handleCommand(s.msg)
+	s.msg.onSuccess()
+	return false
+}
+
+func (b *Bot) documentStage(s *messageState) bool {
+	if !s.msg.translateDoc {
+		return true
+	}
+	b.handleDocument(s.ctx, s.msg, s.ts)
+	return false
+}
+
+func (b *Bot) placeholderStage(s *messageState) bool {
+	b.sendPlaceholder(s.msg)
+	return true
+}
+
+func (b *Bot) transcribeStage(s *messageState) bool {
+	msg := s.msg
+	if !msg.transcribe {
+		return true
+	}
+	err := b.transcribeVoice(s.ctx, msg, s.ts)
+	if err != nil {
+		msg.onMessageHandleFailed()
+		msg.logger.Error(err)
+		return false
+	}
+	msg.transcribe = false
+	return true
+}
+
+// moderateStage checks photo captions only, the text in photos is unknown
+// until translated. The context is sent to translators as well. Messages
+// passed aren't checked again on retries.
+func (b *Bot) moderateStage(s *messageState) bool {
+	msg := s.msg
+	s.replyContext = b.replyContext(msg)
+	if !msg.moderated {
+		if !b.moderate(s.ctx, msg, strings.TrimSpace(s.replyContext+"\n\n"+msg.Content)) {
+			return false
+		}
+		msg.moderated = true
+	}
+	// Retries run the stages again
+	if !msg.historyRecorded {
+		b.recordHistory(msg, msg.Content)
+		msg.historyRecorded = true
+	}
+	return true
+}
+
+func (b *Bot) photoStage(s *messageState) bool {
+	msg := s.msg
+	if !msg.vision {
+		return true
+	}
+	err := b.downloadPhoto(msg)
+	if err != nil {
+		msg.onMessageHandleFailed()
+		msg.logger.Error(err)
+		return false
+	}
+	return true
+}
+
+// detectStage leaves the language of text in photos to the translator.
+func (b *Bot) detectStage(s *messageState) bool {
+	msg, ts := s.msg, s.ts
+	if msg.lang != nil || msg.segmented || msg.vision {
+		return true
+	}
+
+	start := time.Now()
+	langResp, detectorName, err := ts.DetectLangOnce(s.ctx, detector.DetectRequest{
+		Text:    msg.Content,
+		TraceId: msg.TraceId,
+	})
+	msg.addPhase(latencyPhaseDetect, start)
+	if detectorName != "" {
+		msg.logger = msg.logger.WithField("detector_name", detectorName)
+	}
+	if langResp != nil {
+		msg.logger = msg.logger.WithFields(logrus.Fields{
+			"lang":            langResp.Language,
+			"lang_confidence": langResp.Confidence,
+		})
+	}
+	if err != nil && detector.CheckWeakError(err) && ts.SegmentationEnabled() {
+		msg.logger.Debugf("%v, translating sentences separately", err)
+		msg.segmented = true
+	} else if err != nil {
+		if b.scheduleRetry(msg, ts, err, retryStageDetect, &msg.detectRetries) {
+			return false
+		}
+		msg.logger.Warn(err)
+		msg.onMessageHandleFailed()
+		return false
+	}
+	msg.onRetriesDone(retryStageDetect, msg.detectRetries, retryOutcomeRecovered)
+	msg.lang = langResp
+	msg.detectorName = detectorName
+	return true
+}
+
+// routeStage builds the translation request.
+func (b *Bot) routeStage(s *messageState) bool {
+	msg := s.msg
+	s.req = translator.TranslateRequest{
+		Text:       msg.Content,
+		TraceId:    msg.TraceId,
+		Context:    s.replyContext,
+		Metadata:   b.promptMetadata(msg),
+		RoutingKey: msg.routingKey(),
+	}
+	if msg.lang != nil {
+		s.req.SourceLang = msg.lang.Language
+	}
+	return true
+}
+
+func (b *Bot) translateStage(s *messageState) bool {
+	msg, ts, req := s.msg, s.ts, s.req
+	var err error
+	start := time.Now()
+	if msg.vision {
+		req.Image = &translator.Image{Data: msg.image, MimeType: msg.imageType}
+		s.resp, s.translatorName, err = ts.TranslateImageOnce(s.ctx, req)
+	} else if isSubtitles(msg.Content) {
+		s.resp, s.translatorName, err = b.translateSubtitlesOnce(s.ctx, ts, req)
+	} else if msg.segmented {
+		s.resp, msg.lang, s.translatorName, err = ts.TranslateSegmentsOnce(s.ctx, req)
+		if errors.Is(err, translate.ErrNothingToTranslate) {
+			msg.logger.Warn(err)
+			msg.onMessageHandleFailed()
+			return false
+		}
+	} else {
+		s.resp, s.translatorName, err = ts.TranslateOnce(s.ctx, req)
+	}
+	msg.addPhase(latencyPhaseTranslate, start)
+	if s.translatorName != "" {
+		msg.logger = msg.logger.WithField("translator_name", s.translatorName)
+	}
+	if err != nil {
+		if b.scheduleRetry(msg, ts, err, retryStageTranslate, &msg.translateRetries) {
+			return false
+		}
+		b.replyError(msg)
+		msg.onMessageHandleFailed()
+
+		var te = new(common.HTTPError)
+		if errors.As(err, &te) {
+			body := !privacyMode
+			msg.logger.Debugf("http request: %s", base64.StdEncoding.EncodeToString(te.DumpRequest(body)))
+			msg.logger.Debugf("http response: %s", base64.StdEncoding.EncodeToString(te.DumpResponse(body)))
+		}
+		msg.logger.Errorf("an error occurred while translating: %v", err)
+		return false
+	}
+	msg.onRetriesDone(retryStageTranslate, msg.translateRetries, retryOutcomeRecovered)
+	return true
+}
+
+func (b *Bot) deliverStage(s *messageState) bool {
+	b.deliverTranslation(s.ctx, s.msg, s.ts, s.req, s.resp, s.translatorName)
+	return false
+}
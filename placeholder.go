@@ -0,0 +1,76 @@
+package main
+
+type PlaceholderConfig struct {
+	// Reply a placeholder right away and edit it with the translation,
+	// on platforms supporting edits
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Localized "Translating…" if empty
+	Text string `yaml:"text"`
+
+	// Optional. Replaces the placeholder if translating fails,
+	// localized "Translation failed." if empty
+	FailedText string `yaml:"failed_text"`
+}
+
+// sendPlaceholder replies the placeholder to msg once, so the user knows the
+// message is being translated. Failures are logged only.
+func (b *Bot) sendPlaceholder(msg *Message) {
+	if msg.placeholder != nil || !msg.adapter.Capabilities().EditReply {
+		return
+	}
+
+	b.configMu.RLock()
+	conf := b.placeholder
+	replyOpts := ReplyOptions{
+		DisableNotification: b.messageSettings.DisableNotification,
+		DisableLinkPreview:  b.messageSettings.DisableLinkPreview,
+	}
+	webhookOut := b.webhookOut
+	b.configMu.RUnlock()
+
+	if !conf.Enabled || (webhookOut != nil && webhookOut.ReplaceReply()) || b.digested(msg) {
+		return
+	}
+	// Translations appended to posts need no placeholder
+	mode := b.replyMode(msg)
+	if mode == replyModeEdit {
+		return
+	}
+	replyOpts.Standalone = mode == replyModeMessage
+
+	text := conf.Text
+	if text == "" {
+		text = b.text(msg, msgPlaceholder)
+	}
+	sent, err := msg.adapter.Reply(msg, text, replyOpts)
+	if err != nil {
+		msg.logger.Warnf("an error occurred while replying placeholder: %v", err)
+		return
+	}
+	msg.placeholder = sent
+	msg.placeholderFailedText = conf.FailedText
+	if msg.placeholderFailedText == "" {
+		msg.placeholderFailedText = b.text(msg, msgPlaceholderFailed)
+	}
+}
+
+// reply edits the placeholder of msg with text if there is one,
+// otherwise replies text to msg.
+func (m *Message) reply(text string, opts ReplyOptions) (sent *SentReply, err error) {
+	if m.placeholder != nil {
+		return m.placeholder, m.adapter.EditReply(m.placeholder, text, opts)
+	}
+	return m.adapter.Reply(m, text, opts)
+}
+
+// failPlaceholder replaces the placeholder of msg after translating failed.
+func (m *Message) failPlaceholder() {
+	if m.placeholder == nil || m.placeholderFailedText == "" {
+		return
+	}
+	err := m.adapter.EditReply(m.placeholder, m.placeholderFailedText, ReplyOptions{})
+	if err != nil {
+		m.logger.Warnf("an error occurred while editing placeholder: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+)
+
+// privacyMode keeps message content out of logs and HTTP dumps,
+// only hashes and metadata are logged. Set from Config.Privacy at startup.
+var privacyMode bool
+
+// redact returns text, or its length and a short hash in privacy mode,
+// so log lines about the same text can still be correlated.
+func redact(text string) string {
+	if !privacyMode {
+		return text
+	}
+	sum := sha256.Sum256([]byte(text))
+	return fmt.Sprintf("<redacted %d bytes, sha256:%x>", len(text), sum[:8])
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+const defaultPromptMetadataTemplate = `The message was sent by {{.Sender}}` +
+	`{{if .Username}} (@{{.Username}}){{end}}` +
+	`{{if .ChatTitle}} in the chat "{{.ChatTitle}}"{{end}}.`
+
+type PromptMetadataConfig struct {
+	// Give translators the sender's name and the chat title, so they can
+	// resolve first and second person and keep names. Off for privacy
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Chat IDs in which metadata is given, all authorized chats if empty
+	Chats []int64 `yaml:"chats"`
+
+	// Optional. May use {{.Sender}}, {{.Username}}, {{.ChatTitle}},
+	// {{.ChatType}} and {{.Platform}}. A sentence naming sender and chat if empty
+	Template string `yaml:"template"`
+
+	template *template.Template
+}
+
+func (pc *PromptMetadataConfig) Check() (err error) {
+	if !pc.Enabled {
+		return
+	}
+	text := pc.Template
+	if text == "" {
+		text = defaultPromptMetadataTemplate
+	}
+	pc.template, err = template.New("prompt_metadata").Parse(text)
+	if err != nil {
+		err = fmt.Errorf("invalid prompt metadata template: %w", err)
+	}
+	return
+}
+
+type promptMetadataData struct {
+	Sender    string
+	Username  string
+	ChatTitle string
+	ChatType  string
+	Platform  string
+}
+
+// promptMetadata returns the metadata of msg given to translators,
+// "" if none is given.
+func (b *Bot) promptMetadata(msg *Message) string {
+	b.configMu.RLock()
+	conf := b.promptMeta
+	b.configMu.RUnlock()
+
+	if !conf.Enabled || msg.SenderName == "" {
+		return ""
+	}
+	if len(conf.Chats) > 0 && !slices.Contains(conf.Chats, msg.ChatID) {
+		return ""
+	}
+	var sb strings.Builder
+	err := conf.template.Execute(&sb, promptMetadataData{
+		Sender:    msg.SenderName,
+		Username:  msg.SenderUsername,
+		ChatTitle: msg.ChatTitle,
+		ChatType:  msg.ChatType,
+		Platform:  msg.Platform,
+	})
+	if err != nil {
+		msg.logger.Warnf("an error occurred while executing prompt metadata template: %v", err)
+		return ""
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type QuotaRule struct {
+	// Optional. Translations per UTC day after which the chat is warned once,
+	// no warning if 0
+	Soft int64 `yaml:"soft"`
+
+	// Optional. Translations per UTC day after which translating stops until
+	// the next day, unlimited if 0
+	Hard int64 `yaml:"hard"`
+}
+
+func (qr *QuotaRule) Check() (err error) {
+	if qr.Soft < 0 || qr.Hard < 0 {
+		err = fmt.Errorf("quota caps must not be negative")
+		return
+	}
+	if qr.Soft > 0 && qr.Hard > 0 && qr.Soft >= qr.Hard {
+		err = fmt.Errorf("soft quota cap must be below the hard cap")
+	}
+	return
+}
+
+type QuotaConfig struct {
+	// Applies to chats without their own rule
+	Default QuotaRule `yaml:"default"`
+
+	// Optional. Rules by chat ID
+	Chats map[int64]QuotaRule `yaml:"chats"`
+}
+
+func (qc *QuotaConfig) Check() (err error) {
+	err = qc.Default.Check()
+	if err != nil {
+		return
+	}
+	for k, r := range qc.Chats {
+		if err = r.Check(); err != nil {
+			err = fmt.Errorf("chat '%d': %w", k, err)
+			return
+		}
+	}
+	return
+}
+
+func (qc *QuotaConfig) rule(chatId int64) QuotaRule {
+	if r, ok := qc.Chats[chatId]; ok {
+		return r
+	}
+	return qc.Default
+}
+
+// quotaState holds the quota notifications sent today, persisted by the
+// StateStore. Translations are counted by the usageState.
+type quotaState struct {
+	// Notifications by usageChatKey
+	Notices map[string]*quotaNotice `json:"notices"`
+}
+
+type quotaNotice struct {
+	// UTC day of the notifications
+	Day  string `json:"day"`
+	Soft bool   `json:"soft"`
+	Hard bool   `json:"hard"`
+}
+
+func (qs *quotaState) init() {
+	if qs.Notices == nil {
+		qs.Notices = make(map[string]*quotaNotice)
+	}
+}
+
+// checkQuota reports whether msg may be translated under the daily quota
+// of its chat. The chat is notified once a day when reaching each cap.
+// Unauthorized messages are left to handleMessage.
+func (b *Bot) checkQuota(msg *Message) bool {
+	b.configMu.RLock()
+	rule := b.quota.rule(msg.ChatID)
+	b.configMu.RUnlock()
+	if (rule.Soft == 0 && rule.Hard == 0) || !b.isAllowed(msg) {
+		return true
+	}
+
+	day := time.Now().UTC().Format(usageDayLayout)
+	key := usageChatKey(msg.Platform, msg.ChatID)
+	allowed := true
+	var used int64
+	var notice string
+	b.state.update(func(state *botState) {
+		if u, ok := state.Usage.Chats[key][day]; ok {
+			used = u.Messages
+		}
+		n := state.Quota.Notices[key]
+		if n == nil || n.Day != day {
+			// Drop notices of previous days
+			for k, other := range state.Quota.Notices {
+				if other.Day != day {
+					delete(state.Quota.Notices, k)
+				}
+			}
+			n = &quotaNotice{Day: day}
+			state.Quota.Notices[key] = n
+		}
+
+		switch {
+		case rule.Hard > 0 && used >= rule.Hard:
+			allowed = false
+			if !n.Hard {
+				n.Hard = true
+				notice = msgQuotaHard
+			}
+		case rule.Soft > 0 && used >= rule.Soft && !n.Soft:
+			n.Soft = true
+			notice = msgQuotaSoft
+		}
+	})
+
+	switch notice {
+	case msgQuotaHard:
+		go b.replyText(msg, b.text(msg, msgQuotaHard, rule.Hard))
+		b.notify(eventQuotaExhausted,
+			fmt.Sprintf("chat %s reached its daily quota of %d translations", key, rule.Hard),
+			map[string]any{"platform": msg.Platform, "chat_id": msg.ChatID, "hard": rule.Hard})
+	case msgQuotaSoft:
+		if rule.Hard > 0 {
+			go b.replyText(msg, b.text(msg, msgQuotaSoft, used, rule.Hard))
+		} else {
+			go b.replyText(msg, b.text(msg, msgQuotaSoftUnlimited, used))
+		}
+	}
+	return allowed
+}
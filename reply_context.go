@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+type ReplyContextConfig struct {
+	// Give translators the text of the message replied to as context,
+	// so short answers translate sensibly
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Chat IDs in which context is given, all authorized chats if empty
+	Chats []int64 `yaml:"chats"`
+
+	// Positive. Longer texts are cut, in characters
+	MaxLength int `yaml:"max_length"`
+}
+
+func (rc *ReplyContextConfig) Check() (err error) {
+	if rc.Enabled && rc.MaxLength <= 0 {
+		err = fmt.Errorf("reply context max length must be positive")
+	}
+	return
+}
+
+// replyContext returns the text msg replies to if given as context,
+// "" otherwise.
+func (b *Bot) replyContext(msg *Message) string {
+	b.configMu.RLock()
+	conf := b.replyContextConf
+	b.configMu.RUnlock()
+
+	if !conf.Enabled || msg.ReplyTo == "" {
+		return ""
+	}
+	if len(conf.Chats) > 0 && !slices.Contains(conf.Chats, msg.ChatID) {
+		return ""
+	}
+	if r := []rune(msg.ReplyTo); len(r) > conf.MaxLength {
+		return string(r[:conf.MaxLength]) + "…"
+	}
+	return msg.ReplyTo
+}
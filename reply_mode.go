@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// How translations are delivered
+const (
+	// Reply to the original message
+	replyModeReply = "reply"
+	// Send a standalone message
+	replyModeMessage = "message"
+	// Append to the original channel post, replies elsewhere
+	replyModeEdit = "edit"
+)
+
+var replyModes = []string{
+	replyModeReply,
+	replyModeMessage,
+	replyModeEdit,
+}
+
+// PostEditAdapter is implemented by adapters able to edit received
+// messages, e.g. Telegram channel posts if the bot is an admin.
+type PostEditAdapter interface {
+	// AppendToPost appends text to the text or caption of msg.
+	AppendToPost(msg *Message, text string, opts ReplyOptions) (*SentReply, error)
+}
+
+type ReplyModeConfig struct {
+	// Mode of chats without their own, reply if empty
+	Default string `yaml:"default"`
+
+	// Optional. Modes by chat ID
+	Chats map[int64]string `yaml:"chats"`
+}
+
+func (rc *ReplyModeConfig) Check() (err error) {
+	if rc.Default != "" && !slices.Contains(replyModes, rc.Default) {
+		err = fmt.Errorf("invalid reply mode: %s", rc.Default)
+		return
+	}
+	for k, m := range rc.Chats {
+		if !slices.Contains(replyModes, m) {
+			err = fmt.Errorf("chat '%d': invalid reply mode: %s", k, m)
+			return
+		}
+	}
+	return
+}
+
+func (rc *ReplyModeConfig) mode(chatId int64) string {
+	if m, ok := rc.Chats[chatId]; ok {
+		return m
+	}
+	if rc.Default != "" {
+		return rc.Default
+	}
+	return replyModeReply
+}
+
+// replyMode returns the reply mode of msg, falling back to reply if
+// msg can't be edited.
+func (b *Bot) replyMode(msg *Message) string {
+	b.configMu.RLock()
+	mode := b.replyModes.mode(msg.ChatID)
+	b.configMu.RUnlock()
+
+	if mode == replyModeEdit {
+		if _, ok := msg.adapter.(PostEditAdapter); !ok || msg.ChatType != "channel" {
+			return replyModeReply
+		}
+	}
+	return mode
+}
+
+// deliver sends the translation of msg in the reply mode of its chat.
+// If appending to the post fails, e.g. as the bot isn't an admin or the
+// post would get too long, it replies instead.
+func (b *Bot) deliver(msg *Message, text string, opts ReplyOptions) (*SentReply, error) {
+	switch b.replyMode(msg) {
+	case replyModeEdit:
+		sent, err := msg.adapter.(PostEditAdapter).AppendToPost(msg, text, opts)
+		if err == nil {
+			return sent, nil
+		}
+		msg.logger.Warnf("an error occurred while appending to post, replying instead: %v", err)
+	case replyModeMessage:
+		opts.Standalone = true
+	}
+	return msg.reply(text, opts)
+}
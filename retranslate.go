@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	callbackRetranslate = "retranslate"
+
+	// Replies open for retranslation, the oldest are dropped beyond
+	maxRetranslations = 1000
+)
+
+// retranslation is a reply that may be translated again by another
+// translator. Kept in memory only, as it holds the original text.
+type retranslation struct {
+	msg  *Message
+	sent *SentReply
+	req  translator.TranslateRequest
+	opts ReplyOptions
+
+	// Append the detection footer
+	footer bool
+
+	// Translator of the current text of the reply
+	translator string
+
+	time time.Time
+	busy bool
+}
+
+type retranslations struct {
+	mu      sync.Mutex
+	entries map[string]*retranslation
+}
+
+func newRetranslations() *retranslations {
+	return &retranslations{entries: make(map[string]*retranslation)}
+}
+
+// add opens a reply for retranslation, dropping replies older than maxAge
+// and the oldest beyond maxRetranslations.
+func (rs *retranslations) add(key string, r *retranslation, maxAge time.Duration) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for k, other := range rs.entries {
+		if r.time.Sub(other.time) > maxAge {
+			delete(rs.entries, k)
+		}
+	}
+	for len(rs.entries) >= maxRetranslations {
+		oldest := ""
+		for k, other := range rs.entries {
+			if oldest == "" || other.time.Before(rs.entries[oldest].time) {
+				oldest = k
+			}
+		}
+		delete(rs.entries, oldest)
+	}
+	rs.entries[key] = r
+}
+
+// acquire returns a copy of the reply of key and marks it busy until
+// released. busy is true if the reply is being translated already.
+func (rs *retranslations) acquire(key string) (r retranslation, ok, busy bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	entry, ok := rs.entries[key]
+	if !ok {
+		return
+	}
+	if entry.busy {
+		return r, true, true
+	}
+	entry.busy = true
+	return *entry, true, false
+}
+
+// release ends a retranslation, translatorName is the translator of the
+// new text of the reply, empty if unchanged.
+func (rs *retranslations) release(key, translatorName string) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if entry, ok := rs.entries[key]; ok {
+		entry.busy = false
+		if translatorName != "" {
+			entry.translator = translatorName
+		}
+	}
+}
+
+// forget drops the replies to messages of a user, returning their number.
+func (rs *retranslations) forget(platform string, userId int64) (deleted int) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for k, r := range rs.entries {
+		if r.msg.Platform == platform && r.msg.UserID == userId {
+			delete(rs.entries, k)
+			deleted++
+		}
+	}
+	return
+}
+
+// retranslateEnabled reports whether the reply to msg gets a button
+// translating it again. Only plain text translations delivered as replies
+// that can be edited qualify.
+func (b *Bot) retranslateEnabled(msg *Message) bool {
+	b.configMu.RLock()
+	enabled := b.feedback.Retranslate
+	b.configMu.RUnlock()
+	return enabled && b.feedbackEnabled(msg) && msg.adapter.Capabilities().EditReply &&
+		!msg.vision && !msg.segmented && !isSubtitles(msg.Content) &&
+		b.replyMode(msg) != replyModeEdit
+}
+
+func (b *Bot) retranslateButton(msg *Message) ReplyButton {
+	return ReplyButton{Text: b.text(msg, msgRetranslateButton), Data: callbackRetranslate}
+}
+
+// trackRetranslation opens a sent reply for retranslation.
+func (b *Bot) trackRetranslation(msg *Message, sent *SentReply, req translator.TranslateRequest, opts ReplyOptions, footer bool, translatorName string) {
+	b.configMu.RLock()
+	maxAge := time.Duration(b.feedback.MaxAgeDays) * 24 * time.Hour
+	b.configMu.RUnlock()
+
+	b.retranslations.add(feedbackReplyKey(msg.Platform, sent.ChatID, sent.MessageID), &retranslation{
+		msg:        msg,
+		sent:       sent,
+		req:        req,
+		opts:       opts,
+		footer:     footer,
+		translator: translatorName,
+		time:       time.Now(),
+	}, maxAge)
+}
+
+// handleRetranslate translates the reply of cb again with the next
+// translator after the one that translated it, then edits the reply.
+func (b *Bot) handleRetranslate(cb *Callback) {
+	key := feedbackReplyKey(cb.Platform, cb.ChatID, cb.MessageID)
+	r, ok, busy := b.retranslations.acquire(key)
+
+	answer := b.localize(cb.ChatID, cb.LanguageCode, msgRetranslating)
+	if !ok {
+		answer = b.localize(cb.ChatID, cb.LanguageCode, msgRetranslateEnded)
+	}
+	if err := cb.Answer(answer); err != nil {
+		logrus.Debugf("an error occurred while answering retranslation: %v", err)
+	}
+	if ok && !busy {
+		go b.retranslate(key, r)
+	}
+}
+
+func (b *Bot) retranslate(key string, r retranslation) {
+	name := ""
+	defer func() { b.retranslations.release(key, name) }()
+
+	logger := r.msg.logger.WithField("previous_translator_name", r.translator)
+	ts, release := b.acquireTranslateService()
+	defer release()
+	resp, next, err := ts.TranslateExcluding(context.Background(), r.req, []string{r.translator})
+	if errors.Is(err, translate.ErrNoOtherTranslator) {
+		logger.Info("no other translator to retranslate with")
+		return
+	}
+	logger = logger.WithField("translator_name", next)
+	if err != nil {
+		logger.Errorf("an error occurred while retranslating: %v", err)
+		return
+	}
+
+	opts := r.opts
+	if r.footer {
+		lang := r.msg.lang
+		if lang == nil {
+			lang = &detector.DetectResponse{}
+		}
+		opts.Footer = detectionFooter(lang, next)
+	}
+	if err = r.msg.adapter.EditReply(r.sent, resp.Text, opts); err != nil {
+		logger.Errorf("an error occurred while editing retranslated reply: %v", err)
+		return
+	}
+	name = next
+
+	b.recordRetranslation(key, r.translator, next, resp.PromptVariant)
+	b.recordUsage(r.msg, next, resp.TokenUsage.Prompt, resp.TokenUsage.Completion, ts.Cost(next, resp))
+	logger.Info("retranslated")
+}
+
+// recordRetranslation counts a reply translated again by to instead of
+// from. Later votes on the reply count for to.
+func (b *Bot) recordRetranslation(key, from, to, variant string) {
+	b.state.update(func(state *botState) {
+		b.metrics.Retranslations.WithLabelValues(from, to).
+			Set(float64(state.Feedback.addRetranslation(from, to)))
+		if r, ok := state.Feedback.Replies[key]; ok {
+			r.Translator = to
+			r.Variant = variant
+			clear(r.Votes)
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package selector
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	CONSISTENT_HASH = "consistent_hash"
+
+	// Points on the ring per unit of weight, evening out the share of keys
+	hashRingReplicas = 160
+)
+
+// KeyedSelector is implemented by selectors choosing by a routing key.
+type KeyedSelector[T Item] interface {
+	SelectKey(key string) (T, error)
+}
+
+// SelectFor selects by key if s is a KeyedSelector and key isn't empty,
+// or by s.Select otherwise.
+func SelectFor[T Item](s Selector[T], key string) (T, error) {
+	if ks, ok := s.(KeyedSelector[T]); ok && key != "" {
+		return ks.SelectKey(key)
+	}
+	return s.Select()
+}
+
+type hashRingPoint struct {
+	hash  uint64
+	index int
+}
+
+// ConsistentHashSelector maps routing keys onto a hash ring of items, so a
+// key, e.g. a chat, keeps getting the same item. Each item takes points in
+// proportion to its weight. Keys of a disabled item move to the next
+// enabled item on the ring until it recovers, the keys of other items
+// stay. Selections without a key pick a random point.
+// It conforms to the Selector and KeyedSelector interfaces.
+type ConsistentHashSelector[T WeightedItem] struct {
+	items             []T
+	ring              []hashRingPoint
+	totalConfigWeight int
+	mu                *sync.RWMutex
+	logger            *logrus.Entry
+}
+
+// NewConsistentHashSelector creates a new ConsistentHashSelector.
+func NewConsistentHashSelector[T WeightedItem](logger *logrus.Entry) *ConsistentHashSelector[T] {
+	return &ConsistentHashSelector[T]{
+		items:  make([]T, 0),
+		ring:   make([]hashRingPoint, 0),
+		mu:     &sync.RWMutex{},
+		logger: logger.WithField("selector", CONSISTENT_HASH),
+	}
+}
+
+// hashKey spreads similar keys, e.g. sequential chat IDs, evenly.
+func hashKey(key string) uint64 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+// AddItem adds the points of an item to the ring. The points depend on the
+// name only, so keys stay on their items across restarts and reloads.
+func (s *ConsistentHashSelector[T]) AddItem(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := len(s.items)
+	s.items = append(s.items, item)
+	s.totalConfigWeight += item.GetConfigWeight()
+	for i := range item.GetConfigWeight() * hashRingReplicas {
+		s.ring = append(s.ring, hashRingPoint{
+			hash:  hashKey(item.GetName() + "#" + strconv.Itoa(i)),
+			index: index,
+		})
+	}
+	slices.SortFunc(s.ring, func(a, b hashRingPoint) int {
+		return cmp.Compare(a.hash, b.hash)
+	})
+	s.logger.Infof("added item '%s', weight: %d", item.GetName(), item.GetConfigWeight())
+}
+
+// selectAt chooses the first enabled item clockwise from hash.
+func (s *ConsistentHashSelector[T]) selectAt(hash uint64) (item T, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if len(s.ring) == 0 {
+		err = fmt.Errorf("consistent hash selector: no items configured")
+		return
+	}
+
+	start, _ := slices.BinarySearchFunc(s.ring, hash, func(p hashRingPoint, h uint64) int {
+		return cmp.Compare(p.hash, h)
+	})
+	for i := range s.ring {
+		currentItem := s.items[s.ring[(start+i)%len(s.ring)].index]
+		if !currentItem.IsDisabled() {
+			s.logger.Debugf("selected item '%s'", currentItem.GetName())
+			return currentItem, nil
+		}
+	}
+	err = fmt.Errorf("consistent hash selector: all configured items are disabled")
+	return
+}
+
+// Select chooses the item of a random point.
+func (s *ConsistentHashSelector[T]) Select() (item T, err error) {
+	return s.selectAt(rand.Uint64())
+}
+
+// SelectKey chooses the item key is mapped onto.
+func (s *ConsistentHashSelector[T]) SelectKey(key string) (item T, err error) {
+	return s.selectAt(hashKey(key))
+}
+
+// Feedback is a no-op, as ConsistentHashSelector selects by key only.
+func (s *ConsistentHashSelector[T]) Feedback(T, time.Duration, bool) {}
+
+// TotalConfigWeight returns the sum of configured weights of all items.
+func (s *ConsistentHashSelector[T]) TotalConfigWeight() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.totalConfigWeight
+}
+
+func (s *ConsistentHashSelector[T]) GetType() string {
+	return CONSISTENT_HASH
+}
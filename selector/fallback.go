@@ -3,6 +3,7 @@ package selector
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -22,11 +23,11 @@ type FallbackSelector[T Item] struct {
 }
 
 // NewFallbackSelector creates a new FallbackSelector.
-func NewFallbackSelector[T Item]() *FallbackSelector[T] {
+func NewFallbackSelector[T Item](logger *logrus.Entry) *FallbackSelector[T] {
 	return &FallbackSelector[T]{
 		items:  make([]T, 0),
 		mu:     &sync.Mutex{},
-		logger: logrus.WithField("selector", FALLBACK),
+		logger: logger.WithField("selector", FALLBACK),
 	}
 }
 
@@ -71,6 +72,9 @@ func (s *FallbackSelector[T]) Select() (item T, err error) {
 	return
 }
 
+// Feedback is a no-op, as FallbackSelector selects by order only.
+func (s *FallbackSelector[T]) Feedback(T, time.Duration, bool) {}
+
 // TotalConfigWeight returns 0 for FallbackSelector as weights are not applicable.
 func (s *FallbackSelector[T]) TotalConfigWeight() int {
 	return 0
@@ -0,0 +1,130 @@
+package selector
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	LEAST_COST = "least_cost"
+)
+
+// CostItem defines the interface that items managed by the LeastCostSelector must implement.
+type CostItem interface {
+	Item
+	// GetUnitCost returns the configured price of the item, comparable
+	// between items.
+	GetUnitCost() float64
+	// GetDailyBudget returns the spend per UTC day after which the item
+	// is passed over, unlimited if 0.
+	GetDailyBudget() float64
+}
+
+// CostRecorder is implemented by selectors tracking the spend of items.
+type CostRecorder[T Item] interface {
+	RecordCost(item T, cost float64)
+}
+
+// LeastCostSelector selects the cheapest enabled item whose daily budget
+// remains. If every budget is spent, the cheapest enabled item is selected
+// anyway. Spend is kept in memory and resets at UTC midnight.
+// It conforms to the Selector and CostRecorder interfaces.
+type LeastCostSelector[T CostItem] struct {
+	// Ascending unit cost, ties in the order items are added
+	items  []T
+	spent  map[string]float64
+	day    string
+	mu     *sync.Mutex
+	logger *logrus.Entry
+}
+
+// NewLeastCostSelector creates a new LeastCostSelector.
+func NewLeastCostSelector[T CostItem](logger *logrus.Entry) *LeastCostSelector[T] {
+	return &LeastCostSelector[T]{
+		items:  make([]T, 0),
+		spent:  make(map[string]float64),
+		mu:     &sync.Mutex{},
+		logger: logger.WithField("selector", LEAST_COST),
+	}
+}
+
+// AddItem adds an item to the selector.
+func (s *LeastCostSelector[T]) AddItem(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = append(s.items, item)
+	slices.SortStableFunc(s.items, func(a, b T) int {
+		return cmp.Compare(a.GetUnitCost(), b.GetUnitCost())
+	})
+	s.logger.Infof("added item '%s', unit cost: %g, daily budget: %g",
+		item.GetName(), item.GetUnitCost(), item.GetDailyBudget())
+}
+
+// resetDay clears the spend on a new UTC day.
+func (s *LeastCostSelector[T]) resetDay() {
+	day := time.Now().UTC().Format(time.DateOnly)
+	if day != s.day {
+		s.day = day
+		clear(s.spent)
+	}
+}
+
+// Select chooses the cheapest enabled item within its budget.
+func (s *LeastCostSelector[T]) Select() (item T, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.items) == 0 {
+		err = fmt.Errorf("least cost selector: no items configured")
+		return
+	}
+	s.resetDay()
+
+	found := false
+	for _, currentItem := range s.items {
+		if currentItem.IsDisabled() {
+			continue
+		}
+		budget := currentItem.GetDailyBudget()
+		if budget <= 0 || s.spent[currentItem.GetName()] < budget {
+			s.logger.Debugf("selected item '%s'", currentItem.GetName())
+			return currentItem, nil
+		}
+		if !found {
+			item, found = currentItem, true
+		}
+		s.logger.Debugf("item '%s' is over its daily budget, trying next", currentItem.GetName())
+	}
+	if !found {
+		err = fmt.Errorf("least cost selector: all configured items are disabled")
+		return
+	}
+	s.logger.Warnf("all enabled items are over their daily budget, selected cheapest '%s'", item.GetName())
+	return
+}
+
+// RecordCost adds the cost of a call to the spend of item today.
+func (s *LeastCostSelector[T]) RecordCost(item T, cost float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resetDay()
+	s.spent[item.GetName()] += cost
+}
+
+// Feedback is a no-op, as LeastCostSelector selects by cost only.
+func (s *LeastCostSelector[T]) Feedback(T, time.Duration, bool) {}
+
+// TotalConfigWeight returns 0 for LeastCostSelector as weights are not applicable.
+func (s *LeastCostSelector[T]) TotalConfigWeight() int {
+	return 0
+}
+
+func (s *LeastCostSelector[T]) GetType() string {
+	return LEAST_COST
+}
@@ -0,0 +1,128 @@
+package selector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	LEAST_LATENCY = "least_latency"
+
+	// Smoothing factor of the latency moving average
+	latencyAlpha = 0.2
+
+	// Items not selected for this long are selected once, so a slow item
+	// is measured again once it recovers
+	latencyProbeInterval = time.Minute
+)
+
+// latencyStats is the moving average of the response times of an item.
+type latencyStats struct {
+	average      float64
+	observed     bool
+	lastSelected time.Time
+}
+
+// LeastLatencySelector selects the enabled item of the lowest moving
+// average response time, fed by Feedback. Items never observed are
+// selected first. Failed calls count as at least twice the average, so
+// items failing fast aren't preferred.
+// It conforms to the Selector interface.
+type LeastLatencySelector[T Item] struct {
+	items  []T
+	stats  map[string]*latencyStats
+	mu     *sync.Mutex
+	logger *logrus.Entry
+}
+
+// NewLeastLatencySelector creates a new LeastLatencySelector.
+func NewLeastLatencySelector[T Item](logger *logrus.Entry) *LeastLatencySelector[T] {
+	return &LeastLatencySelector[T]{
+		items:  make([]T, 0),
+		stats:  make(map[string]*latencyStats),
+		mu:     &sync.Mutex{},
+		logger: logger.WithField("selector", LEAST_LATENCY),
+	}
+}
+
+// AddItem adds an item to the selector.
+// Ties are broken in the order items are added.
+func (s *LeastLatencySelector[T]) AddItem(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = append(s.items, item)
+	s.stats[item.GetName()] = new(latencyStats)
+	s.logger.Infof("added item '%s'", item.GetName())
+}
+
+// Select chooses the enabled item of the lowest average latency, or an
+// item due for a probe.
+func (s *LeastLatencySelector[T]) Select() (item T, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.items) == 0 {
+		err = fmt.Errorf("least latency selector: no items configured")
+		return
+	}
+
+	now := time.Now()
+	var selected *latencyStats
+	for _, currentItem := range s.items {
+		if currentItem.IsDisabled() {
+			continue
+		}
+		stats := s.stats[currentItem.GetName()]
+		if stats.observed && now.Sub(stats.lastSelected) > latencyProbeInterval {
+			s.logger.Debugf("probing item '%s'", currentItem.GetName())
+			item, selected = currentItem, stats
+			break
+		}
+		if selected == nil || !stats.observed && selected.observed ||
+			stats.observed == selected.observed && stats.average < selected.average {
+			item, selected = currentItem, stats
+		}
+	}
+	if selected == nil {
+		err = fmt.Errorf("least latency selector: all configured items are disabled")
+		return
+	}
+
+	selected.lastSelected = now
+	s.logger.Debugf("selected item '%s', average latency: %.0f ms", item.GetName(), selected.average)
+	return
+}
+
+// Feedback updates the average latency of item.
+func (s *LeastLatencySelector[T]) Feedback(item T, latency time.Duration, success bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats, ok := s.stats[item.GetName()]
+	if !ok {
+		// Not one of ours, e.g. a canary
+		return
+	}
+	sample := float64(latency.Milliseconds())
+	if !success {
+		sample = max(sample, 2*stats.average)
+	}
+	if !stats.observed {
+		stats.average, stats.observed = sample, true
+		return
+	}
+	stats.average += latencyAlpha * (sample - stats.average)
+}
+
+// TotalConfigWeight returns 0 for LeastLatencySelector as weights are not applicable.
+func (s *LeastLatencySelector[T]) TotalConfigWeight() int {
+	return 0
+}
+
+func (s *LeastLatencySelector[T]) GetType() string {
+	return LEAST_LATENCY
+}
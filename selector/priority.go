@@ -0,0 +1,116 @@
+package selector
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	PRIORITY = "priority"
+)
+
+// PriorityItem defines the interface that items managed by the PrioritySelector must implement.
+type PriorityItem interface {
+	WeightedItem
+	// GetPriority returns the tier of the item, lower tiers are selected first.
+	GetPriority() int
+}
+
+// priorityTier is a WRR selector of the items of one priority.
+type priorityTier[T PriorityItem] struct {
+	priority int
+	selector *WeightedRoundRobinSelector[T]
+}
+
+// PrioritySelector groups items into tiers by priority and applies WRR
+// within the lowest tier having an enabled item, e.g. spreading load over
+// two primary APIs and only falling back to a local model when both are
+// disabled.
+// It conforms to the Selector interface.
+type PrioritySelector[T PriorityItem] struct {
+	// Ascending priority
+	tiers  []priorityTier[T]
+	mu     *sync.Mutex
+	logger *logrus.Entry
+
+	healthWeighted bool
+}
+
+// NewPrioritySelector creates a new PrioritySelector.
+func NewPrioritySelector[T PriorityItem](logger *logrus.Entry) *PrioritySelector[T] {
+	return &PrioritySelector[T]{
+		tiers:  make([]priorityTier[T], 0),
+		mu:     &sync.Mutex{},
+		logger: logger.WithField("selector", PRIORITY),
+	}
+}
+
+// SetHealthWeighted scales weights by health within each tier, see
+// WeightedRoundRobinSelector.SetHealthWeighted.
+func (s *PrioritySelector[T]) SetHealthWeighted(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthWeighted = enabled
+	for _, tier := range s.tiers {
+		tier.selector.SetHealthWeighted(enabled)
+	}
+}
+
+// AddItem adds an item to the tier of its priority.
+func (s *PrioritySelector[T]) AddItem(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, found := slices.BinarySearchFunc(s.tiers, item.GetPriority(), func(t priorityTier[T], p int) int {
+		return t.priority - p
+	})
+	if !found {
+		wrr := NewWeightedRoundRobinSelector[T](s.logger)
+		wrr.SetHealthWeighted(s.healthWeighted)
+		s.tiers = slices.Insert(s.tiers, i, priorityTier[T]{priority: item.GetPriority(), selector: wrr})
+	}
+	s.tiers[i].selector.AddItem(item)
+	s.logger.Infof("added item '%s' to priority %d", item.GetName(), item.GetPriority())
+}
+
+// Select chooses an item by WRR in the lowest tier having an enabled item.
+func (s *PrioritySelector[T]) Select() (item T, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.tiers) == 0 {
+		err = fmt.Errorf("priority selector: no items configured")
+		return
+	}
+
+	for _, tier := range s.tiers {
+		item, err = tier.selector.Select()
+		if err == nil {
+			return
+		}
+		s.logger.Debugf("no enabled item of priority %d, trying next", tier.priority)
+	}
+	err = fmt.Errorf("priority selector: all configured items are disabled")
+	return
+}
+
+// Feedback is a no-op, as health weighting reads the health score of items.
+func (s *PrioritySelector[T]) Feedback(T, time.Duration, bool) {}
+
+// TotalConfigWeight returns the sum of configured weights of all items.
+func (s *PrioritySelector[T]) TotalConfigWeight() (total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tier := range s.tiers {
+		total += tier.selector.TotalConfigWeight()
+	}
+	return
+}
+
+func (s *PrioritySelector[T]) GetType() string {
+	return PRIORITY
+}
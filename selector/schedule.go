@@ -0,0 +1,93 @@
+package selector
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SCHEDULE = "schedule"
+)
+
+// ScheduledItem defines the interface that items managed by the ScheduleSelector must implement.
+type ScheduledItem interface {
+	Item
+	// IsActive reports whether t is within the time windows of the item.
+	IsActive(t time.Time) bool
+}
+
+// ScheduleSelector selects the first enabled item, in the order items are
+// added, whose time windows include now. If none is active, e.g. the only
+// item of a window is disabled, the first enabled item is selected anyway
+// rather than failing.
+// It conforms to the Selector interface.
+type ScheduleSelector[T ScheduledItem] struct {
+	items  []T
+	mu     *sync.Mutex
+	logger *logrus.Entry
+}
+
+// NewScheduleSelector creates a new ScheduleSelector.
+func NewScheduleSelector[T ScheduledItem](logger *logrus.Entry) *ScheduleSelector[T] {
+	return &ScheduleSelector[T]{
+		items:  make([]T, 0),
+		mu:     &sync.Mutex{},
+		logger: logger.WithField("selector", SCHEDULE),
+	}
+}
+
+// AddItem adds an item to the selector.
+func (s *ScheduleSelector[T]) AddItem(item T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = append(s.items, item)
+	s.logger.Infof("added item '%s'", item.GetName())
+}
+
+// Select chooses the first enabled item active now.
+func (s *ScheduleSelector[T]) Select() (item T, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.items) == 0 {
+		err = fmt.Errorf("schedule selector: no items configured")
+		return
+	}
+
+	now := time.Now()
+	found := false
+	for _, currentItem := range s.items {
+		if currentItem.IsDisabled() {
+			continue
+		}
+		if currentItem.IsActive(now) {
+			s.logger.Debugf("selected item '%s'", currentItem.GetName())
+			return currentItem, nil
+		}
+		if !found {
+			item, found = currentItem, true
+		}
+	}
+	if !found {
+		err = fmt.Errorf("schedule selector: all configured items are disabled")
+		return
+	}
+	s.logger.Warnf("no enabled item is scheduled now, selected '%s'", item.GetName())
+	return
+}
+
+// Feedback is a no-op, as ScheduleSelector selects by time only.
+func (s *ScheduleSelector[T]) Feedback(T, time.Duration, bool) {}
+
+// TotalConfigWeight returns 0 for ScheduleSelector as weights are not applicable.
+func (s *ScheduleSelector[T]) TotalConfigWeight() int {
+	return 0
+}
+
+func (s *ScheduleSelector[T]) GetType() string {
+	return SCHEDULE
+}
@@ -1,5 +1,7 @@
 package selector
 
+import "time"
+
 type Item interface {
 	// IsDisabled checks if the item is currently disabled.
 	IsDisabled() bool
@@ -7,9 +9,18 @@ type Item interface {
 	GetName() string
 }
 
+// HealthItem is implemented by items keeping a health score.
+type HealthItem interface {
+	// HealthScore returns the smoothed health of the item from 0 to 1.
+	HealthScore() float64
+}
+
 type Selector[T Item] interface {
 	AddItem(T)
 	Select() (T, error)
+	// Feedback reports the response time and outcome of a call to an
+	// item, for selectors picking by them.
+	Feedback(item T, latency time.Duration, success bool)
 	TotalConfigWeight() int
 	// GetType returns the type of this selector
 	GetType() string
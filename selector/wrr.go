@@ -3,13 +3,18 @@ package selector
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"sync"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	WRR = "wrr"
+
+	// Resolution of health weighted weights
+	healthWeightScale = 100
 )
 
 // WeightedItem defines the interface that items managed by the generic WRR selector must implement.
@@ -29,15 +34,41 @@ type WeightedRoundRobinSelector[T WeightedItem] struct {
 	totalConfigWeight int
 	mu                *sync.Mutex
 	logger            *logrus.Entry
+
+	// Scale weights by the health score of items implementing HealthItem
+	healthWeighted bool
 }
 
 // NewWeightedRoundRobinSelector creates a new generic WeightedRoundRobinSelector.
-func NewWeightedRoundRobinSelector[T WeightedItem]() *WeightedRoundRobinSelector[T] {
+func NewWeightedRoundRobinSelector[T WeightedItem](logger *logrus.Entry) *WeightedRoundRobinSelector[T] {
 	return &WeightedRoundRobinSelector[T]{
 		items:  make([]T, 0),
 		mu:     &sync.Mutex{},
-		logger: logrus.WithField("selector", WRR),
+		logger: logger.WithField("selector", WRR),
+	}
+}
+
+// SetHealthWeighted scales the weight of each item implementing HealthItem
+// by its health score, so failing or slow items are selected less often
+// before failover disables them.
+func (s *WeightedRoundRobinSelector[T]) SetHealthWeighted(enabled bool) {
+	s.mu.Lock()
+	s.healthWeighted = enabled
+	s.mu.Unlock()
+}
+
+// effectiveWeight returns the weight of entry in the current round.
+// Health weighted items keep a weight of at least 1, so they can recover.
+func (s *WeightedRoundRobinSelector[T]) effectiveWeight(entry T) int {
+	w := entry.GetConfigWeight()
+	if !s.healthWeighted || w <= 0 {
+		return w
 	}
+	h, ok := any(entry).(HealthItem)
+	if !ok {
+		return w * healthWeightScale
+	}
+	return max(1, int(math.Round(float64(w*healthWeightScale)*h.HealthScore())))
 }
 
 // AddItem adds an item to the selector.
@@ -67,6 +98,7 @@ func (s *WeightedRoundRobinSelector[T]) Select() (item T, err error) {
 
 	selectedIndex := -1
 	maxCurrentWeight := 0
+	totalWeight := 0
 	wrrBefore := s.unsafeString()
 
 	// Nginx's smooth weighted round-robin (sWRR) algorithm:
@@ -79,7 +111,9 @@ func (s *WeightedRoundRobinSelector[T]) Select() (item T, err error) {
 		}
 
 		// sWRR: 1. For each server i: current_weight[i] = current_weight[i] + effective_weight[i]
-		entry.SetCurrentWeight(entry.GetCurrentWeight() + entry.GetConfigWeight())
+		weight := s.effectiveWeight(entry)
+		totalWeight += weight
+		entry.SetCurrentWeight(entry.GetCurrentWeight() + weight)
 
 		if selectedIndex == -1 || entry.GetCurrentWeight() > maxCurrentWeight {
 			// sWRR: 2. selected_server = server with highest current_weight
@@ -94,7 +128,10 @@ func (s *WeightedRoundRobinSelector[T]) Select() (item T, err error) {
 
 	selectedItem := s.items[selectedIndex]
 	// sWRR: 3. current_weight[selected_server] = current_weight[selected_server] - total_weight
-	selectedItem.SetCurrentWeight(selectedItem.GetCurrentWeight() - s.totalConfigWeight)
+	if !s.healthWeighted {
+		totalWeight = s.totalConfigWeight
+	}
+	selectedItem.SetCurrentWeight(selectedItem.GetCurrentWeight() - totalWeight)
 
 	wrrAfter := s.unsafeString()
 	s.logger.Tracef("wrr before: %s", wrrBefore)
@@ -107,6 +144,9 @@ func (s *WeightedRoundRobinSelector[T]) Select() (item T, err error) {
 	return selectedItem, nil
 }
 
+// Feedback is a no-op, as health weighting reads the health score of items.
+func (s *WeightedRoundRobinSelector[T]) Feedback(T, time.Duration, bool) {}
+
 // TotalConfigWeight returns the sum of configured weights of all items.
 func (s *WeightedRoundRobinSelector[T]) TotalConfigWeight() int {
 	s.mu.Lock()
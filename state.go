@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+type StateConfig struct {
+	// Optional. File persisting the bot state, e.g. feedback votes.
+	// The state is kept in memory only if empty
+	File string `yaml:"file"`
+
+	// Positive. Seconds between saves of the state file
+	SaveInterval int `yaml:"save_interval"`
+
+	// Optional. Base64 AES-256 key the state file is encrypted with,
+	// plain JSON if empty
+	EncryptionKey string `yaml:"encryption_key" redact:"true"`
+
+	// Optional. File holding the encryption key instead
+	EncryptionKeyFile string `yaml:"encryption_key_file"`
+
+	key []byte
+}
+
+func (sc *StateConfig) Check() (err error) {
+	if sc.File != "" && sc.SaveInterval <= 0 {
+		err = fmt.Errorf("state save interval must be positive")
+		return
+	}
+	err = common.LoadSecretFile(&sc.EncryptionKey, sc.EncryptionKeyFile)
+	if err != nil {
+		err = fmt.Errorf("state encryption key: %w", err)
+		return
+	}
+	if sc.EncryptionKey != "" {
+		sc.key, err = parseStateKey(sc.EncryptionKey)
+	}
+	return
+}
+
+// botState is everything persisted by the StateStore.
+type botState struct {
+	Feedback feedbackState `json:"feedback"`
+	Usage    usageState    `json:"usage"`
+	Quota    quotaState    `json:"quota"`
+	Summary  summaryState  `json:"summary"`
+	Batches  batchState    `json:"batches"`
+}
+
+// StateStore keeps the bot state in memory and saves it to a JSON file
+// periodically and on Close, once started.
+type StateStore struct {
+	mu       sync.Mutex
+	file     string
+	key      []byte
+	interval time.Duration
+	dirty    bool
+	started  bool
+	state    botState
+	stop     chan struct{}
+}
+
+// newStateStore loads the state file if it exists.
+func newStateStore(conf StateConfig) (s *StateStore, err error) {
+	s = &StateStore{
+		file:     conf.File,
+		key:      conf.key,
+		interval: time.Duration(conf.SaveInterval) * time.Second,
+		stop:     make(chan struct{}),
+	}
+	s.state.init()
+	err = s.load()
+	return
+}
+
+// load replaces the state with the one in the state file, e.g. saved by the
+// previous leader. A plain state file is encrypted on the next save once a
+// key is set.
+func (s *StateStore) load() (err error) {
+	if s.file == "" {
+		return
+	}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		err = nil
+		return
+	} else if err != nil {
+		err = fmt.Errorf("read state file '%s' failed: %w", s.file, err)
+		return
+	}
+
+	dirty := false
+	if isSealedState(data) {
+		if s.key == nil {
+			err = fmt.Errorf("state file '%s' is encrypted, but no encryption key is set", s.file)
+			return
+		}
+		data, err = openState(s.key, data)
+		if err != nil {
+			err = fmt.Errorf("state file '%s': %w", s.file, err)
+			return
+		}
+	} else if s.key != nil {
+		dirty = true
+	}
+	var state botState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		err = fmt.Errorf("parse state file '%s' failed: %w", s.file, err)
+		return
+	}
+	state.init()
+
+	s.mu.Lock()
+	s.state = state
+	s.dirty = dirty
+	s.mu.Unlock()
+	logrus.Infof("loaded state from '%s'", s.file)
+	return
+}
+
+// start begins saving the state. Standby replicas never save, so they
+// don't overwrite the state of the leader.
+func (s *StateStore) start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started || s.file == "" {
+		return
+	}
+	s.started = true
+	go s.saveLoop(s.interval)
+}
+
+// init creates the maps missing from an empty or older state.
+func (bs *botState) init() {
+	bs.Feedback.init()
+	bs.Usage.init()
+	bs.Quota.init()
+	if bs.Batches == nil {
+		bs.Batches = make(batchState)
+	}
+}
+
+// update runs fn holding the state lock and marks the state to be saved.
+func (s *StateStore) update(fn func(state *botState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.state)
+	s.dirty = true
+}
+
+// view runs fn holding the state lock. fn must not modify the state.
+func (s *StateStore) view(fn func(state *botState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.state)
+}
+
+func (s *StateStore) saveLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if err := s.save(); err != nil {
+				logrus.Errorf("save state failed: %v", err)
+			}
+		}
+	}
+}
+
+// save writes the state to a temporary file, then renames it,
+// so a crash never leaves a truncated state file.
+func (s *StateStore) save() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.started || !s.dirty {
+		return
+	}
+
+	data, err := json.Marshal(&s.state)
+	if err != nil {
+		return
+	}
+	if s.key != nil {
+		data, err = sealState(s.key, data)
+		if err != nil {
+			return
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), filepath.Base(s.file)+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return
+	}
+	err = os.Rename(tmp.Name(), s.file)
+	if err != nil {
+		return
+	}
+	s.dirty = false
+	return
+}
+
+// Close saves the state a last time.
+func (s *StateStore) Close() error {
+	close(s.stop)
+	return s.save()
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+)
+
+// encryptedStateMagic prefixes encrypted state files, followed by the
+// nonce and the AES-GCM sealed JSON.
+var encryptedStateMagic = []byte("GURA-STATE-AESGCM1\n")
+
+// parseStateKey decodes a base64 AES-256 key.
+func parseStateKey(encoded string) (key []byte, err error) {
+	key, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		err = fmt.Errorf("state encryption key must be base64: %w", err)
+		return
+	}
+	if len(key) != 32 {
+		err = fmt.Errorf("state encryption key must be 32 bytes, got %d", len(key))
+	}
+	return
+}
+
+func newStateAEAD(key []byte) (aead cipher.AEAD, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealState encrypts the JSON state with key.
+func sealState(key, data []byte) (sealed []byte, err error) {
+	aead, err := newStateAEAD(key)
+	if err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	sealed = append(sealed, encryptedStateMagic...)
+	sealed = append(sealed, nonce...)
+	sealed = aead.Seal(sealed, nonce, data, encryptedStateMagic)
+	return
+}
+
+// isSealedState reports whether data was written by sealState.
+func isSealedState(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedStateMagic)
+}
+
+// openState decrypts data written by sealState.
+func openState(key, data []byte) (plain []byte, err error) {
+	aead, err := newStateAEAD(key)
+	if err != nil {
+		return
+	}
+	data = data[len(encryptedStateMagic):]
+	if len(data) < aead.NonceSize() {
+		err = fmt.Errorf("encrypted state is truncated")
+		return
+	}
+	nonce, data := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err = aead.Open(nil, nonce, data, encryptedStateMagic)
+	if err != nil {
+		err = fmt.Errorf("decrypt state failed, wrong key or corrupted file: %w", err)
+	}
+	return
+}
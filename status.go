@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// handleStatusCommand replies a summary of the pipeline health.
+func (b *Bot) handleStatusCommand(msg *Message, _ string) {
+	if !b.requireAdmin(msg) {
+		return
+	}
+
+	ts := b.getTranslateService()
+	var s strings.Builder
+	s.WriteString(versionString() + "\n")
+	s.WriteString(b.text(msg, msgStatusQueue,
+		b.countMessages(messageHandleStatePending), b.countMessages(messageHandleStateProcessing)) + "\n")
+	b.writeComponentStatus(&s, msg, msgStatusTranslators, ts.TranslatorStatus())
+	b.writeComponentStatus(&s, msg, msgStatusDetectors, ts.DetectorStatus())
+	b.replyText(msg, strings.TrimSpace(s.String()))
+}
+
+func (b *Bot) writeComponentStatus(s *strings.Builder, msg *Message, title string, status []translate.ComponentStatus) {
+	s.WriteString(b.text(msg, title) + "\n")
+	for _, c := range status {
+		var line string
+		switch {
+		case c.PermanentlyDisabled:
+			line = b.text(msg, msgStatusDisabled, c.Name)
+		case !c.DisabledUntil.IsZero():
+			line = b.text(msg, msgStatusCooldown, c.Name, time.Until(c.DisabledUntil).Round(time.Second))
+		case c.Failures > 0:
+			line = b.text(msg, msgStatusFailures, c.Name, c.Failures)
+		default:
+			line = b.text(msg, msgStatusUp, c.Name)
+		}
+		s.WriteString("  " + line + "\n")
+	}
+}
+
+// componentCooldowns returns the cooldowns of the current translators and
+// detectors for the cooldown metric.
+func (b *Bot) componentCooldowns() (cooldowns []metrics.ComponentCooldown) {
+	ts := b.getTranslateService()
+	add := func(component string, status []translate.ComponentStatus) {
+		for _, c := range status {
+			var seconds float64
+			switch {
+			case c.PermanentlyDisabled:
+				seconds = math.Inf(1)
+			case !c.DisabledUntil.IsZero():
+				seconds = max(time.Until(c.DisabledUntil).Seconds(), 0)
+			}
+			cooldowns = append(cooldowns, metrics.ComponentCooldown{Component: component, Name: c.Name, Seconds: seconds})
+		}
+	}
+	add("translator", ts.TranslatorStatus())
+	add("detector", ts.DetectorStatus())
+	return
+}
+
+// countMessages sums the messages in a handling state over all chat types.
+func (b *Bot) countMessages(state string) (n int) {
+	for _, ct := range allChatTypes {
+		m := new(dto.Metric)
+		if err := b.metrics.Messages.WithLabelValues(state, ct).Write(m); err == nil {
+			n += int(m.GetGauge().GetValue())
+		}
+	}
+	return
+}
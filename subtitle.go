@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+)
+
+var (
+	// SubRip and WebVTT cue timings, e.g. "00:00:01,000 --> 00:00:02,500"
+	subtitleTimingRe = regexp.MustCompile(`^\s*(?:\d+:)?\d{1,2}:\d{2}[,.]\d{3}\s+-->\s+(?:\d+:)?\d{1,2}:\d{2}[,.]\d{3}`)
+
+	blankLinesRe = regexp.MustCompile(`\n\s*\n`)
+)
+
+// isSubtitles reports whether text contains subtitle cues.
+func isSubtitles(text string) bool {
+	for line := range strings.Lines(text) {
+		if subtitleTimingRe.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// subtitleCue is the range of text lines of a cue, following its timing line.
+type subtitleCue struct {
+	start, end int
+}
+
+// parseSubtitleCues returns the text lines of every cue with text. Indices, timings
+// and lines outside of cues, e.g. the WebVTT header, are not part of any cue.
+func parseSubtitleCues(lines []string) (cues []subtitleCue) {
+	inCue, started := false, false
+	for i, line := range lines {
+		switch {
+		case subtitleTimingRe.MatchString(line):
+			inCue, started = true, false
+		case strings.TrimSpace(line) == "":
+			inCue = false
+		case inCue && started:
+			cues[len(cues)-1].end = i + 1
+		case inCue:
+			started = true
+			cues = append(cues, subtitleCue{start: i, end: i + 1})
+		}
+	}
+	return
+}
+
+// translateSubtitles translates only the text lines of subtitle cues,
+// keeping indices, timings and blank lines, so the result stays valid.
+// Cues are sent in batches of about chunkSize bytes, separated by blank lines.
+// If a translation doesn't keep the number of cues, the cues of the batch
+// are translated one by one.
+func translateSubtitles(text string, chunkSize int, translate func(string) (*translator.TranslateResponse, error)) (out string, prompt, completion int64, err error) {
+	lines := strings.Split(text, "\n")
+	cues := parseSubtitleCues(lines)
+
+	cueText := func(c subtitleCue) string {
+		return strings.TrimRight(strings.Join(lines[c.start:c.end], "\n"), "\r")
+	}
+	call := func(text string) (translated string, err error) {
+		resp, err := translate(text)
+		if err != nil {
+			return
+		}
+		prompt += resp.TokenUsage.Prompt
+		completion += resp.TokenUsage.Completion
+		translated = strings.TrimSpace(resp.Text)
+		return
+	}
+
+	translated := make([]string, len(cues))
+	for start := 0; start < len(cues); {
+		end, size := start, 0
+		for end < len(cues) && (end == start || size+len(cueText(cues[end])) <= chunkSize) {
+			size += len(cueText(cues[end])) + 2
+			end += 1
+		}
+
+		texts := make([]string, 0, end-start)
+		for _, c := range cues[start:end] {
+			texts = append(texts, cueText(c))
+		}
+
+		var resp string
+		resp, err = call(strings.Join(texts, "\n\n"))
+		if err != nil {
+			err = fmt.Errorf("translate cues %d-%d failed: %w", start+1, end, err)
+			return
+		}
+		parts := blankLinesRe.Split(resp, -1)
+		if len(parts) != len(texts) {
+			parts = make([]string, len(texts))
+			for i, t := range texts {
+				parts[i], err = call(t)
+				if err != nil {
+					err = fmt.Errorf("translate cue %d failed: %w", start+i+1, err)
+					return
+				}
+			}
+		}
+		copy(translated[start:end], parts)
+		start = end
+	}
+
+	var b strings.Builder
+	next := 0
+	for i, c := range cues {
+		for _, line := range lines[next:c.start] {
+			b.WriteString(line + "\n")
+		}
+		// A blank line would end the cue
+		cue := strings.ReplaceAll(blankLinesRe.ReplaceAllString(translated[i], "\n"), "\r\n", "\n")
+		eol := "\n"
+		if strings.HasSuffix(lines[c.end-1], "\r") {
+			eol = "\r\n"
+		}
+		b.WriteString(strings.ReplaceAll(cue, "\n", eol) + eol)
+		next = c.end
+	}
+	b.WriteString(strings.Join(lines[next:], "\n"))
+	out = b.String()
+	return
+}
+
+// translateSubtitlesOnce translates subtitles in a message, e.g. the caption
+// of a video, making a single attempt per batch of cues.
+func (b *Bot) translateSubtitlesOnce(ctx context.Context, ts *translate.TranslateService, req translator.TranslateRequest) (resp *translator.TranslateResponse, name string, err error) {
+	b.configMu.RLock()
+	chunkSize := b.documents.ChunkSize
+	b.configMu.RUnlock()
+
+	resp = new(translator.TranslateResponse)
+	resp.Text, resp.TokenUsage.Prompt, resp.TokenUsage.Completion, err = translateSubtitles(req.Text, chunkSize, func(text string) (r *translator.TranslateResponse, err error) {
+		r, name, err = ts.TranslateOnce(ctx, translator.TranslateRequest{
+			Text:       text,
+			TraceId:    req.TraceId,
+			SourceLang: req.SourceLang,
+			RoutingKey: req.RoutingKey,
+		})
+		if err == nil {
+			resp.TargetLang = r.TargetLang
+			resp.PromptVariant = r.PromptVariant
+		}
+		return
+	})
+	return
+}
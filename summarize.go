@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+const defaultSummarizePrompt = "Summarize the chat messages below concisely in English, " +
+	"whatever language they are written in. Mention who said what where it matters. " +
+	"Output the summary only."
+
+type SummarizeConfig struct {
+	// Keep recent text messages of chats in memory for /summarize
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Chat types whose messages are kept, all if empty
+	ChatTypes []string `yaml:"chat_types"`
+
+	// Positive. Messages kept per chat, the most /summarize takes
+	HistorySize int `yaml:"history_size"`
+
+	// Positive. Messages summarized if /summarize has no argument
+	DefaultMessages int `yaml:"default_messages"`
+
+	// Optional. OpenAI API by default
+	Endpoint string `yaml:"endpoint"`
+
+	// Optional
+	Token string `yaml:"token" redact:"true"`
+
+	// Optional. File holding the token instead
+	TokenFile string `yaml:"token_file"`
+
+	// Required
+	Model string `yaml:"model"`
+
+	// Positive. Timeout in seconds
+	Timeout int64 `yaml:"timeout"`
+
+	// Optional. System prompt, asking for a summary in English by default
+	Prompt string `yaml:"prompt"`
+}
+
+func (sc *SummarizeConfig) Check() (err error) {
+	if !sc.Enabled {
+		return
+	}
+	if sc.HistorySize <= 0 || sc.DefaultMessages <= 0 {
+		err = fmt.Errorf("summarize history size and default messages must be positive")
+		return
+	}
+	if sc.DefaultMessages > sc.HistorySize {
+		err = fmt.Errorf("summarize default messages must not exceed the history size")
+		return
+	}
+	if sc.Endpoint == "" {
+		sc.Endpoint = defaultModerationEndpoint
+	}
+	if sc.Model == "" {
+		err = fmt.Errorf("summarize model is required")
+		return
+	}
+	if sc.Timeout <= 0 {
+		err = fmt.Errorf("summarize timeout must be positive")
+		return
+	}
+	if sc.Prompt == "" {
+		sc.Prompt = defaultSummarizePrompt
+	}
+	err = common.LoadSecretFile(&sc.Token, sc.TokenFile)
+	if err != nil {
+		err = fmt.Errorf("summarize token: %w", err)
+	}
+	return
+}
+
+type historyEntry struct {
+	userId int64
+	sender string
+	text   string
+}
+
+// chatHistory keeps the recent text messages of chats, in memory only.
+type chatHistory struct {
+	mu    sync.Mutex
+	chats map[string][]historyEntry
+}
+
+func newChatHistory() *chatHistory {
+	return &chatHistory{chats: make(map[string][]historyEntry)}
+}
+
+// add keeps text of msg, dropping the oldest messages beyond size.
+func (h *chatHistory) add(msg *Message, text string, size int) {
+	sender := msg.SenderName
+	if sender == "" {
+		sender = strconv.FormatInt(msg.UserID, 10)
+	}
+	key := usageChatKey(msg.Platform, msg.ChatID)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := append(h.chats[key], historyEntry{userId: msg.UserID, sender: sender, text: text})
+	if len(entries) > size {
+		entries = slices.Clone(entries[len(entries)-size:])
+	}
+	h.chats[key] = entries
+}
+
+// last returns the last n messages of the chat of msg, oldest first.
+func (h *chatHistory) last(msg *Message, n int) []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := h.chats[usageChatKey(msg.Platform, msg.ChatID)]
+	return slices.Clone(entries[max(0, len(entries)-n):])
+}
+
+// forget drops the messages of a user on platform, returning how many.
+func (h *chatHistory) forget(platform string, userId int64) (deleted int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k, entries := range h.chats {
+		if !strings.HasPrefix(k, platform+":") {
+			continue
+		}
+		kept := slices.DeleteFunc(entries, func(e historyEntry) bool { return e.userId == userId })
+		deleted += len(entries) - len(kept)
+		h.chats[k] = kept
+	}
+	return
+}
+
+// summarizer asks an OpenAI compatible API for summaries of chat history.
+type summarizer struct {
+	conf   SummarizeConfig
+	client openai.Client
+}
+
+func newSummarizer(conf SummarizeConfig) *summarizer {
+	if !conf.Enabled {
+		return nil
+	}
+	opts := []option.RequestOption{option.WithBaseURL(conf.Endpoint)}
+	if conf.Token != "" {
+		opts = append(opts, option.WithAPIKey(conf.Token))
+	}
+	return &summarizer{conf: conf, client: openai.NewClient(opts...)}
+}
+
+// recordHistory keeps text of msg for /summarize, if enabled in its chat.
+func (b *Bot) recordHistory(msg *Message, text string) {
+	b.configMu.RLock()
+	s := b.summarizer
+	b.configMu.RUnlock()
+	if s == nil || strings.TrimSpace(text) == "" ||
+		(len(s.conf.ChatTypes) > 0 && !slices.Contains(s.conf.ChatTypes, msg.ChatType)) {
+		return
+	}
+	b.history.add(msg, text, s.conf.HistorySize)
+}
+
+// handleSummarizeCommand replies a summary of the last messages of the
+// chat, as many as the argument or the default.
+func (b *Bot) handleSummarizeCommand(msg *Message, args string) {
+	b.configMu.RLock()
+	s := b.summarizer
+	b.configMu.RUnlock()
+	if s == nil {
+		b.replyText(msg, b.text(msg, msgSummarizeDisabled))
+		return
+	}
+
+	n := s.conf.DefaultMessages
+	if args != "" {
+		var err error
+		n, err = strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			n = s.conf.DefaultMessages
+		}
+	}
+	entries := b.history.last(msg, min(n, s.conf.HistorySize))
+	if len(entries) == 0 {
+		b.replyText(msg, b.text(msg, msgSummarizeEmpty))
+		return
+	}
+
+	var lines []string
+	for _, e := range entries {
+		lines = append(lines, e.sender+": "+e.text)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.conf.Timeout)*time.Second)
+	defer cancel()
+	resp, err := s.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: s.conf.Model,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(s.conf.Prompt),
+			openai.UserMessage(strings.Join(lines, "\n")),
+		},
+	})
+	if err == nil && len(resp.Choices) == 0 {
+		err = fmt.Errorf("no choice found in response")
+	}
+	if err != nil {
+		msg.logger.Errorf("an error occurred while summarizing: %v", err)
+		b.replyText(msg, b.text(msg, msgSummarizeFailed))
+		return
+	}
+	msg.logger.Infof("summarized %d messages", len(entries))
+	b.replyText(msg, b.text(msg, msgSummarize, len(entries), resp.Choices[0].Message.Content))
+}
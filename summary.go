@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Periods of usage summaries
+const (
+	// The previous UTC day, posted every day
+	summaryPeriodDaily = "daily"
+	// The previous seven UTC days, posted on Mondays
+	summaryPeriodWeekly = "weekly"
+)
+
+type SummaryConfig struct {
+	// Post a usage summary to an admin chat periodically
+	Enabled bool `yaml:"enabled"`
+
+	// "daily" (default) or "weekly"
+	Period string `yaml:"period"`
+
+	// UTC hour of the day the summary is posted at, 0 to 23
+	Hour int `yaml:"hour"`
+
+	// Name of the adapter of the admin chat, "telegram" by default
+	Platform string `yaml:"platform"`
+
+	// Required if enabled
+	ChatID int64 `yaml:"chat_id"`
+
+	// Chats listed by translated messages, none if 0
+	TopChats int `yaml:"top_chats"`
+}
+
+func (sc *SummaryConfig) Check() (err error) {
+	if !sc.Enabled {
+		return
+	}
+	switch sc.Period {
+	case "":
+		sc.Period = summaryPeriodDaily
+	case summaryPeriodDaily, summaryPeriodWeekly:
+	default:
+		err = fmt.Errorf("summary: invalid period: %s", sc.Period)
+		return
+	}
+	if sc.Hour < 0 || sc.Hour > 23 {
+		err = fmt.Errorf("summary: hour must be between 0 and 23")
+		return
+	}
+	if sc.Platform == "" {
+		sc.Platform = adapterTelegram
+	}
+	if sc.ChatID == 0 {
+		err = fmt.Errorf("summary: chat id is required")
+		return
+	}
+	if sc.TopChats < 0 {
+		err = fmt.Errorf("summary: top chats must not be negative")
+	}
+	return
+}
+
+// days returns the first and last UTC day summarized at now, and whether
+// a summary is due today.
+func (sc *SummaryConfig) days(now time.Time) (from, to string, due bool) {
+	last := now.AddDate(0, 0, -1)
+	first := last
+	if sc.Period == summaryPeriodWeekly {
+		first = now.AddDate(0, 0, -7)
+		due = now.Weekday() == time.Monday
+	} else {
+		due = true
+	}
+	return first.Format(usageDayLayout), last.Format(usageDayLayout), due && now.Hour() >= sc.Hour
+}
+
+// summaryState holds the last summary posted, persisted by the StateStore.
+type summaryState struct {
+	// First day of the period summarized
+	LastFrom string `json:"last_from"`
+}
+
+// runSummaries posts the usage summary once it is due, checking every
+// minute, so missed summaries are posted after a restart the same day.
+func (b *Bot) runSummaries() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		b.postSummary(now.UTC())
+	}
+}
+
+func (b *Bot) postSummary(now time.Time) {
+	b.configMu.RLock()
+	conf := b.summary
+	b.configMu.RUnlock()
+	if !conf.Enabled || !b.isLeader() {
+		return
+	}
+	from, to, due := conf.days(now)
+	if !due {
+		return
+	}
+
+	// Marked before sending, so a failing chat isn't retried every minute
+	posted := false
+	b.state.update(func(state *botState) {
+		posted = state.Summary.LastFrom == from
+		state.Summary.LastFrom = from
+	})
+	if posted {
+		return
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"platform": conf.Platform,
+		"chat_id":  conf.ChatID,
+	})
+	adapter := b.notifyAdapter(conf.Platform)
+	if adapter == nil {
+		logger.Errorf("cannot post usage summary: no %s adapter able to send messages", conf.Platform)
+		return
+	}
+	text := b.summaryText(conf, from, to)
+	_, err := adapter.SendMessage(conf.ChatID, text, b.notifyOptions())
+	if err != nil {
+		logger.Errorf("an error occurred while posting usage summary: %v", err)
+		return
+	}
+	logger.Infof("posted usage summary of %s to %s", from, to)
+}
+
+// notifyAdapter returns the adapter named platform if it can send
+// messages unprompted, nil otherwise.
+func (b *Bot) notifyAdapter(platform string) NotifyAdapter {
+	na, _ := b.adapter(platform).(NotifyAdapter)
+	return na
+}
+
+// adapter returns the adapter named platform, nil if not enabled.
+func (b *Bot) adapter(platform string) ChatAdapter {
+	b.configMu.RLock()
+	defer b.configMu.RUnlock()
+	for _, a := range b.adapters {
+		if a.Name() == platform {
+			return a
+		}
+	}
+	return nil
+}
+
+// notifyOptions returns the reply options of messages sent unprompted.
+func (b *Bot) notifyOptions() ReplyOptions {
+	b.configMu.RLock()
+	defer b.configMu.RUnlock()
+	return ReplyOptions{
+		DisableNotification: b.messageSettings.DisableNotification,
+		DisableLinkPreview:  b.messageSettings.DisableLinkPreview,
+		Standalone:          true,
+	}
+}
+
+// summaryText describes the usage between the UTC days from and to.
+func (b *Bot) summaryText(conf SummaryConfig, from, to string) string {
+	inPeriod := func(day string) bool {
+		return day >= from && day <= to
+	}
+	sumDays := func(days map[string]*chatUsage) (u chatUsage) {
+		for d, du := range days {
+			if inPeriod(d) {
+				u.add(*du)
+			}
+		}
+		return
+	}
+
+	type entry struct {
+		name  string
+		usage chatUsage
+	}
+	var total chatUsage
+	var chats, translators []entry
+	failovers := make(map[string]failoverCount)
+	b.state.view(func(state *botState) {
+		for k, days := range state.Usage.Chats {
+			u := sumDays(days)
+			if u.Messages > 0 {
+				total.add(u)
+				chats = append(chats, entry{k, u})
+			}
+		}
+		for k, days := range state.Usage.Translators {
+			if u := sumDays(days); u.Messages > 0 {
+				translators = append(translators, entry{k, u})
+			}
+		}
+		for d, counts := range state.Usage.Failovers {
+			if !inPeriod(d) {
+				continue
+			}
+			for k, c := range counts {
+				f := failovers[k]
+				f.Cooldowns += c.Cooldowns
+				f.Permanent += c.Permanent
+				failovers[k] = f
+			}
+		}
+	})
+	sort.Slice(chats, func(i, j int) bool {
+		if chats[i].usage.Messages != chats[j].usage.Messages {
+			return chats[i].usage.Messages > chats[j].usage.Messages
+		}
+		return chats[i].name < chats[j].name
+	})
+	sort.Slice(translators, func(i, j int) bool {
+		if translators[i].usage.Cost != translators[j].usage.Cost {
+			return translators[i].usage.Cost > translators[j].usage.Cost
+		}
+		return translators[i].name < translators[j].name
+	})
+
+	text := func(key string, args ...any) string {
+		return b.localize(conf.ChatID, "", key, args...)
+	}
+	line := func(u chatUsage) string {
+		return text(msgUsageLine,
+			u.Messages, u.PromptTokens+u.CompletionTokens, u.PromptTokens, u.CompletionTokens, u.Cost)
+	}
+	writeEntries := func(s *strings.Builder, title string, entries []entry) {
+		s.WriteString("\n" + text(title) + "\n")
+		if len(entries) == 0 {
+			s.WriteString("  " + text(msgSummaryNone) + "\n")
+		}
+		for _, e := range entries {
+			s.WriteString("  " + text(msgSummaryEntry, e.name, line(e.usage)) + "\n")
+		}
+	}
+
+	var s strings.Builder
+	s.WriteString(text(msgSummary, from, to, line(total)) + "\n")
+	writeEntries(&s, msgStatusTranslators, translators)
+	if conf.TopChats > 0 {
+		writeEntries(&s, msgSummaryTopChats, chats[:min(conf.TopChats, len(chats))])
+	}
+
+	s.WriteString("\n" + text(msgSummaryFailovers) + "\n")
+	keys := make([]string, 0, len(failovers))
+	for k := range failovers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if len(keys) == 0 {
+		s.WriteString("  " + text(msgSummaryNone) + "\n")
+	}
+	for _, k := range keys {
+		f := failovers[k]
+		s.WriteString("  " + text(msgSummaryFailover, k, f.Cooldowns, f.Permanent) + "\n")
+	}
+	return strings.TrimSpace(s.String())
+}
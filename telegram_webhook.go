@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/iplist"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+const telegramSecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// telegramSourceRanges are the ranges Telegram sends webhook requests from,
+// see https://core.telegram.org/bots/webhooks
+var telegramSourceRanges = []string{"149.154.160.0/20", "91.108.4.0/22"}
+
+// Allowed characters of Telegram webhook secret tokens
+var telegramSecretTokenRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+type TelegramWebhookConfig struct {
+	// Receive Telegram updates via webhook instead of polling
+	Enabled bool `yaml:"enabled"`
+
+	// Required. Public HTTPS URL Telegram posts updates to,
+	// e.g. "https://bot.example.com/telegram". Its path is served
+	URL string `yaml:"url"`
+
+	// Required. Address the webhook server listens on
+	Listen string `yaml:"listen"`
+
+	// Optional. Sent by Telegram in every request and verified,
+	// 1-256 characters of A-Z, a-z, 0-9, _ and -
+	SecretToken string `yaml:"secret_token" redact:"true"`
+
+	// Optional. IP ranges requests are accepted from, Telegram's by default.
+	// Set to empty to accept any, e.g. behind a reverse proxy
+	AllowedSources []string `yaml:"allowed_sources"`
+
+	path    string
+	sources iplist.Allowlist
+}
+
+func (wc *TelegramWebhookConfig) Check() (err error) {
+	if !wc.Enabled {
+		return
+	}
+	u, err := url.Parse(wc.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		err = fmt.Errorf("telegram webhook: url must be an absolute https URL")
+		return
+	}
+	wc.path = u.Path
+	if wc.path == "" {
+		wc.path = "/"
+	}
+	if wc.Listen == "" {
+		err = fmt.Errorf("telegram webhook: listen is required")
+		return
+	}
+	if wc.SecretToken != "" && !telegramSecretTokenRe.MatchString(wc.SecretToken) {
+		err = fmt.Errorf("telegram webhook: secret token must be 1-256 characters of A-Z, a-z, 0-9, _ and -")
+		return
+	}
+	wc.sources, err = iplist.Parse(wc.AllowedSources)
+	if err != nil {
+		err = fmt.Errorf("telegram webhook: %w", err)
+	}
+	return
+}
+
+// telegramWebhook receives updates posted by Telegram.
+type telegramWebhook struct {
+	conf    TelegramWebhookConfig
+	server  *http.Server
+	updates chan tgbotapi.Update
+	done    chan struct{}
+}
+
+// startTelegramWebhook listens on the webhook address, registers the
+// webhook at Telegram and serves it. Errors of listening are returned, so
+// a port clash fails the startup instead of exiting later.
+func startTelegramWebhook(bot *tgbotapi.BotAPI, conf TelegramWebhookConfig) (wh *telegramWebhook, err error) {
+	listener, err := net.Listen("tcp", conf.Listen)
+	if err != nil {
+		err = fmt.Errorf("telegram webhook listen failed: %w", err)
+		return
+	}
+	params := tgbotapi.Params{"url": conf.URL}
+	params.AddNonEmpty("secret_token", conf.SecretToken)
+	_, err = bot.MakeRequest("setWebhook", params)
+	if err != nil {
+		listener.Close()
+		err = fmt.Errorf("set telegram webhook failed: %w", err)
+		return
+	}
+
+	wh = &telegramWebhook{
+		conf:    conf,
+		updates: make(chan tgbotapi.Update, bot.Buffer),
+		done:    make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(conf.path, func(w http.ResponseWriter, r *http.Request) {
+		wh.handle(bot, w, r)
+	})
+	wh.server = &http.Server{
+		Addr:              conf.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logrus.Infof("telegram webhook listening on %s", conf.Listen)
+	go func() {
+		if err := wh.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.Errorf("telegram webhook server failed: %v", err)
+		}
+	}()
+	return
+}
+
+// handle rejects requests from unexpected sources or without the
+// secret token, so the endpoint can't be spoofed.
+func (wh *telegramWebhook) handle(bot *tgbotapi.BotAPI, w http.ResponseWriter, r *http.Request) {
+	if !wh.conf.sources.Allows(r.RemoteAddr) {
+		logrus.Warnf("telegram webhook: rejected request from %s", r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if wh.conf.SecretToken != "" && subtle.ConstantTimeCompare(
+		[]byte(r.Header.Get(telegramSecretTokenHeader)), []byte(wh.conf.SecretToken)) != 1 {
+		logrus.Warnf("telegram webhook: rejected request with invalid secret token from %s", r.RemoteAddr)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	update, err := bot.HandleUpdate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	select {
+	case wh.updates <- *update:
+	case <-wh.done:
+		// Telegram retries updates not acknowledged
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	}
+}
+
+// Stop stops serving, then closes the updates channel.
+func (wh *telegramWebhook) Stop() {
+	close(wh.done)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := wh.server.Shutdown(ctx); err != nil {
+		logrus.Warnf("telegram webhook shutdown failed: %v", err)
+	}
+	close(wh.updates)
+}
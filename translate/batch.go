@@ -0,0 +1,56 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/protect"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+)
+
+// TranslateBatch translates reqs in one batch of the named translator,
+// waiting for the batch to complete. Protected spans
+// are kept out of translation as by the other methods. errs holds the
+// error of each failed request, err fails the batch as a whole.
+func (ts *TranslateService) TranslateBatch(ctx context.Context, name string, reqs []translator.TranslateRequest, opts translator.BatchOptions) (resps []*translator.TranslateResponse, errs []error, err error) {
+	var bt translator.BatchTranslator
+	for _, t := range ts.translators {
+		if t.GetName() == name {
+			var ok bool
+			if bt, ok = t.(translator.BatchTranslator); !ok {
+				err = fmt.Errorf("translator '%s' has no batch API", name)
+				return
+			}
+		}
+	}
+	if bt == nil {
+		err = fmt.Errorf("translator not found: %s", name)
+		return
+	}
+
+	protected := make([]translator.TranslateRequest, len(reqs))
+	spans := make([][]string, len(reqs))
+	for i, req := range reqs {
+		req.Text, spans[i] = ts.protector.Protect(req.Text)
+		protected[i] = req
+	}
+
+	resps, errs, err = bt.TranslateBatch(ctx, protected, opts)
+	if err != nil {
+		return
+	}
+	for i, resp := range resps {
+		if resp == nil || len(spans[i]) == 0 {
+			continue
+		}
+		var missing int
+		resp.Text, missing = protect.Restore(resp.Text, spans[i])
+		if missing > 0 {
+			ts.logger.WithFields(map[string]any{
+				"trace_id":        reqs[i].TraceId,
+				"translator_name": name,
+			}).Warnf("%d of %d protected spans lost in translation", missing, len(spans[i]))
+		}
+	}
+	return
+}
@@ -0,0 +1,43 @@
+package translate
+
+import (
+	"math/rand/v2"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/selector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+)
+
+// canary is a translator taking a percentage of selections, kept out of
+// the selectors.
+type canary struct {
+	translator translator.Translator
+	percent    float64
+}
+
+// selectTranslator picks an enabled canary by its percentage, or an item of
+// sel otherwise. If sel has no enabled item, canaries take all selections
+// rather than failing them. vision limits canaries to vision capable ones.
+// key is the routing key of keyed selectors.
+func (ts *TranslateService) selectTranslator(sel selector.Selector[translator.Translator], vision bool, key string) (t translator.Translator, err error) {
+	roll := rand.Float64() * 100
+	for _, c := range ts.canaries {
+		if roll < c.percent {
+			if (!vision || c.translator.Vision()) && !c.translator.IsDisabled() {
+				return c.translator, nil
+			}
+			break
+		}
+		roll -= c.percent
+	}
+
+	t, err = selector.SelectFor(sel, key)
+	if err == nil {
+		return
+	}
+	for _, c := range ts.canaries {
+		if (!vision || c.translator.Vision()) && !c.translator.IsDisabled() {
+			return c.translator, nil
+		}
+	}
+	return
+}
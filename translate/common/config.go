@@ -1,7 +1,11 @@
 package common
 
 import (
+	"crypto/tls"
 	"fmt"
+	"net"
+	"net/http"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
@@ -49,11 +53,6 @@ func (fc *FailoverConfig) CheckAndMerge(cfg FailoverConfig) (err error) {
 	if fc.MaxDisableCycles < 1 {
 		fc.MaxDisableCycles = cfg.MaxDisableCycles
 	}
-	if fc.MaxDisableCycles <= 1 {
-		logrus.Warnf(
-			"you set the failover max disable cycles as %d, which might causes component will be DISABLED PERMANENTLY IF ANY FAILURE OCCURRED",
-			fc.MaxDisableCycles)
-	}
 	return
 }
 
@@ -89,3 +88,75 @@ func (rlc *RateLimitConfig) NewLimiterFromConfig(logger *logrus.Entry) *rate.Lim
 	)
 	return rate.NewLimiter(rate.Limit(rlc.RefillTPS), rlc.BucketSize)
 }
+
+// HTTPClientConfig tunes the connection pool of an instance's HTTP client.
+// Zero values keep the defaults of http.DefaultTransport.
+type HTTPClientConfig struct {
+	MaxIdleConns        int `yaml:"max_idle_conns,omitempty"`
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host,omitempty"`
+	MaxConnsPerHost     int `yaml:"max_conns_per_host,omitempty"`
+
+	// Seconds an idle connection is kept in the pool
+	IdleConnTimeoutSec int `yaml:"idle_conn_timeout_sec,omitempty"`
+
+	// Seconds between TCP keep-alive probes. Negative disables keep-alive
+	KeepAliveSec int `yaml:"keep_alive_sec,omitempty"`
+
+	DisableHTTP2 bool `yaml:"disable_http2,omitempty"`
+}
+
+func (hcc *HTTPClientConfig) IsZero() bool {
+	return *hcc == HTTPClientConfig{}
+}
+
+func (hcc *HTTPClientConfig) Check() (err error) {
+	if hcc.MaxIdleConns < 0 || hcc.MaxIdleConnsPerHost < 0 || hcc.MaxConnsPerHost < 0 {
+		err = fmt.Errorf("http client connection limits must not be negative")
+		return
+	}
+	if hcc.IdleConnTimeoutSec < 0 {
+		err = fmt.Errorf("http client idle connection timeout must not be negative")
+		return
+	}
+	return
+}
+
+// NewHTTPClientFromConfig returns nil if nothing is configured,
+// so the instance keeps using its library's default client.
+func (hcc *HTTPClientConfig) NewHTTPClientFromConfig(logger *logrus.Entry) *http.Client {
+	if hcc.IsZero() {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if hcc.MaxIdleConns > 0 {
+		transport.MaxIdleConns = hcc.MaxIdleConns
+	}
+	if hcc.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = hcc.MaxIdleConnsPerHost
+	}
+	if hcc.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = hcc.MaxConnsPerHost
+	}
+	if hcc.IdleConnTimeoutSec > 0 {
+		transport.IdleConnTimeout = time.Duration(hcc.IdleConnTimeoutSec) * time.Second
+	}
+	if hcc.KeepAliveSec != 0 {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: time.Duration(hcc.KeepAliveSec) * time.Second,
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	if hcc.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	logger.Debugf(
+		"http client max idle conns: %d, per host: %d, idle timeout: %s, http2: %t",
+		transport.MaxIdleConns, transport.MaxIdleConnsPerHost,
+		transport.IdleConnTimeout, !hcc.DisableHTTP2,
+	)
+	return &http.Client{Transport: transport}
+}
@@ -11,6 +11,23 @@ type FailoverHandler interface {
 	OnSuccess()
 	OnFailure() (isDisabled bool)
 	IsDisabled() bool
+	Status() FailoverStatus
+}
+
+// DisabledFunc is called with the name of a component each time it is
+// disabled, permanent if disabled until the config is reloaded.
+type DisabledFunc func(name string, permanent bool)
+
+// FailoverStatus is a snapshot of the failover state of a component.
+type FailoverStatus struct {
+	// Consecutive failures counted towards the next cooldown
+	Failures int
+
+	// Zero if not cooling down
+	DisabledUntil time.Time
+
+	// Disabled until the config is reloaded
+	PermanentlyDisabled bool
 }
 
 type GeneralFailoverHandler struct {
@@ -35,6 +52,12 @@ func NewGeneralFailoverHandler(conf FailoverConfig, logger *logrus.Entry) (s *Ge
 		isPermanentlyDisabled: false,
 	}
 
+	if conf.MaxDisableCycles <= 1 {
+		logger.Warnf(
+			"you set the failover max disable cycles as %d, which might causes component will be DISABLED PERMANENTLY IF ANY FAILURE OCCURRED",
+			conf.MaxDisableCycles)
+	}
+
 	// It's safe here
 	s.resetState()
 	return
@@ -91,6 +114,17 @@ func (gfh *GeneralFailoverHandler) OnFailure() (isDisabled bool) {
 	return
 }
 
+func (gfh *GeneralFailoverHandler) Status() (s FailoverStatus) {
+	gfh.mu.Lock()
+	defer gfh.mu.Unlock()
+	s.Failures = gfh.failures
+	s.PermanentlyDisabled = gfh.isPermanentlyDisabled
+	if time.Now().Before(gfh.disableUntil) {
+		s.DisabledUntil = gfh.disableUntil
+	}
+	return
+}
+
 func (gfh *GeneralFailoverHandler) IsDisabled() bool {
 	gfh.mu.Lock()
 	ret := gfh.isPermanentlyDisabled || time.Now().Before(gfh.disableUntil)
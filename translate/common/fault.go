@@ -0,0 +1,95 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrInjectedFault is returned by calls failed by fault injection.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultInjectionConfig injects artificial failures into an instance,
+// for testing failover, cooldown and selector behavior in staging.
+type FaultInjectionConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Probability in [0, 1] of failing a call with ErrInjectedFault
+	ErrorRate float64 `yaml:"error_rate"`
+
+	// Probability in [0, 1] of delaying a call by LatencyMs
+	LatencyRate float64 `yaml:"latency_rate"`
+	LatencyMs   int     `yaml:"latency_ms"`
+
+	// Probability in [0, 1] of blocking a call until it times out
+	TimeoutRate float64 `yaml:"timeout_rate"`
+}
+
+func (fic *FaultInjectionConfig) Check() (err error) {
+	if !fic.Enabled {
+		return
+	}
+	for _, r := range []float64{fic.ErrorRate, fic.LatencyRate, fic.TimeoutRate} {
+		if r < 0 || r > 1 {
+			err = fmt.Errorf("fault injection rates must be between 0 and 1")
+			return
+		}
+	}
+	if fic.LatencyMs < 0 {
+		err = fmt.Errorf("fault injection latency must not be negative")
+		return
+	}
+	return
+}
+
+// NewFaultInjectorFromConfig returns nil if fault injection is disabled.
+func (fic *FaultInjectionConfig) NewFaultInjectorFromConfig(logger *logrus.Entry) *FaultInjector {
+	if !fic.Enabled {
+		return nil
+	}
+	logger.Warnf(
+		"fault injection enabled: error rate %.2f, latency rate %.2f (%d ms), timeout rate %.2f",
+		fic.ErrorRate, fic.LatencyRate, fic.LatencyMs, fic.TimeoutRate,
+	)
+	return &FaultInjector{conf: *fic, logger: logger}
+}
+
+type FaultInjector struct {
+	conf   FaultInjectionConfig
+	logger *logrus.Entry
+}
+
+// Inject delays or fails a call according to the configured rates.
+// A nil FaultInjector never injects faults.
+func (fi *FaultInjector) Inject(ctx context.Context) error {
+	if fi == nil {
+		return nil
+	}
+
+	if rand.Float64() < fi.conf.TimeoutRate {
+		fi.logger.Debug("injecting timeout")
+		<-ctx.Done()
+		return fmt.Errorf("%w: %w", ErrInjectedFault, ctx.Err())
+	}
+
+	if rand.Float64() < fi.conf.LatencyRate {
+		fi.logger.Debugf("injecting %d ms latency", fi.conf.LatencyMs)
+		t := time.NewTimer(time.Duration(fi.conf.LatencyMs) * time.Millisecond)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %w", ErrInjectedFault, ctx.Err())
+		case <-t.C:
+		}
+	}
+
+	if rand.Float64() < fi.conf.ErrorRate {
+		fi.logger.Debug("injecting error")
+		return ErrInjectedFault
+	}
+	return nil
+}
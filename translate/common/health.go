@@ -0,0 +1,69 @@
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Weight of the latest call in the smoothed success rate and latency
+const healthAlpha = 0.1
+
+// HealthTracker keeps an exponentially smoothed health score of a
+// component from the outcome and latency of its calls. The score ranges
+// from 0 to 1: the success rate, lowered as the latency approaches the
+// timeout. Unlike failover, it degrades gradually.
+type HealthTracker struct {
+	mu          sync.Mutex
+	timeout     time.Duration
+	successRate float64
+	latency     float64
+	metric      prometheus.Gauge
+}
+
+// NewHealthTracker returns a tracker of full health. metric is optional.
+func NewHealthTracker(timeout time.Duration, metric prometheus.Gauge) *HealthTracker {
+	ht := &HealthTracker{
+		timeout:     timeout,
+		successRate: 1,
+		metric:      metric,
+	}
+	if metric != nil {
+		metric.Set(1)
+	}
+	return ht
+}
+
+// Observe records a call. The latency of failed calls is ignored, as
+// they may fail fast.
+func (ht *HealthTracker) Observe(success bool, latency time.Duration) {
+	ht.mu.Lock()
+	outcome := 0.0
+	if success {
+		outcome = 1
+		ht.latency += healthAlpha * (latency.Seconds() - ht.latency)
+	}
+	ht.successRate += healthAlpha * (outcome - ht.successRate)
+	score := ht.unsafeScore()
+	ht.mu.Unlock()
+
+	if ht.metric != nil {
+		ht.metric.Set(score)
+	}
+}
+
+// Score returns the current health score.
+func (ht *HealthTracker) Score() float64 {
+	ht.mu.Lock()
+	defer ht.mu.Unlock()
+	return ht.unsafeScore()
+}
+
+func (ht *HealthTracker) unsafeScore() float64 {
+	score := ht.successRate
+	if ht.timeout > 0 {
+		score *= 1 - min(ht.latency/ht.timeout.Seconds(), 1)
+	}
+	return score
+}
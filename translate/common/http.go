@@ -0,0 +1,86 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+// Maximum size of response bodies read by DoJSON
+const maxResponseSize = 10 << 20
+
+// DoJSON sends req with client, decoding the JSON response body into out.
+// Responses other than 2xx fail with an HTTPError, whose request has
+// headers listed in masked replaced.
+func DoJSON(client *http.Client, req *http.Request, out any, masked ...string) (err error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		err = fmt.Errorf("read response failed: %w", err)
+		return
+	}
+	// Keep the body for dumps
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		dump := req.Clone(req.Context())
+		dump.Header = req.Header.Clone()
+		for _, h := range masked {
+			if dump.Header.Get(h) != "" {
+				dump.Header.Set(h, "********")
+			}
+		}
+		err = &HTTPError{
+			Err:      fmt.Errorf("unexpected status %s: %s", resp.Status, errorMessage(body)),
+			Request:  dump,
+			Response: resp,
+		}
+		return
+	}
+
+	if err = json.Unmarshal(body, out); err != nil {
+		err = fmt.Errorf("parse response failed: %w", err)
+	}
+	return
+}
+
+// errorMessage returns the message of an error payload, e.g.
+// {"error": "..."}, {"message": "..."} or {"error": {"message": "..."}},
+// the truncated body if it has none.
+func errorMessage(body []byte) string {
+	var payload map[string]any
+	if json.Unmarshal(body, &payload) == nil {
+		for _, k := range []string{"error", "message", "Message"} {
+			switch v := payload[k].(type) {
+			case string:
+				if v != "" {
+					return v
+				}
+			case map[string]any:
+				if m, ok := v["message"].(string); ok && m != "" {
+					return m
+				}
+			}
+		}
+	}
+	return truncate(string(body), 200)
+}
+
+// truncate cuts s to at most n bytes, at a rune boundary.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "…"
+}
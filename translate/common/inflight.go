@@ -0,0 +1,48 @@
+package common
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// InFlightLimiter caps the simultaneous upstream calls of all translators
+// and detectors sharing it. A nil InFlightLimiter is unlimited.
+type InFlightLimiter struct {
+	slots  chan struct{}
+	metric prometheus.Gauge
+}
+
+// NewInFlightLimiter returns nil if max is not positive.
+func NewInFlightLimiter(max int, metric prometheus.Gauge) *InFlightLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &InFlightLimiter{
+		slots:  make(chan struct{}, max),
+		metric: metric,
+	}
+}
+
+// Acquire waits for a free slot. Every successful Acquire must be
+// followed by a Release.
+func (l *InFlightLimiter) Acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		l.metric.Inc()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *InFlightLimiter) Release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+	l.metric.Dec()
+}
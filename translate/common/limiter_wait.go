@@ -0,0 +1,35 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// Limiters waited on before upstream calls
+const (
+	LimiterRate     = "rate"
+	LimiterInFlight = "in_flight"
+)
+
+// WaitLimiters waits for limiter, then for a slot of inFlight, which must
+// be released afterwards. Both are optional. The time waited on each is
+// observed in metric, labeled by limiter, component and name.
+func WaitLimiters(ctx context.Context, limiter *rate.Limiter, inFlight *InFlightLimiter, metric *prometheus.HistogramVec, component, name string) (err error) {
+	if limiter != nil {
+		start := time.Now()
+		err = limiter.Wait(ctx)
+		metric.WithLabelValues(LimiterRate, component, name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			return
+		}
+	}
+	if inFlight != nil {
+		start := time.Now()
+		err = inFlight.Acquire(ctx)
+		metric.WithLabelValues(LimiterInFlight, component, name).Observe(time.Since(start).Seconds())
+	}
+	return
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleConfig limits a component to time windows, e.g. an expensive
+// model during stream hours only.
+type ScheduleConfig struct {
+	// Optional. Cron expressions of minute, hour, day of month, month and
+	// day of week. Active in the minutes matching any of them, always if
+	// empty. For example "* 18-23 * * 5,6" is 18:00 to 23:59 on Fridays
+	// and Saturdays
+	Windows []string `yaml:"windows"`
+
+	// Optional. IANA time zone of the windows, the local one if empty
+	Timezone string `yaml:"timezone"`
+}
+
+func (sc *ScheduleConfig) Check() (err error) {
+	_, err = sc.NewSchedule()
+	return
+}
+
+// NewSchedule returns nil if no windows are configured.
+func (sc *ScheduleConfig) NewSchedule() (s *Schedule, err error) {
+	if len(sc.Windows) == 0 {
+		return
+	}
+	// LoadLocation returns UTC for ""
+	loc := time.Local
+	if sc.Timezone != "" {
+		loc, err = time.LoadLocation(sc.Timezone)
+		if err != nil {
+			err = fmt.Errorf("schedule timezone: %w", err)
+			return
+		}
+	}
+
+	s = &Schedule{loc: loc}
+	for _, w := range sc.Windows {
+		var expr cronExpr
+		expr, err = parseCron(w)
+		if err != nil {
+			err = fmt.Errorf("schedule window '%s': %w", w, err)
+			return nil, err
+		}
+		s.windows = append(s.windows, expr)
+	}
+	return
+}
+
+// Schedule reports whether a time is within any of its windows.
+type Schedule struct {
+	windows []cronExpr
+	loc     *time.Location
+}
+
+// Active reports whether t is within a window. A nil Schedule is always
+// active.
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	t = t.In(s.loc)
+	for _, w := range s.windows {
+		if w.match(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// cronExpr holds the values matched by each field as bits.
+type cronExpr struct {
+	minute, hour, dom, month, dow uint64
+
+	// Day of month and day of week were both restricted, either matches
+	domOrDow bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	// 7 is Sunday as well
+	{"day of week", 0, 7},
+}
+
+func parseCron(s string) (expr cronExpr, err error) {
+	fields := strings.Fields(s)
+	if len(fields) != len(cronFields) {
+		err = fmt.Errorf("expected %d fields, got %d", len(cronFields), len(fields))
+		return
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		bits[i], err = parseCronField(f, cronFields[i])
+		if err != nil {
+			return
+		}
+	}
+	expr.minute, expr.hour, expr.dom, expr.month, expr.dow = bits[0], bits[1], bits[2], bits[3], bits[4]
+	if expr.dow&(1<<7) != 0 {
+		expr.dow |= 1
+	}
+	expr.domOrDow = fields[2] != "*" && fields[4] != "*"
+	return
+}
+
+// parseCronField parses lists of "*", "n" and "a-b", each optionally
+// stepped by "/n".
+func parseCronField(s string, f cronField) (bits uint64, err error) {
+	for part := range strings.SplitSeq(s, ",") {
+		rng, stepStr, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				err = fmt.Errorf("invalid %s step: %s", f.name, part)
+				return
+			}
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			loStr, hiStr, isRange := strings.Cut(rng, "-")
+			lo, err = strconv.Atoi(loStr)
+			if err != nil {
+				err = fmt.Errorf("invalid %s: %s", f.name, part)
+				return
+			}
+			hi = lo
+			if isRange {
+				hi, err = strconv.Atoi(hiStr)
+				if err != nil {
+					err = fmt.Errorf("invalid %s: %s", f.name, part)
+					return
+				}
+			} else if stepped {
+				hi = f.max
+			}
+		}
+		if lo < f.min || hi > f.max || lo > hi {
+			err = fmt.Errorf("%s out of range %d-%d: %s", f.name, f.min, f.max, part)
+			return
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return
+}
+
+func (e cronExpr) match(t time.Time) bool {
+	if e.minute&(1<<t.Minute()) == 0 || e.hour&(1<<t.Hour()) == 0 || e.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	dom := e.dom&(1<<t.Day()) != 0
+	dow := e.dow&(1<<int(t.Weekday())) != 0
+	if e.domOrDow {
+		return dom || dow
+	}
+	return dom && dow
+}
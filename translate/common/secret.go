@@ -0,0 +1,84 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SecretPollInterval is how often watched secret files are read.
+const SecretPollInterval = 10 * time.Second
+
+// ReadSecretFile returns the content of a secret file, e.g. a mounted
+// Kubernetes secret, without surrounding whitespace.
+func ReadSecretFile(path string) (secret string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		err = fmt.Errorf("read secret file '%s' failed: %w", path, err)
+		return
+	}
+	secret = strings.TrimSpace(string(data))
+	if secret == "" {
+		err = fmt.Errorf("secret file '%s' is empty", path)
+	}
+	return
+}
+
+// LoadSecretFile reads file into secret if file is set.
+// Setting both is an error.
+func LoadSecretFile(secret *string, file string) (err error) {
+	if file == "" {
+		return
+	}
+	if *secret != "" {
+		err = fmt.Errorf("a secret and its file are mutually exclusive")
+		return
+	}
+	*secret, err = ReadSecretFile(file)
+	return
+}
+
+// SecretWatcher polls a secret file and reports changes of its content,
+// e.g. after the secret was rotated by Kubernetes or a Vault agent.
+type SecretWatcher struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+// WatchSecretFile calls onChange with the new secret whenever the content
+// of path differs from current. Unreadable or empty files are logged and
+// the previous secret is kept.
+func WatchSecretFile(path, current string, logger *logrus.Entry, onChange func(secret string)) *SecretWatcher {
+	w := &SecretWatcher{stop: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(SecretPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+
+			secret, err := ReadSecretFile(path)
+			if err != nil {
+				logger.Warnf("keeping previous secret: %v", err)
+				continue
+			}
+			if secret != current {
+				current = secret
+				onChange(secret)
+			}
+		}
+	}()
+	return w
+}
+
+func (w *SecretWatcher) Close() error {
+	w.once.Do(func() { close(w.stop) })
+	return nil
+}
@@ -2,6 +2,8 @@ package translate
 
 import (
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/protect"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/transcriber"
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
 )
 
@@ -15,6 +17,19 @@ type TranslateServiceConfig struct {
 	DefaultTranslatorConfig  translator.DefaultTranslatorConfig `yaml:"default_translator_config"`
 	TranslatorSelector       string                             `yaml:"translator_selector"`
 	Translators              []translator.TranslatorConfig      `yaml:"translators"`
+	Protect                  protect.Config                     `yaml:"protect"`
+	Segmentation             SegmentationConfig                 `yaml:"segmentation"`
+	Transcribers             []transcriber.TranscriberConfig    `yaml:"transcribers"`
+	QualityEstimation        QualityConfig                      `yaml:"quality_estimation"`
+	OutputValidation         ValidationConfig                   `yaml:"output_validation"`
+
+	// Optional. Simultaneous upstream calls of all translators and
+	// detectors combined, unlimited if 0
+	MaxInFlight int `yaml:"max_in_flight"`
+
+	// Optional. Scale the weights of wrr selectors by the health score of
+	// each translator and detector, from its success rate and latency
+	HealthWeighted bool `yaml:"health_weighted"`
 }
 
 // NewTranslateServiceConfig creates a new TranslateConfig with default empty slices and zero values.
@@ -25,5 +40,10 @@ func NewTranslateServiceConfig() (c TranslateServiceConfig) {
 	}
 	c.DefaultTranslatorConfig.Failover.SetDefault()
 	c.DefaultDetectorConfig.Failover.SetDefault()
+	c.Protect.Code = true
+	c.Protect.URLs = true
+	c.Protect.Mentions = true
+	c.Protect.Hashtags = true
+	c.Segmentation.MaxSegments = 20
 	return
 }
@@ -0,0 +1,114 @@
+package detector
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheConfig caches detection results, for stickers and copypasta
+// detected over and over in busy groups.
+type CacheConfig struct {
+	// Optional. Maximum entries, caching disabled if 0
+	Size int `yaml:"size"`
+
+	// Optional. Seconds an entry is kept, until evicted if 0
+	TTLSec int `yaml:"ttl_sec"`
+}
+
+func (cc *CacheConfig) Check() (err error) {
+	if cc.Size < 0 || cc.TTLSec < 0 {
+		err = fmt.Errorf("detector cache size and ttl must not be negative")
+	}
+	return
+}
+
+// detectCache is an LRU cache of detection results by normalized text.
+// Keys are hashes, so no message content is kept in memory.
+type detectCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type detectCacheEntry struct {
+	key     [sha256.Size]byte
+	resp    DetectResponse
+	err     error
+	expires time.Time
+}
+
+// newDetectCache returns nil if caching is disabled.
+func newDetectCache(conf CacheConfig) *detectCache {
+	if conf.Size <= 0 {
+		return nil
+	}
+	return &detectCache{
+		size:    conf.Size,
+		ttl:     time.Duration(conf.TTLSec) * time.Second,
+		order:   list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element, conf.Size),
+	}
+}
+
+// detectCacheKey hashes text with case and whitespace folded.
+func detectCacheKey(text string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(strings.ToLower(strings.Join(strings.Fields(text), " "))))
+}
+
+// get returns a copy of the cached response or the cached weak error.
+func (dc *detectCache) get(text string) (resp *DetectResponse, ok bool, err error) {
+	key := detectCacheKey(text)
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	e, ok := dc.entries[key]
+	if !ok {
+		return
+	}
+	entry := e.Value.(*detectCacheEntry)
+	if dc.ttl > 0 && time.Now().After(entry.expires) {
+		dc.order.Remove(e)
+		delete(dc.entries, key)
+		return nil, false, nil
+	}
+	dc.order.MoveToFront(e)
+	if entry.err != nil {
+		return nil, true, entry.err
+	}
+	r := entry.resp
+	r.Candidates = slices.Clone(r.Candidates)
+	return &r, true, nil
+}
+
+// put caches a response, or a weak error as texts below the threshold
+// stay below it.
+func (dc *detectCache) put(text string, resp *DetectResponse, err error) {
+	entry := &detectCacheEntry{key: detectCacheKey(text), err: err}
+	if resp != nil {
+		entry.resp = *resp
+		entry.resp.Candidates = slices.Clone(resp.Candidates)
+	}
+	if dc.ttl > 0 {
+		entry.expires = time.Now().Add(dc.ttl)
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if e, ok := dc.entries[entry.key]; ok {
+		e.Value = entry
+		dc.order.MoveToFront(e)
+		return
+	}
+	dc.entries[entry.key] = dc.order.PushFront(entry)
+	if dc.order.Len() > dc.size {
+		oldest := dc.order.Back()
+		dc.order.Remove(oldest)
+		delete(dc.entries, oldest.Value.(*detectCacheEntry).key)
+	}
+}
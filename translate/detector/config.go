@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/plugin"
 )
 
 type DefaultDetectorConfig struct {
@@ -18,6 +19,12 @@ type DefaultDetectorConfig struct {
 
 	// Optional. Failover
 	Failover common.FailoverConfig `yaml:"failover,omitempty"`
+
+	// Optional. Connection pool tuning of API based instances
+	HTTPClient common.HTTPClientConfig `yaml:"http_client,omitempty"`
+
+	// Optional. LRU cache of detection results
+	Cache CacheConfig `yaml:"cache,omitempty"`
 }
 
 type DetectorConfig struct {
@@ -32,6 +39,9 @@ type DetectorConfig struct {
 	// Positive
 	Timeout int64 `yaml:"timeout"`
 
+	// Optional. Tier of the priority selector, lower tiers first
+	Priority int `yaml:"priority"`
+
 	// Minimum confidence score required for a detected language to be
 	// considered valid by this detector.
 	SourceLangConfidenceThreshold float64 `yaml:"source_lang_confidence_threshold"`
@@ -40,10 +50,25 @@ type DetectorConfig struct {
 	Endpoint string `yaml:"endpoint"`
 
 	// Optional
-	Token string `yaml:"token"`
+	Token string `yaml:"token" redact:"true"`
+
+	// Optional. File holding the token instead, watched for rotation
+	TokenFile string `yaml:"token_file"`
 
 	// Optional
 	RateLimit common.RateLimitConfig `yaml:"rate_limit"`
+
+	// Optional. For resilience testing only
+	FaultInjection common.FaultInjectionConfig `yaml:"fault_injection"`
+
+	// Required by plugin instances
+	Plugin plugin.Config `yaml:"plugin"`
+
+	// Required by ensemble instances
+	Ensemble EnsembleConfig `yaml:"ensemble"`
+
+	// Required by google_translate instances
+	Google GoogleConfig `yaml:"google"`
 }
 
 func (tic *DetectorConfig) CheckAndMergeDefaultConfig(dtc DefaultDetectorConfig) (err error) {
@@ -65,11 +90,22 @@ func (tic *DetectorConfig) CheckAndMergeDefaultConfig(dtc DefaultDetectorConfig)
 		tic.Weight = dtc.Weight
 	}
 
+	if tic.Priority < 0 {
+		err = fmt.Errorf("%s: priority must not be negative", tic.Name)
+		return
+	}
+
 	if tic.Timeout <= 0 {
 		err = fmt.Errorf("%s: timeout must be positive", tic.Name)
 		return
 	}
 
+	err = common.LoadSecretFile(&tic.Token, tic.TokenFile)
+	if err != nil {
+		err = fmt.Errorf("%s: token: %w", tic.Name, err)
+		return
+	}
+
 	/*
 		if tic.Endpoint == "" {
 			err = fmt.Errorf("%s: endpoint is required", tic.Name)
@@ -100,7 +136,43 @@ func (tic *DetectorConfig) CheckAndMergeDefaultConfig(dtc DefaultDetectorConfig)
 		return
 	}
 
+	// HTTP Client
+	if tic.HTTPClient.IsZero() {
+		tic.HTTPClient = dtc.HTTPClient
+	}
+	err = tic.HTTPClient.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tic.Name, err)
+		return
+	}
+
+	// Cache
+	if tic.Cache == (CacheConfig{}) {
+		tic.Cache = dtc.Cache
+	}
+	err = tic.Cache.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tic.Name, err)
+		return
+	}
+
 	// Rate Limit
 	err = tic.RateLimit.Check()
+	if err != nil {
+		return
+	}
+
+	err = tic.FaultInjection.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tic.Name, err)
+		return
+	}
+
+	if tic.Type == ENSEMBLE {
+		err = tic.Ensemble.checkAndMerge(*tic)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", tic.Name, err)
+		}
+	}
 	return
 }
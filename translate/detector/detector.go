@@ -3,6 +3,7 @@ package detector
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 
@@ -31,7 +32,7 @@ var (
 	}
 )
 
-type newDetectorInstanceFunc func(DetectorConfig) (Instance, error)
+type newDetectorInstanceFunc func(DetectorConfig, *logrus.Entry) (Instance, error)
 
 func registerDetectorInstance(name string, f newDetectorInstanceFunc) {
 	if _, ok := registeredDetectorInstances[name]; !ok {
@@ -41,32 +42,46 @@ func registerDetectorInstance(name string, f newDetectorInstanceFunc) {
 	panic(fmt.Sprintf("detector instance type '%s' already registered", name))
 }
 
-func NewDetectorInstance(conf DetectorConfig) (Instance, error) {
+func NewDetectorInstance(conf DetectorConfig, logger *logrus.Entry) (Instance, error) {
 	if f, ok := registeredDetectorInstances[conf.Type]; ok {
-		return f(conf)
+		return f(conf, logger.WithField("detector_instance", conf.Name))
 	}
 	return nil, fmt.Errorf("unknown detector type '%s', detector: %s", conf.Type, conf.Name)
 }
 
-func NewDetector(selectorType string, conf DetectorConfig) (LanguageDetector, error) {
-	instance, err := NewDetectorInstance(conf)
+// NewDetector creates a detector from conf. inFlight is optional and
+// may be shared with other translators and detectors.
+func NewDetector(selectorType string, conf DetectorConfig, logger *logrus.Entry, m *metrics.Metrics, inFlight *common.InFlightLimiter, onDisabled common.DisabledFunc) (LanguageDetector, error) {
+	instance, err := NewDetectorInstance(conf, logger)
 	if err != nil {
 		return nil, err
 	}
+	if conf.TokenFile != "" {
+		instance = newRotatingInstance(conf, instance, logger)
+	}
 
 	opts := DetectorOptions{
 		Instance:        instance,
+		Logger:          logger,
 		Timeout:         conf.Timeout,
 		FailoverConfig:  conf.Failover,
 		RateLimitConfig: conf.RateLimit,
-		UpMetric:        metrics.MetricDetectorUp,
-		SelectionMetric: metrics.MetricDetectorSelectionTotal,
-		TasksMetric:     metrics.MetricDetectorTasks,
+		InFlight:        inFlight,
+		OnDisabled:      onDisabled,
+		FaultInjection:  conf.FaultInjection,
+		Cache:           conf.Cache,
+		UpMetric:        m.DetectorUp,
+		HealthMetric:    m.DetectorHealth,
+		SelectionMetric: m.DetectorSelectionTotal,
+		TasksMetric:     m.DetectorTasks,
+		WaitMetric:      m.LimiterWait,
+		CacheMetric:     m.DetectorCacheRequests,
 		Weight:          conf.Weight,
+		Priority:        conf.Priority,
 	}
 
 	switch selectorType {
-	case selector.WRR, selector.FALLBACK:
+	case selector.WRR, selector.FALLBACK, selector.LEAST_LATENCY, selector.PRIORITY:
 		return newGeneralLanguageDetector(opts), nil
 	}
 	return nil, fmt.Errorf("unrecognized translator selector: %s", selectorType)
@@ -77,67 +92,116 @@ type DetectRequest struct {
 	TraceId string
 }
 
+// LanguageCandidate is a language the text may be in.
+type LanguageCandidate struct {
+	// ISO 639-1 code, upper case
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
 type DetectResponse struct {
+	// Best candidate
 	Language   string
 	Confidence float64
+
+	// Candidates of the source language filter, the best first, then by
+	// descending confidence. Only the best if the instance reports one
+	Candidates []LanguageCandidate
 }
 
 type LanguageDetector interface {
-	selector.WeightedItem
+	selector.PriorityItem
+	selector.HealthItem
 
-	Detect(DetectRequest) (*DetectResponse, error)
+	Detect(context.Context, DetectRequest) (*DetectResponse, error)
 	GetName() string
+	Close() error
+	FailoverStatus() common.FailoverStatus
 }
 
 type DetectorOptions struct {
 	Instance Instance
+	Logger   *logrus.Entry
 	Timeout  int64
 
 	// Failover
 	FailoverConfig  common.FailoverConfig
 	RateLimitConfig common.RateLimitConfig
 
+	// Optional. Shared limit of upstream calls
+	InFlight *common.InFlightLimiter
+
+	// Optional. Called when failover disables the detector
+	OnDisabled common.DisabledFunc
+
+	// Optional. Testing only
+	FaultInjection common.FaultInjectionConfig
+
+	// Optional
+	Cache CacheConfig
+
 	UpMetric        *prometheus.GaugeVec
+	HealthMetric    *prometheus.GaugeVec
 	SelectionMetric *prometheus.CounterVec
 	TasksMetric     *prometheus.GaugeVec
+	WaitMetric      *prometheus.HistogramVec
+	CacheMetric     *prometheus.CounterVec
 
 	// WRR
 	Weight int
+
+	// Priority
+	Priority int
 }
 
 type GeneralLanguageDetector struct {
 	instance        Instance
 	logger          *logrus.Entry
 	limiter         *rate.Limiter
+	inFlight        *common.InFlightLimiter
 	timeout         time.Duration
 	failoverHandler common.FailoverHandler
+	faultInjector   *common.FaultInjector
+	health          *common.HealthTracker
+	cache           *detectCache
 
 	// Metrics
 	upMetric        *prometheus.GaugeVec
 	selectionMetric *prometheus.CounterVec
 	tasksMetric     *prometheus.GaugeVec
+	waitMetric      *prometheus.HistogramVec
+	cacheMetric     *prometheus.CounterVec
 
 	// Weighted
 	configWeight  int
 	currentWeight int
 	weightedMu    *sync.Mutex
+	priority      int
+
+	onDisabled common.DisabledFunc
 }
 
 func newGeneralLanguageDetector(opts DetectorOptions) (gld *GeneralLanguageDetector) {
 	gld = &GeneralLanguageDetector{
 		instance: opts.Instance,
+		inFlight: opts.InFlight,
 		timeout:  time.Duration(opts.Timeout) * time.Second,
-		logger:   logrus.WithField("detector_name", opts.Instance.Name()),
+		logger:   opts.Logger.WithField("detector_name", opts.Instance.Name()),
 
 		// Metrics
 		upMetric:        opts.UpMetric,
 		selectionMetric: opts.SelectionMetric,
 		tasksMetric:     opts.TasksMetric,
+		waitMetric:      opts.WaitMetric,
+		cacheMetric:     opts.CacheMetric,
 
 		// Weighted
 		configWeight:  opts.Weight,
 		currentWeight: 0,
 		weightedMu:    new(sync.Mutex),
+		priority:      opts.Priority,
+
+		onDisabled: opts.OnDisabled,
 	}
 	// Initialize metrics
 	gld.upMetric.WithLabelValues(gld.GetName()).Set(1)
@@ -148,16 +212,41 @@ func newGeneralLanguageDetector(opts DetectorOptions) (gld *GeneralLanguageDetec
 
 	gld.failoverHandler = common.NewGeneralFailoverHandler(opts.FailoverConfig, gld.logger)
 	gld.limiter = opts.RateLimitConfig.NewLimiterFromConfig(gld.logger)
+	gld.faultInjector = opts.FaultInjection.NewFaultInjectorFromConfig(gld.logger)
+	var healthGauge prometheus.Gauge
+	if opts.HealthMetric != nil {
+		healthGauge = opts.HealthMetric.WithLabelValues(gld.GetName())
+	}
+	gld.health = common.NewHealthTracker(gld.timeout, healthGauge)
+	gld.cache = newDetectCache(opts.Cache)
+	if gld.cache != nil && gld.cacheMetric != nil {
+		gld.cacheMetric.WithLabelValues(gld.GetName(), "hit").Add(0.0)
+		gld.cacheMetric.WithLabelValues(gld.GetName(), "miss").Add(0.0)
+	}
 	return
 }
 
-func (gld *GeneralLanguageDetector) Detect(req DetectRequest) (resp *DetectResponse, err error) {
+func (gld *GeneralLanguageDetector) Detect(ctx context.Context, req DetectRequest) (resp *DetectResponse, err error) {
 	gld.selectionMetric.WithLabelValues(gld.GetName()).Inc()
+	logger := gld.logger.WithField("trace_id", req.TraceId)
 
-	ctx, cancel := context.WithTimeout(context.Background(), gld.timeout)
-	defer cancel()
+	if gld.cache != nil {
+		var hit bool
+		if resp, hit, err = gld.cache.get(req.Text); hit {
+			gld.observeCache("hit")
+			logger.Debug("detection cache hit")
+			return
+		}
+		gld.observeCache("miss")
+		defer func() {
+			if err == nil || CheckWeakError(err) {
+				gld.cache.put(req.Text, resp, err)
+			}
+		}()
+	}
 
-	logger := gld.logger.WithField("trace_id", req.TraceId)
+	ctx, cancel := context.WithTimeout(ctx, gld.timeout)
+	defer cancel()
 
 	logger.Trace("wating for limiter")
 	gld.tasksMetric.WithLabelValues(detectionStatePending, gld.GetName()).Inc()
@@ -167,12 +256,17 @@ func (gld *GeneralLanguageDetector) Detect(req DetectRequest) (resp *DetectRespo
 		return nil, fmt.Errorf("rate limiter wait failed: %w", err)
 	}
 	logger.Trace("acquired limiter")
+	defer gld.inFlight.Release()
 
 	gld.tasksMetric.WithLabelValues(detectionStateProcessing, gld.GetName()).Inc()
 	defer gld.tasksMetric.WithLabelValues(detectionStateProcessing, gld.GetName()).Dec()
 
 	logger.Debug("wating for detect response")
-	resp, err = gld.instance.Detect(ctx, req)
+	start := time.Now()
+	err = gld.faultInjector.Inject(ctx)
+	if err == nil {
+		resp, err = gld.instance.Detect(ctx, req)
+	}
 
 	if err != nil {
 		// WeakError shouldn't trigger failure event
@@ -180,18 +274,33 @@ func (gld *GeneralLanguageDetector) Detect(req DetectRequest) (resp *DetectRespo
 			return
 		}
 
+		gld.health.Observe(false, 0)
 		gld.onFailure()
 		return
 	}
+	gld.health.Observe(true, time.Since(start))
 	gld.onSuccess()
 	return
 }
 
+func (gld *GeneralLanguageDetector) observeCache(result string) {
+	if gld.cacheMetric != nil {
+		gld.cacheMetric.WithLabelValues(gld.GetName(), result).Inc()
+	}
+}
+
+// wait waits for the rate limiter, then for an in flight slot,
+// which must be released afterwards.
 func (gld *GeneralLanguageDetector) wait(ctx context.Context) (err error) {
-	if gld.limiter != nil {
-		err = gld.limiter.Wait(ctx)
+	return common.WaitLimiters(ctx, gld.limiter, gld.inFlight, gld.waitMetric, "detector", gld.GetName())
+}
+
+// Close releases resources held by the underlying instance, if any.
+func (gld *GeneralLanguageDetector) Close() error {
+	if c, ok := gld.instance.(io.Closer); ok {
+		return c.Close()
 	}
-	return
+	return nil
 }
 
 func (gld *GeneralLanguageDetector) GetName() string {
@@ -208,13 +317,28 @@ func (gld *GeneralLanguageDetector) onFailure() {
 	gld.tasksMetric.WithLabelValues(detectionStateFailed, gld.GetName()).Inc()
 	if gld.failoverHandler.OnFailure() {
 		gld.upMetric.WithLabelValues(gld.GetName()).Set(0)
+		if gld.onDisabled != nil {
+			gld.onDisabled(gld.GetName(), gld.failoverHandler.Status().PermanentlyDisabled)
+		}
 	}
 }
 
+func (gld *GeneralLanguageDetector) FailoverStatus() common.FailoverStatus {
+	return gld.failoverHandler.Status()
+}
+
 func (gld *GeneralLanguageDetector) IsDisabled() bool {
 	return gld.failoverHandler.IsDisabled()
 }
 
+func (gld *GeneralLanguageDetector) HealthScore() float64 {
+	return gld.health.Score()
+}
+
+func (gld *GeneralLanguageDetector) GetPriority() int {
+	return gld.priority
+}
+
 func (gld *GeneralLanguageDetector) GetConfigWeight() int {
 	gld.weightedMu.Lock()
 	defer gld.weightedMu.Unlock()
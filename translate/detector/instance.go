@@ -1,6 +1,7 @@
 package detector
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"slices"
@@ -8,6 +9,9 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// Maximum candidates of a DetectResponse
+const maxCandidates = 5
+
 type Instance interface {
 	Detect(context.Context, DetectRequest) (*DetectResponse, error)
 	Name() string
@@ -42,3 +46,33 @@ func (t *baseInstance) checkDetectResult(lang string, confidence float64) (err e
 	}
 	return
 }
+
+// detectResult checks the best language, then returns it with the other
+// candidates of the source language filter.
+func (t *baseInstance) detectResult(lang string, confidence float64, candidates []LanguageCandidate) (resp *DetectResponse, err error) {
+	err = t.checkDetectResult(lang, confidence)
+	if err != nil {
+		return
+	}
+
+	resp = &DetectResponse{
+		Language:   lang,
+		Confidence: confidence,
+		Candidates: []LanguageCandidate{{Language: lang, Confidence: confidence}},
+	}
+	candidates = slices.Clone(candidates)
+	slices.SortStableFunc(candidates, func(a, b LanguageCandidate) int {
+		return cmp.Compare(b.Confidence, a.Confidence)
+	})
+	for _, c := range candidates {
+		if len(resp.Candidates) >= maxCandidates {
+			break
+		}
+		if c.Confidence <= 0 || !slices.Contains(t.sourceLangs, c.Language) ||
+			slices.ContainsFunc(resp.Candidates, func(r LanguageCandidate) bool { return r.Language == c.Language }) {
+			continue
+		}
+		resp.Candidates = append(resp.Candidates, c)
+	}
+	return
+}
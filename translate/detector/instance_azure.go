@@ -0,0 +1,134 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	AZURE_TEXT_ANALYTICS = "azure_text_analytics"
+
+	azureLanguagesPath = "/text/analytics/v3.1/languages"
+	azureHeaderKey     = "Ocp-Apim-Subscription-Key"
+)
+
+func init() {
+	registerDetectorInstance(AZURE_TEXT_ANALYTICS, newAzureInstance)
+}
+
+// InstanceAzure detects with the language detection of Azure AI Language
+// (Text Analytics). The token is the key of the resource.
+type InstanceAzure struct {
+	baseInstance
+	client   *http.Client
+	endpoint string
+	key      string
+}
+
+func newAzureInstance(conf DetectorConfig, logger *logrus.Entry) (instance Instance, err error) {
+	if conf.Endpoint == "" || conf.Token == "" {
+		err = fmt.Errorf("endpoint and token are required by azure text analytics")
+		return
+	}
+
+	ad := &InstanceAzure{
+		baseInstance: baseInstance{
+			name:                conf.Name,
+			confidenceThreshold: conf.SourceLangConfidenceThreshold,
+			sourceLangs:         conf.SourceLangFilter,
+			logger:              logger,
+		},
+		client:   conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint: strings.TrimSuffix(conf.Endpoint, "/") + azureLanguagesPath,
+		key:      conf.Token,
+	}
+	if ad.client == nil {
+		ad.client = http.DefaultClient
+	}
+
+	ad.logger.Debugf("initialized Azure Text Analytics instance, api url: %s", ad.endpoint)
+	return ad, nil
+}
+
+type azureDocument struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+
+	// Empty disables the default hint of US
+	CountryHint string `json:"countryHint"`
+}
+
+type azureLanguagesRequest struct {
+	Documents []azureDocument `json:"documents"`
+}
+
+type azureLanguagesResponse struct {
+	Documents []struct {
+		DetectedLanguage struct {
+			ISO6391Name     string  `json:"iso6391Name"`
+			ConfidenceScore float64 `json:"confidenceScore"`
+		} `json:"detectedLanguage"`
+	} `json:"documents"`
+	Errors []struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"errors"`
+}
+
+func (ad *InstanceAzure) Detect(ctx context.Context, req DetectRequest) (resp *DetectResponse, err error) {
+	body, err := json.Marshal(azureLanguagesRequest{
+		Documents: []azureDocument{{ID: "1", Text: req.Text}},
+	})
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, ad.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(azureHeaderKey, ad.key)
+
+	var result azureLanguagesResponse
+	err = common.DoJSON(ad.client, httpReq, &result, azureHeaderKey)
+	if err != nil {
+		err = fmt.Errorf("azure text analytics request failed: %w", err)
+		return
+	}
+	// Documents failing are listed in errors with status 200
+	if len(result.Errors) > 0 {
+		err = fmt.Errorf("azure text analytics error %s: %s",
+			result.Errors[0].Error.Code, result.Errors[0].Error.Message)
+		return
+	}
+
+	lang := ""
+	confidence := 0.0
+	if len(result.Documents) > 0 {
+		detected := result.Documents[0].DetectedLanguage
+		// "(Unknown)" if undetectable
+		if !strings.HasPrefix(detected.ISO6391Name, "(") {
+			lang = isoLang(detected.ISO6391Name)
+			confidence = detected.ConfidenceScore
+		}
+	}
+
+	return ad.detectResult(lang, confidence, nil)
+}
+
+// isoLang returns the upper-case ISO 639-1 code of a language tag of a
+// cloud service, e.g. ZH for zh-CN or zh_chs.
+func isoLang(tag string) string {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	return strings.ToUpper(lang)
+}
@@ -24,16 +24,17 @@ type InstanceDetectLanguage struct {
 	client *detectlanguage.Client
 }
 
-func newDetectLanguageInstance(conf DetectorConfig) (instance Instance, err error) {
+func newDetectLanguageInstance(conf DetectorConfig, logger *logrus.Entry) (instance Instance, err error) {
 	ld := &InstanceDetectLanguage{
 		baseInstance: baseInstance{
 			name:                conf.Name,
 			confidenceThreshold: conf.SourceLangConfidenceThreshold,
 			sourceLangs:         conf.SourceLangFilter,
-			logger:              logrus.WithField("detector_instance", conf.Name),
+			logger:              logger,
 		},
 		client: detectlanguage.New(conf.Token),
 	}
+	ld.client.Client = conf.HTTPClient.NewHTTPClientFromConfig(logger)
 
 	// Check API status
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -68,26 +69,21 @@ func (ld *InstanceDetectLanguage) Detect(ctx context.Context, req DetectRequest)
 
 	lang := ""
 	confidence := 0.0
+	var candidates []LanguageCandidate
 	for _, cv := range r {
+		l := strings.ToUpper(cv.Language)
+		c := float64(cv.Confidence)
+		// Unreliable results are candidates only
+		candidates = append(candidates, LanguageCandidate{Language: l, Confidence: c})
 		if !cv.Reliable {
 			continue
 		}
 
-		l := strings.ToUpper(cv.Language)
-		c := float64(cv.Confidence)
 		if c > confidence {
 			lang = l
 			confidence = c
 		}
 	}
 
-	err = ld.checkDetectResult(lang, confidence)
-	if err != nil {
-		return
-	}
-
-	return &DetectResponse{
-		Language:   lang,
-		Confidence: confidence,
-	}, nil
+	return ld.detectResult(lang, confidence, candidates)
 }
@@ -0,0 +1,235 @@
+package detector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ENSEMBLE = "ensemble"
+
+	// Language detected by most members, ties broken by confidence
+	ensembleStrategyMajority = "majority"
+	// Language of the highest confidence summed over members
+	ensembleStrategyConfidence = "confidence"
+	// Language of the first member detecting one, members tried in order
+	ensembleStrategySequential = "sequential"
+)
+
+func init() {
+	registerDetectorInstance(ENSEMBLE, newEnsembleInstance)
+}
+
+// EnsembleConfig holds the members of ensemble instances. Members inherit
+// the defaults and the timeout of the ensemble.
+type EnsembleConfig struct {
+	// Optional. "majority" (default), "confidence" or "sequential", e.g.
+	// a local detector falling back to a cloud service below its threshold
+	Strategy string `yaml:"strategy"`
+
+	// Required. At least 2, ensembles can't be nested
+	Members []DetectorConfig `yaml:"members"`
+}
+
+func (ec *EnsembleConfig) checkAndMerge(parent DetectorConfig) (err error) {
+	switch ec.Strategy {
+	case "":
+		ec.Strategy = ensembleStrategyMajority
+	case ensembleStrategyMajority, ensembleStrategyConfidence, ensembleStrategySequential:
+	default:
+		err = fmt.Errorf("invalid ensemble strategy: %s", ec.Strategy)
+		return
+	}
+	if len(ec.Members) < 2 {
+		err = fmt.Errorf("ensemble requires at least 2 members")
+		return
+	}
+	for i := range ec.Members {
+		m := &ec.Members[i]
+		if m.Type == ENSEMBLE {
+			err = fmt.Errorf("ensembles can't be nested")
+			return
+		}
+		if m.Name == "" {
+			m.Name = fmt.Sprintf("%s-%d", parent.Name, i)
+		}
+		if m.Timeout <= 0 {
+			m.Timeout = parent.Timeout
+		}
+		err = m.CheckAndMergeDefaultConfig(parent.DefaultDetectorConfig)
+		if err != nil {
+			err = fmt.Errorf("member %d: %w", i, err)
+			return
+		}
+	}
+	return
+}
+
+// InstanceEnsemble runs its members in parallel and returns the language
+// they agree on, or in order with the sequential strategy. Members below
+// their own threshold don't vote, the threshold of the ensemble applies to
+// the averaged confidence.
+type InstanceEnsemble struct {
+	baseInstance
+	strategy string
+	members  []Instance
+}
+
+func newEnsembleInstance(conf DetectorConfig, logger *logrus.Entry) (instance Instance, err error) {
+	ed := &InstanceEnsemble{
+		baseInstance: baseInstance{
+			name:                conf.Name,
+			confidenceThreshold: conf.SourceLangConfidenceThreshold,
+			sourceLangs:         conf.SourceLangFilter,
+			logger:              logger,
+		},
+		strategy: conf.Ensemble.Strategy,
+	}
+	for _, mc := range conf.Ensemble.Members {
+		var m Instance
+		m, err = NewDetectorInstance(mc, logger)
+		if err != nil {
+			ed.Close()
+			err = fmt.Errorf("member %s: %w", mc.Name, err)
+			return
+		}
+		if mc.TokenFile != "" {
+			m = newRotatingInstance(mc, m, logger)
+		}
+		ed.members = append(ed.members, m)
+	}
+
+	ed.logger.Debugf("initialized ensemble of %d members, strategy: %s", len(ed.members), ed.strategy)
+	return ed, nil
+}
+
+type ensembleVote struct {
+	votes      int
+	confidence float64
+}
+
+func (ed *InstanceEnsemble) Detect(ctx context.Context, req DetectRequest) (resp *DetectResponse, err error) {
+	if ed.strategy == ensembleStrategySequential {
+		return ed.detectSequential(ctx, req)
+	}
+
+	resps := make([]*DetectResponse, len(ed.members))
+	errs := make([]error, len(ed.members))
+	wg := new(sync.WaitGroup)
+	for i, m := range ed.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resps[i], errs[i] = m.Detect(ctx, req)
+		}()
+	}
+	wg.Wait()
+
+	logger := ed.logger.WithField("trace_id", req.TraceId)
+	votes := map[string]*ensembleVote{}
+	responded := 0
+	var failures []error
+	for i, r := range resps {
+		if errs[i] != nil {
+			logger.Debugf("member %s: %v", ed.members[i].Name(), errs[i])
+			if !CheckWeakError(errs[i]) {
+				failures = append(failures, fmt.Errorf("%s: %w", ed.members[i].Name(), errs[i]))
+			}
+			continue
+		}
+		responded++
+		v, ok := votes[r.Language]
+		if !ok {
+			v = new(ensembleVote)
+			votes[r.Language] = v
+		}
+		v.votes++
+		v.confidence += r.Confidence
+	}
+
+	// Only members failing for real fail the ensemble, as long as none voted
+	if responded == 0 && len(failures) > 0 {
+		err = fmt.Errorf("all ensemble members failed: %w", errors.Join(failures...))
+		return
+	}
+	for _, f := range failures {
+		logger.Warnf("ensemble member failed: %v", f)
+	}
+
+	lang := ""
+	var best *ensembleVote
+	for l, v := range votes {
+		if best == nil || ed.better(v, best) || (!ed.better(best, v) && l < lang) {
+			lang, best = l, v
+		}
+	}
+	confidence := 0.0
+	if best != nil {
+		confidence = best.confidence / float64(responded)
+		logger.Debugf("ensemble detected %s, %d of %d votes", lang, best.votes, len(ed.members))
+	}
+
+	candidates := make([]LanguageCandidate, 0, len(votes))
+	for l, v := range votes {
+		candidates = append(candidates, LanguageCandidate{Language: l, Confidence: v.confidence / float64(responded)})
+	}
+	return ed.detectResult(lang, confidence, candidates)
+}
+
+// detectSequential returns the language of the first member detecting one.
+func (ed *InstanceEnsemble) detectSequential(ctx context.Context, req DetectRequest) (resp *DetectResponse, err error) {
+	logger := ed.logger.WithField("trace_id", req.TraceId)
+	var failures []error
+	weak := false
+	for _, m := range ed.members {
+		resp, err = m.Detect(ctx, req)
+		if err == nil {
+			resp, err = ed.detectResult(resp.Language, resp.Confidence, resp.Candidates)
+			if err == nil {
+				logger.Debugf("ensemble detected %s by %s", resp.Language, m.Name())
+				return
+			}
+		}
+		logger.Debugf("member %s: %v", m.Name(), err)
+		if CheckWeakError(err) {
+			weak = true
+		} else {
+			failures = append(failures, fmt.Errorf("%s: %w", m.Name(), err))
+		}
+	}
+	resp = nil
+
+	// Weak if any member answered, like a single detector below threshold
+	err = fmt.Errorf("all ensemble members failed: %w", errors.Join(failures...))
+	if weak {
+		for _, f := range failures {
+			logger.Warnf("ensemble member failed: %v", f)
+		}
+		err = newWeakError(fmt.Errorf("no reliable language detected by any member"))
+	}
+	return
+}
+
+// better reports whether a wins over b by the strategy.
+func (ed *InstanceEnsemble) better(a, b *ensembleVote) bool {
+	if ed.strategy == ensembleStrategyMajority && a.votes != b.votes {
+		return a.votes > b.votes
+	}
+	return a.confidence > b.confidence
+}
+
+// Close releases the members holding resources, e.g. plugins.
+func (ed *InstanceEnsemble) Close() error {
+	var errs []error
+	for _, m := range ed.members {
+		if c, ok := m.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,153 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	GOOGLE_TRANSLATE = "google_translate"
+
+	defaultGoogleEndpoint = "https://translation.googleapis.com"
+	defaultGoogleLocation = "global"
+
+	// Token kinds of Google Cloud
+	googleAuthAccessToken = "access_token"
+	googleAuthAPIKey      = "api_key"
+
+	googleHeaderAPIKey = "X-Goog-Api-Key"
+)
+
+func init() {
+	registerDetectorInstance(GOOGLE_TRANSLATE, newGoogleInstance)
+}
+
+// GoogleConfig holds the project of google_translate instances.
+type GoogleConfig struct {
+	// Required. Project billed for the detections
+	ProjectID string `yaml:"project_id"`
+
+	// Optional. "global" by default
+	Location string `yaml:"location"`
+
+	// Optional. Kind of the token, "access_token" (default), an OAuth 2.0
+	// token of a service account, short-lived so best kept in a token file
+	// refreshed by a sidecar, or "api_key"
+	Auth string `yaml:"auth" redact:"true"`
+}
+
+// InstanceGoogle detects with detectLanguage of Google Cloud Translation
+// v3.
+type InstanceGoogle struct {
+	baseInstance
+	client     *http.Client
+	endpoint   string
+	authHeader string
+	authValue  string
+}
+
+func newGoogleInstance(conf DetectorConfig, logger *logrus.Entry) (instance Instance, err error) {
+	gc := conf.Google
+	if gc.ProjectID == "" {
+		err = fmt.Errorf("project id is required by google translate")
+		return
+	}
+	if conf.Token == "" {
+		err = fmt.Errorf("token is required by google translate")
+		return
+	}
+	if gc.Location == "" {
+		gc.Location = defaultGoogleLocation
+	}
+	endpoint := strings.TrimSuffix(conf.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = defaultGoogleEndpoint
+	}
+
+	gd := &InstanceGoogle{
+		baseInstance: baseInstance{
+			name:                conf.Name,
+			confidenceThreshold: conf.SourceLangConfidenceThreshold,
+			sourceLangs:         conf.SourceLangFilter,
+			logger:              logger,
+		},
+		client: conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint: fmt.Sprintf("%s/v3/projects/%s/locations/%s:detectLanguage",
+			endpoint, gc.ProjectID, gc.Location),
+	}
+	switch gc.Auth {
+	case "", googleAuthAccessToken:
+		gd.authHeader, gd.authValue = "Authorization", "Bearer "+conf.Token
+	case googleAuthAPIKey:
+		gd.authHeader, gd.authValue = googleHeaderAPIKey, conf.Token
+	default:
+		err = fmt.Errorf("invalid google auth: %s", gc.Auth)
+		return
+	}
+	if gd.client == nil {
+		gd.client = http.DefaultClient
+	}
+
+	gd.logger.Debugf("initialized Google Translate instance, api url: %s", gd.endpoint)
+	return gd, nil
+}
+
+type googleDetectRequest struct {
+	Content  string `json:"content"`
+	MimeType string `json:"mimeType"`
+}
+
+type googleDetectResponse struct {
+	Languages []struct {
+		LanguageCode string  `json:"languageCode"`
+		Confidence   float64 `json:"confidence"`
+	} `json:"languages"`
+}
+
+func (gd *InstanceGoogle) Detect(ctx context.Context, req DetectRequest) (resp *DetectResponse, err error) {
+	body, err := json.Marshal(googleDetectRequest{
+		Content:  req.Text,
+		MimeType: "text/plain",
+	})
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, gd.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(gd.authHeader, gd.authValue)
+
+	var result googleDetectResponse
+	err = common.DoJSON(gd.client, httpReq, &result, gd.authHeader)
+	if err != nil {
+		err = fmt.Errorf("google translate request failed: %w", err)
+		return
+	}
+
+	lang := ""
+	confidence := 0.0
+	var candidates []LanguageCandidate
+	for _, l := range result.Languages {
+		// "und" if undetermined
+		if l.LanguageCode == "und" {
+			continue
+		}
+		candidates = append(candidates, LanguageCandidate{Language: isoLang(l.LanguageCode), Confidence: l.Confidence})
+		if l.Confidence > confidence {
+			lang = isoLang(l.LanguageCode)
+			confidence = l.Confidence
+		}
+	}
+
+	return gd.detectResult(lang, confidence, candidates)
+}
@@ -3,6 +3,9 @@ package detector
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/pemistahl/lingua-go"
 	"github.com/sirupsen/logrus"
@@ -16,20 +19,81 @@ func init() {
 	registerDetectorInstance(LINGUA, newLinguaInstance)
 }
 
+var (
+	// Lingua models shared between instances with identical language sets.
+	// Kept while any instance uses them, so reloads, building the new
+	// instances before closing the old ones, don't rebuild them.
+	linguaModels   = map[string]*linguaModel{}
+	linguaModelsMu = new(sync.Mutex)
+)
+
+// linguaModel builds its detector on first use.
+type linguaModel struct {
+	key      string
+	langs    []lingua.Language
+	once     *sync.Once
+	detector lingua.LanguageDetector
+
+	// Instances using the model, guarded by linguaModelsMu
+	refs int
+}
+
+func (lm *linguaModel) get() lingua.LanguageDetector {
+	lm.once.Do(func() {
+		lm.detector = lingua.NewLanguageDetectorBuilder().FromLanguages(lm.langs...).Build()
+	})
+	return lm.detector
+}
+
+func getLinguaModel(langs []lingua.Language) (lm *linguaModel, shared bool) {
+	codes := make([]string, 0, len(langs))
+	for _, l := range langs {
+		codes = append(codes, l.IsoCode639_1().String())
+	}
+	slices.Sort(codes)
+	key := strings.Join(slices.Compact(codes), ",")
+
+	linguaModelsMu.Lock()
+	defer linguaModelsMu.Unlock()
+	lm, shared = linguaModels[key]
+	if !shared {
+		lm = &linguaModel{
+			key:   key,
+			langs: langs,
+			once:  new(sync.Once),
+		}
+		linguaModels[key] = lm
+	}
+	lm.refs++
+	return
+}
+
+// releaseLinguaModel drops lm once no instance uses it.
+func releaseLinguaModel(lm *linguaModel) {
+	linguaModelsMu.Lock()
+	defer linguaModelsMu.Unlock()
+	lm.refs--
+	if lm.refs <= 0 {
+		delete(linguaModels, lm.key)
+	}
+}
+
 type InstanceLingua struct {
 	baseInstance
-	detector lingua.LanguageDetector
+	model   *linguaModel
+	release *sync.Once
 }
 
-func newLinguaInstance(conf DetectorConfig) (instance Instance, err error) {
+func newLinguaInstance(conf DetectorConfig, logger *logrus.Entry) (instance Instance, err error) {
 	ld := &InstanceLingua{
 		baseInstance: baseInstance{
 			name:                conf.Name,
 			confidenceThreshold: conf.SourceLangConfidenceThreshold,
 			sourceLangs:         conf.SourceLangFilter,
-			logger:              logrus.WithField("detector_instance", conf.Name),
+			logger:              logger,
 		},
-		detector: nil,
+		model:   nil,
+		release: new(sync.Once),
 	}
 
 	allLanguages := map[string]lingua.Language{}
@@ -48,29 +112,35 @@ func newLinguaInstance(conf DetectorConfig) (instance Instance, err error) {
 		}
 	}
 
-	ld.detector = lingua.NewLanguageDetectorBuilder().FromLanguages(availableLangs...).Build()
+	var shared bool
+	ld.model, shared = getLinguaModel(availableLangs)
+	if shared {
+		ld.logger.Debug("reusing language model with the same detect languages")
+	}
 	return ld, nil
 }
 
 func (ld *InstanceLingua) Detect(_ context.Context, req DetectRequest) (resp *DetectResponse, err error) {
 	lang := ""
 	confidence := 0.0
-	for _, cv := range ld.detector.ComputeLanguageConfidenceValues(req.Text) {
+	var candidates []LanguageCandidate
+	for _, cv := range ld.model.get().ComputeLanguageConfidenceValues(req.Text) {
 		l := cv.Language().IsoCode639_1().String()
 		c := cv.Value()
+		candidates = append(candidates, LanguageCandidate{Language: l, Confidence: c})
 		if c > confidence {
 			lang = l
 			confidence = c
 		}
 	}
 
-	err = ld.checkDetectResult(lang, confidence)
-	if err != nil {
-		return
-	}
+	return ld.detectResult(lang, confidence, candidates)
+}
 
-	return &DetectResponse{
-		Language:   lang,
-		Confidence: confidence,
-	}, nil
+// Close releases the language model.
+func (ld *InstanceLingua) Close() error {
+	ld.release.Do(func() {
+		releaseLinguaModel(ld.model)
+	})
+	return nil
 }
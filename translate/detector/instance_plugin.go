@@ -0,0 +1,75 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/plugin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	PLUGIN = "plugin"
+)
+
+func init() {
+	registerDetectorInstance(PLUGIN, newPluginInstance)
+}
+
+// InstancePlugin forwards detection requests to an out-of-process plugin.
+type InstancePlugin struct {
+	baseInstance
+	client   *plugin.Client
+	detector *plugin.DetectorClient
+}
+
+func newPluginInstance(conf DetectorConfig, logger *logrus.Entry) (instance Instance, err error) {
+	client, err := plugin.NewClient(conf.Plugin, logger)
+	if err != nil {
+		return
+	}
+
+	d, err := client.Detector()
+	if err != nil {
+		client.Close()
+		return
+	}
+
+	logger.Debugf("initialized plugin instance: %s", conf.Plugin.Path)
+	return &InstancePlugin{
+		baseInstance: baseInstance{
+			name:                conf.Name,
+			confidenceThreshold: conf.SourceLangConfidenceThreshold,
+			sourceLangs:         conf.SourceLangFilter,
+			logger:              logger,
+		},
+		client:   client,
+		detector: d,
+	}, nil
+}
+
+func (pd *InstancePlugin) Detect(ctx context.Context, req DetectRequest) (resp *DetectResponse, err error) {
+	reply, err := pd.detector.Detect(ctx, plugin.DetectArgs{
+		Text:    req.Text,
+		TraceId: req.TraceId,
+	})
+	if err != nil {
+		err = fmt.Errorf("plugin error: %w", err)
+		return
+	}
+
+	candidates := make([]LanguageCandidate, 0, len(reply.Candidates))
+	for _, c := range reply.Candidates {
+		candidates = append(candidates, LanguageCandidate{
+			Language:   strings.ToUpper(c.Language),
+			Confidence: c.Confidence,
+		})
+	}
+	return pd.detectResult(strings.ToUpper(reply.Language), reply.Confidence, candidates)
+}
+
+// Close terminates the plugin process.
+func (pd *InstancePlugin) Close() error {
+	return pd.client.Close()
+}
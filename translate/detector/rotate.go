@@ -0,0 +1,67 @@
+package detector
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+// rotatingInstance rebuilds the wrapped instance whenever the token file
+// of its config changes, so keys rotate without a restart or reload.
+type rotatingInstance struct {
+	mu       sync.RWMutex
+	instance Instance
+	watcher  *common.SecretWatcher
+}
+
+func newRotatingInstance(conf DetectorConfig, instance Instance, logger *logrus.Entry) *rotatingInstance {
+	r := &rotatingInstance{instance: instance}
+	wl := logger.WithField("detector_name", conf.Name)
+	r.watcher = common.WatchSecretFile(conf.TokenFile, conf.Token, wl, func(token string) {
+		conf.Token = token
+		instance, err := NewDetectorInstance(conf, logger)
+		if err != nil {
+			wl.Errorf("rebuild with rotated token failed, keeping previous token: %v", err)
+			return
+		}
+		r.swap(instance)
+		wl.Info("token rotated")
+	})
+	return r
+}
+
+func (r *rotatingInstance) get() Instance {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.instance
+}
+
+// swap replaces the instance, closing the previous one if needed.
+func (r *rotatingInstance) swap(instance Instance) {
+	r.mu.Lock()
+	old := r.instance
+	r.instance = instance
+	r.mu.Unlock()
+	if c, ok := old.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func (r *rotatingInstance) Detect(ctx context.Context, req DetectRequest) (*DetectResponse, error) {
+	return r.get().Detect(ctx, req)
+}
+
+func (r *rotatingInstance) Name() string {
+	return r.get().Name()
+}
+
+func (r *rotatingInstance) Close() error {
+	r.watcher.Close()
+	if c, ok := r.get().(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/rpc"
+	"os/exec"
+
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Names of the plugins dispensed by a plugin binary.
+	TranslatorPluginName = "translator"
+	DetectorPluginName   = "detector"
+)
+
+// HandshakeConfig must match between the bot and the plugin binary,
+// otherwise the plugin will not be loaded.
+type HandshakeConfig struct {
+	ProtocolVersion  uint   `yaml:"protocol_version"`
+	MagicCookieKey   string `yaml:"magic_cookie_key"`
+	MagicCookieValue string `yaml:"magic_cookie_value"`
+}
+
+func (hc HandshakeConfig) toGoPlugin() goplugin.HandshakeConfig {
+	return goplugin.HandshakeConfig{
+		ProtocolVersion:  hc.ProtocolVersion,
+		MagicCookieKey:   hc.MagicCookieKey,
+		MagicCookieValue: hc.MagicCookieValue,
+	}
+}
+
+type Config struct {
+	// Required. Path to the plugin binary.
+	Path string `yaml:"path"`
+
+	// Optional. Arguments passed to the plugin binary.
+	Args []string `yaml:"args"`
+
+	// Required
+	Handshake HandshakeConfig `yaml:"handshake"`
+}
+
+func (pc *Config) Check() (err error) {
+	if pc.Path == "" {
+		err = fmt.Errorf("plugin path is required")
+		return
+	}
+	if pc.Handshake.MagicCookieKey == "" || pc.Handshake.MagicCookieValue == "" {
+		err = fmt.Errorf("plugin handshake magic cookie is required")
+		return
+	}
+	return
+}
+
+type TranslateArgs struct {
+	Text    string
+	TraceId string
+}
+
+type TranslateReply struct {
+	Text             string
+	CompletionTokens int64
+	PromptTokens     int64
+}
+
+type DetectArgs struct {
+	Text    string
+	TraceId string
+}
+
+type DetectReply struct {
+	// ISO 639-1 code, upper case
+	Language   string
+	Confidence float64
+
+	// Optional. Other languages the text may be in
+	Candidates []DetectCandidate
+}
+
+type DetectCandidate struct {
+	// ISO 639-1 code, upper case
+	Language   string
+	Confidence float64
+}
+
+// Translator is implemented by plugin binaries providing a translator.
+type Translator interface {
+	Translate(TranslateArgs) (TranslateReply, error)
+}
+
+// Detector is implemented by plugin binaries providing a language detector.
+type Detector interface {
+	Detect(DetectArgs) (DetectReply, error)
+}
+
+// Serve is called by plugin binaries to serve their implementations.
+// Either translator or detector may be nil.
+func Serve(handshake HandshakeConfig, translator Translator, detector Detector) {
+	plugins := goplugin.PluginSet{}
+	if translator != nil {
+		plugins[TranslatorPluginName] = &translatorPlugin{impl: translator}
+	}
+	if detector != nil {
+		plugins[DetectorPluginName] = &detectorPlugin{impl: detector}
+	}
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: handshake.toGoPlugin(),
+		Plugins:         plugins,
+	})
+}
+
+// Client manages a running plugin process.
+type Client struct {
+	client *goplugin.Client
+	rpc    goplugin.ClientProtocol
+}
+
+// NewClient starts the plugin binary and connects to it.
+func NewClient(conf Config, logger *logrus.Entry) (c *Client, err error) {
+	err = conf.Check()
+	if err != nil {
+		return
+	}
+
+	client := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig: conf.Handshake.toGoPlugin(),
+		Plugins: goplugin.PluginSet{
+			TranslatorPluginName: &translatorPlugin{},
+			DetectorPluginName:   &detectorPlugin{},
+		},
+		Cmd:              exec.Command(conf.Path, conf.Args...),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolNetRPC},
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "plugin",
+			Output: logger.WriterLevel(logrus.DebugLevel),
+			Level:  hclog.Debug,
+		}),
+	})
+
+	logger.Debugf("starting plugin: %s", conf.Path)
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		err = fmt.Errorf("start plugin '%s' failed: %w", conf.Path, err)
+		return
+	}
+	return &Client{client: client, rpc: rpcClient}, nil
+}
+
+func (c *Client) dispense(name string) (raw any, err error) {
+	raw, err = c.rpc.Dispense(name)
+	if err != nil {
+		err = fmt.Errorf("plugin does not provide a %s: %w", name, err)
+	}
+	return
+}
+
+// Translator returns the translator served by the plugin.
+func (c *Client) Translator() (*TranslatorClient, error) {
+	raw, err := c.dispense(TranslatorPluginName)
+	if err != nil {
+		return nil, err
+	}
+	return raw.(*TranslatorClient), nil
+}
+
+// Detector returns the detector served by the plugin.
+func (c *Client) Detector() (*DetectorClient, error) {
+	raw, err := c.dispense(DetectorPluginName)
+	if err != nil {
+		return nil, err
+	}
+	return raw.(*DetectorClient), nil
+}
+
+// Close terminates the plugin process.
+func (c *Client) Close() error {
+	c.client.Kill()
+	return nil
+}
+
+// call invokes an RPC method and aborts waiting once ctx is done.
+func call(ctx context.Context, client *rpc.Client, method string, args any, reply any) error {
+	c := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.Done:
+		return c.Error
+	}
+}
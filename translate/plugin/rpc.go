@@ -0,0 +1,70 @@
+package plugin
+
+import (
+	"context"
+	"net/rpc"
+
+	goplugin "github.com/hashicorp/go-plugin"
+)
+
+type translatorPlugin struct {
+	impl Translator
+}
+
+func (p *translatorPlugin) Server(*goplugin.MuxBroker) (any, error) {
+	return &translatorServer{impl: p.impl}, nil
+}
+
+func (p *translatorPlugin) Client(_ *goplugin.MuxBroker, c *rpc.Client) (any, error) {
+	return &TranslatorClient{client: c}, nil
+}
+
+type translatorServer struct {
+	impl Translator
+}
+
+func (s *translatorServer) Translate(args TranslateArgs, reply *TranslateReply) (err error) {
+	*reply, err = s.impl.Translate(args)
+	return
+}
+
+// TranslatorClient is the bot side of a translator plugin.
+type TranslatorClient struct {
+	client *rpc.Client
+}
+
+func (c *TranslatorClient) Translate(ctx context.Context, args TranslateArgs) (reply TranslateReply, err error) {
+	err = call(ctx, c.client, "Plugin.Translate", args, &reply)
+	return
+}
+
+type detectorPlugin struct {
+	impl Detector
+}
+
+func (p *detectorPlugin) Server(*goplugin.MuxBroker) (any, error) {
+	return &detectorServer{impl: p.impl}, nil
+}
+
+func (p *detectorPlugin) Client(_ *goplugin.MuxBroker, c *rpc.Client) (any, error) {
+	return &DetectorClient{client: c}, nil
+}
+
+type detectorServer struct {
+	impl Detector
+}
+
+func (s *detectorServer) Detect(args DetectArgs, reply *DetectReply) (err error) {
+	*reply, err = s.impl.Detect(args)
+	return
+}
+
+// DetectorClient is the bot side of a detector plugin.
+type DetectorClient struct {
+	client *rpc.Client
+}
+
+func (c *DetectorClient) Detect(ctx context.Context, args DetectArgs) (reply DetectReply, err error) {
+	err = call(ctx, c.client, "Plugin.Detect", args, &reply)
+	return
+}
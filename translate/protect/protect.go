@@ -0,0 +1,132 @@
+// Package protect replaces spans of a text that must survive translation
+// byte-for-byte, such as code or URLs, with placeholders and restores them afterwards.
+package protect
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Instruction tells LLM translators to keep placeholders untouched.
+const Instruction = "The text contains placeholders like ⟦0⟧. " +
+	"Keep every placeholder exactly as it is, at the appropriate position of the translation. " +
+	"Never translate, alter or remove placeholders."
+
+var (
+	placeholderRe = regexp.MustCompile(`⟦(\d+)⟧`)
+
+	codePatterns = []string{
+		"```[\\s\\S]*?```",
+		"`[^`\\n]+`",
+	}
+	urlPatterns = []string{
+		`https?://[^\s]*[^\s.,;:!?)\]}'"」）]`,
+	}
+	mentionPatterns = []string{
+		// Telegram usernames
+		`\B@[A-Za-z0-9_]{2,}`,
+		// Discord user, role and channel mentions
+		`<(?:@[!&]?|#)\d+>`,
+	}
+	hashtagPatterns = []string{
+		`\B#[\p{L}\p{N}_]+`,
+	}
+)
+
+type Config struct {
+	// Fenced code blocks and inline code
+	Code bool `yaml:"code"`
+
+	URLs     bool `yaml:"urls"`
+	Mentions bool `yaml:"mentions"`
+	Hashtags bool `yaml:"hashtags"`
+
+	// Optional. Regular expressions of additional spans to protect
+	Patterns []string `yaml:"patterns"`
+}
+
+// Protector replaces matches of the configured patterns with placeholders.
+type Protector struct {
+	re *regexp.Regexp
+}
+
+// NewProtector returns nil if nothing is protected.
+func NewProtector(conf Config) (p *Protector, err error) {
+	patterns := []string{}
+	// Earlier patterns take precedence, e.g. URLs inside code stay part of the code
+	if conf.Code {
+		patterns = append(patterns, codePatterns...)
+	}
+	if conf.URLs {
+		patterns = append(patterns, urlPatterns...)
+	}
+	if conf.Mentions {
+		patterns = append(patterns, mentionPatterns...)
+	}
+	if conf.Hashtags {
+		patterns = append(patterns, hashtagPatterns...)
+	}
+	for _, pattern := range conf.Patterns {
+		if _, err = regexp.Compile(pattern); err != nil {
+			err = fmt.Errorf("invalid protect pattern '%s': %w", pattern, err)
+			return
+		}
+		patterns = append(patterns, "(?:"+pattern+")")
+	}
+	if len(patterns) == 0 {
+		return
+	}
+	// Placeholders already in the text are protected as spans themselves,
+	// so Restore doesn't replace them
+	patterns = append([]string{placeholderRe.String()}, patterns...)
+
+	re, err := regexp.Compile(strings.Join(patterns, "|"))
+	if err != nil {
+		err = fmt.Errorf("invalid protect pattern: %w", err)
+		return
+	}
+	return &Protector{re: re}, nil
+}
+
+// Protect returns text with protected spans replaced by placeholders,
+// and the spans in placeholder order. A nil Protector returns text as is.
+func (p *Protector) Protect(text string) (masked string, spans []string) {
+	if p == nil {
+		return text, nil
+	}
+	masked = p.re.ReplaceAllStringFunc(text, func(span string) string {
+		spans = append(spans, span)
+		return fmt.Sprintf("⟦%d⟧", len(spans)-1)
+	})
+	return
+}
+
+// Restore replaces placeholders in text with their spans.
+// It returns the number of spans whose placeholder was lost.
+func Restore(text string, spans []string) (restored string, missing int) {
+	if len(spans) == 0 {
+		return text, 0
+	}
+	found := make([]bool, len(spans))
+	restored = placeholderRe.ReplaceAllStringFunc(text, func(placeholder string) string {
+		i, err := strconv.Atoi(placeholderRe.FindStringSubmatch(placeholder)[1])
+		if err != nil || i >= len(spans) {
+			return placeholder
+		}
+		found[i] = true
+		return spans[i]
+	})
+	for _, ok := range found {
+		if !ok {
+			missing += 1
+		}
+	}
+	return
+}
+
+// HasPlaceholders reports whether text contains placeholders.
+func HasPlaceholders(text string) bool {
+	return placeholderRe.MatchString(text)
+}
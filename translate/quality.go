@@ -0,0 +1,148 @@
+package translate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+)
+
+// Actions on translations scoring below the quality threshold
+const (
+	// Count and log them
+	qualityActionFlag = "flag"
+	// Translate them again with another translator
+	qualityActionRetry = "retry"
+)
+
+// Flagging estimations in flight at most, further ones are dropped
+const maxFlagsInFlight = 32
+
+// Results of quality estimations
+const (
+	qualityResultPass = "pass"
+	qualityResultLow  = "low"
+	// Back-translation failed, the translation is not scored
+	qualityResultFailed = "failed"
+	// Too many flagging estimations in flight
+	qualityResultDropped = "dropped"
+)
+
+type QualityConfig struct {
+	// Back-translate text translations into the detected source language
+	// and score their similarity to the original text
+	Enabled bool `yaml:"enabled"`
+
+	// Required. Translator back-translating, kept out of selection.
+	// A cheap one will do
+	Translator string `yaml:"translator"`
+
+	// Between 0 and 1. Translations scoring below are low quality
+	Threshold float64 `yaml:"threshold"`
+
+	// "flag" (default) or "retry"
+	Action string `yaml:"action"`
+}
+
+func (qc *QualityConfig) Check() (err error) {
+	if !qc.Enabled {
+		return
+	}
+	if qc.Translator == "" {
+		err = fmt.Errorf("quality estimation translator is required")
+		return
+	}
+	if qc.Threshold <= 0 || qc.Threshold > 1 {
+		err = fmt.Errorf("quality estimation threshold must be between 0 and 1")
+		return
+	}
+	switch qc.Action {
+	case "":
+		qc.Action = qualityActionFlag
+	case qualityActionFlag, qualityActionRetry:
+	default:
+		err = fmt.Errorf("invalid quality estimation action: %s", qc.Action)
+	}
+	return
+}
+
+// estimateQuality scores resp of translator name. Flagged translations are
+// scored in the background. With the retry action, a low scoring
+// translation is translated again by another translator, and the better
+// scoring of both is returned. Texts of unknown language and images are
+// not scored.
+func (ts *TranslateService) estimateQuality(ctx context.Context, req translator.TranslateRequest, name string, resp *translator.TranslateResponse) (*translator.TranslateResponse, string) {
+	if req.SourceLang == "" || req.Image != nil {
+		return resp, name
+	}
+	if ts.quality.Action == qualityActionFlag {
+		select {
+		case ts.flagSlots <- struct{}{}:
+		default:
+			ts.metrics.QualityEstimations.WithLabelValues(name, qualityResultDropped).Inc()
+			return resp, name
+		}
+		ts.users.Add(1)
+		go func() {
+			defer ts.users.Done()
+			defer func() { <-ts.flagSlots }()
+			ts.scoreQuality(context.Background(), req, name, resp)
+		}()
+		return resp, name
+	}
+
+	score, ok := ts.scoreQuality(ctx, req, name, resp)
+	if !ok || score >= ts.quality.Threshold {
+		return resp, name
+	}
+	next := ts.nextTranslators(name)
+	if len(next) == 0 {
+		return resp, name
+	}
+	alt := next[0]
+	ts.metrics.QualityRetries.WithLabelValues(name, rejectReasonScore).Inc()
+	logger := ts.logger.WithField("trace_id", req.TraceId)
+	logger.Infof("translating again with '%s' after low quality score %.2f of '%s'", alt.GetName(), score, name)
+	altResp, err := ts.translateProtected(ctx, alt, req)
+	if err != nil {
+		logger.WithField("translator_name", alt.GetName()).
+			Warnf("quality retry failed, keeping the first translation: %v", err)
+		return resp, name
+	}
+	altScore, ok := ts.scoreQuality(ctx, req, alt.GetName(), altResp)
+	if ok && altScore > score {
+		return altResp, alt.GetName()
+	}
+	return resp, name
+}
+
+// scoreQuality back-translates resp of translator name into the language
+// of req, returning the similarity of the result to the text of req.
+func (ts *TranslateService) scoreQuality(ctx context.Context, req translator.TranslateRequest, name string, resp *translator.TranslateResponse) (score float64, ok bool) {
+	logger := ts.logger.WithFields(map[string]any{
+		"trace_id":        req.TraceId,
+		"translator_name": name,
+	})
+	back, err := ts.translateProtected(ctx, ts.backTranslator, translator.TranslateRequest{
+		Text:       resp.Text,
+		TraceId:    req.TraceId,
+		SourceLang: resp.TargetLang,
+		TargetLang: req.SourceLang,
+	})
+	if err != nil {
+		ts.metrics.QualityEstimations.WithLabelValues(name, qualityResultFailed).Inc()
+		logger.Warnf("back-translation failed: %v", err)
+		return
+	}
+
+	score, ok = Similarity(req.Text, back.Text), true
+	ts.metrics.QualityScore.WithLabelValues(name, resp.PromptVariant).Observe(score)
+	if score < ts.quality.Threshold {
+		ts.metrics.QualityEstimations.WithLabelValues(name, qualityResultLow).Inc()
+		logger.WithField("quality_score", score).Warn("translation scored below the quality threshold")
+		return
+	}
+	ts.metrics.QualityEstimations.WithLabelValues(name, qualityResultPass).Inc()
+	logger.WithField("quality_score", score).Debug("translation passed quality estimation")
+	return
+}
@@ -0,0 +1,149 @@
+package translate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+)
+
+// ErrNothingToTranslate is returned if no segment of a mixed-language
+// text passed language detection.
+var ErrNothingToTranslate = errors.New("no segment to translate")
+
+type SegmentationConfig struct {
+	// Detect and translate each sentence separately if the language
+	// of the whole text couldn't be detected reliably
+	Enabled bool `yaml:"enabled"`
+
+	// Positive. Texts with more sentences are not segmented
+	MaxSegments int `yaml:"max_segments"`
+}
+
+func (sc *SegmentationConfig) Check() (err error) {
+	if sc.Enabled && sc.MaxSegments <= 0 {
+		err = fmt.Errorf("segmentation max segments must be positive")
+	}
+	return
+}
+
+// SegmentationEnabled reports whether TranslateSegmentsOnce may be used.
+func (ts *TranslateService) SegmentationEnabled() bool {
+	return ts.segmentation.Enabled
+}
+
+// TranslateSegmentsOnce detects the language of every sentence of the text
+// and translates consecutive sentences of the same detected language together.
+// Sentences failing detection, e.g. already in the target language, are kept as is.
+// The response takes its model and prompt variant from the longest translated
+// part, whose language is returned. It returns ErrNothingToTranslate if no
+// sentence needs translation.
+func (ts *TranslateService) TranslateSegmentsOnce(ctx context.Context, req translator.TranslateRequest) (resp *translator.TranslateResponse, lang *detector.DetectResponse, name string, err error) {
+	segments := splitSentences(req.Text)
+	if len(segments) < 2 || len(segments) > ts.segmentation.MaxSegments {
+		err = fmt.Errorf("%w: %d segments", ErrNothingToTranslate, len(segments))
+		return
+	}
+
+	type part struct {
+		text string
+		lang *detector.DetectResponse
+	}
+	parts := []*part{}
+	for _, s := range segments {
+		var segLang *detector.DetectResponse
+		if strings.TrimSpace(s) != "" {
+			segLang, _, err = ts.DetectLangOnce(ctx, detector.DetectRequest{Text: s, TraceId: req.TraceId})
+			if err != nil && !detector.CheckWeakError(err) {
+				return
+			}
+			err = nil
+		}
+
+		if n := len(parts); n > 0 && sameLanguage(parts[n-1].lang, segLang) {
+			parts[n-1].text += s
+			continue
+		}
+		parts = append(parts, &part{text: s, lang: segLang})
+	}
+
+	resp = new(translator.TranslateResponse)
+	var out strings.Builder
+	longest := 0
+	for _, p := range parts {
+		if p.lang == nil {
+			out.WriteString(p.text)
+			continue
+		}
+
+		// Keep surrounding whitespace of the part
+		trimmed := strings.TrimSpace(p.text)
+		start := strings.Index(p.text, trimmed)
+
+		var partResp *translator.TranslateResponse
+		partResp, name, err = ts.TranslateOnce(ctx, translator.TranslateRequest{
+			Text:       trimmed,
+			TraceId:    req.TraceId,
+			SourceLang: p.lang.Language,
+			RoutingKey: req.RoutingKey,
+		})
+		if err != nil {
+			return
+		}
+		out.WriteString(p.text[:start] + partResp.Text + p.text[start+len(trimmed):])
+		resp.TokenUsage.Completion += partResp.TokenUsage.Completion
+		resp.TokenUsage.Prompt += partResp.TokenUsage.Prompt
+		resp.TokenUsage.CachedPrompt += partResp.TokenUsage.CachedPrompt
+		resp.TargetLang = partResp.TargetLang
+
+		if len(trimmed) > longest {
+			longest = len(trimmed)
+			lang = p.lang
+			resp.Model = partResp.Model
+			resp.PromptVariant = partResp.PromptVariant
+		}
+	}
+	if lang == nil {
+		resp = nil
+		err = ErrNothingToTranslate
+		return
+	}
+	resp.Text = out.String()
+	return
+}
+
+func sameLanguage(a, b *detector.DetectResponse) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Language == b.Language
+}
+
+// splitSentences splits text after sentence terminators and line breaks.
+// Whitespace following a terminator stays with its sentence,
+// so joining the sentences yields the text.
+func splitSentences(text string) (sentences []string) {
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if !strings.ContainsRune(".!?。！？\n", runes[i]) {
+			continue
+		}
+		end := i + 1
+		// Closing quotes and brackets belong to the sentence
+		for end < len(runes) && (strings.ContainsRune(".!?。！？」』）)\"'”’", runes[end]) || unicode.IsSpace(runes[end])) {
+			end++
+		}
+		sentences = append(sentences, string(runes[start:end]))
+		start = end
+		i = end - 1
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
+	}
+	return
+}
@@ -0,0 +1,93 @@
+package translate
+
+import (
+	"context"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+)
+
+// Shadow translations in flight at most, further ones are dropped
+const maxShadowsInFlight = 32
+
+// Results of shadow translations
+const (
+	shadowResultSuccess = "success"
+	shadowResultFailed  = "failed"
+	// Too many shadow translations in flight
+	shadowResultDropped = "dropped"
+)
+
+// mirror sends a copy of req to every shadow translator in the background,
+// recording its result, latency and similarity to primary, the translation
+// replied to users.
+func (ts *TranslateService) mirror(req translator.TranslateRequest, primary string) {
+	for _, t := range ts.shadows {
+		select {
+		case ts.shadowSlots <- struct{}{}:
+		default:
+			ts.metrics.ShadowTranslations.WithLabelValues(t.GetName(), shadowResultDropped).Inc()
+			continue
+		}
+		ts.users.Add(1)
+		go func() {
+			defer ts.users.Done()
+			defer func() { <-ts.shadowSlots }()
+
+			start := time.Now()
+			resp, err := ts.translateProtected(context.Background(), t, req)
+			logger := ts.logger.WithFields(map[string]any{
+				"trace_id":        req.TraceId,
+				"translator_name": t.GetName(),
+			})
+			if err != nil {
+				ts.metrics.ShadowTranslations.WithLabelValues(t.GetName(), shadowResultFailed).Inc()
+				logger.Debugf("shadow translation failed: %v", err)
+				return
+			}
+			similarity := Similarity(primary, resp.Text)
+			ts.metrics.ShadowTranslations.WithLabelValues(t.GetName(), shadowResultSuccess).Inc()
+			ts.metrics.ShadowLatency.WithLabelValues(t.GetName()).Observe(time.Since(start).Seconds())
+			ts.metrics.ShadowSimilarity.WithLabelValues(t.GetName()).Observe(similarity)
+			logger.WithField("shadow_similarity", similarity).Debug("shadow translation completed")
+		}()
+	}
+}
+
+// Similarity returns the Dice coefficient of the character bigrams of a
+// and b, from 0 for nothing in common to 1 for equal texts. Bigrams work
+// for scripts without spaces as well.
+func Similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ba, bb := bigrams(a), bigrams(b)
+	total := 0
+	for _, n := range ba {
+		total += n
+	}
+	for _, n := range bb {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	common := 0
+	for g, n := range ba {
+		common += min(n, bb[g])
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+func bigrams(s string) map[[2]rune]int {
+	m := make(map[[2]rune]int)
+	var prev rune
+	first := true
+	for _, r := range s {
+		if !first {
+			m[[2]rune{prev, r}]++
+		}
+		prev, first = r, false
+	}
+	return m
+}
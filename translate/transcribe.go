@@ -0,0 +1,81 @@
+package translate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/transcriber"
+)
+
+type transcriberEntry struct {
+	instance transcriber.Instance
+	timeout  time.Duration
+}
+
+func (ts *TranslateService) initTranscribers(confs []transcriber.TranscriberConfig) (err error) {
+	names := []string{}
+	for _, tc := range confs {
+		err = tc.Check()
+		if err != nil {
+			return
+		}
+		if slices.Contains(names, tc.Name) {
+			err = fmt.Errorf("duplicated transcriber: %s", tc.Name)
+			return
+		}
+		names = append(names, tc.Name)
+
+		var instance transcriber.Instance
+		instance, err = transcriber.NewInstance(tc, ts.logger)
+		if err != nil {
+			return
+		}
+		if tc.TokenFile != "" {
+			r := transcriber.NewRotatingInstance(tc, instance, ts.logger)
+			ts.closers = append(ts.closers, r)
+			instance = r
+		}
+		ts.transcribers = append(ts.transcribers, transcriberEntry{
+			instance: instance,
+			timeout:  time.Duration(tc.Timeout) * time.Second,
+		})
+	}
+	return
+}
+
+// TranscriptionAvailable reports whether any transcriber is configured.
+func (ts *TranslateService) TranscriptionAvailable() bool {
+	return len(ts.transcribers) > 0
+}
+
+// Transcribe converts speech to text, trying transcribers in configuration order.
+func (ts *TranslateService) Transcribe(ctx context.Context, req transcriber.TranscribeRequest) (resp *transcriber.TranscribeResponse, name string, err error) {
+	if len(ts.transcribers) == 0 {
+		err = fmt.Errorf("no transcriber configured")
+		return
+	}
+
+	errs := []error{}
+	for _, t := range ts.transcribers {
+		name = t.instance.Name()
+		tctx, cancel := context.WithTimeout(ctx, t.timeout)
+		resp, err = t.instance.Transcribe(tctx, req)
+		cancel()
+		if err == nil && strings.TrimSpace(resp.Text) != "" {
+			return
+		}
+		if err == nil {
+			err = fmt.Errorf("empty transcription")
+		}
+		ts.logger.WithField("trace_id", req.TraceId).
+			Warnf("transcriber %s failed: %v", name, err)
+		errs = append(errs, fmt.Errorf("%s: %w", name, err))
+	}
+	resp = nil
+	err = errors.Join(errs...)
+	return
+}
@@ -0,0 +1,85 @@
+package transcriber
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypeOpenAI = "openai"
+)
+
+func init() {
+	registerTranscriberInstance(instanceTypeOpenAI, newOpenAIInstance)
+}
+
+// InstanceOpenAI transcribes through an OpenAI-compatible audio API,
+// e.g. OpenAI or a local Whisper server.
+type InstanceOpenAI struct {
+	name     string
+	logger   *logrus.Entry
+	client   openai.Client
+	model    string
+	language string
+}
+
+func newOpenAIInstance(conf TranscriberConfig, logger *logrus.Entry) (instance Instance, err error) {
+	opts := []option.RequestOption{}
+	if conf.Token != "" {
+		opts = append(opts, option.WithAPIKey(conf.Token))
+	}
+	if conf.Endpoint == "" {
+		err = fmt.Errorf("transcriber endpoint is required")
+		return
+	}
+	opts = append(opts, option.WithBaseURL(conf.Endpoint))
+	if conf.Organization != "" {
+		opts = append(opts, option.WithOrganization(conf.Organization))
+	}
+	if conf.Project != "" {
+		opts = append(opts, option.WithProject(conf.Project))
+	}
+	if client := conf.HTTPClient.NewHTTPClientFromConfig(logger); client != nil {
+		opts = append(opts, option.WithHTTPClient(client))
+	}
+
+	if conf.Model == "" {
+		err = fmt.Errorf("no transcription model configured")
+		return
+	}
+
+	logger.Debugf("initialized OpenAI transcriber instance, model: %s, api url: %s",
+		conf.Model, conf.Endpoint)
+	return &InstanceOpenAI{
+		name:     conf.Name,
+		logger:   logger,
+		client:   openai.NewClient(opts...),
+		model:    conf.Model,
+		language: conf.Language,
+	}, nil
+}
+
+func (t *InstanceOpenAI) Name() string {
+	return t.name
+}
+
+func (t *InstanceOpenAI) Transcribe(ctx context.Context, req TranscribeRequest) (resp *TranscribeResponse, err error) {
+	params := openai.AudioTranscriptionNewParams{
+		File:  openai.File(bytes.NewReader(req.Audio), req.FileName, ""),
+		Model: openai.AudioModel(t.model),
+	}
+	if t.language != "" {
+		params.Language = openai.String(t.language)
+	}
+
+	transcription, err := t.client.Audio.Transcriptions.New(ctx, params)
+	if err != nil {
+		return
+	}
+	return &TranscribeResponse{Text: transcription.Text}, nil
+}
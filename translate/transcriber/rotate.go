@@ -0,0 +1,68 @@
+package transcriber
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+// RotatingInstance rebuilds the wrapped instance whenever the token file
+// of its config changes, so keys rotate without a restart or reload.
+type RotatingInstance struct {
+	mu       sync.RWMutex
+	instance Instance
+	watcher  *common.SecretWatcher
+}
+
+// NewRotatingInstance wraps instance, watching conf.TokenFile until Close.
+func NewRotatingInstance(conf TranscriberConfig, instance Instance, logger *logrus.Entry) *RotatingInstance {
+	r := &RotatingInstance{instance: instance}
+	wl := logger.WithField("transcriber_name", conf.Name)
+	r.watcher = common.WatchSecretFile(conf.TokenFile, conf.Token, wl, func(token string) {
+		conf.Token = token
+		instance, err := NewInstance(conf, logger)
+		if err != nil {
+			wl.Errorf("rebuild with rotated token failed, keeping previous token: %v", err)
+			return
+		}
+		r.swap(instance)
+		wl.Info("token rotated")
+	})
+	return r
+}
+
+func (r *RotatingInstance) get() Instance {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.instance
+}
+
+// swap replaces the instance, closing the previous one if needed.
+func (r *RotatingInstance) swap(instance Instance) {
+	r.mu.Lock()
+	old := r.instance
+	r.instance = instance
+	r.mu.Unlock()
+	if c, ok := old.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func (r *RotatingInstance) Transcribe(ctx context.Context, req TranscribeRequest) (*TranscribeResponse, error) {
+	return r.get().Transcribe(ctx, req)
+}
+
+func (r *RotatingInstance) Name() string {
+	return r.get().Name()
+}
+
+func (r *RotatingInstance) Close() error {
+	r.watcher.Close()
+	if c, ok := r.get().(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package transcriber converts speech to text before detection and translation.
+package transcriber
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	registeredTranscriberInstances = map[string]newTranscriberInstanceFunc{}
+)
+
+type newTranscriberInstanceFunc func(TranscriberConfig, *logrus.Entry) (Instance, error)
+
+func registerTranscriberInstance(name string, f newTranscriberInstanceFunc) {
+	if _, ok := registeredTranscriberInstances[name]; !ok {
+		registeredTranscriberInstances[name] = f
+		return
+	}
+	panic(fmt.Sprintf("transcriber instance type '%s' already registered", name))
+}
+
+func NewInstance(conf TranscriberConfig, logger *logrus.Entry) (Instance, error) {
+	if f, ok := registeredTranscriberInstances[conf.Type]; ok {
+		return f(conf, logger.WithField("transcriber_instance", conf.Name))
+	}
+	return nil, fmt.Errorf("unknown transcriber type '%s', transcriber: %s", conf.Type, conf.Name)
+}
+
+type TranscriberConfig struct {
+	// Required
+	Name string `yaml:"name"`
+
+	// Required
+	Type string `yaml:"type"`
+
+	// Positive
+	Timeout int64 `yaml:"timeout"`
+
+	// Required by API based instances
+	Endpoint string `yaml:"endpoint"`
+
+	// Optional
+	Token string `yaml:"token" redact:"true"`
+
+	// Optional. File holding the token instead, watched for rotation
+	TokenFile string `yaml:"token_file"`
+
+	// Optional. OpenAI organization and project the usage is attributed to
+	Organization string `yaml:"organization"`
+	Project      string `yaml:"project"`
+
+	// Required
+	Model string `yaml:"model"`
+
+	// Optional. ISO 639-1 code of the expected speech language
+	Language string `yaml:"language"`
+
+	// Optional. Connection pool tuning
+	HTTPClient common.HTTPClientConfig `yaml:"http_client,omitempty"`
+}
+
+func (tc *TranscriberConfig) Check() (err error) {
+	if tc.Name == "" {
+		err = fmt.Errorf("transcriber name is required")
+		return
+	}
+	if tc.Type == "" {
+		err = fmt.Errorf("%s: type is required", tc.Name)
+		return
+	}
+	if tc.Timeout <= 0 {
+		err = fmt.Errorf("%s: timeout must be positive", tc.Name)
+		return
+	}
+	err = common.LoadSecretFile(&tc.Token, tc.TokenFile)
+	if err != nil {
+		err = fmt.Errorf("%s: token: %w", tc.Name, err)
+		return
+	}
+	err = tc.HTTPClient.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tc.Name, err)
+	}
+	return
+}
+
+type TranscribeRequest struct {
+	Audio []byte
+
+	// Used by APIs to guess the audio format, e.g. "voice.ogg"
+	FileName string
+	TraceId  string
+}
+
+type TranscribeResponse struct {
+	Text string
+}
+
+type Instance interface {
+	Transcribe(context.Context, TranscribeRequest) (*TranscribeResponse, error)
+	Name() string
+}
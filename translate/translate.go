@@ -1,13 +1,21 @@
 package translate
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"slices"
+	"sync"
 	"time"
 
+	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
 	"github.com/4O4-Not-F0und/Gura-Bot/selector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/protect"
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
 )
 
@@ -20,18 +28,104 @@ type TranslateService struct {
 	languageDetectorSelector selector.Selector[detector.LanguageDetector]
 	defaultTranslatorConfig  translator.DefaultTranslatorConfig
 	translatorSelector       selector.Selector[translator.Translator]
+	visionSelector           selector.Selector[translator.Translator]
+	protector                *protect.Protector
+	segmentation             SegmentationConfig
+	logger                   *logrus.Entry
+	metrics                  *metrics.Metrics
+
+	// Users and background translations in flight, see Acquire
+	users sync.WaitGroup
+
+	// Translators and detectors owned by this service
+	closers     []io.Closer
+	translators []translator.Translator
+	detectors   []detector.LanguageDetector
+
+	// Translators taking a percentage of selections, in config order
+	canaries []canary
+
+	// Translators receiving copies of text translations
+	shadows     []translator.Translator
+	shadowSlots chan struct{}
+
+	// Quality estimations of the flag action in flight
+	flagSlots chan struct{}
+
+	// Translators taking selections, canaries included, in config order
+	selectable []translator.Translator
+
+	// Back-translation, optional
+	quality        QualityConfig
+	backTranslator translator.Translator
+
+	validation ValidationConfig
+
+	// Prices by translator name, then model
+	pricing map[string]map[string]translator.Pricing
+
+	// Shared by all translators and detectors, optional
+	inFlight *common.InFlightLimiter
+
+	// Speech to text, optional
+	transcribers []transcriberEntry
+
+	// Optional
+	onDisabled func(component, name string, permanent bool)
+}
+
+// TranslateServiceOptions holds the dependencies injected into a TranslateService.
+// All fields are optional, allowing the service to be embedded in other programs.
+type TranslateServiceOptions struct {
+	// Defaults to an entry of the logrus standard logger.
+	Logger *logrus.Entry
+
+	// Defaults to collectors registered on a private registry.
+	Metrics *metrics.Metrics
+
+	// Called with "translator" or "detector" and the name of a component
+	// each time failover disables it.
+	OnDisabled func(component, name string, permanent bool)
 }
 
-func NewTranslateService(conf TranslateServiceConfig) (ts *TranslateService, err error) {
+func NewTranslateService(conf TranslateServiceConfig, opts TranslateServiceOptions) (ts *TranslateService, err error) {
+	if opts.Logger == nil {
+		opts.Logger = logrus.NewEntry(logrus.StandardLogger())
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = metrics.NewMetrics(prometheus.NewRegistry())
+	}
+
 	ts = &TranslateService{
 		MaximumRetry: conf.MaximumRetry,
+		logger:       opts.Logger,
+		metrics:      opts.Metrics,
+		onDisabled:   opts.OnDisabled,
+		shadowSlots:  make(chan struct{}, maxShadowsInFlight),
+		flagSlots:    make(chan struct{}, maxFlagsInFlight),
 	}
+	// Images go to vision capable translators in configuration order
+	ts.visionSelector = selector.NewFallbackSelector[translator.Translator](ts.logger)
 
 	switch conf.TranslatorSelector {
 	case selector.WRR:
-		ts.translatorSelector = selector.NewWeightedRoundRobinSelector[translator.Translator]()
+		s := selector.NewWeightedRoundRobinSelector[translator.Translator](ts.logger)
+		s.SetHealthWeighted(conf.HealthWeighted)
+		ts.translatorSelector = s
 	case selector.FALLBACK:
-		ts.translatorSelector = selector.NewFallbackSelector[translator.Translator]()
+		ts.translatorSelector = selector.NewFallbackSelector[translator.Translator](ts.logger)
+	case selector.LEAST_LATENCY:
+		ts.translatorSelector = selector.NewLeastLatencySelector[translator.Translator](ts.logger)
+	case selector.LEAST_COST:
+		ts.translatorSelector = selector.NewLeastCostSelector[translator.Translator](ts.logger)
+	case selector.PRIORITY:
+		s := selector.NewPrioritySelector[translator.Translator](ts.logger)
+		s.SetHealthWeighted(conf.HealthWeighted)
+		ts.translatorSelector = s
+	case selector.CONSISTENT_HASH:
+		ts.translatorSelector = selector.NewConsistentHashSelector[translator.Translator](ts.logger)
+	case selector.SCHEDULE:
+		ts.translatorSelector = selector.NewScheduleSelector[translator.Translator](ts.logger)
 	default:
 		err = fmt.Errorf("unrecognized translator selector: %s", conf.TranslatorSelector)
 		return
@@ -39,9 +133,17 @@ func NewTranslateService(conf TranslateServiceConfig) (ts *TranslateService, err
 
 	switch conf.LanguageDetectorSelector {
 	case selector.WRR:
-		ts.languageDetectorSelector = selector.NewWeightedRoundRobinSelector[detector.LanguageDetector]()
+		s := selector.NewWeightedRoundRobinSelector[detector.LanguageDetector](ts.logger)
+		s.SetHealthWeighted(conf.HealthWeighted)
+		ts.languageDetectorSelector = s
 	case selector.FALLBACK:
-		ts.languageDetectorSelector = selector.NewFallbackSelector[detector.LanguageDetector]()
+		ts.languageDetectorSelector = selector.NewFallbackSelector[detector.LanguageDetector](ts.logger)
+	case selector.LEAST_LATENCY:
+		ts.languageDetectorSelector = selector.NewLeastLatencySelector[detector.LanguageDetector](ts.logger)
+	case selector.PRIORITY:
+		s := selector.NewPrioritySelector[detector.LanguageDetector](ts.logger)
+		s.SetHealthWeighted(conf.HealthWeighted)
+		ts.languageDetectorSelector = s
 	default:
 		err = fmt.Errorf("unrecognized language detector selector: %s", conf.LanguageDetectorSelector)
 		return
@@ -53,6 +155,35 @@ func NewTranslateService(conf TranslateServiceConfig) (ts *TranslateService, err
 	}
 	ts.retryCooldown = conf.RetryCooldown
 
+	if conf.MaxInFlight < 0 {
+		err = fmt.Errorf("max in flight must not be negative")
+		return
+	}
+	ts.inFlight = common.NewInFlightLimiter(conf.MaxInFlight, ts.metrics.UpstreamInFlight)
+
+	ts.protector, err = protect.NewProtector(conf.Protect)
+	if err != nil {
+		return
+	}
+
+	err = conf.Segmentation.Check()
+	if err != nil {
+		return
+	}
+	ts.segmentation = conf.Segmentation
+
+	err = conf.QualityEstimation.Check()
+	if err != nil {
+		return
+	}
+	ts.quality = conf.QualityEstimation
+
+	err = conf.OutputValidation.Check()
+	if err != nil {
+		return
+	}
+	ts.validation = conf.OutputValidation
+
 	// No need to validate default config here
 	ts.defaultTranslatorConfig = conf.DefaultTranslatorConfig
 	ts.defaultDetectorConfig = conf.DefaultDetectorConfig
@@ -60,14 +191,128 @@ func NewTranslateService(conf TranslateServiceConfig) (ts *TranslateService, err
 	// Initialize translators
 	err = ts.initTranslators(conf.Translators)
 	if err != nil {
+		ts.Close()
 		return
 	}
 
 	// Initialize language detectors
 	err = ts.initDetectors(conf.LanguageDetectors)
+	if err != nil {
+		ts.Close()
+		return
+	}
+
+	err = ts.initTranscribers(conf.Transcribers)
+	if err != nil {
+		ts.Close()
+	}
 	return
 }
 
+// Acquire marks a user of the service in flight until release is called,
+// so CloseWhenIdle waits for it. Users must not acquire a service after
+// CloseWhenIdle is called.
+func (ts *TranslateService) Acquire() (release func()) {
+	ts.users.Add(1)
+	return ts.users.Done
+}
+
+// CloseWhenIdle closes the service once all users and background
+// translations in flight are done, e.g. after it was replaced by a reload.
+func (ts *TranslateService) CloseWhenIdle() {
+	ts.users.Wait()
+	ts.Close()
+}
+
+// Close releases all translators and detectors of this service,
+// e.g. terminating plugin processes. The service must not be used afterwards.
+func (ts *TranslateService) Close() {
+	for _, c := range ts.closers {
+		if err := c.Close(); err != nil {
+			ts.logger.Warnf("error closing translate service component: %v", err)
+		}
+	}
+	ts.closers = nil
+}
+
+// VisionAvailable reports whether any vision capable translator is configured.
+func (ts *TranslateService) VisionAvailable() bool {
+	for _, t := range ts.translators {
+		if t.Vision() {
+			return true
+		}
+	}
+	return false
+}
+
+// TranslateImageOnce makes a single attempt to translate the text in req.Image
+// with a vision capable translator.
+func (ts *TranslateService) TranslateImageOnce(ctx context.Context, req translator.TranslateRequest) (resp *translator.TranslateResponse, name string, err error) {
+	t, err := ts.selectTranslator(ts.visionSelector, true, req.RoutingKey)
+	if err != nil {
+		err = fmt.Errorf("error on select vision translator: %w", err)
+		return
+	}
+	name = t.GetName()
+
+	resp, err = ts.translateProtected(ctx, t, req)
+	return
+}
+
+// Cost estimates the price in USD of a translation by the named translator,
+// 0 if its pricing is not configured.
+func (ts *TranslateService) Cost(name string, resp *translator.TranslateResponse) float64 {
+	p, ok := ts.pricing[name][resp.Model]
+	if !ok {
+		p = ts.pricing[name][""]
+	}
+	return p.Cost(resp)
+}
+
+// ComponentStatus describes the state of a translator or detector.
+type ComponentStatus struct {
+	Name string
+	common.FailoverStatus
+}
+
+// TranslatorStatus returns the state of every translator in config order.
+func (ts *TranslateService) TranslatorStatus() (status []ComponentStatus) {
+	for _, t := range ts.translators {
+		status = append(status, ComponentStatus{Name: t.GetName(), FailoverStatus: t.FailoverStatus()})
+	}
+	return
+}
+
+// DetectorStatus returns the state of every detector in config order.
+func (ts *TranslateService) DetectorStatus() (status []ComponentStatus) {
+	for _, d := range ts.detectors {
+		status = append(status, ComponentStatus{Name: d.GetName(), FailoverStatus: d.FailoverStatus()})
+	}
+	return
+}
+
+// TranslatorAvailable reports whether any translator taking selections is
+// currently enabled. Shadows and the back-translator don't count.
+func (ts *TranslateService) TranslatorAvailable() bool {
+	for _, t := range ts.selectable {
+		if !t.IsDisabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// disabledFunc returns the hook of components of the kind, nil if no
+// OnDisabled option is given.
+func (ts *TranslateService) disabledFunc(component string) common.DisabledFunc {
+	if ts.onDisabled == nil {
+		return nil
+	}
+	return func(name string, permanent bool) {
+		ts.onDisabled(component, name, permanent)
+	}
+}
+
 func (ts *TranslateService) initDetectors(detectorConfs []detector.DetectorConfig) (err error) {
 	if len(detectorConfs) == 0 {
 		err = fmt.Errorf("no detector configured")
@@ -83,10 +328,11 @@ func (ts *TranslateService) initDetectors(detectorConfs []detector.DetectorConfi
 		}
 
 		var d detector.LanguageDetector
-		d, err = detector.NewDetector(ts.languageDetectorSelector.GetType(), dc)
+		d, err = detector.NewDetector(ts.languageDetectorSelector.GetType(), dc, ts.logger, ts.metrics, ts.inFlight, ts.disabledFunc("detector"))
 		if err != nil {
 			return
 		}
+		ts.closers = append(ts.closers, d)
 
 		if slices.Contains(names, d.GetName()) {
 			err = fmt.Errorf("duplicated detector: %s", d.GetName())
@@ -94,9 +340,10 @@ func (ts *TranslateService) initDetectors(detectorConfs []detector.DetectorConfi
 		}
 
 		names = append(names, d.GetName())
+		ts.detectors = append(ts.detectors, d)
 		ts.languageDetectorSelector.AddItem(d)
 	}
-	logrus.Debugf("total weight of WRR entry: %d", ts.languageDetectorSelector.TotalConfigWeight())
+	ts.logger.Debugf("total weight of WRR entry: %d", ts.languageDetectorSelector.TotalConfigWeight())
 	return
 }
 
@@ -107,6 +354,8 @@ func (ts *TranslateService) initTranslators(translatorConfs []translator.Transla
 	}
 
 	names := []string{}
+	ts.pricing = make(map[string]map[string]translator.Pricing, len(translatorConfs))
+	var canaryPercent float64
 
 	for _, tc := range translatorConfs {
 		err = tc.CheckAndMergeDefaultConfig(ts.defaultTranslatorConfig)
@@ -115,10 +364,11 @@ func (ts *TranslateService) initTranslators(translatorConfs []translator.Transla
 		}
 
 		var t translator.Translator
-		t, err = translator.NewTranslator(ts.translatorSelector.GetType(), tc)
+		t, err = translator.NewTranslator(ts.translatorSelector.GetType(), tc, ts.logger, ts.metrics, ts.inFlight, ts.disabledFunc("translator"))
 		if err != nil {
 			return
 		}
+		ts.closers = append(ts.closers, t)
 
 		if slices.Contains(names, t.GetName()) {
 			err = fmt.Errorf("duplicated translator: %s", t.GetName())
@@ -126,19 +376,55 @@ func (ts *TranslateService) initTranslators(translatorConfs []translator.Transla
 		}
 
 		names = append(names, t.GetName())
+		ts.pricing[t.GetName()] = tc.Prices()
+		ts.translators = append(ts.translators, t)
+		if ts.quality.Enabled && t.GetName() == ts.quality.Translator {
+			if tc.Shadow || tc.CanaryPercent > 0 {
+				err = fmt.Errorf("%s: the quality estimation translator can't be a shadow or canary", t.GetName())
+				return
+			}
+			ts.backTranslator = t
+			ts.logger.Infof("added quality estimation translator '%s'", t.GetName())
+			continue
+		}
+		if tc.Shadow {
+			ts.shadows = append(ts.shadows, t)
+			ts.logger.Infof("added shadow translator '%s'", t.GetName())
+			continue
+		}
+		ts.selectable = append(ts.selectable, t)
+		if tc.CanaryPercent > 0 {
+			canaryPercent += tc.CanaryPercent
+			ts.canaries = append(ts.canaries, canary{translator: t, percent: tc.CanaryPercent})
+			ts.logger.Infof("added canary translator '%s', %.2f%% of selections", t.GetName(), tc.CanaryPercent)
+			continue
+		}
 		ts.translatorSelector.AddItem(t)
+		if t.Vision() {
+			ts.visionSelector.AddItem(t)
+		}
+	}
+	if canaryPercent > 100 {
+		err = fmt.Errorf("canary percents add up to more than 100: %.2f", canaryPercent)
+		return
+	}
+	if ts.quality.Enabled && ts.backTranslator == nil {
+		err = fmt.Errorf("quality estimation translator not found: %s", ts.quality.Translator)
+		return
 	}
-	logrus.Debugf("total weight of WRR entry: %d", ts.translatorSelector.TotalConfigWeight())
+	ts.logger.Debugf("total weight of WRR entry: %d", ts.translatorSelector.TotalConfigWeight())
 	return
 }
 
 // DetectLang attempts to detect the language of the given text.
-// It returns the detected language (ISO 639-1 code), the confidence score.
-func (ts *TranslateService) DetectLang(req detector.DetectRequest) (resp *detector.DetectResponse, name string, err error) {
+// It returns the detected language (ISO 639-1 code), the confidence score
+// and the candidates of the source language filter, the best first, for
+// routing by second-best guesses.
+func (ts *TranslateService) DetectLang(ctx context.Context, req detector.DetectRequest) (resp *detector.DetectResponse, name string, err error) {
 	retry := 0
-	logger := logrus.WithField("trace_id", req.TraceId)
+	logger := ts.logger.WithField("trace_id", req.TraceId)
 	for {
-		resp, name, err = ts.detect(req)
+		resp, name, err = ts.detect(ctx, req)
 		if err == nil {
 			return
 		}
@@ -159,11 +445,39 @@ func (ts *TranslateService) DetectLang(req detector.DetectRequest) (resp *detect
 		} else {
 			logger.Warnf("%v. Retry attempt %d/%d in %d seconds", err, retry, ts.MaximumRetry, ts.retryCooldown)
 		}
-		time.Sleep(time.Duration(ts.retryCooldown) * time.Second)
+		if !ts.sleep(ctx) {
+			return
+		}
 	}
 }
 
-func (ts *TranslateService) detect(req detector.DetectRequest) (resp *detector.DetectResponse, name string, err error) {
+// DetectLangOnce makes a single detection attempt without retrying.
+// Use Retryable and RetryCooldown to schedule retries.
+func (ts *TranslateService) DetectLangOnce(ctx context.Context, req detector.DetectRequest) (resp *detector.DetectResponse, name string, err error) {
+	return ts.detect(ctx, req)
+}
+
+// DetectorNames returns the names of all detectors in configuration order.
+func (ts *TranslateService) DetectorNames() (names []string) {
+	for _, d := range ts.detectors {
+		names = append(names, d.GetName())
+	}
+	return
+}
+
+// DetectWith makes a single detection attempt with the named detector,
+// bypassing the selector.
+func (ts *TranslateService) DetectWith(ctx context.Context, name string, req detector.DetectRequest) (resp *detector.DetectResponse, err error) {
+	for _, d := range ts.detectors {
+		if d.GetName() == name {
+			return d.Detect(ctx, req)
+		}
+	}
+	err = fmt.Errorf("detector not found: %s", name)
+	return
+}
+
+func (ts *TranslateService) detect(ctx context.Context, req detector.DetectRequest) (resp *detector.DetectResponse, name string, err error) {
 	t, err := ts.languageDetectorSelector.Select()
 	if err != nil {
 		err = fmt.Errorf("error on select detector: %w", err)
@@ -171,18 +485,20 @@ func (ts *TranslateService) detect(req detector.DetectRequest) (resp *detector.D
 	}
 	name = t.GetName()
 
-	resp, err = t.Detect(req)
+	start := time.Now()
+	resp, err = t.Detect(ctx, req)
+	ts.languageDetectorSelector.Feedback(t, time.Since(start), err == nil || detector.CheckWeakError(err))
 	if err != nil {
 		return
 	}
 	return
 }
 
-func (ts *TranslateService) Translate(req translator.TranslateRequest) (resp *translator.TranslateResponse, name string, err error) {
+func (ts *TranslateService) Translate(ctx context.Context, req translator.TranslateRequest) (resp *translator.TranslateResponse, name string, err error) {
 	retry := 0
-	logger := logrus.WithField("trace_id", req.TraceId)
+	logger := ts.logger.WithField("trace_id", req.TraceId)
 	for {
-		resp, name, err = ts.translate(req)
+		resp, name, err = ts.translate(ctx, req)
 		if err == nil {
 			return
 		}
@@ -198,21 +514,152 @@ func (ts *TranslateService) Translate(req translator.TranslateRequest) (resp *tr
 		} else {
 			logger.Warnf("%v. Retry attempt %d/%d in %d seconds", err, retry, ts.MaximumRetry, ts.retryCooldown)
 		}
-		time.Sleep(time.Duration(ts.retryCooldown) * time.Second)
+		if !ts.sleep(ctx) {
+			return
+		}
+	}
+}
+
+// TranslateOnce makes a single translation attempt without retrying.
+// Use Retryable and RetryCooldown to schedule retries.
+func (ts *TranslateService) TranslateOnce(ctx context.Context, req translator.TranslateRequest) (resp *translator.TranslateResponse, name string, err error) {
+	return ts.translate(ctx, req)
+}
+
+// Retryable reports whether a failed attempt should be retried,
+// given the number of retries already made.
+func (ts *TranslateService) Retryable(err error, retries int) bool {
+	// WeakError shouldn't retry
+	return !detector.CheckWeakError(err) && !errors.Is(err, ErrNothingToTranslate) &&
+		retries < ts.MaximumRetry
+}
+
+// RetryCooldown returns the time to wait before retrying a failed attempt.
+func (ts *TranslateService) RetryCooldown() time.Duration {
+	return time.Duration(ts.retryCooldown) * time.Second
+}
+
+// TranslatorNames returns the names of all translators in configuration order.
+func (ts *TranslateService) TranslatorNames() (names []string) {
+	for _, t := range ts.translators {
+		names = append(names, t.GetName())
+	}
+	return
+}
+
+// TranslateWith makes a single translation attempt with the named translator,
+// bypassing the selector.
+func (ts *TranslateService) TranslateWith(ctx context.Context, name string, req translator.TranslateRequest) (resp *translator.TranslateResponse, err error) {
+	for _, t := range ts.translators {
+		if t.GetName() == name {
+			return ts.translateProtected(ctx, t, req)
+		}
+	}
+	err = fmt.Errorf("translator not found: %s", name)
+	return
+}
+
+// ErrNoOtherTranslator is returned by TranslateExcluding if no enabled
+// translator is left.
+var ErrNoOtherTranslator = errors.New("no other translator available")
+
+// TranslateExcluding makes a single translation attempt with an enabled
+// translator other than those in exclude, in config order starting after
+// the last excluded one. Failed and rejected outputs are translated again
+// by the next translators in turn.
+func (ts *TranslateService) TranslateExcluding(ctx context.Context, req translator.TranslateRequest, exclude []string) (resp *translator.TranslateResponse, name string, err error) {
+	last := ""
+	if len(exclude) > 0 {
+		last = exclude[len(exclude)-1]
+	}
+	logger := ts.logger.WithField("trace_id", req.TraceId)
+	for _, t := range ts.nextTranslators(last) {
+		if slices.Contains(exclude, t.GetName()) {
+			continue
+		}
+		name = t.GetName()
+		resp, err = ts.translateProtected(ctx, t, req)
+		if err != nil {
+			logger.WithField("translator_name", name).Warnf("translation failed, trying the next translator: %v", err)
+			continue
+		}
+		if reason := ts.validation.reject(req.Text, resp.Text); reason != "" {
+			err = fmt.Errorf("%w: %s", ErrInvalidOutput, reason)
+			logger.WithField("translator_name", name).Warnf("translation rejected as %s, trying the next translator", reason)
+			continue
+		}
+		return
 	}
+	if err == nil {
+		err = ErrNoOtherTranslator
+	}
+	return nil, name, err
 }
 
-func (ts *TranslateService) translate(req translator.TranslateRequest) (resp *translator.TranslateResponse, name string, err error) {
-	t, err := ts.translatorSelector.Select()
+func (ts *TranslateService) translate(ctx context.Context, req translator.TranslateRequest) (resp *translator.TranslateResponse, name string, err error) {
+	t, err := ts.selectTranslator(ts.translatorSelector, false, req.RoutingKey)
 	if err != nil {
 		err = fmt.Errorf("error on select translator: %w", err)
 		return
 	}
 	name = t.GetName()
 
-	resp, err = t.Translate(req)
+	start := time.Now()
+	resp, err = ts.translateProtected(ctx, t, req)
+	ts.translatorSelector.Feedback(t, time.Since(start), err == nil)
 	if err != nil {
 		return
 	}
+	resp, name, err = ts.validated(ctx, req, name, resp)
+	if err != nil {
+		return
+	}
+	if len(ts.shadows) > 0 {
+		ts.mirror(req, resp.Text)
+	}
+	if ts.backTranslator != nil {
+		resp, name = ts.estimateQuality(ctx, req, name, resp)
+	}
 	return
 }
+
+// translateProtected keeps protected spans of the text out of translation.
+func (ts *TranslateService) translateProtected(ctx context.Context, t translator.Translator, req translator.TranslateRequest) (resp *translator.TranslateResponse, err error) {
+	var spans []string
+	req.Text, spans = ts.protector.Protect(req.Text)
+
+	resp, err = t.Translate(ctx, req)
+	if err != nil {
+		return
+	}
+	// Every billed call counts, retries and back-translations included
+	if r, ok := ts.translatorSelector.(selector.CostRecorder[translator.Translator]); ok {
+		r.RecordCost(t, ts.Cost(t.GetName(), resp))
+	}
+	if len(spans) == 0 {
+		return
+	}
+
+	var missing int
+	resp.Text, missing = protect.Restore(resp.Text, spans)
+	if missing > 0 {
+		ts.logger.WithFields(logrus.Fields{
+			"trace_id":        req.TraceId,
+			"translator_name": t.GetName(),
+		}).Warnf("%d of %d protected spans lost in translation", missing, len(spans))
+	}
+	return
+}
+
+// sleep waits for the retry cooldown.
+// It returns false if ctx is done before the cooldown elapsed.
+func (ts *TranslateService) sleep(ctx context.Context) bool {
+	t := time.NewTimer(ts.RetryCooldown())
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,199 @@
+package translator
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSConfig holds the region and credentials of AWS instances. Values not
+// set are read from the environment, then from the shared config and
+// credentials files, as the AWS CLI does.
+type AWSConfig struct {
+	// Optional. AWS_REGION, AWS_DEFAULT_REGION or the shared config if empty
+	Region string `yaml:"region"`
+
+	// Optional. Explicit credentials
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key" redact:"true"`
+	SessionToken    string `yaml:"session_token" redact:"true"`
+
+	// Optional. Profile of the shared files, AWS_PROFILE or "default" if empty
+	Profile string `yaml:"profile"`
+}
+
+type awsCredentials struct {
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// resolve returns the region and credentials of ac.
+func (ac AWSConfig) resolve() (region string, creds awsCredentials, err error) {
+	profile := ac.Profile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	region = ac.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		section := "profile " + profile
+		if profile == "default" {
+			section = profile
+		}
+		region = readAWSFile("AWS_CONFIG_FILE", "config", section)["region"]
+	}
+	if region == "" {
+		err = fmt.Errorf("aws region is required")
+		return
+	}
+
+	switch {
+	case ac.AccessKeyID != "" || ac.SecretAccessKey != "":
+		creds = awsCredentials{ac.AccessKeyID, ac.SecretAccessKey, ac.SessionToken}
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		creds = awsCredentials{
+			os.Getenv("AWS_ACCESS_KEY_ID"),
+			os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	default:
+		values := readAWSFile("AWS_SHARED_CREDENTIALS_FILE", "credentials", profile)
+		creds = awsCredentials{
+			values["aws_access_key_id"],
+			values["aws_secret_access_key"],
+			values["aws_session_token"],
+		}
+	}
+	if creds.accessKeyId == "" || creds.secretAccessKey == "" {
+		err = fmt.Errorf("no aws credentials found in config, environment or shared credentials of profile '%s'", profile)
+	}
+	return
+}
+
+// readAWSFile returns the keys of section in the shared file named by the
+// environment variable env, or ~/.aws/name. Missing files have no keys.
+func readAWSFile(env, name, section string) (values map[string]string) {
+	values = make(map[string]string)
+	path := os.Getenv(env)
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		path = filepath.Join(home, ".aws", name)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		if current != section {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			values[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return
+}
+
+// signAWS signs req with body by AWS Signature Version 4.
+func signAWS(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	// Canonical request
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	// String to sign
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyId, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
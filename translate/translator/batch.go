@@ -0,0 +1,88 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BatchOptions controls a batch of TranslateBatch.
+type BatchOptions struct {
+	// Positive. Interval between checks of the batch
+	Poll time.Duration
+
+	// Optional. ID of a batch submitted before, e.g. by a previous run,
+	// waited for instead of submitting the requests again. The requests
+	// must be the same
+	ID string
+
+	// Optional. Called with the ID once the batch is submitted, e.g. to
+	// persist it
+	OnSubmit func(id string)
+}
+
+// BatchInstance is implemented by instances able to submit requests to
+// a batch API of their provider, cheaper but completing within hours.
+type BatchInstance interface {
+	// TranslateBatch submits reqs as one batch and waits for it. errs
+	// holds the error of each failed request, err fails the batch as a
+	// whole.
+	TranslateBatch(ctx context.Context, reqs []TranslateRequest, opts BatchOptions) (resps []*TranslateResponse, errs []error, err error)
+}
+
+// BatchTranslator is implemented by translators whose instance may be a
+// BatchInstance.
+type BatchTranslator interface {
+	TranslateBatch(ctx context.Context, reqs []TranslateRequest, opts BatchOptions) (resps []*TranslateResponse, errs []error, err error)
+}
+
+func (r *rotatingInstance) TranslateBatch(ctx context.Context, reqs []TranslateRequest, opts BatchOptions) ([]*TranslateResponse, []error, error) {
+	bi, ok := r.get().(BatchInstance)
+	if !ok {
+		return nil, nil, fmt.Errorf("translator '%s' has no batch API", r.Name())
+	}
+	return bi.TranslateBatch(ctx, reqs, opts)
+}
+
+// TranslateBatch translates reqs in one batch of the instance. Batches
+// bypass the timeout, limiters and failover of the translator, being
+// a single upstream call taking hours.
+func (ct *CommonTranslator) TranslateBatch(ctx context.Context, reqs []TranslateRequest, opts BatchOptions) (resps []*TranslateResponse, errs []error, err error) {
+	bi, ok := ct.instance.(BatchInstance)
+	if !ok {
+		err = fmt.Errorf("translator '%s' has no batch API", ct.GetName())
+		return
+	}
+	ct.tasksMetric.WithLabelValues(translationStateProcessing, ct.GetName()).Add(float64(len(reqs)))
+	defer ct.tasksMetric.WithLabelValues(translationStateProcessing, ct.GetName()).Sub(float64(len(reqs)))
+
+	resps, errs, err = bi.TranslateBatch(ctx, reqs, opts)
+	if err != nil {
+		ct.tasksMetric.WithLabelValues(translationStateFailed, ct.GetName()).Add(float64(len(reqs)))
+		return
+	}
+	for i, tr := range resps {
+		if tr != nil {
+			ct.tokensUsedMetric.WithLabelValues(
+				translationTokenUsedTypeCompletion, ct.GetName()).Add(
+				float64(tr.TokenUsage.Completion))
+			ct.tokensUsedMetric.WithLabelValues(
+				translationTokenUsedTypePrompt, ct.GetName()).Add(
+				float64(tr.TokenUsage.Prompt))
+			ct.tokensUsedMetric.WithLabelValues(
+				translationTokenUsedTypeCachedPrompt, ct.GetName()).Add(
+				float64(tr.TokenUsage.CachedPrompt))
+		}
+		if errs[i] == nil {
+			tr.Text, errs[i] = trimAfter(tr.Text, ct.trimAfter)
+		}
+		if errs[i] != nil {
+			resps[i] = nil
+			ct.tasksMetric.WithLabelValues(translationStateFailed, ct.GetName()).Inc()
+			continue
+		}
+		tr.TargetLang = ct.targetLang
+		ct.tasksMetric.WithLabelValues(translationStateSuccess, ct.GetName()).Inc()
+	}
+	return
+}
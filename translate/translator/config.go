@@ -2,19 +2,50 @@ package translator
 
 import (
 	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/plugin"
+	"gopkg.in/yaml.v3"
 )
 
+// Parameter profiles of OpenAI models
+const (
+	// Chat models, taking temperature and max_tokens
+	ParamProfileChat = "chat"
+	// Reasoning models, rejecting temperature and taking
+	// max_completion_tokens and reasoning_effort
+	ParamProfileReasoning = "reasoning"
+)
+
+var reasoningEfforts = []string{"low", "medium", "high"}
+
 type DefaultTranslatorConfig struct {
 	// Positive
 	Weight int `yaml:"weight"`
 
-	// Optional
-	SystemPrompt string `yaml:"system_prompt"`
+	// Optional. A prompt, or prompts by source language
+	SystemPrompt SystemPrompt `yaml:"system_prompt"`
+
+	// Optional. Prompts replacing SystemPrompt on a share of traffic each,
+	// for comparing them by feedback and quality metrics
+	PromptVariants []PromptVariant `yaml:"prompt_variants"`
+
+	// Optional. Translations given to LLM translators as prior turns
+	Examples []Example `yaml:"examples"`
+
+	// Optional. ISO 639-1 code of the language the prompt translates into.
+	// Labels the language pair metric only
+	TargetLang string `yaml:"target_lang"`
 
 	// Optional. Failover
 	Failover common.FailoverConfig `yaml:"failover,omitempty"`
+
+	// Optional. Connection pool tuning
+	HTTPClient common.HTTPClientConfig `yaml:"http_client,omitempty"`
 }
 
 type TranslatorConfig struct {
@@ -29,17 +60,137 @@ type TranslatorConfig struct {
 	// Positive
 	Timeout int64 `yaml:"timeout"`
 
+	// Optional. Tier of the priority selector, lower tiers first
+	Priority int `yaml:"priority"`
+
+	// Optional. Time windows of the schedule selector
+	Schedule common.ScheduleConfig `yaml:"schedule"`
+
 	// Optional
 	Model string `yaml:"model"`
 
-	// Required
+	// Optional. Models picked by the length of the text, e.g. a cheaper
+	// one for short messages. Texts longer than all rules use Model
+	ModelRules []ModelRule `yaml:"model_rules"`
+
+	// Optional. Parameters accepted by the model, "chat" by default, or
+	// "reasoning" for o-series and other reasoning models
+	ParamProfile string `yaml:"param_profile"`
+
+	// Optional. Sampling temperature, the API's default if not set.
+	// Not accepted by reasoning models
+	Temperature *float64 `yaml:"temperature"`
+
+	// Optional. Nucleus sampling probability mass, the API's default if
+	// not set. Not accepted by reasoning models
+	TopP *float64 `yaml:"top_p"`
+
+	// Optional. Penalties in [-2, 2] of repeated tokens, the API's default
+	// if not set. Not accepted by reasoning models
+	FrequencyPenalty *float64 `yaml:"frequency_penalty"`
+	PresencePenalty  *float64 `yaml:"presence_penalty"`
+
+	// Optional. Maximum tokens of the answer, sent as max_completion_tokens
+	// to reasoning models, unlimited if 0
+	MaxTokens int64 `yaml:"max_tokens"`
+
+	// Optional. Maximum tokens of the answer sent as max_completion_tokens
+	// to chat models too, for models rejecting max_tokens. Exclusive with
+	// MaxTokens
+	MaxCompletionTokens int64 `yaml:"max_completion_tokens"`
+
+	// Optional. Fields added to the body of OpenAI requests, for
+	// parameters of compatible APIs not listed here, e.g. top_k. They
+	// override the fields above
+	ExtraParams map[string]any `yaml:"extra_params"`
+
+	// Optional. Sequences the model stops generating at, up to 4 for OpenAI
+	Stop []string `yaml:"stop"`
+
+	// Optional. Delimiters after which answers are cut, e.g. "\n\nNote:",
+	// for models appending explanations to the translation. Any type
+	TrimAfter []string `yaml:"trim_after"`
+
+	// Optional. "low", "medium" or "high", reasoning models only
+	ReasoningEffort string `yaml:"reasoning_effort"`
+
+	// Optional. Mark the system prompt with cache_control, for providers
+	// caching marked prefixes only, e.g. Anthropic models via OpenRouter.
+	// OpenAI caches prefixes automatically
+	CacheControl bool `yaml:"cache_control"`
+
+	// Optional. The model accepts images, for photo translation
+	Vision bool `yaml:"vision"`
+
+	// Required by API based instances
 	Endpoint string `yaml:"endpoint"`
 
 	// Optional
-	Token string `yaml:"token"`
+	Token string `yaml:"token" redact:"true"`
+
+	// Optional. File holding the token instead, watched for rotation
+	TokenFile string `yaml:"token_file"`
+
+	// Optional. OpenAI organization and project the usage is attributed to,
+	// sent as OpenAI-Organization and OpenAI-Project headers
+	Organization string `yaml:"organization"`
+	Project      string `yaml:"project"`
 
 	// Optional
 	RateLimit common.RateLimitConfig `yaml:"rate_limit"`
+
+	// Optional. Percentage of selections routed to this translator
+	// regardless of weight and order, for trialing it on a slice of
+	// traffic. Not a canary if 0
+	CanaryPercent float64 `yaml:"canary_percent"`
+
+	// Optional. Receive a copy of the text translations of the others,
+	// recording results, latency and similarity to the translation replied,
+	// without ever replying
+	Shadow bool `yaml:"shadow"`
+
+	// Optional. For resilience testing only
+	FaultInjection common.FaultInjectionConfig `yaml:"fault_injection"`
+
+	// Optional. For cost estimates
+	Pricing Pricing `yaml:"pricing"`
+
+	// Optional. USD spent per UTC day before the least_cost selector
+	// passes over the translator, unlimited if 0
+	DailyBudget float64 `yaml:"daily_budget"`
+
+	// Required by plugin instances
+	Plugin plugin.Config `yaml:"plugin"`
+
+	// Optional. Region and credentials of aws_translate instances
+	AWS AWSConfig `yaml:"aws"`
+
+	// Optional. Language parameters of libretranslate instances
+	LibreTranslate LibreTranslateConfig `yaml:"libretranslate"`
+
+	// Optional. Parameters of ollama instances
+	Ollama OllamaConfig `yaml:"ollama"`
+
+	// Optional. Folder and auth of yandex instances
+	Yandex YandexConfig `yaml:"yandex"`
+
+	// Optional. Language codes of huggingface instances
+	HuggingFace HuggingFaceConfig `yaml:"huggingface"`
+
+	// Optional. Client of papago instances
+	Papago PapagoConfig `yaml:"papago"`
+
+	// Optional. Request and response templates of http_template instances
+	HTTPTemplate HTTPTemplateConfig `yaml:"http_template"`
+
+	// Optional. App of baidu instances
+	Baidu BaiduConfig `yaml:"baidu"`
+
+	// Optional. Credentials of tencent instances
+	Tencent TencentConfig `yaml:"tencent"`
+
+	// Optional. Behavior of mock instances
+	Mock MockConfig `yaml:"mock"`
 }
 
 func (tic *TranslatorConfig) CheckAndMergeDefaultConfig(dtc DefaultTranslatorConfig) (err error) {
@@ -61,17 +212,71 @@ func (tic *TranslatorConfig) CheckAndMergeDefaultConfig(dtc DefaultTranslatorCon
 		tic.Weight = dtc.Weight
 	}
 
-	if tic.SystemPrompt == "" {
+	if tic.Priority < 0 {
+		err = fmt.Errorf("%s: translator priority must not be negative", tic.Name)
+		return
+	}
+
+	if len(tic.SystemPrompt) == 0 {
 		tic.SystemPrompt = dtc.SystemPrompt
 	}
+	err = tic.SystemPrompt.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tic.Name, err)
+		return
+	}
+
+	if len(tic.PromptVariants) == 0 {
+		tic.PromptVariants = dtc.PromptVariants
+	}
+	names := make(map[string]bool, len(tic.PromptVariants))
+	for i, v := range tic.PromptVariants {
+		err = v.Check()
+		if err != nil {
+			err = fmt.Errorf("%s: prompt variant %d: %w", tic.Name, i, err)
+			return
+		}
+		if names[v.Name] {
+			err = fmt.Errorf("%s: duplicate prompt variant: %s", tic.Name, v.Name)
+			return
+		}
+		names[v.Name] = true
+	}
+
+	if len(tic.Examples) == 0 {
+		tic.Examples = dtc.Examples
+	}
+	tic.Examples = slices.Clone(tic.Examples)
+	for i := range tic.Examples {
+		err = tic.Examples[i].Check()
+		if err != nil {
+			err = fmt.Errorf("%s: example %d: %w", tic.Name, i, err)
+			return
+		}
+	}
+
+	if tic.TargetLang == "" {
+		tic.TargetLang = dtc.TargetLang
+	}
+	tic.TargetLang = strings.ToUpper(tic.TargetLang)
+
+	if tic.CanaryPercent < 0 || tic.CanaryPercent > 100 {
+		err = fmt.Errorf("%s: canary percent must be between 0 and 100", tic.Name)
+		return
+	}
+	if tic.Shadow && tic.CanaryPercent > 0 {
+		err = fmt.Errorf("%s: a shadow translator can't be a canary", tic.Name)
+		return
+	}
 
 	if tic.Timeout <= 0 {
 		err = fmt.Errorf("%s: translator timeout must be positive", tic.Name)
 		return
 	}
 
-	if tic.Endpoint == "" {
-		err = fmt.Errorf("translator endpoint is required")
+	err = common.LoadSecretFile(&tic.Token, tic.TokenFile)
+	if err != nil {
+		err = fmt.Errorf("%s: token: %w", tic.Name, err)
 		return
 	}
 
@@ -82,7 +287,306 @@ func (tic *TranslatorConfig) CheckAndMergeDefaultConfig(dtc DefaultTranslatorCon
 		return
 	}
 
+	// HTTP Client
+	if tic.HTTPClient.IsZero() {
+		tic.HTTPClient = dtc.HTTPClient
+	}
+	err = tic.HTTPClient.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tic.Name, err)
+		return
+	}
+
 	// Rate Limit
 	err = tic.RateLimit.Check()
+	if err != nil {
+		return
+	}
+
+	err = tic.FaultInjection.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tic.Name, err)
+		return
+	}
+
+	err = tic.Schedule.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tic.Name, err)
+		return
+	}
+
+	if tic.Pricing.Prompt < 0 || tic.Pricing.Completion < 0 {
+		err = fmt.Errorf("%s: translator pricing must not be negative", tic.Name)
+		return
+	}
+
+	if tic.DailyBudget < 0 {
+		err = fmt.Errorf("%s: translator daily budget must not be negative", tic.Name)
+		return
+	}
+
+	err = tic.checkParams()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", tic.Name, err)
+		return
+	}
+
+	for i := range tic.ModelRules {
+		err = tic.ModelRules[i].Check()
+		if err != nil {
+			err = fmt.Errorf("%s: model rule %d: %w", tic.Name, i, err)
+			return
+		}
+	}
+	slices.SortStableFunc(tic.ModelRules, func(a, b ModelRule) int {
+		return a.MaxLength - b.MaxLength
+	})
+	return
+}
+
+// SystemPromptDefault is the key of the prompt used for source languages
+// without their own.
+const SystemPromptDefault = "default"
+
+// SystemPrompt holds prompts by upper-case ISO 639-1 code of the source
+// language, e.g. for honorifics and name order from Japanese. In YAML it
+// is a single prompt, or a mapping of languages and "default".
+type SystemPrompt map[string]string
+
+func (sp *SystemPrompt) UnmarshalYAML(value *yaml.Node) (err error) {
+	if value.Kind == yaml.ScalarNode {
+		*sp = SystemPrompt{SystemPromptDefault: value.Value}
+		return
+	}
+	var prompts map[string]string
+	err = value.Decode(&prompts)
+	if err != nil {
+		return
+	}
+	*sp = make(SystemPrompt, len(prompts))
+	for k, v := range prompts {
+		if k != SystemPromptDefault {
+			k = strings.ToUpper(k)
+		}
+		(*sp)[k] = v
+	}
+	return
+}
+
+func (sp SystemPrompt) Check() (err error) {
+	if len(sp) > 0 && sp[SystemPromptDefault] == "" {
+		err = fmt.Errorf("system prompt of '%s' is required with per-language prompts", SystemPromptDefault)
+	}
+	return
+}
+
+// For returns the prompt of lang, the default prompt if it has none.
+func (sp SystemPrompt) For(lang string) string {
+	if p, ok := sp[strings.ToUpper(lang)]; ok {
+		return p
+	}
+	return sp[SystemPromptDefault]
+}
+
+// PromptVariant is a system prompt given to a share of translations
+// instead of the instance's.
+type PromptVariant struct {
+	// Required. Labels metrics and feedback
+	Name string `yaml:"name"`
+
+	// Positive. Share of translations relative to the other variants
+	Weight int `yaml:"weight"`
+
+	// Required. A prompt, or prompts by source language
+	SystemPrompt SystemPrompt `yaml:"system_prompt"`
+}
+
+func (pv PromptVariant) Check() (err error) {
+	if pv.Name == "" {
+		err = fmt.Errorf("name is required")
+		return
+	}
+	if pv.Weight <= 0 {
+		err = fmt.Errorf("%s: weight must be positive", pv.Name)
+		return
+	}
+	if len(pv.SystemPrompt) == 0 {
+		err = fmt.Errorf("%s: system prompt is required", pv.Name)
+		return
+	}
+	err = pv.SystemPrompt.Check()
+	if err != nil {
+		err = fmt.Errorf("%s: %w", pv.Name, err)
+	}
+	return
+}
+
+// promptFor returns the variant translating the message of traceId and
+// its prompt, SystemPrompt without variants. Variants are picked by a hash
+// of traceId, so parts and retries of a message get the same one.
+func (tic *TranslatorConfig) promptFor(traceId string) (variant string, prompt SystemPrompt) {
+	if len(tic.PromptVariants) == 0 {
+		return "", tic.SystemPrompt
+	}
+	total := 0
+	for _, v := range tic.PromptVariants {
+		total += v.Weight
+	}
+	h := fnv.New32a()
+	h.Write([]byte(traceId))
+	n := int(h.Sum32() % uint32(total))
+	for _, v := range tic.PromptVariants {
+		if n < v.Weight {
+			return v.Name, v.SystemPrompt
+		}
+		n -= v.Weight
+	}
+	return
+}
+
+// Example is a translation shown to the model before the text, e.g. for
+// community slang.
+type Example struct {
+	// Optional. ISO 639-1 code of the source languages the example is given
+	// for, all if empty
+	Lang string `yaml:"lang"`
+
+	// Required
+	Source string `yaml:"source"`
+
+	// Required
+	Translation string `yaml:"translation"`
+}
+
+func (e *Example) Check() (err error) {
+	if e.Source == "" || e.Translation == "" {
+		err = fmt.Errorf("source and translation are required")
+		return
+	}
+	e.Lang = strings.ToUpper(e.Lang)
+	return
+}
+
+// examplesFor returns the examples given for texts in lang.
+func (tic *TranslatorConfig) examplesFor(lang string) (examples []Example) {
+	lang = strings.ToUpper(lang)
+	for _, e := range tic.Examples {
+		if e.Lang == "" || e.Lang == lang {
+			examples = append(examples, e)
+		}
+	}
+	return
+}
+
+// ModelRule picks Model for texts of at most MaxLength characters.
+type ModelRule struct {
+	// Positive
+	MaxLength int `yaml:"max_length"`
+
+	// Required
+	Model string `yaml:"model"`
+
+	// Optional. Prices of Model, the instance's if not set
+	Pricing *Pricing `yaml:"pricing"`
+}
+
+func (mr *ModelRule) Check() (err error) {
+	if mr.MaxLength <= 0 {
+		err = fmt.Errorf("max length must be positive")
+		return
+	}
+	if mr.Model == "" {
+		err = fmt.Errorf("model is required")
+		return
+	}
+	if mr.Pricing != nil && (mr.Pricing.Prompt < 0 || mr.Pricing.Completion < 0) {
+		err = fmt.Errorf("pricing must not be negative")
+	}
+	return
+}
+
+// modelFor returns the model of the first rule matching text,
+// Model if none does.
+func (tic *TranslatorConfig) modelFor(text string) string {
+	length := utf8.RuneCountInString(text)
+	for _, r := range tic.ModelRules {
+		if length <= r.MaxLength {
+			return r.Model
+		}
+	}
+	return tic.Model
+}
+
+// Prices returns the prices of the instance by model, "" for models
+// without their own.
+func (tic *TranslatorConfig) Prices() map[string]Pricing {
+	prices := map[string]Pricing{"": tic.Pricing}
+	for _, r := range tic.ModelRules {
+		if r.Pricing != nil {
+			prices[r.Model] = *r.Pricing
+		}
+	}
+	return prices
+}
+
+// Pricing is the price per million tokens, in USD.
+type Pricing struct {
+	Prompt     float64 `yaml:"prompt"`
+	Completion float64 `yaml:"completion"`
+}
+
+// Cost estimates the price of a translation.
+func (p Pricing) Cost(resp *TranslateResponse) float64 {
+	return (float64(resp.TokenUsage.Prompt)*p.Prompt + float64(resp.TokenUsage.Completion)*p.Completion) / 1e6
+}
+
+func (tic *TranslatorConfig) checkParams() (err error) {
+	switch tic.ParamProfile {
+	case "":
+		tic.ParamProfile = ParamProfileChat
+	case ParamProfileChat, ParamProfileReasoning:
+	default:
+		err = fmt.Errorf("invalid param profile: %s", tic.ParamProfile)
+		return
+	}
+	if tic.MaxTokens < 0 || tic.MaxCompletionTokens < 0 {
+		err = fmt.Errorf("max tokens must not be negative")
+		return
+	}
+	if tic.MaxTokens > 0 && tic.MaxCompletionTokens > 0 {
+		err = fmt.Errorf("max_tokens and max_completion_tokens are exclusive")
+		return
+	}
+	if tic.Temperature != nil && (*tic.Temperature < 0 || *tic.Temperature > 2) {
+		err = fmt.Errorf("temperature must be between 0 and 2")
+		return
+	}
+	if tic.TopP != nil && (*tic.TopP < 0 || *tic.TopP > 1) {
+		err = fmt.Errorf("top_p must be between 0 and 1")
+		return
+	}
+	for _, p := range []*float64{tic.FrequencyPenalty, tic.PresencePenalty} {
+		if p != nil && (*p < -2 || *p > 2) {
+			err = fmt.Errorf("penalties must be between -2 and 2")
+			return
+		}
+	}
+	if slices.Contains(tic.Stop, "") || slices.Contains(tic.TrimAfter, "") {
+		err = fmt.Errorf("stop sequences and trim delimiters must not be empty")
+		return
+	}
+	if tic.ParamProfile == ParamProfileReasoning {
+		if tic.Temperature != nil || tic.TopP != nil || tic.FrequencyPenalty != nil || tic.PresencePenalty != nil {
+			err = fmt.Errorf("sampling parameters are not accepted by reasoning models")
+			return
+		}
+		if tic.ReasoningEffort != "" && !slices.Contains(reasoningEfforts, tic.ReasoningEffort) {
+			err = fmt.Errorf("invalid reasoning effort: %s", tic.ReasoningEffort)
+		}
+		return
+	}
+	if tic.ReasoningEffort != "" {
+		err = fmt.Errorf("reasoning effort requires the reasoning param profile")
+	}
 	return
 }
@@ -0,0 +1,126 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypeAWSTranslate = "aws_translate"
+
+	awsTranslateService = "translate"
+	awsTranslateTarget  = "AWSShineFrontendService_20170701.TranslateText"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypeAWSTranslate, newAWSTranslateInstance)
+}
+
+// InstanceAWSTranslate translates with Amazon Translate. It reports the
+// characters sent as prompt tokens, as Amazon Translate bills by character.
+type InstanceAWSTranslate struct {
+	name       string
+	logger     *logrus.Entry
+	client     *http.Client
+	endpoint   string
+	region     string
+	creds      awsCredentials
+	targetLang string
+}
+
+func newAWSTranslateInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	if conf.TargetLang == "" {
+		err = fmt.Errorf("target lang is required by aws_translate")
+		return
+	}
+
+	instance := &InstanceAWSTranslate{
+		name:       conf.Name,
+		logger:     logger,
+		client:     conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint:   conf.Endpoint,
+		targetLang: strings.ToLower(conf.TargetLang),
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	instance.region, instance.creds, err = conf.AWS.resolve()
+	if err != nil {
+		return
+	}
+	if instance.endpoint == "" {
+		instance.endpoint = fmt.Sprintf("https://translate.%s.amazonaws.com", instance.region)
+	}
+
+	instance.logger.Debugf("initialized AWS Translate instance, region: %s, api url: %s",
+		instance.region, instance.endpoint)
+	return instance, nil
+}
+
+func (t *InstanceAWSTranslate) Name() string {
+	return t.name
+}
+
+type awsTranslateRequest struct {
+	Text               string `json:"Text"`
+	SourceLanguageCode string `json:"SourceLanguageCode"`
+	TargetLanguageCode string `json:"TargetLanguageCode"`
+}
+
+type awsTranslateResponse struct {
+	TranslatedText     string `json:"TranslatedText"`
+	SourceLanguageCode string `json:"SourceLanguageCode"`
+}
+
+func (t *InstanceAWSTranslate) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	if req.Image != nil {
+		err = fmt.Errorf("aws_translate does not support images")
+		return
+	}
+
+	r := awsTranslateRequest{
+		Text:               req.Text,
+		SourceLanguageCode: "auto",
+		TargetLanguageCode: t.targetLang,
+	}
+	if req.SourceLang != "" {
+		r.SourceLanguageCode = strings.ToLower(req.SourceLang)
+	}
+	if req.TargetLang != "" {
+		r.TargetLanguageCode = strings.ToLower(req.TargetLang)
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	httpReq.Header.Set("X-Amz-Target", awsTranslateTarget)
+	signAWS(httpReq, body, t.creds, t.region, awsTranslateService, time.Now())
+
+	var result awsTranslateResponse
+	err = common.DoJSON(t.client, httpReq, &result, "Authorization", "X-Amz-Security-Token")
+	if err != nil {
+		err = fmt.Errorf("aws translate request failed: %w", err)
+		return
+	}
+	t.logger.WithField("trace_id", req.TraceId).
+		Debugf("translated from %s", result.SourceLanguageCode)
+
+	resp = &TranslateResponse{Text: result.TranslatedText}
+	resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	return
+}
@@ -0,0 +1,175 @@
+package translator
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypeBaidu = "baidu"
+
+	defaultBaiduEndpoint = "https://fanyi-api.baidu.com/api/trans/vip/translate"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypeBaidu, newBaiduInstance)
+}
+
+// Baidu codes differing from ISO 639-1
+var baiduLangs = map[string]string{
+	"ja":    "jp",
+	"ko":    "kor",
+	"fr":    "fra",
+	"es":    "spa",
+	"ar":    "ara",
+	"vi":    "vie",
+	"sv":    "swe",
+	"da":    "dan",
+	"fi":    "fin",
+	"bg":    "bul",
+	"et":    "est",
+	"ro":    "rom",
+	"sl":    "slo",
+	"zh-tw": "cht",
+}
+
+// BaiduConfig holds the app of baidu instances. The secret key of the
+// app is the token.
+type BaiduConfig struct {
+	// Required
+	AppID string `yaml:"app_id"`
+}
+
+// InstanceBaidu translates with Baidu Fanyi. It reports the characters
+// sent as prompt tokens, as Baidu bills by character.
+type InstanceBaidu struct {
+	name       string
+	logger     *logrus.Entry
+	client     *http.Client
+	endpoint   string
+	appId      string
+	secret     string
+	targetLang string
+}
+
+func newBaiduInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	if conf.Baidu.AppID == "" || conf.Token == "" {
+		err = fmt.Errorf("app id and token are required by baidu")
+		return
+	}
+	if conf.TargetLang == "" {
+		err = fmt.Errorf("target lang is required by baidu")
+		return
+	}
+
+	instance := &InstanceBaidu{
+		name:       conf.Name,
+		logger:     logger,
+		client:     conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint:   conf.Endpoint,
+		appId:      conf.Baidu.AppID,
+		secret:     conf.Token,
+		targetLang: baiduLang(conf.TargetLang),
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	if instance.endpoint == "" {
+		instance.endpoint = defaultBaiduEndpoint
+	}
+
+	instance.logger.Debugf("initialized Baidu instance, api url: %s", instance.endpoint)
+	return instance, nil
+}
+
+func (t *InstanceBaidu) Name() string {
+	return t.name
+}
+
+// baiduLang returns the Baidu code of an ISO 639-1 code.
+func baiduLang(lang string) string {
+	lang = strings.ToLower(lang)
+	if code, ok := baiduLangs[lang]; ok {
+		return code
+	}
+	return lang
+}
+
+type baiduResponse struct {
+	From        string `json:"from"`
+	TransResult []struct {
+		Dst string `json:"dst"`
+	} `json:"trans_result"`
+
+	// Errors are returned with status 200
+	ErrorCode string `json:"error_code"`
+	ErrorMsg  string `json:"error_msg"`
+}
+
+func (t *InstanceBaidu) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	if req.Image != nil {
+		err = fmt.Errorf("baidu does not support images")
+		return
+	}
+
+	from := "auto"
+	if req.SourceLang != "" {
+		from = baiduLang(req.SourceLang)
+	}
+	to := t.targetLang
+	if req.TargetLang != "" {
+		to = baiduLang(req.TargetLang)
+	}
+	salt := strconv.FormatUint(rand.Uint64(), 10)
+	sum := md5.Sum([]byte(t.appId + req.Text + salt + t.secret))
+	form := url.Values{
+		"q":     {req.Text},
+		"from":  {from},
+		"to":    {to},
+		"appid": {t.appId},
+		"salt":  {salt},
+		"sign":  {hex.EncodeToString(sum[:])},
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var result baiduResponse
+	err = common.DoJSON(t.client, httpReq, &result)
+	if err == nil && result.ErrorCode != "" && result.ErrorCode != "52000" {
+		err = fmt.Errorf("error %s: %s", result.ErrorCode, result.ErrorMsg)
+	}
+	if err != nil {
+		err = fmt.Errorf("baidu request failed: %w", err)
+		return
+	}
+
+	// Paragraphs are translated separately
+	var parts []string
+	for _, r := range result.TransResult {
+		parts = append(parts, r.Dst)
+	}
+	if len(parts) == 0 {
+		err = fmt.Errorf("no translation found in response")
+		return
+	}
+	t.logger.WithField("trace_id", req.TraceId).Debugf("translated from %s", result.From)
+
+	resp = &TranslateResponse{Text: strings.Join(parts, "\n")}
+	resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	return
+}
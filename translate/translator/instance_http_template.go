@@ -0,0 +1,254 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const instanceTypeHTTPTemplate = "http_template"
+
+func init() {
+	registerTranslatorInstance(instanceTypeHTTPTemplate, newHTTPTemplateInstance)
+}
+
+// HTTPTemplateConfig describes the API of http_template instances by Go
+// templates. Request templates are executed on httpTemplateRequest,
+// response templates on the decoded JSON response, e.g.
+// {{index .translations 0 "text"}}.
+type HTTPTemplateConfig struct {
+	// Optional. POST by default
+	Method string `yaml:"method"`
+
+	// Optional. Header templates by name
+	Headers map[string]string `yaml:"headers"`
+
+	// Required. Template of the JSON body. The json function quotes a
+	// value, e.g. {"q": {{json .Text}}}
+	Body string `yaml:"body"`
+
+	// Required. Template of the translated text
+	Text string `yaml:"text"`
+
+	// Optional. Templates of the token counts, e.g. {{.usage.input}}.
+	// Characters sent are counted as prompt tokens without them
+	PromptTokens     string `yaml:"prompt_tokens"`
+	CompletionTokens string `yaml:"completion_tokens"`
+}
+
+// httpTemplateRequest is the data of request templates.
+type httpTemplateRequest struct {
+	Text       string
+	TraceId    string
+	SourceLang string
+	TargetLang string
+	Context    string
+	Metadata   string
+	Model      string
+	Token      string
+}
+
+var httpTemplateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+// InstanceHTTPTemplate translates with any JSON API described in the
+// config, e.g. internal translation services.
+type InstanceHTTPTemplate struct {
+	name       string
+	logger     *logrus.Entry
+	client     *http.Client
+	endpoint   string
+	method     string
+	token      string
+	model      string
+	targetLang string
+
+	headers          map[string]*template.Template
+	body             *template.Template
+	text             *template.Template
+	promptTokens     *template.Template
+	completionTokens *template.Template
+}
+
+func newHTTPTemplateInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	tc := conf.HTTPTemplate
+	if conf.Endpoint == "" {
+		err = fmt.Errorf("translator endpoint is required")
+		return
+	}
+	if tc.Body == "" || tc.Text == "" {
+		err = fmt.Errorf("body and text templates are required by http_template")
+		return
+	}
+
+	instance := &InstanceHTTPTemplate{
+		name:       conf.Name,
+		logger:     logger,
+		client:     conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint:   conf.Endpoint,
+		method:     strings.ToUpper(tc.Method),
+		token:      conf.Token,
+		model:      conf.Model,
+		targetLang: strings.ToLower(conf.TargetLang),
+		headers:    make(map[string]*template.Template, len(tc.Headers)),
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	if instance.method == "" {
+		instance.method = http.MethodPost
+	}
+
+	for name, text := range tc.Headers {
+		if instance.headers[name], err = parseHTTPTemplate("header "+name, text); err != nil {
+			return
+		}
+	}
+	if instance.body, err = parseHTTPTemplate("body", tc.Body); err != nil {
+		return
+	}
+	if instance.text, err = parseHTTPTemplate("text", tc.Text); err != nil {
+		return
+	}
+	if instance.promptTokens, err = parseHTTPTemplate("prompt_tokens", tc.PromptTokens); err != nil {
+		return
+	}
+	if instance.completionTokens, err = parseHTTPTemplate("completion_tokens", tc.CompletionTokens); err != nil {
+		return
+	}
+
+	instance.logger.Debugf("initialized HTTP template instance, api url: %s", instance.endpoint)
+	return instance, nil
+}
+
+// parseHTTPTemplate returns nil for empty templates.
+func parseHTTPTemplate(name, text string) (t *template.Template, err error) {
+	if text == "" {
+		return
+	}
+	t, err = template.New(name).Funcs(httpTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		err = fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return
+}
+
+func executeHTTPTemplate(t *template.Template, data any) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("execute %s template failed: %w", t.Name(), err)
+	}
+	return b.String(), nil
+}
+
+func (t *InstanceHTTPTemplate) Name() string {
+	return t.name
+}
+
+func (t *InstanceHTTPTemplate) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	if req.Image != nil {
+		err = fmt.Errorf("http_template does not support images")
+		return
+	}
+
+	data := httpTemplateRequest{
+		Text:       req.Text,
+		TraceId:    req.TraceId,
+		SourceLang: strings.ToLower(req.SourceLang),
+		TargetLang: t.targetLang,
+		Context:    req.Context,
+		Metadata:   req.Metadata,
+		Model:      t.model,
+		Token:      t.token,
+	}
+	if req.TargetLang != "" {
+		data.TargetLang = strings.ToLower(req.TargetLang)
+	}
+	body, err := executeHTTPTemplate(t.body, data)
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, t.method, t.endpoint, bytes.NewReader([]byte(body)))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	masked := make([]string, 0, len(t.headers))
+	for name, tmpl := range t.headers {
+		var value string
+		if value, err = executeHTTPTemplate(tmpl, data); err != nil {
+			return
+		}
+		httpReq.Header.Set(name, value)
+		// Headers may hold the token
+		masked = append(masked, name)
+	}
+
+	var result any
+	err = common.DoJSON(t.client, httpReq, &result, masked...)
+	if err != nil {
+		err = fmt.Errorf("http template request failed: %w", err)
+		return
+	}
+
+	resp = new(TranslateResponse)
+	if resp.Text, err = resultValue(t.text, result); err != nil {
+		return nil, err
+	}
+	if resp.Text == "" {
+		err = fmt.Errorf("no translation found in response")
+		return nil, err
+	}
+	resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	if t.promptTokens != nil {
+		if resp.TokenUsage.Prompt, err = tokenCount(t.promptTokens, result); err != nil {
+			return nil, err
+		}
+	}
+	if t.completionTokens != nil {
+		if resp.TokenUsage.Completion, err = tokenCount(t.completionTokens, result); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+// resultValue executes tmpl on the decoded response result, empty if the
+// value is missing.
+func resultValue(tmpl *template.Template, result any) (s string, err error) {
+	s, err = executeHTTPTemplate(tmpl, result)
+	if s == "<no value>" {
+		s = ""
+	}
+	return
+}
+
+// tokenCount returns the count of tmpl in result, 0 if missing.
+func tokenCount(tmpl *template.Template, result any) (n int64, err error) {
+	s, err := resultValue(tmpl, result)
+	if err != nil || s == "" {
+		return
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		err = fmt.Errorf("parse %s failed: %w", tmpl.Name(), err)
+		return
+	}
+	return int64(f), nil
+}
@@ -0,0 +1,162 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypeHuggingFace = "huggingface"
+
+	defaultHuggingFaceEndpoint = "https://api-inference.huggingface.co/models/"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypeHuggingFace, newHuggingFaceInstance)
+}
+
+// HuggingFaceConfig holds the language codes of huggingface instances.
+// Multilingual models like NLLB take explicit codes, as they don't detect
+// the source language. Models of a single pair like MarianMT take none.
+type HuggingFaceConfig struct {
+	// Optional. Codes of the model by ISO 639-1 code of the detected
+	// language, e.g. JA: jpn_Jpan for NLLB
+	SourceLangs map[string]string `yaml:"source_langs"`
+
+	// Optional. Code of the model for languages not in SourceLangs
+	SourceLang string `yaml:"source_lang"`
+
+	// Optional. Code of the model translated into, e.g. eng_Latn
+	TargetLang string `yaml:"target_lang"`
+
+	// Optional. Wait while the Inference API loads the model instead of
+	// failing
+	WaitForModel bool `yaml:"wait_for_model"`
+}
+
+// InstanceHuggingFace translates with a seq2seq translation model of the
+// Hugging Face Inference API or a self-hosted endpoint serving its
+// protocol. It reports the characters sent as prompt tokens.
+type InstanceHuggingFace struct {
+	name     string
+	logger   *logrus.Entry
+	client   *http.Client
+	endpoint string
+	token    string
+	conf     HuggingFaceConfig
+}
+
+func newHuggingFaceInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	instance := &InstanceHuggingFace{
+		name:     conf.Name,
+		logger:   logger,
+		client:   conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint: conf.Endpoint,
+		token:    conf.Token,
+		conf:     conf.HuggingFace,
+	}
+	if instance.endpoint == "" {
+		if conf.Model == "" {
+			err = fmt.Errorf("model or endpoint is required by huggingface")
+			return
+		}
+		instance.endpoint = defaultHuggingFaceEndpoint + conf.Model
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	// Keys are matched against detected languages
+	instance.conf.SourceLangs = make(map[string]string, len(conf.HuggingFace.SourceLangs))
+	for k, v := range conf.HuggingFace.SourceLangs {
+		instance.conf.SourceLangs[strings.ToUpper(k)] = v
+	}
+
+	instance.logger.Debugf("initialized Hugging Face instance, api url: %s", instance.endpoint)
+	return instance, nil
+}
+
+func (t *InstanceHuggingFace) Name() string {
+	return t.name
+}
+
+type huggingFaceRequest struct {
+	Inputs     string                 `json:"inputs"`
+	Parameters *huggingFaceParameters `json:"parameters,omitempty"`
+	Options    *huggingFaceOptions    `json:"options,omitempty"`
+}
+
+type huggingFaceParameters struct {
+	SrcLang string `json:"src_lang,omitempty"`
+	TgtLang string `json:"tgt_lang,omitempty"`
+}
+
+type huggingFaceOptions struct {
+	WaitForModel bool `json:"wait_for_model"`
+}
+
+type huggingFaceResult struct {
+	TranslationText string `json:"translation_text"`
+	GeneratedText   string `json:"generated_text"`
+}
+
+func (t *InstanceHuggingFace) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	if req.Image != nil {
+		err = fmt.Errorf("huggingface does not support images")
+		return
+	}
+
+	r := huggingFaceRequest{Inputs: req.Text}
+	source, ok := t.conf.SourceLangs[strings.ToUpper(req.SourceLang)]
+	if !ok {
+		source = t.conf.SourceLang
+	}
+	if len(t.conf.SourceLangs) > 0 && source == "" {
+		err = fmt.Errorf("%w: no source language code configured for '%s'", ErrUnsupportedLanguage, req.SourceLang)
+		return
+	}
+	if source != "" || t.conf.TargetLang != "" {
+		r.Parameters = &huggingFaceParameters{SrcLang: source, TgtLang: t.conf.TargetLang}
+	}
+	if t.conf.WaitForModel {
+		r.Options = &huggingFaceOptions{WaitForModel: true}
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if t.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+t.token)
+	}
+
+	var results []huggingFaceResult
+	err = common.DoJSON(t.client, httpReq, &results, "Authorization")
+	if err != nil {
+		err = fmt.Errorf("huggingface request failed: %w", err)
+		return
+	}
+	if len(results) == 0 {
+		err = fmt.Errorf("no translation found in response")
+		return
+	}
+
+	resp = &TranslateResponse{Text: results[0].TranslationText}
+	if resp.Text == "" {
+		resp.Text = results[0].GeneratedText
+	}
+	resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	return
+}
@@ -0,0 +1,160 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypeLibreTranslate = "libretranslate"
+
+	libreTranslateSourceAuto = "auto"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypeLibreTranslate, newLibreTranslateInstance)
+}
+
+// LibreTranslateConfig holds the language parameters of libretranslate
+// instances.
+type LibreTranslateConfig struct {
+	// Optional. Source language sent instead of the detected one, "auto"
+	// lets the server detect it
+	SourceLang string `yaml:"source_lang"`
+
+	// Optional. Target language sent instead of target_lang, e.g. for
+	// codes of the server differing from ISO 639-1 like "zh-Hant"
+	TargetLang string `yaml:"target_lang"`
+}
+
+// InstanceLibreTranslate translates with a LibreTranslate server. It
+// reports the characters sent as prompt tokens.
+type InstanceLibreTranslate struct {
+	name       string
+	logger     *logrus.Entry
+	client     *http.Client
+	endpoint   string
+	apiKey     string
+	sourceLang string
+	targetLang string
+}
+
+func newLibreTranslateInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	if conf.Endpoint == "" {
+		err = fmt.Errorf("translator endpoint is required")
+		return
+	}
+
+	instance := &InstanceLibreTranslate{
+		name:       conf.Name,
+		logger:     logger,
+		client:     conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint:   strings.TrimSuffix(conf.Endpoint, "/"),
+		apiKey:     conf.Token,
+		sourceLang: conf.LibreTranslate.SourceLang,
+		targetLang: conf.LibreTranslate.TargetLang,
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	if instance.targetLang == "" {
+		instance.targetLang = strings.ToLower(conf.TargetLang)
+	}
+	if instance.targetLang == "" {
+		err = fmt.Errorf("target lang is required by libretranslate")
+		return
+	}
+
+	instance.logger.Debugf("initialized LibreTranslate instance, target: %s, api url: %s",
+		instance.targetLang, instance.endpoint)
+	return instance, nil
+}
+
+func (t *InstanceLibreTranslate) Name() string {
+	return t.name
+}
+
+type libreTranslateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Format string `json:"format"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type libreTranslateResponse struct {
+	TranslatedText   string `json:"translatedText"`
+	DetectedLanguage *struct {
+		Language   string  `json:"language"`
+		Confidence float64 `json:"confidence"`
+	} `json:"detectedLanguage"`
+}
+
+func (t *InstanceLibreTranslate) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	if req.Image != nil {
+		err = fmt.Errorf("libretranslate does not support images")
+		return
+	}
+
+	r := libreTranslateRequest{
+		Q:      req.Text,
+		Source: t.sourceLang,
+		Target: t.targetLang,
+		Format: "text",
+		APIKey: t.apiKey,
+	}
+	if r.Source == "" {
+		r.Source = strings.ToLower(req.SourceLang)
+	}
+	if r.Source == "" {
+		r.Source = libreTranslateSourceAuto
+	}
+	if req.TargetLang != "" {
+		r.Target = strings.ToLower(req.TargetLang)
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/translate", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	var result libreTranslateResponse
+	err = common.DoJSON(t.client, httpReq, &result)
+	if err != nil {
+		var httpErr *common.HTTPError
+		if errors.As(err, &httpErr) && t.apiKey != "" {
+			// Mask the key in dumps of the body
+			r.APIKey = "********"
+			masked, _ := json.Marshal(r)
+			httpErr.Request.GetBody = func() (io.ReadCloser, error) {
+				return io.NopCloser(bytes.NewReader(masked)), nil
+			}
+			httpErr.Request.ContentLength = int64(len(masked))
+		}
+		err = fmt.Errorf("libretranslate request failed: %w", err)
+		return
+	}
+	if result.DetectedLanguage != nil {
+		t.logger.WithField("trace_id", req.TraceId).Debugf("translated from %s, confidence: %.2f",
+			result.DetectedLanguage.Language, result.DetectedLanguage.Confidence)
+	}
+
+	resp = &TranslateResponse{Text: result.TranslatedText}
+	resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	return
+}
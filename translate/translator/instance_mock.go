@@ -0,0 +1,115 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+const instanceTypeMock = "mock"
+
+// errMockFailure is returned by the failed calls of mock instances.
+var errMockFailure = errors.New("mock failure")
+
+func init() {
+	registerTranslatorInstance(instanceTypeMock, newMockInstance)
+}
+
+// MockConfig holds the behavior of mock instances.
+type MockConfig struct {
+	// Optional. Returned for every request, the text echoed if empty
+	Text string `yaml:"text"`
+
+	// Optional. Delay of every call, plus a random jitter up to JitterMs
+	LatencyMs int `yaml:"latency_ms"`
+	JitterMs  int `yaml:"jitter_ms"`
+
+	// Optional. Probability in [0, 1] of failing a call
+	FailureRate float64 `yaml:"failure_rate"`
+
+	// Optional. Token usage reported per call. The characters of the text
+	// and the translation are counted if 0
+	PromptTokens     int64 `yaml:"prompt_tokens"`
+	CompletionTokens int64 `yaml:"completion_tokens"`
+}
+
+// InstanceMock translates nothing. It returns canned or echoed text, for
+// testing selectors, failover and metrics without spending API credits.
+type InstanceMock struct {
+	name   string
+	logger *logrus.Entry
+	model  string
+	conf   MockConfig
+}
+
+func newMockInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	mc := conf.Mock
+	if mc.LatencyMs < 0 || mc.JitterMs < 0 {
+		err = fmt.Errorf("mock latency must not be negative")
+		return
+	}
+	if mc.FailureRate < 0 || mc.FailureRate > 1 {
+		err = fmt.Errorf("mock failure rate must be between 0 and 1")
+		return
+	}
+	if mc.PromptTokens < 0 || mc.CompletionTokens < 0 {
+		err = fmt.Errorf("mock token usage must not be negative")
+		return
+	}
+
+	instance := &InstanceMock{
+		name:   conf.Name,
+		logger: logger,
+		model:  conf.Model,
+		conf:   mc,
+	}
+	instance.logger.Warnf("initialized mock instance, latency: %d ms, failure rate: %.2f",
+		mc.LatencyMs, mc.FailureRate)
+	return instance, nil
+}
+
+func (t *InstanceMock) Name() string {
+	return t.name
+}
+
+func (t *InstanceMock) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	latency := time.Duration(t.conf.LatencyMs) * time.Millisecond
+	if t.conf.JitterMs > 0 {
+		latency += time.Duration(rand.IntN(t.conf.JitterMs+1)) * time.Millisecond
+	}
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-timer.C:
+		}
+	}
+
+	if rand.Float64() < t.conf.FailureRate {
+		err = errMockFailure
+		return
+	}
+
+	resp = &TranslateResponse{Text: t.conf.Text, Model: t.model}
+	if resp.Text == "" {
+		resp.Text = req.Text
+	}
+	resp.TokenUsage.Prompt = t.conf.PromptTokens
+	if resp.TokenUsage.Prompt == 0 {
+		resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	}
+	resp.TokenUsage.Completion = t.conf.CompletionTokens
+	if resp.TokenUsage.Completion == 0 {
+		resp.TokenUsage.Completion = int64(utf8.RuneCountInString(resp.Text))
+	}
+	t.logger.WithField("trace_id", req.TraceId).Debugf("mock translated after %s", latency)
+	return
+}
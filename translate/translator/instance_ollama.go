@@ -0,0 +1,185 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypeOllama = "ollama"
+
+	defaultOllamaEndpoint = "http://localhost:11434"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypeOllama, newOllamaInstance)
+}
+
+// OllamaConfig holds the parameters of ollama instances.
+type OllamaConfig struct {
+	// Optional. How long the model stays loaded after a request, e.g. "10m",
+	// or seconds, -1 keeping it loaded. The server's default if empty
+	KeepAlive string `yaml:"keep_alive"`
+
+	// Optional. Model options, e.g. num_ctx. temperature, max_tokens and
+	// stop of the translator are added as the options they correspond to
+	Options map[string]any `yaml:"options"`
+}
+
+// InstanceOllama translates with a model of an Ollama server.
+type InstanceOllama struct {
+	name      string
+	logger    *logrus.Entry
+	client    *http.Client
+	endpoint  string
+	keepAlive any
+	options   map[string]any
+	conf      TranslatorConfig
+}
+
+func newOllamaInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	if conf.Model == "" {
+		err = fmt.Errorf("no ollama model configured")
+		return
+	}
+
+	instance := &InstanceOllama{
+		name:     conf.Name,
+		logger:   logger,
+		client:   conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint: strings.TrimSuffix(conf.Endpoint, "/"),
+		options:  maps.Clone(conf.Ollama.Options),
+		conf:     conf,
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	if instance.endpoint == "" {
+		instance.endpoint = defaultOllamaEndpoint
+	}
+	if conf.Ollama.KeepAlive != "" {
+		// Numbers are seconds, strings durations
+		if n, err := strconv.Atoi(conf.Ollama.KeepAlive); err == nil {
+			instance.keepAlive = n
+		} else {
+			instance.keepAlive = conf.Ollama.KeepAlive
+		}
+	}
+	if instance.options == nil {
+		instance.options = make(map[string]any)
+	}
+	if conf.Temperature != nil {
+		instance.options["temperature"] = *conf.Temperature
+	}
+	if conf.TopP != nil {
+		instance.options["top_p"] = *conf.TopP
+	}
+	if conf.FrequencyPenalty != nil {
+		instance.options["frequency_penalty"] = *conf.FrequencyPenalty
+	}
+	if conf.PresencePenalty != nil {
+		instance.options["presence_penalty"] = *conf.PresencePenalty
+	}
+	if maxTokens := max(conf.MaxTokens, conf.MaxCompletionTokens); maxTokens > 0 {
+		instance.options["num_predict"] = maxTokens
+	}
+	if len(conf.Stop) > 0 {
+		instance.options["stop"] = conf.Stop
+	}
+
+	instance.logger.Debugf("initialized Ollama instance, model: %s, api url: %s",
+		conf.Model, instance.endpoint)
+	return instance, nil
+}
+
+func (t *InstanceOllama) Name() string {
+	return t.name
+}
+
+type ollamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []ollamaMessage `json:"messages"`
+	Stream    bool            `json:"stream"`
+	KeepAlive any             `json:"keep_alive,omitempty"`
+	Options   map[string]any  `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	PromptEvalCount int64         `json:"prompt_eval_count"`
+	EvalCount       int64         `json:"eval_count"`
+}
+
+func (t *InstanceOllama) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	variant, prompt := t.conf.promptFor(req.TraceId)
+	system := strings.Join(append([]string{prompt.For(req.SourceLang)}, requestInstructions(req)...), "\n\n")
+
+	r := ollamaChatRequest{
+		Model:     t.conf.modelFor(req.Text),
+		Messages:  []ollamaMessage{{Role: "system", Content: system}},
+		KeepAlive: t.keepAlive,
+		Options:   t.options,
+	}
+	for _, e := range t.conf.examplesFor(req.SourceLang) {
+		r.Messages = append(r.Messages,
+			ollamaMessage{Role: "user", Content: e.Source},
+			ollamaMessage{Role: "assistant", Content: e.Translation})
+	}
+	user := ollamaMessage{Role: "user", Content: req.Text}
+	if req.Image != nil {
+		user.Content = imageText(req)
+		user.Images = []string{base64.StdEncoding.EncodeToString(req.Image.Data)}
+		r.Model = t.conf.Model
+	}
+	r.Messages = append(r.Messages, user)
+
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	var result ollamaChatResponse
+	err = common.DoJSON(t.client, httpReq, &result)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		// Counted as a failure like any other, so failover falls back to
+		// the other translators while the server is down
+		err = fmt.Errorf("ollama server is down: %w", err)
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("ollama request failed: %w", err)
+		return
+	}
+
+	resp = &TranslateResponse{
+		Text:          result.Message.Content,
+		Model:         r.Model,
+		PromptVariant: variant,
+	}
+	resp.TokenUsage.Prompt = result.PromptEvalCount
+	resp.TokenUsage.Completion = result.EvalCount
+	return
+}
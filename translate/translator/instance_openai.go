@@ -2,17 +2,29 @@ package translator
 
 import (
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/protect"
 	"github.com/openai/openai-go"
 	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
 	"github.com/sirupsen/logrus"
 )
 
 const (
 	instanceTypeOpenAI = "openai"
+
+	// Tells the model the context is not part of the text to translate
+	contextInstruction = "The message to translate is a reply to the message below, " +
+		"given as context only. Do not translate or repeat it.\n<context>\n%s\n</context>"
+	metadataInstruction = "About the message, to resolve who is speaking and to whom. " +
+		"Do not translate or repeat it.\n<metadata>\n%s\n</metadata>"
+	targetLangInstruction = "Translate the message into the language of ISO 639-1 code %s " +
+		"instead of the language asked above."
 )
 
 func init() {
@@ -22,20 +34,17 @@ func init() {
 // TranslatorInstanceOpenAI implements the translation logic using the OpenAI style API.
 // It embeds baseTranslator for common functionalities.
 type InstanceOpenAI struct {
-	name         string
-	logger       *logrus.Entry
-	aiClient     openai.Client
-	systemPrompt string
-	model        string
+	name     string
+	logger   *logrus.Entry
+	aiClient openai.Client
+	conf     TranslatorConfig
 }
 
 // newTranslatorInstanceOpenAI creates and initializes a new TranslatorInstanceOpenAI.
 // It validates the provided TranslateConfig and configures the OpenAI client,
 // language detector, rate limiter, and other parameters.
 // Returns an error if any critical configuration is missing or invalid.
-func newOpenAIInstance(conf TranslatorConfig) (c Instance, err error) {
-	logger := logrus.WithField("translator_instance", conf.Name)
-
+func newOpenAIInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
 	openaiOpts := []option.RequestOption{}
 
 	if conf.Token == "" {
@@ -43,8 +52,19 @@ func newOpenAIInstance(conf TranslatorConfig) (c Instance, err error) {
 	} else {
 		openaiOpts = append(openaiOpts, option.WithAPIKey(conf.Token))
 	}
-	if conf.Endpoint != "" {
-		openaiOpts = append(openaiOpts, option.WithBaseURL(conf.Endpoint))
+	if conf.Endpoint == "" {
+		err = fmt.Errorf("translator endpoint is required")
+		return
+	}
+	openaiOpts = append(openaiOpts, option.WithBaseURL(conf.Endpoint))
+	if conf.Organization != "" {
+		openaiOpts = append(openaiOpts, option.WithOrganization(conf.Organization))
+	}
+	if conf.Project != "" {
+		openaiOpts = append(openaiOpts, option.WithProject(conf.Project))
+	}
+	if client := conf.HTTPClient.NewHTTPClientFromConfig(logger); client != nil {
+		openaiOpts = append(openaiOpts, option.WithHTTPClient(client))
 	}
 
 	if conf.Model == "" {
@@ -54,15 +74,14 @@ func newOpenAIInstance(conf TranslatorConfig) (c Instance, err error) {
 
 	instance := new(InstanceOpenAI)
 	instance.aiClient = openai.NewClient(openaiOpts...)
-	instance.model = conf.Model
+	instance.conf = conf
 
 	// Already validated, just set it
 	instance.name = conf.Name
-	instance.systemPrompt = conf.SystemPrompt
 	instance.logger = logger
 
 	instance.logger.Debugf("initialized OpenAI instance, model: %s, api url: %s",
-		instance.model, conf.Endpoint)
+		conf.Model, conf.Endpoint)
 	return instance, nil
 }
 
@@ -74,17 +93,19 @@ func (t *InstanceOpenAI) Name() string {
 // It respects the configured timeout and rate limiter.
 // Returns the API's chat completion response or an error.
 func (t *InstanceOpenAI) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	instructions := requestInstructions(req)
+
+	userMessage := openai.UserMessage(req.Text)
+	model := t.conf.modelFor(req.Text)
+	if req.Image != nil {
+		userMessage = t.imageMessage(req)
+		// Rules pick by text length, which says nothing about images
+		model = t.conf.Model
+	}
+
+	variant, prompt := t.conf.promptFor(req.TraceId)
 	var chatCompletion *openai.ChatCompletion
-	chatCompletion, err = t.aiClient.Chat.Completions.New(
-		ctx,
-		openai.ChatCompletionNewParams{
-			Model: t.model,
-			Messages: []openai.ChatCompletionMessageParamUnion{
-				openai.SystemMessage(t.systemPrompt),
-				openai.UserMessage(req.Text),
-			},
-		},
-	)
+	chatCompletion, err = t.aiClient.Chat.Completions.New(ctx, t.params(model, prompt.For(req.SourceLang), req.SourceLang, instructions, userMessage))
 
 	if err != nil {
 		var apiErr = new(openai.Error)
@@ -105,10 +126,131 @@ func (t *InstanceOpenAI) Translate(ctx context.Context, req TranslateRequest) (r
 	resp = new(TranslateResponse)
 	if len(chatCompletion.Choices) > 0 {
 		resp.Text = chatCompletion.Choices[0].Message.Content
+		resp.Model = model
+		resp.PromptVariant = variant
 		resp.TokenUsage.Completion = chatCompletion.Usage.CompletionTokens
 		resp.TokenUsage.Prompt = chatCompletion.Usage.PromptTokens
+		resp.TokenUsage.CachedPrompt = chatCompletion.Usage.PromptTokensDetails.CachedTokens
 		return
 	}
 	err = fmt.Errorf("no choice found in response")
 	return
 }
+
+// requestInstructions returns the instructions of req, appended after the
+// static prompt, keeping the prompt a cacheable prefix.
+func requestInstructions(req TranslateRequest) (instructions []string) {
+	if protect.HasPlaceholders(req.Text) {
+		instructions = append(instructions, protect.Instruction)
+	}
+	if req.Context != "" {
+		instructions = append(instructions, fmt.Sprintf(contextInstruction, req.Context))
+	}
+	if req.Metadata != "" {
+		instructions = append(instructions, fmt.Sprintf(metadataInstruction, req.Metadata))
+	}
+	if req.TargetLang != "" {
+		instructions = append(instructions, fmt.Sprintf(targetLangInstruction, req.TargetLang))
+	}
+	return
+}
+
+// params builds the request in the parameter profile of the instance.
+// Examples of lang are given as prior turns.
+func (t *InstanceOpenAI) params(model, systemPrompt, lang string, instructions []string, userMessage openai.ChatCompletionMessageParamUnion) (params openai.ChatCompletionNewParams) {
+	params.Model = model
+	params.Stop.OfStringArray = t.conf.Stop
+	system := t.systemMessage(systemPrompt, instructions)
+
+	var examples []openai.ChatCompletionMessageParamUnion
+	for _, e := range t.conf.examplesFor(lang) {
+		examples = append(examples, openai.UserMessage(e.Source), openai.AssistantMessage(e.Translation))
+	}
+
+	if t.conf.ParamProfile == ParamProfileReasoning {
+		// Reasoning models take instructions as developer messages
+		developer := openai.ChatCompletionDeveloperMessageParam{}
+		developer.Content.OfString = system.Content.OfString
+		developer.Content.OfArrayOfContentParts = system.Content.OfArrayOfContentParts
+		params.Messages = append([]openai.ChatCompletionMessageParamUnion{{OfDeveloper: &developer}}, examples...)
+		params.Messages = append(params.Messages, userMessage)
+		if maxTokens := max(t.conf.MaxTokens, t.conf.MaxCompletionTokens); maxTokens > 0 {
+			params.MaxCompletionTokens = openai.Int(maxTokens)
+		}
+		params.ReasoningEffort = shared.ReasoningEffort(t.conf.ReasoningEffort)
+		t.setExtraParams(&params)
+		return
+	}
+
+	params.Messages = append([]openai.ChatCompletionMessageParamUnion{{OfSystem: &system}}, examples...)
+	params.Messages = append(params.Messages, userMessage)
+	if t.conf.MaxTokens > 0 {
+		params.MaxTokens = openai.Int(t.conf.MaxTokens)
+	}
+	if t.conf.MaxCompletionTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(t.conf.MaxCompletionTokens)
+	}
+	if t.conf.Temperature != nil {
+		params.Temperature = openai.Float(*t.conf.Temperature)
+	}
+	if t.conf.TopP != nil {
+		params.TopP = openai.Float(*t.conf.TopP)
+	}
+	if t.conf.FrequencyPenalty != nil {
+		params.FrequencyPenalty = openai.Float(*t.conf.FrequencyPenalty)
+	}
+	if t.conf.PresencePenalty != nil {
+		params.PresencePenalty = openai.Float(*t.conf.PresencePenalty)
+	}
+	t.setExtraParams(&params)
+	return
+}
+
+// setExtraParams adds the extra params of the config to the body.
+func (t *InstanceOpenAI) setExtraParams(params *openai.ChatCompletionNewParams) {
+	if len(t.conf.ExtraParams) > 0 {
+		params.SetExtraFields(t.conf.ExtraParams)
+	}
+}
+
+// systemMessage returns the system prompt followed by the instructions of
+// the request. With cache_control, they are sent as separate parts and the
+// prompt is marked as a cache breakpoint, for providers caching marked
+// prefixes only, e.g. Anthropic. Plain text otherwise, as not every
+// compatible API accepts parts in system messages.
+func (t *InstanceOpenAI) systemMessage(systemPrompt string, instructions []string) (system openai.ChatCompletionSystemMessageParam) {
+	if !t.conf.CacheControl {
+		system.Content.OfString = openai.String(strings.Join(append([]string{systemPrompt}, instructions...), "\n\n"))
+		return
+	}
+	prompt := openai.ChatCompletionContentPartTextParam{Text: systemPrompt}
+	prompt.SetExtraFields(map[string]any{
+		"cache_control": map[string]string{"type": "ephemeral"},
+	})
+	system.Content.OfArrayOfContentParts = []openai.ChatCompletionContentPartTextParam{prompt}
+	for _, i := range instructions {
+		system.Content.OfArrayOfContentParts = append(system.Content.OfArrayOfContentParts,
+			openai.ChatCompletionContentPartTextParam{Text: i})
+	}
+	return
+}
+
+// imageMessage asks for the text in the image, sent inline as a data URL,
+// with the caption as context.
+func (t *InstanceOpenAI) imageMessage(req TranslateRequest) openai.ChatCompletionMessageParamUnion {
+	text := imageText(req)
+	url := fmt.Sprintf("data:%s;base64,%s", req.Image.MimeType, base64.StdEncoding.EncodeToString(req.Image.Data))
+	return openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+		openai.TextContentPart(text),
+		openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: url}),
+	})
+}
+
+// imageText returns the text sent along the image of req.
+func imageText(req TranslateRequest) string {
+	text := "Translate all text in this image."
+	if req.Text != "" {
+		text += "\n\nImage caption:\n" + req.Text
+	}
+	return text
+}
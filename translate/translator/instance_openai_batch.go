@@ -0,0 +1,173 @@
+package translator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// Polling errors back off up to this
+const maxBatchPollInterval = 30 * time.Minute
+
+// Lines of batch input files
+type openAIBatchRequest struct {
+	CustomID string                         `json:"custom_id"`
+	Method   string                         `json:"method"`
+	URL      string                         `json:"url"`
+	Body     openai.ChatCompletionNewParams `json:"body"`
+}
+
+// Lines of batch output files
+type openAIBatchResponse struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int                   `json:"status_code"`
+		Body       openai.ChatCompletion `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// TranslateBatch submits reqs to the Batch API, uploading them as a JSONL
+// file of chat completions, each identified by its index. Images are not
+// supported.
+func (t *InstanceOpenAI) TranslateBatch(ctx context.Context, reqs []TranslateRequest, opts BatchOptions) (resps []*TranslateResponse, errs []error, err error) {
+	var input bytes.Buffer
+	enc := json.NewEncoder(&input)
+	variants := make([]string, len(reqs))
+	models := make([]string, len(reqs))
+	for i, req := range reqs {
+		if req.Image != nil {
+			err = fmt.Errorf("images are not supported in batches")
+			return
+		}
+		var prompt SystemPrompt
+		variants[i], prompt = t.conf.promptFor(req.TraceId)
+		models[i] = t.conf.modelFor(req.Text)
+		err = enc.Encode(openAIBatchRequest{
+			CustomID: strconv.Itoa(i),
+			Method:   "POST",
+			URL:      string(openai.BatchNewParamsEndpointV1ChatCompletions),
+			Body:     t.params(models[i], prompt.For(req.SourceLang), req.SourceLang, requestInstructions(req), openai.UserMessage(req.Text)),
+		})
+		if err != nil {
+			err = fmt.Errorf("encode batch request failed: %w", err)
+			return
+		}
+	}
+
+	var batch *openai.Batch
+	if opts.ID != "" {
+		// Polled after the first interval
+		batch = &openai.Batch{ID: opts.ID}
+		t.logger.Infof("resumed batch '%s' of %d requests", batch.ID, len(reqs))
+	} else {
+		var file *openai.FileObject
+		file, err = t.aiClient.Files.New(ctx, openai.FileNewParams{
+			File:    openai.File(&input, "batch.jsonl", "application/jsonl"),
+			Purpose: openai.FilePurposeBatch,
+		})
+		if err != nil {
+			err = fmt.Errorf("upload batch input failed: %w", err)
+			return
+		}
+		batch, err = t.aiClient.Batches.New(ctx, openai.BatchNewParams{
+			CompletionWindow: openai.BatchNewParamsCompletionWindow24h,
+			Endpoint:         openai.BatchNewParamsEndpointV1ChatCompletions,
+			InputFileID:      file.ID,
+		})
+		if err != nil {
+			err = fmt.Errorf("create batch failed: %w", err)
+			return
+		}
+		t.logger.Infof("submitted batch '%s' of %d requests", batch.ID, len(reqs))
+		if opts.OnSubmit != nil {
+			opts.OnSubmit(batch.ID)
+		}
+	}
+
+	// Transient errors of polling don't fail a batch taking hours, the
+	// interval doubles until a poll succeeds
+	poll := opts.Poll
+	wait := poll
+	for batch.Status != openai.BatchStatusCompleted {
+		switch batch.Status {
+		case openai.BatchStatusFailed, openai.BatchStatusExpired, openai.BatchStatusCancelled:
+			err = fmt.Errorf("batch '%s' %s", batch.ID, batch.Status)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-time.After(wait):
+		}
+		polled, pollErr := t.aiClient.Batches.Get(ctx, batch.ID)
+		if pollErr != nil {
+			wait = min(wait*2, max(poll, maxBatchPollInterval))
+			t.logger.Warnf("get batch '%s' failed, retrying in %s: %v", batch.ID, wait, pollErr)
+			continue
+		}
+		batch, wait = polled, poll
+	}
+	t.logger.Infof("batch '%s' completed", batch.ID)
+
+	resps = make([]*TranslateResponse, len(reqs))
+	errs = make([]error, len(reqs))
+	for i := range errs {
+		errs[i] = fmt.Errorf("no result in batch '%s'", batch.ID)
+	}
+	if batch.OutputFileID == "" {
+		return
+	}
+	output, err := t.aiClient.Files.Content(ctx, batch.OutputFileID)
+	if err != nil {
+		err = fmt.Errorf("download batch output failed: %w", err)
+		return
+	}
+	defer output.Body.Close()
+
+	scanner := bufio.NewScanner(output.Body)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var line openAIBatchResponse
+		if json.Unmarshal(scanner.Bytes(), &line) != nil {
+			continue
+		}
+		i, convErr := strconv.Atoi(line.CustomID)
+		if convErr != nil || i < 0 || i >= len(reqs) {
+			continue
+		}
+		switch {
+		case line.Error != nil:
+			errs[i] = fmt.Errorf("batch request failed: %s: %s", line.Error.Code, line.Error.Message)
+		case line.Response == nil || line.Response.StatusCode != 200:
+			errs[i] = fmt.Errorf("batch request failed")
+		case len(line.Response.Body.Choices) == 0:
+			errs[i] = fmt.Errorf("no choice found in response")
+		default:
+			completion := line.Response.Body
+			resp := new(TranslateResponse)
+			resp.Text = completion.Choices[0].Message.Content
+			resp.Model = models[i]
+			resp.PromptVariant = variants[i]
+			resp.TokenUsage.Completion = completion.Usage.CompletionTokens
+			resp.TokenUsage.Prompt = completion.Usage.PromptTokens
+			resp.TokenUsage.CachedPrompt = completion.Usage.PromptTokensDetails.CachedTokens
+			resps[i], errs[i] = resp, nil
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		err = fmt.Errorf("read batch output failed: %w", err)
+	}
+	return
+}
@@ -0,0 +1,152 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypePapago = "papago"
+
+	defaultPapagoEndpoint = "https://papago.apigw.ntruss.com/nmt/v1/translation"
+
+	papagoHeaderClientID     = "X-NCP-APIGW-API-KEY-ID"
+	papagoHeaderClientSecret = "X-NCP-APIGW-API-KEY"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypePapago, newPapagoInstance)
+}
+
+// PapagoConfig holds the client of papago instances. The client secret
+// is the token.
+type PapagoConfig struct {
+	// Required
+	ClientID string `yaml:"client_id"`
+}
+
+// InstancePapago translates with Naver Papago. It reports the characters
+// sent as prompt tokens, as Papago bills by character.
+type InstancePapago struct {
+	name         string
+	logger       *logrus.Entry
+	client       *http.Client
+	endpoint     string
+	clientId     string
+	clientSecret string
+	targetLang   string
+}
+
+func newPapagoInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	if conf.Papago.ClientID == "" || conf.Token == "" {
+		err = fmt.Errorf("client id and token are required by papago")
+		return
+	}
+	if conf.TargetLang == "" {
+		err = fmt.Errorf("target lang is required by papago")
+		return
+	}
+
+	instance := &InstancePapago{
+		name:         conf.Name,
+		logger:       logger,
+		client:       conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint:     conf.Endpoint,
+		clientId:     conf.Papago.ClientID,
+		clientSecret: conf.Token,
+		targetLang:   papagoLang(conf.TargetLang),
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	if instance.endpoint == "" {
+		instance.endpoint = defaultPapagoEndpoint
+	}
+
+	instance.logger.Debugf("initialized Papago instance, api url: %s", instance.endpoint)
+	return instance, nil
+}
+
+func (t *InstancePapago) Name() string {
+	return t.name
+}
+
+// papagoLang returns the Papago code of an ISO 639-1 code.
+// Chinese is taken as simplified.
+func papagoLang(lang string) string {
+	lang = strings.ToLower(lang)
+	switch lang {
+	case "zh":
+		return "zh-CN"
+	case "zh-tw", "zh-hant":
+		return "zh-TW"
+	}
+	return lang
+}
+
+type papagoRequest struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Text   string `json:"text"`
+}
+
+type papagoResponse struct {
+	Message struct {
+		Result struct {
+			SrcLangType    string `json:"srcLangType"`
+			TranslatedText string `json:"translatedText"`
+		} `json:"result"`
+	} `json:"message"`
+}
+
+func (t *InstancePapago) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	if req.Image != nil {
+		err = fmt.Errorf("papago does not support images")
+		return
+	}
+
+	r := papagoRequest{
+		Source: "auto",
+		Target: t.targetLang,
+		Text:   req.Text,
+	}
+	if req.SourceLang != "" {
+		r.Source = papagoLang(req.SourceLang)
+	}
+	if req.TargetLang != "" {
+		r.Target = papagoLang(req.TargetLang)
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(papagoHeaderClientID, t.clientId)
+	httpReq.Header.Set(papagoHeaderClientSecret, t.clientSecret)
+
+	var result papagoResponse
+	err = common.DoJSON(t.client, httpReq, &result, papagoHeaderClientSecret)
+	if err != nil {
+		err = fmt.Errorf("papago request failed: %w", err)
+		return
+	}
+	t.logger.WithField("trace_id", req.TraceId).
+		Debugf("translated from %s", result.Message.Result.SrcLangType)
+
+	resp = &TranslateResponse{Text: result.Message.Result.TranslatedText}
+	resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	return
+}
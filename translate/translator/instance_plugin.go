@@ -0,0 +1,72 @@
+package translator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/plugin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypePlugin = "plugin"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypePlugin, newPluginInstance)
+}
+
+// InstancePlugin forwards translation requests to an out-of-process plugin.
+type InstancePlugin struct {
+	name       string
+	logger     *logrus.Entry
+	client     *plugin.Client
+	translator *plugin.TranslatorClient
+}
+
+func newPluginInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	client, err := plugin.NewClient(conf.Plugin, logger)
+	if err != nil {
+		return
+	}
+
+	t, err := client.Translator()
+	if err != nil {
+		client.Close()
+		return
+	}
+
+	logger.Debugf("initialized plugin instance: %s", conf.Plugin.Path)
+	return &InstancePlugin{
+		name:       conf.Name,
+		logger:     logger,
+		client:     client,
+		translator: t,
+	}, nil
+}
+
+func (t *InstancePlugin) Name() string {
+	return t.name
+}
+
+func (t *InstancePlugin) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	reply, err := t.translator.Translate(ctx, plugin.TranslateArgs{
+		Text:    req.Text,
+		TraceId: req.TraceId,
+	})
+	if err != nil {
+		err = fmt.Errorf("plugin error: %w", err)
+		return
+	}
+
+	resp = new(TranslateResponse)
+	resp.Text = reply.Text
+	resp.TokenUsage.Completion = reply.CompletionTokens
+	resp.TokenUsage.Prompt = reply.PromptTokens
+	return
+}
+
+// Close terminates the plugin process.
+func (t *InstancePlugin) Close() error {
+	return t.client.Close()
+}
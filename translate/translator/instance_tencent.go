@@ -0,0 +1,217 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypeTencent = "tencent"
+
+	defaultTencentEndpoint = "https://tmt.tencentcloudapi.com"
+	defaultTencentRegion   = "ap-guangzhou"
+
+	tencentService = "tmt"
+	tencentAction  = "TextTranslate"
+	tencentVersion = "2018-03-21"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypeTencent, newTencentInstance)
+}
+
+// TencentConfig holds the credentials of tencent instances. The secret
+// key is the token.
+type TencentConfig struct {
+	// Required
+	SecretID string `yaml:"secret_id"`
+
+	// Optional. ap-guangzhou by default
+	Region string `yaml:"region"`
+}
+
+// InstanceTencent translates with Tencent Machine Translation. It reports
+// the characters sent as prompt tokens, as TMT bills by character.
+type InstanceTencent struct {
+	name       string
+	logger     *logrus.Entry
+	client     *http.Client
+	endpoint   string
+	secretId   string
+	secretKey  string
+	region     string
+	targetLang string
+}
+
+func newTencentInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	if conf.Tencent.SecretID == "" || conf.Token == "" {
+		err = fmt.Errorf("secret id and token are required by tencent")
+		return
+	}
+	if conf.TargetLang == "" {
+		err = fmt.Errorf("target lang is required by tencent")
+		return
+	}
+
+	instance := &InstanceTencent{
+		name:       conf.Name,
+		logger:     logger,
+		client:     conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint:   conf.Endpoint,
+		secretId:   conf.Tencent.SecretID,
+		secretKey:  conf.Token,
+		region:     conf.Tencent.Region,
+		targetLang: tencentLang(conf.TargetLang),
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	if instance.endpoint == "" {
+		instance.endpoint = defaultTencentEndpoint
+	}
+	if instance.region == "" {
+		instance.region = defaultTencentRegion
+	}
+
+	instance.logger.Debugf("initialized Tencent instance, region: %s, api url: %s",
+		instance.region, instance.endpoint)
+	return instance, nil
+}
+
+func (t *InstanceTencent) Name() string {
+	return t.name
+}
+
+// tencentLang returns the TMT code of an ISO 639-1 code.
+func tencentLang(lang string) string {
+	lang = strings.ToLower(lang)
+	switch lang {
+	case "zh-tw", "zh-hant":
+		return "zh-TW"
+	}
+	return lang
+}
+
+type tencentRequest struct {
+	SourceText string `json:"SourceText"`
+	Source     string `json:"Source"`
+	Target     string `json:"Target"`
+	ProjectId  int    `json:"ProjectId"`
+}
+
+type tencentResponse struct {
+	Response struct {
+		TargetText string `json:"TargetText"`
+		Source     string `json:"Source"`
+
+		// Errors are returned with status 200
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+	} `json:"Response"`
+}
+
+func (t *InstanceTencent) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	if req.Image != nil {
+		err = fmt.Errorf("tencent does not support images")
+		return
+	}
+
+	r := tencentRequest{
+		SourceText: req.Text,
+		Source:     "auto",
+		Target:     t.targetLang,
+	}
+	if req.SourceLang != "" {
+		r.Source = tencentLang(req.SourceLang)
+	}
+	if req.TargetLang != "" {
+		r.Target = tencentLang(req.TargetLang)
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json; charset=utf-8")
+	httpReq.Header.Set("X-TC-Action", tencentAction)
+	httpReq.Header.Set("X-TC-Version", tencentVersion)
+	httpReq.Header.Set("X-TC-Region", t.region)
+	signTencent(httpReq, body, t.secretId, t.secretKey, tencentService, time.Now())
+
+	var result tencentResponse
+	err = common.DoJSON(t.client, httpReq, &result, "Authorization")
+	if err == nil && result.Response.Error != nil {
+		err = fmt.Errorf("error %s: %s", result.Response.Error.Code, result.Response.Error.Message)
+	}
+	if err != nil {
+		err = fmt.Errorf("tencent request failed: %w", err)
+		return
+	}
+	if result.Response.TargetText == "" {
+		err = fmt.Errorf("no translation found in response")
+		return
+	}
+	t.logger.WithField("trace_id", req.TraceId).Debugf("translated from %s", result.Response.Source)
+
+	resp = &TranslateResponse{Text: result.Response.TargetText}
+	resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	return
+}
+
+// signTencent signs req with body by Tencent Cloud TC3-HMAC-SHA256,
+// over the content-type and host headers.
+func signTencent(req *http.Request, body []byte, secretId, secretKey, service string, now time.Time) {
+	now = now.UTC()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	date := now.Format("2006-01-02")
+	req.Header.Set("X-TC-Timestamp", timestamp)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	signedHeaders := "content-type;host"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		"content-type:" + strings.ToLower(req.Header.Get("Content-Type")) + "\n" +
+			"host:" + req.URL.Host + "\n",
+		signedHeaders,
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + service + "/tc3_request"
+	stringToSign := strings.Join([]string{
+		"TC3-HMAC-SHA256",
+		timestamp,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("TC3"+secretKey), date)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"TC3-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		secretId, scope, signedHeaders, signature))
+}
@@ -0,0 +1,155 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/common"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	instanceTypeYandex = "yandex"
+
+	defaultYandexEndpoint = "https://translate.api.cloud.yandex.net"
+
+	// Token kinds of Yandex Cloud
+	yandexAuthAPIKey   = "api_key"
+	yandexAuthIAMToken = "iam_token"
+)
+
+func init() {
+	registerTranslatorInstance(instanceTypeYandex, newYandexInstance)
+}
+
+// YandexConfig holds the parameters of yandex instances.
+type YandexConfig struct {
+	// Required. Folder billed for the translations
+	FolderID string `yaml:"folder_id"`
+
+	// Optional. Kind of the token, "api_key" (default) or "iam_token"
+	Auth string `yaml:"auth" redact:"true"`
+}
+
+// InstanceYandex translates with Yandex Translate. It reports the
+// characters sent as prompt tokens, as Yandex bills by character.
+type InstanceYandex struct {
+	name          string
+	logger        *logrus.Entry
+	client        *http.Client
+	endpoint      string
+	authorization string
+	folderId      string
+	targetLang    string
+}
+
+func newYandexInstance(conf TranslatorConfig, logger *logrus.Entry) (c Instance, err error) {
+	if conf.Yandex.FolderID == "" {
+		err = fmt.Errorf("folder id is required by yandex")
+		return
+	}
+	if conf.TargetLang == "" {
+		err = fmt.Errorf("target lang is required by yandex")
+		return
+	}
+	if conf.Token == "" {
+		err = fmt.Errorf("token is required by yandex")
+		return
+	}
+
+	instance := &InstanceYandex{
+		name:       conf.Name,
+		logger:     logger,
+		client:     conf.HTTPClient.NewHTTPClientFromConfig(logger),
+		endpoint:   strings.TrimSuffix(conf.Endpoint, "/"),
+		folderId:   conf.Yandex.FolderID,
+		targetLang: strings.ToLower(conf.TargetLang),
+	}
+	switch conf.Yandex.Auth {
+	case "", yandexAuthAPIKey:
+		instance.authorization = "Api-Key " + conf.Token
+	case yandexAuthIAMToken:
+		instance.authorization = "Bearer " + conf.Token
+	default:
+		err = fmt.Errorf("invalid yandex auth: %s", conf.Yandex.Auth)
+		return
+	}
+	if instance.client == nil {
+		instance.client = http.DefaultClient
+	}
+	if instance.endpoint == "" {
+		instance.endpoint = defaultYandexEndpoint
+	}
+
+	instance.logger.Debugf("initialized Yandex instance, folder: %s, api url: %s",
+		instance.folderId, instance.endpoint)
+	return instance, nil
+}
+
+func (t *InstanceYandex) Name() string {
+	return t.name
+}
+
+type yandexTranslateRequest struct {
+	FolderID           string   `json:"folderId"`
+	Texts              []string `json:"texts"`
+	SourceLanguageCode string   `json:"sourceLanguageCode,omitempty"`
+	TargetLanguageCode string   `json:"targetLanguageCode"`
+	Format             string   `json:"format"`
+}
+
+type yandexTranslateResponse struct {
+	Translations []struct {
+		Text                 string `json:"text"`
+		DetectedLanguageCode string `json:"detectedLanguageCode"`
+	} `json:"translations"`
+}
+
+func (t *InstanceYandex) Translate(ctx context.Context, req TranslateRequest) (resp *TranslateResponse, err error) {
+	if req.Image != nil {
+		err = fmt.Errorf("yandex does not support images")
+		return
+	}
+
+	r := yandexTranslateRequest{
+		FolderID:           t.folderId,
+		Texts:              []string{req.Text},
+		SourceLanguageCode: strings.ToLower(req.SourceLang),
+		TargetLanguageCode: t.targetLang,
+		Format:             "PLAIN_TEXT",
+	}
+	if req.TargetLang != "" {
+		r.TargetLanguageCode = strings.ToLower(req.TargetLang)
+	}
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint+"/translate/v2/translate", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", t.authorization)
+
+	var result yandexTranslateResponse
+	err = common.DoJSON(t.client, httpReq, &result, "Authorization")
+	if err != nil {
+		err = fmt.Errorf("yandex request failed: %w", err)
+		return
+	}
+	if len(result.Translations) == 0 {
+		err = fmt.Errorf("no translation found in response")
+		return
+	}
+
+	resp = &TranslateResponse{Text: result.Translations[0].Text}
+	resp.TokenUsage.Prompt = int64(utf8.RuneCountInString(req.Text))
+	return
+}
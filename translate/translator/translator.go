@@ -2,9 +2,13 @@ package translator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"github.com/4O4-Not-F0und/Gura-Bot/metrics"
 	"github.com/4O4-Not-F0und/Gura-Bot/selector"
@@ -20,8 +24,9 @@ const (
 	translationStateSuccess    = "success"
 	translationStateFailed     = "failed"
 
-	translationTokenUsedTypeCompletion = "completion"
-	translationTokenUsedTypePrompt     = "prompt"
+	translationTokenUsedTypeCompletion   = "completion"
+	translationTokenUsedTypePrompt       = "prompt"
+	translationTokenUsedTypeCachedPrompt = "cached_prompt"
 )
 
 var (
@@ -35,12 +40,13 @@ var (
 	allTranslationTokenUsedTypes = []string{
 		translationTokenUsedTypeCompletion,
 		translationTokenUsedTypePrompt,
+		translationTokenUsedTypeCachedPrompt,
 	}
 
 	registeredTranslatorInstances = map[string]newTranslatorInstanceFunc{}
 )
 
-type newTranslatorInstanceFunc func(TranslatorConfig) (Instance, error)
+type newTranslatorInstanceFunc func(TranslatorConfig, *logrus.Entry) (Instance, error)
 
 func registerTranslatorInstance(name string, f newTranslatorInstanceFunc) {
 	if _, ok := registeredTranslatorInstances[name]; !ok {
@@ -50,109 +56,242 @@ func registerTranslatorInstance(name string, f newTranslatorInstanceFunc) {
 	panic(fmt.Sprintf("translator instance type '%s' already registered", name))
 }
 
-func NewInstance(conf TranslatorConfig) (Instance, error) {
+func NewInstance(conf TranslatorConfig, logger *logrus.Entry) (Instance, error) {
 	if f, ok := registeredTranslatorInstances[conf.Type]; ok {
-		return f(conf)
+		return f(conf, logger.WithField("translator_instance", conf.Name))
 	}
 	return nil, fmt.Errorf("unknown translator type: %s", conf.Type)
 }
 
-func NewTranslator(selectorType string, conf TranslatorConfig) (Translator, error) {
-	instance, err := NewInstance(conf)
+// NewTranslator creates a translator from conf. inFlight is optional and
+// may be shared with other translators and detectors.
+func NewTranslator(selectorType string, conf TranslatorConfig, logger *logrus.Entry, m *metrics.Metrics, inFlight *common.InFlightLimiter, onDisabled common.DisabledFunc) (Translator, error) {
+	instance, err := NewInstance(conf, logger)
+	if err != nil {
+		return nil, err
+	}
+	if conf.TokenFile != "" {
+		instance = newRotatingInstance(conf, instance, logger)
+	}
+	schedule, err := conf.Schedule.NewSchedule()
 	if err != nil {
 		return nil, err
 	}
 
 	opts := TranslatorOptions{
 		Instance:         instance,
+		Logger:           logger,
 		Timeout:          conf.Timeout,
-		UpMetric:         metrics.MetricTranslatorUp,
-		SelectionMetric:  metrics.MetricTranslatorSelectionTotal,
-		TasksMetric:      metrics.MetricTranslatorTasks,
-		TokensUsedMetric: metrics.MetricTranslatorTokensUsed,
+		UpMetric:         m.TranslatorUp,
+		HealthMetric:     m.TranslatorHealth,
+		SelectionMetric:  m.TranslatorSelectionTotal,
+		TasksMetric:      m.TranslatorTasks,
+		TokensUsedMetric: m.TranslatorTokensUsed,
+		WaitMetric:       m.LimiterWait,
 		FailoverConfig:   conf.Failover,
 		RateLimitConfig:  conf.RateLimit,
+		InFlight:         inFlight,
+		OnDisabled:       onDisabled,
+		FaultInjection:   conf.FaultInjection,
 		Weight:           conf.Weight,
+		Priority:         conf.Priority,
+		Schedule:         schedule,
+		UnitCost:         conf.Pricing.Prompt + conf.Pricing.Completion,
+		DailyBudget:      conf.DailyBudget,
+		Vision:           conf.Vision,
+		TrimAfter:        conf.TrimAfter,
+		TargetLang:       conf.TargetLang,
 	}
 
 	switch selectorType {
-	case selector.WRR, selector.FALLBACK:
+	case selector.WRR, selector.FALLBACK, selector.LEAST_LATENCY, selector.LEAST_COST, selector.PRIORITY, selector.CONSISTENT_HASH, selector.SCHEDULE:
 		return NewCommonTranslator(opts), nil
 	}
 	return nil, fmt.Errorf("unrecognized translator selector: %s", selectorType)
 }
 
+// ErrUnsupportedLanguage is returned by instances unable to translate the
+// source language of a request. It doesn't count as a failure.
+var ErrUnsupportedLanguage = errors.New("unsupported language")
+
 type TranslateRequest struct {
 	Text    string
 	TraceId string
+
+	// Optional. Detected language of Text, choosing the system prompt
+	SourceLang string
+
+	// Optional. Text of the message replied to, given to LLM translators
+	// as context only
+	Context string
+
+	// Optional. Describes the sender and chat, given to LLM translators
+	Metadata string
+
+	// Optional. ISO 639-1 code of the language to translate into instead
+	// of the prompt's, e.g. for back-translation. LLM translators only
+	TargetLang string
+
+	// Optional. Image whose text is translated, Text is its caption
+	Image *Image
+
+	// Optional. Key of the consistent_hash selector, e.g. the chat ID, so
+	// a chat keeps getting the same translator
+	RoutingKey string
+}
+
+type Image struct {
+	Data     []byte
+	MimeType string
 }
 
 type TranslateResponse struct {
-	Text       string
+	Text string
+
+	// Optional. Model that translated, if the instance picks one
+	Model string
+
+	// Optional. Language translated into, as configured
+	TargetLang string
+
+	// Optional. Name of the prompt variant that translated
+	PromptVariant string
+
 	TokenUsage struct {
 		Completion int64
 		Prompt     int64
+
+		// Prompt tokens read from the provider's prompt cache
+		CachedPrompt int64
 	}
 }
 
 type TranslatorOptions struct {
 	Instance Instance
+	Logger   *logrus.Entry
 	Timeout  int64
 
 	// Failover
 	FailoverConfig  common.FailoverConfig
 	RateLimitConfig common.RateLimitConfig
 
+	// Optional. Shared limit of upstream calls
+	InFlight *common.InFlightLimiter
+
+	// Optional. Called when failover disables the translator
+	OnDisabled common.DisabledFunc
+
+	// Optional. Testing only
+	FaultInjection common.FaultInjectionConfig
+
 	// Metrics
 	UpMetric         *prometheus.GaugeVec
+	HealthMetric     *prometheus.GaugeVec
 	SelectionMetric  *prometheus.CounterVec
 	TasksMetric      *prometheus.GaugeVec
 	TokensUsedMetric *prometheus.CounterVec
+	WaitMetric       *prometheus.HistogramVec
 
 	// WRR
 	Weight int
+
+	// Priority
+	Priority int
+
+	// Optional. Time windows, always active if nil
+	Schedule *common.Schedule
+
+	// Least cost
+	UnitCost    float64
+	DailyBudget float64
+
+	// Capabilities
+	Vision bool
+
+	// Optional. Delimiters after which answers are cut
+	TrimAfter []string
+
+	// Optional. Language translated into
+	TargetLang string
 }
 
 type Translator interface {
-	selector.WeightedItem
+	selector.PriorityItem
+	selector.HealthItem
+	selector.CostItem
+	selector.ScheduledItem
 
-	Translate(TranslateRequest) (*TranslateResponse, error)
+	Translate(context.Context, TranslateRequest) (*TranslateResponse, error)
 	GetName() string
+	Close() error
+
+	// Vision reports whether requests may contain images.
+	Vision() bool
+
+	FailoverStatus() common.FailoverStatus
 }
 
 type CommonTranslator struct {
 	instance        Instance
 	logger          *logrus.Entry
 	limiter         *rate.Limiter
+	inFlight        *common.InFlightLimiter
 	timeout         time.Duration
 	failoverHandler common.FailoverHandler
+	faultInjector   *common.FaultInjector
+	health          *common.HealthTracker
 
 	// Metrics
 	upMetric         *prometheus.GaugeVec
 	selectionMetric  *prometheus.CounterVec
 	tasksMetric      *prometheus.GaugeVec
 	tokensUsedMetric *prometheus.CounterVec
+	waitMetric       *prometheus.HistogramVec
 
 	// Weighted
 	configWeight  int
 	currentWeight int
 	weightedMu    *sync.Mutex
+	priority      int
+	schedule      *common.Schedule
+
+	// Least cost
+	unitCost    float64
+	dailyBudget float64
+
+	vision     bool
+	trimAfter  []string
+	targetLang string
+	onDisabled common.DisabledFunc
 }
 
 func NewCommonTranslator(opts TranslatorOptions) (ct *CommonTranslator) {
 	ct = &CommonTranslator{
 		instance: opts.Instance,
+		inFlight: opts.InFlight,
 		timeout:  time.Duration(opts.Timeout) * time.Second,
 
 		upMetric:         opts.UpMetric,
 		selectionMetric:  opts.SelectionMetric,
 		tasksMetric:      opts.TasksMetric,
 		tokensUsedMetric: opts.TokensUsedMetric,
+		waitMetric:       opts.WaitMetric,
 
 		// Weighted
 		configWeight:  opts.Weight,
 		currentWeight: 0,
 		weightedMu:    &sync.Mutex{},
+		priority:      opts.Priority,
+		schedule:      opts.Schedule,
+
+		// Least cost
+		unitCost:    opts.UnitCost,
+		dailyBudget: opts.DailyBudget,
+
+		vision:     opts.Vision,
+		trimAfter:  opts.TrimAfter,
+		targetLang: opts.TargetLang,
+		onDisabled: opts.OnDisabled,
 	}
 	// Initialize metrics
 	ct.upMetric.WithLabelValues(ct.GetName()).Set(1)
@@ -164,23 +303,28 @@ func NewCommonTranslator(opts TranslatorOptions) (ct *CommonTranslator) {
 		ct.tokensUsedMetric.WithLabelValues(t, ct.GetName()).Add(0.0)
 	}
 
-	ct.logger = logrus.WithField("translator_name", ct.GetName())
+	ct.logger = opts.Logger.WithField("translator_name", ct.GetName())
 	ct.failoverHandler = common.NewGeneralFailoverHandler(opts.FailoverConfig, ct.logger)
 	ct.limiter = opts.RateLimitConfig.NewLimiterFromConfig(ct.logger)
+	ct.faultInjector = opts.FaultInjection.NewFaultInjectorFromConfig(ct.logger)
+	var healthGauge prometheus.Gauge
+	if opts.HealthMetric != nil {
+		healthGauge = opts.HealthMetric.WithLabelValues(ct.GetName())
+	}
+	ct.health = common.NewHealthTracker(ct.timeout, healthGauge)
 	return
 }
 
+// wait waits for the rate limiter, then for an in flight slot,
+// which must be released afterwards.
 func (ct *CommonTranslator) wait(ctx context.Context) (err error) {
-	if ct.limiter != nil {
-		err = ct.limiter.Wait(ctx)
-	}
-	return
+	return common.WaitLimiters(ctx, ct.limiter, ct.inFlight, ct.waitMetric, "translator", ct.GetName())
 }
 
-func (ct *CommonTranslator) Translate(req TranslateRequest) (tr *TranslateResponse, err error) {
+func (ct *CommonTranslator) Translate(ctx context.Context, req TranslateRequest) (tr *TranslateResponse, err error) {
 	ct.selectionMetric.WithLabelValues(ct.GetName()).Inc()
 
-	ctx, cancel := context.WithTimeout(context.Background(), ct.timeout)
+	ctx, cancel := context.WithTimeout(ctx, ct.timeout)
 	defer cancel()
 
 	logger := ct.logger.WithField("trace_id", req.TraceId)
@@ -193,12 +337,18 @@ func (ct *CommonTranslator) Translate(req TranslateRequest) (tr *TranslateRespon
 		return nil, fmt.Errorf("rate limiter wait failed: %w", err)
 	}
 	logger.Trace("acquired limiter")
+	defer ct.inFlight.Release()
 
 	ct.tasksMetric.WithLabelValues(translationStateProcessing, ct.GetName()).Inc()
 	defer ct.tasksMetric.WithLabelValues(translationStateProcessing, ct.GetName()).Dec()
 
 	logger.Debug("wating for translate response")
-	tr, err = ct.instance.Translate(ctx, req)
+	start := time.Now()
+	err = ct.faultInjector.Inject(ctx)
+	if err == nil {
+		tr, err = ct.instance.Translate(ctx, req)
+	}
+	latency := time.Since(start)
 	if tr != nil {
 		ct.tokensUsedMetric.WithLabelValues(
 			translationTokenUsedTypeCompletion, ct.GetName()).Add(
@@ -206,16 +356,68 @@ func (ct *CommonTranslator) Translate(req TranslateRequest) (tr *TranslateRespon
 		ct.tokensUsedMetric.WithLabelValues(
 			translationTokenUsedTypePrompt, ct.GetName()).Add(
 			float64(tr.TokenUsage.Prompt))
+		ct.tokensUsedMetric.WithLabelValues(
+			translationTokenUsedTypeCachedPrompt, ct.GetName()).Add(
+			float64(tr.TokenUsage.CachedPrompt))
+	}
+
+	if err == nil {
+		tr.Text, err = trimAfter(tr.Text, ct.trimAfter)
 	}
 
+	if errors.Is(err, ErrUnsupportedLanguage) {
+		// Not a fault of the upstream
+		return
+	}
+	ct.health.Observe(err == nil, latency)
 	if err != nil {
 		ct.onFailure()
 		return
 	}
+	tr.TargetLang = ct.targetLang
 	ct.onSuccess()
 	return
 }
 
+// trimAfter cuts text at the first of delims, failing if nothing is left.
+func trimAfter(text string, delims []string) (string, error) {
+	if len(delims) == 0 {
+		return text, nil
+	}
+	for _, d := range delims {
+		if i := strings.Index(text, d); i >= 0 {
+			text = text[:i]
+		}
+	}
+	text = strings.TrimRightFunc(text, unicode.IsSpace)
+	if text == "" {
+		return "", fmt.Errorf("translation is empty after trimming")
+	}
+	return text, nil
+}
+
+// Close releases resources held by the underlying instance, if any.
+func (ct *CommonTranslator) Close() error {
+	if c, ok := ct.instance.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (ct *CommonTranslator) Vision() bool {
+	return ct.vision
+}
+
+// GetUnitCost returns the sum of the configured prompt and completion
+// prices.
+func (ct *CommonTranslator) GetUnitCost() float64 {
+	return ct.unitCost
+}
+
+func (ct *CommonTranslator) GetDailyBudget() float64 {
+	return ct.dailyBudget
+}
+
 func (ct *CommonTranslator) GetName() string {
 	return ct.instance.Name()
 }
@@ -230,13 +432,33 @@ func (ct *CommonTranslator) onFailure() {
 	ct.tasksMetric.WithLabelValues(translationStateFailed, ct.GetName()).Inc()
 	if ct.failoverHandler.OnFailure() {
 		ct.upMetric.WithLabelValues(ct.GetName()).Set(0)
+		if ct.onDisabled != nil {
+			ct.onDisabled(ct.GetName(), ct.failoverHandler.Status().PermanentlyDisabled)
+		}
 	}
 }
 
+func (ct *CommonTranslator) FailoverStatus() common.FailoverStatus {
+	return ct.failoverHandler.Status()
+}
+
 func (ct *CommonTranslator) IsDisabled() bool {
 	return ct.failoverHandler.IsDisabled()
 }
 
+func (ct *CommonTranslator) HealthScore() float64 {
+	return ct.health.Score()
+}
+
+func (ct *CommonTranslator) GetPriority() int {
+	return ct.priority
+}
+
+// IsActive reports whether t is within the schedule of the translator.
+func (ct *CommonTranslator) IsActive(t time.Time) bool {
+	return ct.schedule.Active(t)
+}
+
 func (ct *CommonTranslator) GetConfigWeight() int {
 	ct.weightedMu.Lock()
 	defer ct.weightedMu.Unlock()
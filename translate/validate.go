@@ -0,0 +1,135 @@
+package translate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/translator"
+)
+
+// ErrInvalidOutput is returned if translations were rejected by the
+// output validation.
+var ErrInvalidOutput = errors.New("invalid translation output")
+
+// Reasons of rejected translations
+const (
+	// The translator refused to translate
+	rejectReasonRefusal = "refusal"
+	// The translation is too short or too long for the text
+	rejectReasonLength = "length"
+	// The translation scored below the quality threshold
+	rejectReasonScore = "quality_score"
+)
+
+// Texts shorter are not checked by length ratio, as translations of
+// a few characters vary too much in length
+const minRatioTextLength = 10
+
+type ValidationConfig struct {
+	// Optional. Regular expressions matching refusals, e.g.
+	// "(?i)^I (can't|cannot) translate"
+	RefusalPatterns []string `yaml:"refusal_patterns"`
+
+	// Optional. Bounds of the length of translations relative to the
+	// text, in characters, unchecked if 0
+	MinLengthRatio float64 `yaml:"min_length_ratio"`
+	MaxLengthRatio float64 `yaml:"max_length_ratio"`
+
+	// Translate rejected outputs again with the next translators before
+	// failing
+	Retry bool `yaml:"retry"`
+
+	refusals []*regexp.Regexp
+}
+
+func (vc *ValidationConfig) Check() (err error) {
+	vc.refusals = nil
+	for _, p := range vc.RefusalPatterns {
+		var re *regexp.Regexp
+		re, err = regexp.Compile(p)
+		if err != nil {
+			err = fmt.Errorf("output validation: invalid refusal pattern '%s': %w", p, err)
+			return
+		}
+		vc.refusals = append(vc.refusals, re)
+	}
+	if vc.MinLengthRatio < 0 || vc.MaxLengthRatio < 0 {
+		err = fmt.Errorf("output validation: length ratios must not be negative")
+		return
+	}
+	if vc.MaxLengthRatio > 0 && vc.MinLengthRatio >= vc.MaxLengthRatio {
+		err = fmt.Errorf("output validation: min length ratio must be below the max")
+	}
+	return
+}
+
+// reject returns the reason translation of text is rejected, "" if it
+// passes.
+func (vc *ValidationConfig) reject(text, translation string) string {
+	for _, re := range vc.refusals {
+		if re.MatchString(translation) {
+			return rejectReasonRefusal
+		}
+	}
+	length := utf8.RuneCountInString(text)
+	if length < minRatioTextLength {
+		return ""
+	}
+	ratio := float64(utf8.RuneCountInString(translation)) / float64(length)
+	if (vc.MinLengthRatio > 0 && ratio < vc.MinLengthRatio) ||
+		(vc.MaxLengthRatio > 0 && ratio > vc.MaxLengthRatio) {
+		return rejectReasonLength
+	}
+	return ""
+}
+
+// validated checks resp of translator name against the output validation.
+// With retries enabled, rejected outputs are translated again by the next
+// translators in turn. Fails once no translation passes.
+func (ts *TranslateService) validated(ctx context.Context, req translator.TranslateRequest, name string, resp *translator.TranslateResponse) (*translator.TranslateResponse, string, error) {
+	reason := ts.validation.reject(req.Text, resp.Text)
+	if reason == "" {
+		return resp, name, nil
+	}
+
+	logger := ts.logger.WithField("trace_id", req.TraceId)
+	if ts.validation.Retry {
+		for _, t := range ts.nextTranslators(name) {
+			ts.metrics.QualityRetries.WithLabelValues(name, reason).Inc()
+			logger.WithField("translator_name", name).
+				Warnf("translation rejected as %s, translating again with '%s'", reason, t.GetName())
+			next, err := ts.translateProtected(ctx, t, req)
+			if err != nil {
+				logger.WithField("translator_name", t.GetName()).Warnf("retry of rejected translation failed: %v", err)
+				continue
+			}
+			resp, name = next, t.GetName()
+			reason = ts.validation.reject(req.Text, resp.Text)
+			if reason == "" {
+				return resp, name, nil
+			}
+		}
+	}
+	return nil, name, fmt.Errorf("%w: %s", ErrInvalidOutput, reason)
+}
+
+// nextTranslators returns the enabled translators taking selections other
+// than name, in config order starting after name.
+func (ts *TranslateService) nextTranslators(name string) (next []translator.Translator) {
+	start := 0
+	for i, t := range ts.selectable {
+		if t.GetName() == name {
+			start = i + 1
+		}
+	}
+	for i := range ts.selectable {
+		t := ts.selectable[(start+i)%len(ts.selectable)]
+		if t.GetName() != name && !t.IsDisabled() {
+			next = append(next, t)
+		}
+	}
+	return
+}
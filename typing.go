@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// TypingAdapter is implemented by adapters able to show a typing indicator.
+type TypingAdapter interface {
+	// SendTyping shows the typing indicator in the chat of msg
+	// for a few seconds.
+	SendTyping(msg *Message) error
+}
+
+type TypingConfig struct {
+	// Show the typing indicator while a message is pending or processing
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Chat types in which the indicator is shown, all if empty
+	ChatTypes []string `yaml:"chat_types"`
+
+	// Positive. Seconds between refreshes of the indicator
+	Interval int `yaml:"interval"`
+}
+
+func (tc *TypingConfig) Check() (err error) {
+	if tc.Enabled && tc.Interval <= 0 {
+		err = fmt.Errorf("typing interval must be positive")
+	}
+	return
+}
+
+// startTyping shows the typing indicator for msg until it is processed.
+func (b *Bot) startTyping(msg *Message) {
+	ta, ok := msg.adapter.(TypingAdapter)
+	if !ok || msg.stopTyping != nil {
+		return
+	}
+
+	b.configMu.RLock()
+	conf := b.typing
+	b.configMu.RUnlock()
+
+	if !conf.Enabled || (len(conf.ChatTypes) > 0 && !slices.Contains(conf.ChatTypes, msg.ChatType)) {
+		return
+	}
+	if !b.isAllowed(msg) {
+		return
+	}
+
+	stop := make(chan struct{})
+	msg.stopTyping = stop
+	go func() {
+		ticker := time.NewTicker(time.Duration(conf.Interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			if err := ta.SendTyping(msg); err != nil {
+				msg.logger.Debugf("an error occurred while sending typing action: %v", err)
+			}
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// endTyping stops refreshing the typing indicator of msg.
+func (m *Message) endTyping() {
+	if m.stopTyping != nil {
+		close(m.stopTyping)
+		m.stopTyping = nil
+	}
+}
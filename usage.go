@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const usageDayLayout = "2006-01-02"
+
+// usageState holds the consumption of chats, persisted by the StateStore.
+type usageState struct {
+	// Daily usage by usageChatKey, then by UTC day. Days before the
+	// previous month are dropped.
+	Chats map[string]map[string]*chatUsage `json:"chats"`
+
+	// Daily usage by translator name, then by UTC day
+	Translators map[string]map[string]*chatUsage `json:"translators"`
+
+	// Failover incidents by UTC day, then by component and name, e.g.
+	// "translator:gpt-4o-mini"
+	Failovers map[string]map[string]*failoverCount `json:"failovers"`
+}
+
+type failoverCount struct {
+	// Times disabled for a cooldown or permanently
+	Cooldowns int64 `json:"cooldowns"`
+	Permanent int64 `json:"permanent"`
+}
+
+type chatUsage struct {
+	Messages         int64   `json:"messages"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+func (us *usageState) init() {
+	if us.Chats == nil {
+		us.Chats = make(map[string]map[string]*chatUsage)
+	}
+	if us.Translators == nil {
+		us.Translators = make(map[string]map[string]*chatUsage)
+	}
+	if us.Failovers == nil {
+		us.Failovers = make(map[string]map[string]*failoverCount)
+	}
+}
+
+func (cu *chatUsage) add(u chatUsage) {
+	cu.Messages += u.Messages
+	cu.PromptTokens += u.PromptTokens
+	cu.CompletionTokens += u.CompletionTokens
+	cu.Cost += u.Cost
+}
+
+func usageChatKey(platform string, chatId int64) string {
+	return fmt.Sprintf("%s:%d", platform, chatId)
+}
+
+// oldestUsageDay returns the first day of the previous month, the oldest
+// day of usage kept for reference.
+func oldestUsageDay(now time.Time) string {
+	return time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC).Format(usageDayLayout)
+}
+
+// addDailyUsage adds u to the usage of key today.
+func addDailyUsage(usage map[string]map[string]*chatUsage, key string, now time.Time, u chatUsage) {
+	day := now.Format(usageDayLayout)
+	days := usage[key]
+	if days == nil {
+		days = make(map[string]*chatUsage)
+		usage[key] = days
+	}
+	du, ok := days[day]
+	if !ok {
+		du = new(chatUsage)
+		days[day] = du
+
+		oldest := oldestUsageDay(now)
+		for d := range days {
+			if d < oldest {
+				delete(days, d)
+			}
+		}
+	}
+	du.add(u)
+}
+
+// recordUsage adds a translated message to the usage of its chat and of
+// the translator.
+func (b *Bot) recordUsage(msg *Message, translatorName string, prompt, completion int64, cost float64) {
+	now := time.Now().UTC()
+	u := chatUsage{
+		Messages:         1,
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		Cost:             cost,
+	}
+	b.state.update(func(state *botState) {
+		addDailyUsage(state.Usage.Chats, usageChatKey(msg.Platform, msg.ChatID), now, u)
+		if translatorName != "" {
+			addDailyUsage(state.Usage.Translators, translatorName, now, u)
+		}
+	})
+}
+
+// recordFailover counts a component disabled by failover today.
+func (b *Bot) recordFailover(component, name string, permanent bool) {
+	now := time.Now().UTC()
+	day := now.Format(usageDayLayout)
+	b.state.update(func(state *botState) {
+		counts := state.Usage.Failovers[day]
+		if counts == nil {
+			counts = make(map[string]*failoverCount)
+			state.Usage.Failovers[day] = counts
+
+			oldest := oldestUsageDay(now)
+			for d := range state.Usage.Failovers {
+				if d < oldest {
+					delete(state.Usage.Failovers, d)
+				}
+			}
+		}
+		key := component + ":" + name
+		c := counts[key]
+		if c == nil {
+			c = new(failoverCount)
+			counts[key] = c
+		}
+		if permanent {
+			c.Permanent++
+		} else {
+			c.Cooldowns++
+		}
+	})
+}
+
+// handleUsageCommand replies the usage of the chat today and this month.
+func (b *Bot) handleUsageCommand(msg *Message, _ string) {
+	if !b.requireAdmin(msg) {
+		return
+	}
+
+	now := time.Now().UTC()
+	today := now.Format(usageDayLayout)
+	month := now.Format("2006-01")
+
+	var day, total chatUsage
+	b.state.view(func(state *botState) {
+		for d, u := range state.Usage.Chats[usageChatKey(msg.Platform, msg.ChatID)] {
+			if d == today {
+				day.add(*u)
+			}
+			if strings.HasPrefix(d, month) {
+				total.add(*u)
+			}
+		}
+	})
+	line := func(u chatUsage) string {
+		return b.text(msg, msgUsageLine,
+			u.Messages, u.PromptTokens+u.CompletionTokens, u.PromptTokens, u.CompletionTokens, u.Cost)
+	}
+	b.replyText(msg, b.text(msg, msgUsage, line(day), line(total)))
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+type UserRateLimitConfig struct {
+	// Optional. Messages each user may have translated per minute over all
+	// chats, unlimited if 0
+	PerMinute float64 `yaml:"per_minute"`
+
+	// Positive if limited. Messages a user may send at once
+	Burst int `yaml:"burst"`
+}
+
+func (uc *UserRateLimitConfig) Check() (err error) {
+	if uc.PerMinute < 0 {
+		err = fmt.Errorf("user rate limit must not be negative")
+		return
+	}
+	if uc.PerMinute > 0 && uc.Burst <= 0 {
+		err = fmt.Errorf("user rate limit burst must be positive")
+	}
+	return
+}
+
+type userLimitKey struct {
+	platform string
+	userId   int64
+}
+
+// userLimiter keeps a token bucket per user. Buckets are dropped once
+// full again, so idle users take no memory.
+type userLimiter struct {
+	mu        sync.Mutex
+	conf      UserRateLimitConfig
+	limiters  map[userLimitKey]*rate.Limiter
+	lastSweep time.Time
+}
+
+func newUserLimiter() *userLimiter {
+	return &userLimiter{
+		limiters: make(map[userLimitKey]*rate.Limiter),
+	}
+}
+
+// allow reports whether the sender of msg is within conf.
+// Buckets are reset when conf changes.
+func (ul *userLimiter) allow(msg *Message, conf UserRateLimitConfig) bool {
+	if conf.PerMinute == 0 || msg.UserID == 0 {
+		return true
+	}
+
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+
+	now := time.Now()
+	if conf != ul.conf {
+		ul.conf = conf
+		clear(ul.limiters)
+	}
+	if now.Sub(ul.lastSweep) > time.Minute {
+		for k, l := range ul.limiters {
+			if l.TokensAt(now) >= float64(conf.Burst) {
+				delete(ul.limiters, k)
+			}
+		}
+		ul.lastSweep = now
+	}
+
+	key := userLimitKey{platform: msg.Platform, userId: msg.UserID}
+	l, ok := ul.limiters[key]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(conf.PerMinute/60), conf.Burst)
+		ul.limiters[key] = l
+	}
+	return l.AllowN(now, 1)
+}
+
+// checkUserRate reports whether the sender of msg is within the user rate
+// limit. Unauthorized messages are left to handleMessage.
+func (b *Bot) checkUserRate(msg *Message) bool {
+	b.configMu.RLock()
+	conf := b.userRateLimit
+	b.configMu.RUnlock()
+	return !b.isAllowed(msg) || b.userLimiter.allow(msg, conf)
+}
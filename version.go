@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Build metadata, injected via -ldflags "-X main.version=..."
+var (
+	version   = "dev"
+	gitCommit = "unknown"
+	buildDate = "unknown"
+)
+
+func versionString() string {
+	return fmt.Sprintf("gura_bot %s (commit: %s, built: %s, %s %s/%s)",
+		version, gitCommit, buildDate, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
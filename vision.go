@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+type VisionConfig struct {
+	// Send photos to vision capable translators
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Chat IDs in which photos are translated, all authorized chats if empty
+	Chats []int64 `yaml:"chats"`
+
+	// Positive. Larger photos are ignored
+	MaxSizeKB int64 `yaml:"max_size_kb"`
+}
+
+func (vc *VisionConfig) Check() (err error) {
+	if vc.Enabled && vc.MaxSizeKB <= 0 {
+		err = fmt.Errorf("vision max size must be positive")
+	}
+	return
+}
+
+// accepts reports whether the photo of msg should be translated.
+func (vc *VisionConfig) accepts(msg *Message) bool {
+	if !vc.Enabled || msg.Photo == nil || msg.Photo.Size > vc.MaxSizeKB*1024 {
+		return false
+	}
+	if len(vc.Chats) > 0 && !slices.Contains(vc.Chats, msg.ChatID) {
+		return false
+	}
+	_, ok := msg.adapter.(DocumentAdapter)
+	return ok
+}
+
+// downloadPhoto fetches the photo of msg once, keeping it for retries.
+func (b *Bot) downloadPhoto(msg *Message) (err error) {
+	if msg.image != nil {
+		return
+	}
+
+	b.configMu.RLock()
+	limit := b.vision.MaxSizeKB * 1024
+	b.configMu.RUnlock()
+
+	data, err := msg.adapter.(DocumentAdapter).DownloadDocument(msg.Photo, limit)
+	if err != nil {
+		err = fmt.Errorf("download photo failed: %w", err)
+		return
+	}
+	msg.image = data
+	msg.imageType = http.DetectContentType(data)
+	return
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate"
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/transcriber"
+)
+
+type VoiceConfig struct {
+	// Transcribe voice notes with the configured transcribers, then translate them
+	Enabled bool `yaml:"enabled"`
+
+	// Optional. Chat IDs in which voice notes are transcribed, all authorized chats if empty
+	Chats []int64 `yaml:"chats"`
+
+	// Positive. Larger voice notes are ignored
+	MaxSizeKB int64 `yaml:"max_size_kb"`
+}
+
+func (vc *VoiceConfig) Check() (err error) {
+	if vc.Enabled && vc.MaxSizeKB <= 0 {
+		err = fmt.Errorf("voice max size must be positive")
+	}
+	return
+}
+
+// accepts reports whether the voice note of msg should be transcribed.
+func (vc *VoiceConfig) accepts(msg *Message) bool {
+	if !vc.Enabled || msg.Voice == nil || msg.Voice.Size > vc.MaxSizeKB*1024 {
+		return false
+	}
+	if len(vc.Chats) > 0 && !slices.Contains(vc.Chats, msg.ChatID) {
+		return false
+	}
+	_, ok := msg.adapter.(DocumentAdapter)
+	return ok
+}
+
+// transcribeVoice sets the content of msg to the transcription of its voice note.
+func (b *Bot) transcribeVoice(ctx context.Context, msg *Message, ts *translate.TranslateService) (err error) {
+	b.configMu.RLock()
+	limit := b.voice.MaxSizeKB * 1024
+	b.configMu.RUnlock()
+
+	audio, err := msg.adapter.(DocumentAdapter).DownloadDocument(msg.Voice, limit)
+	if err != nil {
+		err = fmt.Errorf("download voice note failed: %w", err)
+		return
+	}
+
+	resp, name, err := ts.Transcribe(ctx, transcriber.TranscribeRequest{
+		Audio:    audio,
+		FileName: msg.Voice.Name,
+		TraceId:  msg.TraceId,
+	})
+	if err != nil {
+		err = fmt.Errorf("transcribe voice note failed: %w", err)
+		return
+	}
+	msg.logger = msg.logger.WithField("transcriber_name", name)
+	msg.Content = resp.Text
+	return
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/4O4-Not-F0und/Gura-Bot/translate/detector"
+)
+
+const (
+	// Post to the webhook and reply in chat
+	webhookOutModeAppend = "append"
+	// Post to the webhook only
+	webhookOutModeReplace = "replace"
+)
+
+type WebhookOutConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Required if enabled
+	URL string `yaml:"url"`
+
+	// Optional. Extra HTTP headers, e.g. Authorization
+	Headers map[string]string `yaml:"headers"`
+
+	// Positive. Timeout in seconds
+	Timeout int64 `yaml:"timeout"`
+
+	// "append" (default) or "replace"
+	Mode string `yaml:"mode"`
+}
+
+func (wc *WebhookOutConfig) Check() (err error) {
+	if !wc.Enabled {
+		return
+	}
+	if wc.URL == "" {
+		err = fmt.Errorf("webhook_out: url is required")
+		return
+	}
+	if wc.Timeout <= 0 {
+		err = fmt.Errorf("webhook_out: timeout must be positive")
+		return
+	}
+	switch wc.Mode {
+	case "":
+		wc.Mode = webhookOutModeAppend
+	case webhookOutModeAppend, webhookOutModeReplace:
+	default:
+		err = fmt.Errorf("webhook_out: unrecognized mode: %s", wc.Mode)
+	}
+	return
+}
+
+// WebhookOutPayload is the JSON body posted for each completed translation.
+type WebhookOutPayload struct {
+	Platform  string `json:"platform"`
+	ChatID    int64  `json:"chat_id"`
+	ChatType  string `json:"chat_type"`
+	UserID    int64  `json:"user_id,omitempty"`
+	MessageID int64  `json:"message_id"`
+	TraceId   string `json:"trace_id"`
+
+	Original           string                       `json:"original"`
+	Translation        string                       `json:"translation"`
+	SourceLanguage     string                       `json:"source_language"`
+	LanguageConfidence float64                      `json:"language_confidence"`
+	LanguageCandidates []detector.LanguageCandidate `json:"language_candidates,omitempty"`
+	DetectorName       string                       `json:"detector_name"`
+	TranslatorName     string                       `json:"translator_name"`
+	CompletionTokens   int64                        `json:"completion_tokens"`
+	PromptTokens       int64                        `json:"prompt_tokens"`
+}
+
+// WebhookOut posts completed translations to an external HTTP endpoint.
+type WebhookOut struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+	mode    string
+}
+
+func newWebhookOut(conf WebhookOutConfig) *WebhookOut {
+	if !conf.Enabled {
+		return nil
+	}
+	return &WebhookOut{
+		client:  &http.Client{Timeout: time.Duration(conf.Timeout) * time.Second},
+		url:     conf.URL,
+		headers: conf.Headers,
+		mode:    conf.Mode,
+	}
+}
+
+// ReplaceReply reports whether the chat reply should be skipped.
+func (wo *WebhookOut) ReplaceReply() bool {
+	return wo.mode == webhookOutModeReplace
+}
+
+func (wo *WebhookOut) Post(ctx context.Context, payload WebhookOutPayload) (err error) {
+	err = postJSON(ctx, wo.client, wo.url, wo.headers, payload)
+	if err != nil {
+		err = fmt.Errorf("webhook_out: %w", err)
+	}
+	return
+}
+
+// postJSON posts payload as JSON to url, failing on non-2xx statuses.
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload any) (err error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return
+}
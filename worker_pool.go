@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	priorityHigh = "high"
+	priorityLow  = "low"
+
+	// Wait for a free queue slot
+	overflowPolicyBlock = "block"
+	// Drop the oldest queued message to make room
+	overflowPolicyDropOldest = "drop_oldest"
+	// Drop the incoming message
+	overflowPolicyDropNewest = "drop_newest"
+)
+
+const (
+	saturationQueueFull              = "queue_full"
+	saturationTranslatorsUnavailable = "translators_unavailable"
+	saturationMemoryPressure         = "memory_pressure"
+)
+
+type BackpressureConfig struct {
+	// Pause receiving messages while all translators are disabled
+	Enabled bool `yaml:"enabled"`
+
+	// Positive. Seconds between availability checks while paused
+	CheckInterval int `yaml:"check_interval"`
+}
+
+func (bc *BackpressureConfig) Check() (err error) {
+	if bc.Enabled && bc.CheckInterval <= 0 {
+		err = fmt.Errorf("backpressure check interval must be positive")
+	}
+	return
+}
+
+func checkOverflowPolicy(policy string) (err error) {
+	switch policy {
+	case overflowPolicyBlock, overflowPolicyDropOldest, overflowPolicyDropNewest:
+	default:
+		err = fmt.Errorf("unrecognized overflow policy: %s", policy)
+	}
+	return
+}
+
+// PriorityConfig maps messages to a queue priority, "high" or "low".
+// Chat IDs take precedence over chat types. Unmapped messages are low priority.
+type PriorityConfig struct {
+	ChatTypes map[string]string `yaml:"chat_types"`
+	Chats     map[int64]string  `yaml:"chats"`
+}
+
+func (pc *PriorityConfig) Check() (err error) {
+	for k, p := range pc.ChatTypes {
+		if p != priorityHigh && p != priorityLow {
+			err = fmt.Errorf("invalid priority of chat type '%s': %s", k, p)
+			return
+		}
+	}
+	for k, p := range pc.Chats {
+		if p != priorityHigh && p != priorityLow {
+			err = fmt.Errorf("invalid priority of chat '%d': %s", k, p)
+			return
+		}
+	}
+	return
+}
+
+// isHigh reports whether msg should be queued with high priority.
+func (pc *PriorityConfig) isHigh(msg *Message) bool {
+	if p, ok := pc.Chats[msg.ChatID]; ok {
+		return p == priorityHigh
+	}
+	return pc.ChatTypes[msg.ChatType] == priorityHigh
+}
+
+// workerPool runs a fixed number of pre-spawned workers consuming
+// messages from two buffered job queues. Workers always take
+// high priority messages first.
+type workerPool struct {
+	high chan *Message
+	low  chan *Message
+	size int
+}
+
+func newWorkerPool(size, queueSize int, handler func(*Message)) *workerPool {
+	wp := &workerPool{
+		high: make(chan *Message, queueSize),
+		low:  make(chan *Message, queueSize),
+		size: size,
+	}
+	for i := range size {
+		go wp.work(i, handler)
+	}
+	logrus.Infof("started worker pool, workers: %d, queue size: %d", size, queueSize)
+	return wp
+}
+
+// queue returns the job queue for a message priority.
+func (wp *workerPool) queue(high bool) chan *Message {
+	if high {
+		return wp.high
+	}
+	return wp.low
+}
+
+func (wp *workerPool) work(id int, handler func(*Message)) {
+	logger := logrus.WithField("worker_id", id)
+	logger.Trace("worker started")
+	defer logger.Trace("worker stopped")
+
+	high, low := wp.high, wp.low
+	for high != nil || low != nil {
+		var msg *Message
+		var ok bool
+
+		// Drain high priority queue first
+		select {
+		case msg, ok = <-high:
+			if !ok {
+				high = nil
+				continue
+			}
+		default:
+			select {
+			case msg, ok = <-high:
+				if !ok {
+					high = nil
+					continue
+				}
+			case msg, ok = <-low:
+				if !ok {
+					low = nil
+					continue
+				}
+			}
+		}
+
+		msg.onProcessing()
+		handler(msg)
+	}
+}
+
+// Close stops accepting jobs. Workers exit after draining queued jobs.
+// Must only be called by the goroutine submitting jobs.
+func (wp *workerPool) Close() {
+	close(wp.high)
+	close(wp.low)
+}